@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"github.com/co1seam/ember-backend-media/internal/adapters/repository"
+	"github.com/co1seam/ember-backend-media/internal/adapters/scanner"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/core/services"
+)
+
+const importUsage = "import -owner ID (-bucket NAME [-prefix P] | -dir PATH) | -resume JOB | -status JOB: bulk import files as media"
+
+func importCommand(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	owner := fs.String("owner", "", "owner of the imported media")
+	bucket := fs.String("bucket", "", "source bucket on the configured MinIO")
+	prefix := fs.String("prefix", "", "only import keys under this prefix of -bucket")
+	dir := fs.String("dir", "", "source directory")
+	resume := fs.String("resume", "", "resume the import job with this id")
+	status := fs.String("status", "", "print the import job with this id and its failures")
+	failures := fs.Int("failures", 100, "number of failures printed by -status")
+	parseArgs(fs, args)
+
+	return withRepository(ctx, a, func(ctx context.Context, repos *repository.Repository, opts *models.Options) error {
+		malwareScanner, err := scanner.New(&opts.Config.Scanner)
+		if err != nil {
+			return err
+		}
+		importer := services.NewImporter(repos.Imports, repos.Media, repos.Versions, repos.Audit, repos.Transactor, repos.MinIO, malwareScanner, opts)
+
+		if *status != "" {
+			job, err := importer.Job(ctx, *status)
+			if err != nil {
+				return err
+			}
+			items, err := importer.Failures(ctx, job.ID, *failures)
+			if err != nil {
+				return err
+			}
+			if items == nil {
+				items = []*models.ImportItem{}
+			}
+			printReport(map[string]any{"job": job, "failures": items})
+			return nil
+		}
+
+		var job *models.ImportJob
+		switch {
+		case *resume != "":
+			job, err = importer.Job(ctx, *resume)
+		case *bucket != "" && *dir != "":
+			return errors.New("import: -bucket and -dir are mutually exclusive")
+		case *bucket != "":
+			job, err = importer.Create(ctx, *owner, models.ImportSourceBucket, *bucket, *prefix)
+		case *dir != "":
+			job, err = importer.Create(ctx, *owner, models.ImportSourceDir, *dir, *prefix)
+		default:
+			return errors.New("import: one of -bucket, -dir, -resume or -status is required")
+		}
+		if err != nil {
+			return err
+		}
+
+		a.log.Info("import started", "job_id", job.ID, "source", job.Source, "location", job.Location, "cursor", job.Cursor)
+
+		err = importer.Run(ctx, job, func(job *models.ImportJob) {
+			a.log.Info("import progress", "job_id", job.ID, "state", job.State, "imported", job.Imported, "failed", job.Failed, "bytes", job.Bytes)
+		})
+		printReport(job)
+		if errors.Is(err, context.Canceled) {
+			a.log.Warn("import interrupted; resume with -resume", "job_id", job.ID)
+		}
+		return err
+	})
+}
@@ -2,68 +2,254 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"github.com/co1seam/ember-backend-media/config"
+	"github.com/co1seam/ember-backend-media/internal/adapters/events"
+	"github.com/co1seam/ember-backend-media/internal/adapters/health"
+	"github.com/co1seam/ember-backend-media/internal/adapters/logging"
+	"github.com/co1seam/ember-backend-media/internal/adapters/metrics"
+	"github.com/co1seam/ember-backend-media/internal/adapters/ratelimit"
 	"github.com/co1seam/ember-backend-media/internal/adapters/repository"
+	"github.com/co1seam/ember-backend-media/internal/adapters/rest"
 	"github.com/co1seam/ember-backend-media/internal/adapters/rpc"
+	"github.com/co1seam/ember-backend-media/internal/adapters/scanner"
+	"github.com/co1seam/ember-backend-media/internal/adapters/tracing"
+	"github.com/co1seam/ember-backend-media/internal/adapters/webhook"
+	"github.com/co1seam/ember-backend-media/internal/core/lifecycle"
 	"github.com/co1seam/ember-backend-media/internal/core/models"
 	"github.com/co1seam/ember-backend-media/internal/core/services"
+	"github.com/co1seam/ember-backend-media/internal/core/settings"
 	"github.com/gofiber/fiber/v2/log"
 	"log/slog"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"syscall"
 )
 
+// app is what every subcommand gets: the loaded configuration and a logger.
+type app struct {
+	store     *config.Store
+	cfgPath   string
+	overrides config.Overrides
+	log       *slog.Logger
+}
+
+type command struct {
+	usage string
+	run   func(ctx context.Context, app *app, args []string) error
+}
+
+var commands = map[string]command{
+	"serve":          {"run the gRPC server and HTTP gateway (default)", serve},
+	"migrate":        {migrateUsage, migrateCommand},
+	"reconcile":      {"reconcile [-dry-run] [-min-age 1h]: move objects without a media row to the trash, report rows without an object", reconcileCommand},
+	"backfill-sizes": {"backfill-sizes [-dry-run]: record object sizes missing from the media table", backfillSizesCommand},
+	"purge-trash":    {"purge-trash [-dry-run] [-older-than 168h]: delete trashed objects for good", purgeTrashCommand},
+	"prune-audit":    {"prune-audit [-dry-run] [-older-than 8760h]: delete audit events past retention", pruneAuditCommand},
+	"export":         {"export [-o file]: write all media rows as JSON lines", exportCommand},
+	"import":         {importUsage, importCommand},
+}
+
 func main() {
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	cfgFlag := flag.String("config", "", "flag to add config path")
+	cfgFlag := flag.String("config", ".env", "path to a .env, YAML or TOML config file")
+	overrides := config.Overrides{}
+	flag.Var(overrides, "set", "override a setting as KEY=VALUE, may be repeated")
+	flag.Usage = usage
 	flag.Parse()
 
-	cfg, err := config.New(cfgFlag)
+	name, args := "serve", flag.Args()
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.New(*cfgFlag, overrides)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	level := new(slog.LevelVar)
+	level.Set(logLevelChoice(cfg.App.LogLevel))
+
 	handlerOpts := &slog.HandlerOptions{
 		AddSource: true,
-		Level:     logLevelChoice(cfg.App.LogLevel),
+		Level:     level,
 	}
 
-	log := slog.New(slog.NewJSONHandler(os.Stdout, handlerOpts))
+	// Only the server logs to stdout; the other commands write results there.
+	output := os.Stderr
+	if name == "serve" {
+		output = os.Stdout
+	}
+
+	log := slog.New(logging.NewHandler(tracing.NewLogHandler(slog.NewJSONHandler(output, handlerOpts))))
 
 	slog.SetDefault(log)
 
+	log.Info("configuration loaded", "config", cfg)
+
+	store := config.NewStore(cfg)
+	store.Subscribe(func(cfg *config.Config) {
+		level.Set(logLevelChoice(cfg.App.LogLevel))
+	})
+
+	a := &app{store: store, cfgPath: *cfgFlag, overrides: overrides, log: log}
+	if err := cmd.run(ctx, a, args); err != nil {
+		log.Error(name+" failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s [-config file] [-set KEY=VALUE] [command]\n\ncommands:\n", filepath.Base(os.Args[0]))
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-15s %s\n", name, commands[name].usage)
+	}
+
+	fmt.Fprintln(os.Stderr, "\nflags:")
+	flag.PrintDefaults()
+}
+
+func serve(ctx context.Context, a *app, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("serve takes no arguments, got %q", args)
+	}
+
+	store, cfgPath, overrides, log := a.store, a.cfgPath, a.overrides, a.log
+	cfg := store.Load()
+	shutdownTimeout := cfg.App.ShutdownTimeout
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = models.DefaultShutdownTimeout
+	}
+	manager := lifecycle.NewManager(shutdownTimeout, log)
+
+	tracer, err := tracing.Setup(&cfg.Tracing, log)
+	if err != nil {
+		return fmt.Errorf("error initializing tracing: %w", err)
+	}
+	manager.Add("tracing", nil, tracer.Shutdown)
+
 	db, err := repository.NewPostgres(ctx, &cfg.Database)
 	if err != nil {
-		log.Error("error initializing PostgreSQL", err)
-		return
+		return errors.Join(fmt.Errorf("error initializing PostgreSQL: %w", err), manager.Close())
 	}
-	defer db.Close()
+	manager.Add("postgres", nil, func(context.Context) error { return db.Close() })
 
 	minioClient, err := repository.NewMinio(&cfg.MinIO)
 	if err != nil {
-		log.Error("error initializing MinIO", err)
-		return
+		return errors.Join(fmt.Errorf("error initializing MinIO: %w", err), manager.Close())
 	}
 
 	cache := repository.NewRedis(cfg.Redis.Host, cfg.Redis.Port)
+	manager.Add("redis", nil, func(context.Context) error { return cache.Close() })
+
+	malwareScanner, err := scanner.New(&cfg.Scanner)
+	if err != nil {
+		return errors.Join(fmt.Errorf("error initializing malware scanner: %w", err), manager.Close())
+	}
+
+	eventSink, err := events.New(&cfg.Events, cache.Redis)
+	if err != nil {
+		return errors.Join(fmt.Errorf("error initializing events sink: %w", err), manager.Close())
+	}
 
 	opts := &models.Options{
-		Logger: log,
-		Config: cfg,
+		Logger:   log,
+		Config:   cfg,
+		Settings: store,
 	}
 
 	repos := repository.NewRepository(db.DB, minioClient, cache, opts)
-	service := services.NewService(repos, opts)
-	handler := rpc.NewHandler(service, opts)
+	service := services.NewService(repos, malwareScanner, eventSink, webhook.NewSender(models.WebhookTimeout), opts)
+	metrics.RegisterDBStats(db.DB)
+	metrics.RegisterRedisStats(cache.Redis)
+	metrics.RegisterQueueDepth("jobs", service.Jobs.Len)
 
-	server := rpc.NewServer()
-	if err := server.Run(handler); err != nil {
-		log.Error("server run error", err)
+	limiter, err := ratelimit.New(&cfg.RateLimit, cache.Redis, log)
+	if err != nil {
+		return errors.Join(fmt.Errorf("error initializing rate limiter: %w", err), manager.Close())
 	}
 
-	if err := db.Close(); err != nil {
-		log.Error("error closing DB", err)
+	store.Subscribe(func(cfg *config.Config) {
+		policy, err := ratelimit.PolicyFromConfig(&cfg.RateLimit)
+		if err != nil {
+			log.Error("rate limit policy not reloaded", "error", err)
+			return
+		}
+		limiter.SetPolicy(policy)
+	})
+
+	watcher := settings.NewWatcher(store, repos.Settings, cfgPath, overrides, opts)
+	reloadOnHangup(watcher)
+
+	checker := health.NewChecker(opts)
+	checker.Register("postgres", db)
+	checker.Register("minio", minioClient)
+	checker.Register("redis", cache)
+
+	manager.Add("settings", starter(watcher.Start), stopper(watcher.Stop))
+	manager.Add("health", starter(checker.Start), stopper(checker.Stop))
+	manager.Add("jobs", starter(service.Jobs.Start), stopper(service.Jobs.Stop))
+	manager.Add("privacy", starter(service.Privacy.Resume), nil)
+	manager.Add("outbox relay", starter(service.Relay.Start), stopper(service.Relay.Stop))
+	manager.Add("webhooks", starter(service.Webhooks.Start), stopper(service.Webhooks.Stop))
+	manager.Add("rescans", starter(service.Rescans.Start), stopper(service.Rescans.Stop))
+
+	gateway := rest.NewServer(opts)
+	gatewayHandler := rest.NewHandler(service, checker, limiter, opts)
+	manager.Go("http gateway", func(context.Context) error { return gateway.Run(gatewayHandler) }, gateway.Shutdown)
+
+	server, err := rpc.NewServer(rpc.NewHandler(service, checker, limiter, opts), opts)
+	if err != nil {
+		return errors.Join(fmt.Errorf("error initializing gRPC server: %w", err), manager.Close())
+	}
+	manager.Go("grpc server", func(context.Context) error { return server.Run() }, server.Shutdown)
+
+	return manager.Run(ctx)
+}
+
+// reloadOnHangup forces a settings reload on every SIGHUP.
+func reloadOnHangup(watcher *settings.Watcher) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	go func() {
+		for range hangup {
+			watcher.Request()
+		}
+	}()
+}
+
+func starter(start func(ctx context.Context)) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		start(ctx)
+		return nil
+	}
+}
+
+func stopper(stop func()) func(ctx context.Context) error {
+	return func(context.Context) error {
+		stop()
+		return nil
 	}
 }
 
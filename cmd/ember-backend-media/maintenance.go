@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/co1seam/ember-backend-media/internal/adapters/repository"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/core/services"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func reconcileCommand(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "report without moving objects")
+	minAge := fs.Duration("min-age", models.ReconcileGrace, "leave objects younger than this alone")
+	parseArgs(fs, args)
+
+	return withMaintenance(ctx, a, func(ctx context.Context, m *services.Maintenance) error {
+		report, err := m.Reconcile(ctx, *minAge, *dryRun)
+		if report != nil {
+			printReport(report)
+		}
+		return err
+	})
+}
+
+func backfillSizesCommand(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("backfill-sizes", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "report without updating rows")
+	parseArgs(fs, args)
+
+	return withMaintenance(ctx, a, func(ctx context.Context, m *services.Maintenance) error {
+		report, err := m.BackfillSizes(ctx, *dryRun)
+		printReport(report)
+		return err
+	})
+}
+
+func purgeTrashCommand(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("purge-trash", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "report without deleting objects")
+	olderThan := fs.Duration("older-than", models.TrashRetention, "only purge objects trashed at least this long ago")
+	parseArgs(fs, args)
+
+	return withMaintenance(ctx, a, func(ctx context.Context, m *services.Maintenance) error {
+		report, err := m.PurgeTrash(ctx, *olderThan, *dryRun)
+		printReport(report)
+		return err
+	})
+}
+
+func pruneAuditCommand(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("prune-audit", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "report without deleting events")
+	olderThan := fs.Duration("older-than", models.AuditRetention, "only delete events at least this old")
+	parseArgs(fs, args)
+
+	return withRepository(ctx, a, func(ctx context.Context, repos *repository.Repository, opts *models.Options) error {
+		count, err := services.NewAudit(repos.Audit, opts).PruneAuditEvents(ctx, *olderThan, *dryRun)
+		if err != nil {
+			return err
+		}
+
+		if *dryRun {
+			a.log.Info("audit events to prune", "count", count, "older_than", olderThan.String())
+			return nil
+		}
+		a.log.Info("audit events pruned", "deleted", count, "older_than", olderThan.String())
+		return nil
+	})
+}
+
+func exportCommand(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	output := fs.String("o", "-", "output file, - for stdout")
+	parseArgs(fs, args)
+
+	return withMaintenance(ctx, a, func(ctx context.Context, m *services.Maintenance) error {
+		var w io.Writer = os.Stdout
+		if *output != "-" {
+			file, err := os.Create(*output)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			w = file
+		}
+
+		count, err := m.Export(ctx, w)
+		if err != nil {
+			return err
+		}
+
+		a.log.Info("media exported", "count", count, "output", *output)
+		return nil
+	})
+}
+
+func withMaintenance(ctx context.Context, a *app, fn func(ctx context.Context, m *services.Maintenance) error) error {
+	return withRepository(ctx, a, func(ctx context.Context, repos *repository.Repository, opts *models.Options) error {
+		return fn(ctx, services.NewMaintenance(repos.Media, repos.Versions, repos.MinIO, opts))
+	})
+}
+
+// withRepository connects to Postgres and MinIO without migrating, so an
+// admin command never changes the schema behind the server's back.
+func withRepository(ctx context.Context, a *app, fn func(ctx context.Context, repos *repository.Repository, opts *models.Options) error) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg := a.store.Load()
+
+	db, err := repository.OpenPostgres(ctx, &cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	minioClient, err := repository.NewMinio(&cfg.MinIO)
+	if err != nil {
+		return fmt.Errorf("error initializing MinIO: %w", err)
+	}
+
+	opts := &models.Options{
+		Logger:   a.log,
+		Config:   cfg,
+		Settings: a.store,
+	}
+
+	return fn(ctx, repository.NewRepository(db.DB, minioClient, nil, opts), opts)
+}
+
+func printReport(report any) {
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return
+	}
+	fmt.Println(string(out))
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"github.com/co1seam/ember-backend-media/internal/adapters/repository"
+	"strconv"
+)
+
+const migrateUsage = "migrate [-dry-run] up | down [N] | down -all | goto V | force V | version"
+
+func migrateCommand(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "print the current version and the planned change only")
+	all := fs.Bool("all", false, "with down, revert every migration")
+	args = parseArgs(fs, args)
+	if len(args) == 0 {
+		return fmt.Errorf("usage: %s", migrateUsage)
+	}
+
+	cfg := &a.store.Load().Database
+	migrator := repository.NewMigrator()
+
+	// Each migrator call closes the handle it is given.
+	open := func() (*sql.DB, error) {
+		return repository.OpenDB(ctx, cfg)
+	}
+
+	version := func() error {
+		db, err := open()
+		if err != nil {
+			return err
+		}
+
+		v, dirty, err := migrator.Version(db)
+		if err != nil {
+			return err
+		}
+
+		state := ""
+		if dirty {
+			state = " (dirty)"
+		}
+		fmt.Printf("schema version %d%s\n", v, state)
+		return nil
+	}
+
+	if err := version(); err != nil {
+		return err
+	}
+
+	var plan string
+	var apply func(db *sql.DB) error
+
+	switch action := args[0]; action {
+	case "version":
+		return nil
+	case "up":
+		plan, apply = "apply all pending migrations", migrator.Up
+	case "down":
+		if *all {
+			plan, apply = "revert all migrations", migrator.Down
+			break
+		}
+
+		n := 1
+		if len(args) > 1 {
+			var err error
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				return fmt.Errorf("down takes a positive number of steps, got %q", args[1])
+			}
+		}
+		plan = fmt.Sprintf("revert %d migration(s)", n)
+		apply = func(db *sql.DB) error { return migrator.Steps(db, -n) }
+	case "goto":
+		if len(args) < 2 {
+			return fmt.Errorf("goto needs a version")
+		}
+		v, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		plan = fmt.Sprintf("migrate to version %d", v)
+		apply = func(db *sql.DB) error { return migrator.Goto(db, uint(v)) }
+	case "force":
+		if len(args) < 2 {
+			return fmt.Errorf("force needs a version")
+		}
+		v, err := strconv.Atoi(args[1])
+		if err != nil || v < -1 {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		plan = fmt.Sprintf("record version %d as clean without running migrations", v)
+		apply = func(db *sql.DB) error { return migrator.Force(db, v) }
+	default:
+		return fmt.Errorf("unknown migrate action %q", action)
+	}
+
+	if *dryRun {
+		fmt.Printf("dry run: would %s\n", plan)
+		return nil
+	}
+
+	db, err := open()
+	if err != nil {
+		return err
+	}
+	if err := apply(db); err != nil {
+		return err
+	}
+
+	return version()
+}
+
+// parseArgs lets flags follow positional arguments, as in "migrate down 2 -dry-run".
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
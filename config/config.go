@@ -1,35 +1,159 @@
 package config
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
 type App struct {
 	Host     string `mapstructure:"APP_HOST"`
-	Port     string `mapstructure:"APP_PORT"`
-	LogLevel string `mapstructure:"APP_LOG_LEVEL"`
+	Port     string `mapstructure:"APP_PORT" default:"50052"`
+	HTTPPort string `mapstructure:"APP_HTTP_PORT" default:"8080"`
+	LogLevel string `mapstructure:"APP_LOG_LEVEL" default:"debug" reload:"true"`
+
+	ShutdownTimeout time.Duration `mapstructure:"APP_SHUTDOWN_TIMEOUT" default:"30s"`
+
+	// HTTPProxyHeader names the header carrying the client address behind a
+	// reverse proxy, such as X-Forwarded-For. It is only believed on requests
+	// from HTTPTrustedProxies, a comma-separated list of addresses and CIDRs.
+	HTTPProxyHeader    string `mapstructure:"APP_HTTP_PROXY_HEADER"`
+	HTTPTrustedProxies string `mapstructure:"APP_HTTP_TRUSTED_PROXIES"`
+}
+
+type GRPC struct {
+	TLSCertFile          string        `mapstructure:"GRPC_TLS_CERT_FILE"`
+	TLSKeyFile           string        `mapstructure:"GRPC_TLS_KEY_FILE"`
+	ClientCAFile         string        `mapstructure:"GRPC_TLS_CLIENT_CA_FILE"`
+	ClientAuth           string        `mapstructure:"GRPC_TLS_CLIENT_AUTH"`
+	MaxRecvMsgSize       int           `mapstructure:"GRPC_MAX_RECV_MSG_SIZE"`
+	MaxSendMsgSize       int           `mapstructure:"GRPC_MAX_SEND_MSG_SIZE"`
+	MaxConcurrentStreams uint32        `mapstructure:"GRPC_MAX_CONCURRENT_STREAMS"`
+	KeepaliveTime        time.Duration `mapstructure:"GRPC_KEEPALIVE_TIME"`
+	KeepaliveTimeout     time.Duration `mapstructure:"GRPC_KEEPALIVE_TIMEOUT"`
+	KeepaliveMinTime     time.Duration `mapstructure:"GRPC_KEEPALIVE_MIN_TIME"`
+	MaxConnectionIdle    time.Duration `mapstructure:"GRPC_MAX_CONNECTION_IDLE"`
+	MaxConnectionAge     time.Duration `mapstructure:"GRPC_MAX_CONNECTION_AGE"`
 }
 
 type Database struct {
-	Host string `mapstructure:"POSTGRES_HOST"`
-	Port string `mapstructure:"POSTGRES_PORT"`
-	User string `mapstructure:"POSTGRES_USER"`
-	Pass string `mapstructure:"POSTGRES_PASS"`
-	Name string `mapstructure:"POSTGRES_NAME"`
+	Host string `mapstructure:"POSTGRES_HOST" required:"true"`
+	Port string `mapstructure:"POSTGRES_PORT" default:"5432"`
+	User string `mapstructure:"POSTGRES_USER" required:"true"`
+	Pass string `mapstructure:"POSTGRES_PASS" secret:"true"`
+	Name string `mapstructure:"POSTGRES_NAME" required:"true"`
 }
 
 type MinIO struct {
-	Endpoint  string `mapstructure:"MINIO_ENDPOINT"`
-	AccessKey string `mapstructure:"MINIO_ACCESS_KEY"`
-	SecretKey string `mapstructure:"MINIO_SECRET_KEY"`
-	Bucket    string `mapstructure:"MINIO_BUCKET"`
-	UseSSL    bool   `mapstructure:"MINIO_USE_SSL"`
+	Endpoint         string `mapstructure:"MINIO_ENDPOINT" required:"true"`
+	AccessKey        string `mapstructure:"MINIO_ACCESS_KEY" required:"true"`
+	SecretKey        string `mapstructure:"MINIO_SECRET_KEY" required:"true" secret:"true"`
+	Bucket           string `mapstructure:"MINIO_BUCKET" required:"true"`
+	UseSSL           bool   `mapstructure:"MINIO_USE_SSL"`
+	QuarantineBucket string `mapstructure:"MINIO_QUARANTINE_BUCKET"`
+	QuarantinePrefix string `mapstructure:"MINIO_QUARANTINE_PREFIX"`
 }
 
 type Redis struct {
-	Host string `mapstructure:"REDIS_HOST"`
-	Port string `mapstructure:"REDIS_PORT"`
+	Host string `mapstructure:"REDIS_HOST" required:"true"`
+	Port string `mapstructure:"REDIS_PORT" default:"6379"`
+}
+
+// Scanner points at clamd. clamd refuses streams over its StreamMaxLength,
+// 25 MiB by default, while MEDIA_MAX_FILE_SIZE defaults to 100 MiB: raise
+// StreamMaxLength to at least MEDIA_MAX_FILE_SIZE, or larger uploads end up
+// in the scan_failed state and are never served.
+type Scanner struct {
+	Address string        `mapstructure:"CLAMAV_ADDRESS"`
+	Timeout time.Duration `mapstructure:"CLAMAV_TIMEOUT" default:"5m"`
+}
+
+type Events struct {
+	Sink         string        `mapstructure:"EVENTS_SINK" default:"redis"`
+	RedisStream  string        `mapstructure:"EVENTS_REDIS_STREAM" default:"media.events"`
+	WebhookURL   string        `mapstructure:"EVENTS_WEBHOOK_URL"`
+	PollInterval time.Duration `mapstructure:"EVENTS_POLL_INTERVAL" default:"2s"`
+	BatchSize    int           `mapstructure:"EVENTS_BATCH_SIZE" default:"100"`
+}
+
+type RateLimit struct {
+	Backend             string  `mapstructure:"RATE_LIMIT_BACKEND" default:"memory"`
+	Rate                float64 `mapstructure:"RATE_LIMIT_RPS" reload:"true"`
+	Burst               int     `mapstructure:"RATE_LIMIT_BURST" reload:"true"`
+	Methods             string  `mapstructure:"RATE_LIMIT_METHODS" reload:"true"`
+	MaxUploads          int     `mapstructure:"RATE_LIMIT_MAX_UPLOADS" reload:"true"`
+	MaxDownloads        int     `mapstructure:"RATE_LIMIT_MAX_DOWNLOADS" reload:"true"`
+	DownloadBytesPerSec int64   `mapstructure:"RATE_LIMIT_DOWNLOAD_BYTES_PER_SEC" reload:"true"`
+}
+
+type Media struct {
+	URLExpiry   time.Duration `mapstructure:"MEDIA_URL_EXPIRY" default:"24h" reload:"true"`
+	MaxFileSize int64         `mapstructure:"MEDIA_MAX_FILE_SIZE" default:"104857600" reload:"true"`
+	MaxVersions int           `mapstructure:"MEDIA_MAX_VERSIONS" default:"10" reload:"true"`
+}
+
+type Settings struct {
+	Source         string        `mapstructure:"SETTINGS_SOURCE" default:"file"`
+	ReloadInterval time.Duration `mapstructure:"SETTINGS_RELOAD_INTERVAL" default:"30s"`
+}
+
+type Tracing struct {
+	Exporter    string `mapstructure:"OTEL_TRACES_EXPORTER" default:"none"`
+	Endpoint    string `mapstructure:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	Headers     string `mapstructure:"OTEL_EXPORTER_OTLP_HEADERS" secret:"true"`
+	ServiceName string `mapstructure:"OTEL_SERVICE_NAME" default:"ember-backend-media"`
+	SamplerArg  string `mapstructure:"OTEL_TRACES_SAMPLER_ARG"`
+}
+
+// Admin lists the bearer tokens allowed on the REST admin API as
+// comma-separated subject=token pairs. With none configured the admin API
+// refuses every request.
+type Admin struct {
+	Tokens string `mapstructure:"ADMIN_TOKENS" secret:"true"`
 }
 
 type Config struct {
-	App      App      `mapstructure:",squash"`
-	Database Database `mapstructure:",squash"`
-	MinIO    MinIO    `mapstructure:",squash"`
-	Redis    Redis    `mapstructure:",squash"`
+	App       App       `mapstructure:",squash"`
+	GRPC      GRPC      `mapstructure:",squash"`
+	Database  Database  `mapstructure:",squash"`
+	MinIO     MinIO     `mapstructure:",squash"`
+	Redis     Redis     `mapstructure:",squash"`
+	Scanner   Scanner   `mapstructure:",squash"`
+	Events    Events    `mapstructure:",squash"`
+	Tracing   Tracing   `mapstructure:",squash"`
+	RateLimit RateLimit `mapstructure:",squash"`
+	Media     Media     `mapstructure:",squash"`
+	Settings  Settings  `mapstructure:",squash"`
+	Admin     Admin     `mapstructure:",squash"`
+}
+
+// TrustedProxies returns the entries of APP_HTTP_TRUSTED_PROXIES.
+func (a *App) TrustedProxies() []string {
+	var proxies []string
+	for _, entry := range strings.Split(a.HTTPTrustedProxies, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			proxies = append(proxies, entry)
+		}
+	}
+	return proxies
+}
+
+// ParseTokens returns the configured admin subjects keyed by token.
+func (a *Admin) ParseTokens() (map[string]string, error) {
+	tokens := make(map[string]string)
+	for _, pair := range strings.Split(a.Tokens, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		subject, token, ok := strings.Cut(pair, "=")
+		subject, token = strings.TrimSpace(subject), strings.TrimSpace(token)
+		if !ok || subject == "" || token == "" {
+			return nil, fmt.Errorf("ADMIN_TOKENS entries must be subject=token")
+		}
+		if _, dup := tokens[token]; dup {
+			return nil, fmt.Errorf("ADMIN_TOKENS contains the same token twice")
+		}
+		tokens[token] = subject
+	}
+	return tokens, nil
 }
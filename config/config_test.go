@@ -0,0 +1,51 @@
+package config
+
+import "testing"
+
+func TestAdminParseTokens(t *testing.T) {
+	admin := Admin{Tokens: " ops = t0k3n ,dpo=other,"}
+	tokens, err := admin.ParseTokens()
+	if err != nil {
+		t.Fatalf("ParseTokens() error = %v", err)
+	}
+	if len(tokens) != 2 || tokens["t0k3n"] != "ops" || tokens["other"] != "dpo" {
+		t.Errorf("ParseTokens() = %v", tokens)
+	}
+
+	for _, value := range []string{"ops", "=t0k3n", "ops=", "a=same,b=same"} {
+		if _, err := (&Admin{Tokens: value}).ParseTokens(); err == nil {
+			t.Errorf("ParseTokens(%q) error = nil, want an error", value)
+		}
+	}
+}
+
+func TestLoadRejectsMalformedAdminTokens(t *testing.T) {
+	setRequired(t)
+	t.Setenv("ADMIN_TOKENS", "ops")
+
+	problems := loadProblems(t)
+	if !containsProblem(problems, "ADMIN_TOKENS entries must be subject=token") {
+		t.Errorf("problems = %q, want the malformed ADMIN_TOKENS", problems)
+	}
+}
+
+func TestLoadChecksTrustedProxies(t *testing.T) {
+	setRequired(t)
+	t.Setenv("APP_HTTP_PROXY_HEADER", "X-Forwarded-For")
+
+	problems := loadProblems(t)
+	if !containsProblem(problems, "APP_HTTP_PROXY_HEADER requires APP_HTTP_TRUSTED_PROXIES") {
+		t.Errorf("problems = %q, want the proxy header without proxies", problems)
+	}
+
+	t.Setenv("APP_HTTP_TRUSTED_PROXIES", "10.0.0.0/8, 192.168.1.10,proxy.local")
+	problems = loadProblems(t)
+	if len(problems) != 1 || !containsProblem(problems, `got "proxy.local"`) {
+		t.Errorf("problems = %q, want only the host name rejected", problems)
+	}
+
+	app := App{HTTPTrustedProxies: "10.0.0.0/8, 192.168.1.10,"}
+	if got := app.TrustedProxies(); len(got) != 2 || got[0] != "10.0.0.0/8" || got[1] != "192.168.1.10" {
+		t.Errorf("TrustedProxies() = %q", got)
+	}
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// readFile loads a YAML or TOML file into flat keys. Nested sections are
+// joined with underscores and upper-cased, so `minio: {bucket: media}` and
+// `MINIO_BUCKET: media` both set MINIO_BUCKET. Lists of scalars become
+// comma-separated values; lists of tables have no flat form and are
+// rejected.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".toml":
+		err = toml.Unmarshal(data, &doc)
+	default:
+		return nil, fmt.Errorf("%s: unsupported config file format", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	if err := flatten(values, nil, doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return values, nil
+}
+
+func flatten(values map[string]string, path []string, node map[string]any) error {
+	keys := make([]string, 0, len(node))
+	for key := range node {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		keyPath := append(append([]string{}, path...), key)
+		switch value := node[key].(type) {
+		case map[string]any:
+			if err := flatten(values, keyPath, value); err != nil {
+				return err
+			}
+		case []any:
+			items := make([]string, 0, len(value))
+			for _, item := range value {
+				text, err := scalar(item)
+				if err != nil {
+					return fmt.Errorf("%s: %w", flatKey(keyPath), err)
+				}
+				items = append(items, text)
+			}
+			values[flatKey(keyPath)] = strings.Join(items, ",")
+		case []map[string]any:
+			return fmt.Errorf("%s: lists of tables are not supported", flatKey(keyPath))
+		default:
+			text, err := scalar(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", flatKey(keyPath), err)
+			}
+			values[flatKey(keyPath)] = text
+		}
+	}
+
+	return nil
+}
+
+func scalar(value any) (string, error) {
+	switch value := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case int:
+		return strconv.Itoa(value), nil
+	case int64:
+		return strconv.FormatInt(value, 10), nil
+	case uint64:
+		return strconv.FormatUint(value, 10), nil
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	case time.Time:
+		return value.Format(time.RFC3339Nano), nil
+	case map[string]any, []any, []map[string]any:
+		return "", fmt.Errorf("nested values are not supported here")
+	default:
+		return fmt.Sprint(value), nil
+	}
+}
+
+func flatKey(path []string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.Join(path, "_"), "-", "_"))
+}
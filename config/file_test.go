@@ -0,0 +1,136 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadFileYAML(t *testing.T) {
+	path := writeConfig(t, "config.yaml", `
+defaults: &defaults
+  port: "5432"
+postgres:
+  <<: *defaults
+  host: db
+minio:
+  bucket: media
+  use-ssl: true
+rate_limit:
+  methods:
+    - Upload
+    - Download
+media:
+  url_expiry: 1h
+events:
+  webhook_url: >-
+    https://example.com/hook
+`)
+
+	values, err := readFile(path)
+	if err != nil {
+		t.Fatalf("readFile() error = %v", err)
+	}
+
+	want := map[string]string{
+		"DEFAULTS_PORT":      "5432",
+		"POSTGRES_PORT":      "5432",
+		"POSTGRES_HOST":      "db",
+		"MINIO_BUCKET":       "media",
+		"MINIO_USE_SSL":      "true",
+		"RATE_LIMIT_METHODS": "Upload,Download",
+		"MEDIA_URL_EXPIRY":   "1h",
+		"EVENTS_WEBHOOK_URL": "https://example.com/hook",
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("readFile() = %v, want %v", values, want)
+	}
+}
+
+func TestReadFileTOML(t *testing.T) {
+	path := writeConfig(t, "config.toml", `
+APP_PORT = "50052"
+
+[minio]
+bucket = "media"
+endpoint = { host = "ignored" }.host
+
+[rate_limit]
+methods = ["Upload", "Download"]
+rps = 2.5
+burst = 10
+`)
+	if _, err := readFile(path); err == nil {
+		t.Fatal("readFile() accepted invalid TOML")
+	}
+
+	path = writeConfig(t, "config.toml", `
+APP_PORT = "50052"
+clamav = { timeout = "1m" }
+
+[minio]
+bucket = "media"
+quarantine_prefix = '''
+quarantine/'''
+
+[rate_limit]
+methods = ["Upload", "Download"]
+rps = 2.5
+burst = 1_000
+`)
+
+	values, err := readFile(path)
+	if err != nil {
+		t.Fatalf("readFile() error = %v", err)
+	}
+
+	want := map[string]string{
+		"APP_PORT":                "50052",
+		"CLAMAV_TIMEOUT":          "1m",
+		"MINIO_BUCKET":            "media",
+		"MINIO_QUARANTINE_PREFIX": "quarantine/",
+		"RATE_LIMIT_METHODS":      "Upload,Download",
+		"RATE_LIMIT_RPS":          "2.5",
+		"RATE_LIMIT_BURST":        "1000",
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("readFile() = %v, want %v", values, want)
+	}
+}
+
+func TestReadFileRejectsListsOfTables(t *testing.T) {
+	for name, content := range map[string]string{
+		"config.toml": "[[minio]]\nbucket = \"a\"\n",
+		"config.yaml": "minio:\n  - bucket: a\n",
+	} {
+		_, err := readFile(writeConfig(t, name, content))
+		if err == nil || !strings.Contains(err.Error(), "MINIO") {
+			t.Errorf("%s: readFile() error = %v, want an error naming MINIO", name, err)
+		}
+	}
+}
+
+func TestLoadReportsProblemsInOrder(t *testing.T) {
+	setRequired(t)
+	t.Setenv("APP_PORT", "x")
+	t.Setenv("REDIS_PORT", "y")
+	t.Setenv("POSTGRES_PORT", "z")
+
+	first := loadProblems(t)
+	for i := 0; i < 10; i++ {
+		if got := loadProblems(t); !reflect.DeepEqual(got, first) {
+			t.Fatalf("problems changed between runs: %q then %q", first, got)
+		}
+	}
+}
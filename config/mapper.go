@@ -6,36 +6,161 @@ import (
 	"github.com/mitchellh/mapstructure"
 	"log"
 	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
 )
 
-func New(path *string) (*Config, error) {
-	if path != nil {
-		err := godotenv.Load(*path)
-		if err != nil {
-			if os.IsNotExist(err) {
-				log.Printf("Notice: .env file not found at %s", path)
-			} else {
-				return nil, fmt.Errorf("error loading .env file: %v", err)
-			}
+// Overrides collects -set KEY=VALUE command line flags.
+type Overrides map[string]string
+
+func (o Overrides) String() string {
+	pairs := make([]string, 0, len(o))
+	for key, value := range o {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (o Overrides) Set(pair string) error {
+	key, value, ok := strings.Cut(pair, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected KEY=VALUE, got %q", pair)
+	}
+	o[strings.ToUpper(key)] = value
+	return nil
+}
+
+// Layer is a named set of settings applied over the process environment.
+type Layer struct {
+	Name   string
+	Values map[string]string
+}
+
+// New builds the configuration from, in increasing order of precedence:
+// declared defaults, the file at path (YAML, TOML or .env), the process
+// environment and overrides. In every layer KEY_FILE reads KEY from a file,
+// which is how secrets mounted by the orchestrator are passed in.
+func New(path string, overrides Overrides) (*Config, error) {
+	return Load(path, Layer{Name: "flags", Values: overrides})
+}
+
+// Load is New with arbitrary layers after the environment, later ones winning.
+func Load(path string, layers ...Layer) (*Config, error) {
+	specs := fieldSpecs()
+	problems := &ValidationError{}
+
+	values := make(map[string]string)
+	for _, spec := range specs {
+		if spec.def != "" {
+			values[spec.key] = spec.def
 		}
 	}
 
-	envVars := make(map[string]interface{})
-	for _, env := range os.Environ() {
-		pair := strings.SplitN(env, "=", 2)
-		if len(pair) != 2 {
-			continue
+	if path != "" {
+		layer, err := readLayer(path)
+		switch {
+		case os.IsNotExist(err):
+			log.Printf("Notice: config file not found at %s", path)
+		case err != nil:
+			return nil, err
+		default:
+			merge(values, layer, specs, problems, path, true)
+		}
+	}
+
+	env := make(map[string]string)
+	for _, pair := range os.Environ() {
+		if key, value, ok := strings.Cut(pair, "="); ok {
+			env[key] = value
 		}
-		key := pair[0]
-		value := pair[1]
-		envVars[key] = value
+	}
+	merge(values, env, specs, problems, "environment", false)
+
+	for _, layer := range layers {
+		merge(values, layer.Values, specs, problems, layer.Name, true)
+	}
+
+	input := make(map[string]any, len(values))
+	for key, value := range values {
+		input[key] = value
 	}
 
 	var cfg Config
+	if err := decode(input, &cfg); err != nil {
+		if decodeErr, ok := err.(*mapstructure.Error); ok {
+			problems.Problems = append(problems.Problems, decodeErr.Errors...)
+		} else {
+			problems.Problems = append(problems.Problems, err.Error())
+		}
+	}
+
+	cfg.validate(problems)
+	if len(problems.Problems) > 0 {
+		sort.Strings(problems.Problems)
+		return nil, problems
+	}
+
+	return &cfg, nil
+}
+
+func readLayer(path string) (map[string]string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".toml":
+		return readFile(path)
+	default:
+		values, err := godotenv.Read(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("error loading .env file: %v", err)
+		}
+		return values, err
+	}
+}
+
+// merge copies the known keys of layer over values. Unknown keys are only
+// reported when strict is set; the environment is full of unrelated variables.
+func merge(values, layer map[string]string, specs map[string]fieldSpec, problems *ValidationError, source string, strict bool) {
+	keys := make([]string, 0, len(layer))
+	for key := range layer {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := layer[key]
+
+		if target, ok := strings.CutSuffix(key, "_FILE"); ok {
+			if _, known := specs[target]; known {
+				if _, direct := layer[target]; direct {
+					problems.add("%s: both %s and %s are set", source, target, key)
+					continue
+				}
+
+				content, err := os.ReadFile(value)
+				if err != nil {
+					problems.add("%s: %s: %v", source, key, err)
+					continue
+				}
+				values[target] = strings.TrimRight(string(content), "\r\n")
+				continue
+			}
+		}
+
+		if _, known := specs[key]; !known {
+			if strict {
+				problems.add("%s: unknown setting %s", source, key)
+			}
+			continue
+		}
+		values[key] = value
+	}
+}
+
+func decode(input map[string]any, cfg *Config) error {
 	decoderConfig := &mapstructure.DecoderConfig{
-		Result:           &cfg,
+		Result:           cfg,
 		WeaklyTypedInput: true,
 		ErrorUnused:      false,
 		TagName:          "mapstructure",
@@ -49,7 +174,7 @@ func New(path *string) (*Config, error) {
 				switch strings.ToLower(data.(string)) {
 				case "true", "1", "yes":
 					return true, nil
-				case "false", "0", "no":
+				case "false", "0", "no", "":
 					return false, nil
 				default:
 					return nil, fmt.Errorf("invalid boolean value: %s", data)
@@ -60,12 +185,44 @@ func New(path *string) (*Config, error) {
 
 	decoder, err := mapstructure.NewDecoder(decoderConfig)
 	if err != nil {
-		return nil, fmt.Errorf("decoder creation failed: %v", err)
+		return fmt.Errorf("decoder creation failed: %v", err)
 	}
 
-	if err := decoder.Decode(envVars); err != nil {
-		return nil, fmt.Errorf("decoding failed: %v", err)
-	}
+	return decoder.Decode(input)
+}
 
-	return &cfg, nil
+type fieldSpec struct {
+	key      string
+	def      string
+	required bool
+	secret   bool
+	reload   bool
+}
+
+func fieldSpecs() map[string]fieldSpec {
+	specs := make(map[string]fieldSpec)
+	walk(reflect.ValueOf(&Config{}).Elem(), func(field reflect.StructField, _ reflect.Value) {
+		spec := fieldSpec{
+			key:      field.Tag.Get("mapstructure"),
+			def:      field.Tag.Get("default"),
+			required: field.Tag.Get("required") == "true",
+			secret:   field.Tag.Get("secret") == "true",
+			reload:   field.Tag.Get("reload") == "true",
+		}
+		specs[spec.key] = spec
+	})
+	return specs
+}
+
+// walk calls fn for every leaf setting of a squashed config struct.
+func walk(v reflect.Value, fn func(field reflect.StructField, value reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Type.Kind() == reflect.Struct && strings.Contains(field.Tag.Get("mapstructure"), "squash") {
+			walk(v.Field(i), fn)
+			continue
+		}
+		fn(field, v.Field(i))
+	}
 }
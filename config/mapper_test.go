@@ -0,0 +1,99 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setRequired sets the settings Load insists on, so that a test only sees
+// the problems it provokes.
+func setRequired(t *testing.T) {
+	t.Helper()
+	for key, value := range map[string]string{
+		"POSTGRES_HOST":    "localhost",
+		"POSTGRES_USER":    "ember",
+		"POSTGRES_NAME":    "ember",
+		"MINIO_ENDPOINT":   "localhost:9000",
+		"MINIO_ACCESS_KEY": "access",
+		"MINIO_SECRET_KEY": "secret",
+		"MINIO_BUCKET":     "media",
+		"REDIS_HOST":       "localhost",
+	} {
+		t.Setenv(key, value)
+	}
+}
+
+func loadProblems(t *testing.T, layers ...Layer) []string {
+	t.Helper()
+	_, err := Load("", layers...)
+	var problems *ValidationError
+	if !errors.As(err, &problems) {
+		t.Fatalf("Load() error = %v, want a ValidationError", err)
+	}
+	return problems.Problems
+}
+
+func containsProblem(problems []string, substr string) bool {
+	for _, problem := range problems {
+		if strings.Contains(problem, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLoadEnvFileSecret(t *testing.T) {
+	setRequired(t)
+	os.Unsetenv("POSTGRES_PASS")
+
+	path := filepath.Join(t.TempDir(), "pass")
+	if err := os.WriteFile(path, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("POSTGRES_PASS_FILE", path)
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Database.Pass != "s3cret" {
+		t.Errorf("Database.Pass = %q, want %q", cfg.Database.Pass, "s3cret")
+	}
+}
+
+func TestLoadEnvBothKeyAndFile(t *testing.T) {
+	setRequired(t)
+	t.Setenv("MINIO_SECRET_KEY_FILE", filepath.Join(t.TempDir(), "secret"))
+
+	problems := loadProblems(t)
+	if !containsProblem(problems, "environment: both MINIO_SECRET_KEY and MINIO_SECRET_KEY_FILE are set") {
+		t.Errorf("problems = %q, want the KEY/KEY_FILE conflict", problems)
+	}
+}
+
+func TestLoadEnvUnreadableFile(t *testing.T) {
+	setRequired(t)
+	os.Unsetenv("POSTGRES_PASS")
+	t.Setenv("POSTGRES_PASS_FILE", filepath.Join(t.TempDir(), "missing"))
+
+	problems := loadProblems(t)
+	if !containsProblem(problems, "environment: POSTGRES_PASS_FILE:") {
+		t.Errorf("problems = %q, want the unreadable POSTGRES_PASS_FILE", problems)
+	}
+}
+
+func TestLoadUnknownKeys(t *testing.T) {
+	setRequired(t)
+	t.Setenv("SOMETHING_UNRELATED", "1")
+
+	problems := loadProblems(t, Layer{Name: "flags", Values: map[string]string{"NOT_A_SETTING": "1"}})
+	if !containsProblem(problems, "flags: unknown setting NOT_A_SETTING") {
+		t.Errorf("problems = %q, want the unknown flag", problems)
+	}
+	if containsProblem(problems, "SOMETHING_UNRELATED") {
+		t.Errorf("problems = %q, unknown environment variables must be ignored", problems)
+	}
+}
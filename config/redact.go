@@ -0,0 +1,40 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sort"
+)
+
+const redacted = "[REDACTED]"
+
+// Redacted returns every setting by key with secrets masked.
+func (c *Config) Redacted() map[string]string {
+	values := make(map[string]string)
+	walk(reflect.ValueOf(c).Elem(), func(field reflect.StructField, value reflect.Value) {
+		text := fmt.Sprint(value.Interface())
+		if field.Tag.Get("secret") == "true" && !value.IsZero() {
+			text = redacted
+		}
+		values[field.Tag.Get("mapstructure")] = text
+	})
+	return values
+}
+
+// LogValue keeps secrets out of logs when the config is logged as a value.
+func (c *Config) LogValue() slog.Value {
+	values := c.Redacted()
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]slog.Attr, 0, len(keys))
+	for _, key := range keys {
+		attrs = append(attrs, slog.String(key, values[key]))
+	}
+	return slog.GroupValue(attrs...)
+}
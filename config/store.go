@@ -0,0 +1,100 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Store holds the active configuration. Only settings tagged reload:"true"
+// change after start-up; the rest need a restart and are kept as loaded.
+type Store struct {
+	current     atomic.Pointer[Config]
+	loadedAt    atomic.Pointer[time.Time]
+	mu          sync.Mutex
+	subscribers []func(*Config)
+}
+
+func NewStore(cfg *Config) *Store {
+	s := &Store{}
+	s.current.Store(cfg)
+	now := time.Now()
+	s.loadedAt.Store(&now)
+	return s
+}
+
+// Load returns the current snapshot. It must not be modified.
+func (s *Store) Load() *Config {
+	return s.current.Load()
+}
+
+func (s *Store) LoadedAt() time.Time {
+	return *s.loadedAt.Load()
+}
+
+// Subscribe registers fn to be called with every new snapshot.
+func (s *Store) Subscribe(fn func(*Config)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribers = append(s.subscribers, fn)
+}
+
+// Update swaps in the reloadable settings of next and notifies subscribers
+// when any of them changed. It reports the keys that changed and those that
+// differ but only take effect after a restart.
+func (s *Store) Update(next *Config) (changed, restart []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.current.Load()
+	merged := *current
+
+	target := reflect.ValueOf(&merged).Elem()
+	var fields []reflect.Value
+	walk(target, func(_ reflect.StructField, value reflect.Value) {
+		fields = append(fields, value)
+	})
+
+	i := 0
+	walk(reflect.ValueOf(next).Elem(), func(field reflect.StructField, value reflect.Value) {
+		dst := fields[i]
+		i++
+		if reflect.DeepEqual(dst.Interface(), value.Interface()) {
+			return
+		}
+
+		key := field.Tag.Get("mapstructure")
+		if field.Tag.Get("reload") != "true" {
+			restart = append(restart, key)
+			return
+		}
+		dst.Set(value)
+		changed = append(changed, key)
+	})
+
+	if len(changed) == 0 {
+		return nil, restart
+	}
+
+	s.current.Store(&merged)
+	now := time.Now()
+	s.loadedAt.Store(&now)
+	for _, fn := range s.subscribers {
+		fn(&merged)
+	}
+	return changed, restart
+}
+
+// Reloadable lists the keys that Update applies at runtime.
+func Reloadable() []string {
+	var keys []string
+	for key, spec := range fieldSpecs() {
+		if spec.reload {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
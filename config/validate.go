@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxURLExpiry is the longest lifetime S3 accepts for a presigned URL.
+const maxURLExpiry = 7 * 24 * time.Hour
+
+// ValidationError lists every configuration problem found at once, so that a
+// deployment can be fixed in a single round trip.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+func (e *ValidationError) add(format string, args ...any) {
+	e.Problems = append(e.Problems, fmt.Sprintf(format, args...))
+}
+
+func (c *Config) validate(problems *ValidationError) {
+	walk(reflect.ValueOf(c).Elem(), func(field reflect.StructField, value reflect.Value) {
+		if field.Tag.Get("required") == "true" && value.IsZero() {
+			problems.add("%s is required", field.Tag.Get("mapstructure"))
+		}
+	})
+
+	for key, port := range map[string]string{
+		"APP_PORT":      c.App.Port,
+		"APP_HTTP_PORT": c.App.HTTPPort,
+		"POSTGRES_PORT": c.Database.Port,
+		"REDIS_PORT":    c.Redis.Port,
+	} {
+		if n, err := strconv.Atoi(port); port != "" && (err != nil || n < 1 || n > 65535) {
+			problems.add("%s must be a port number, got %q", key, port)
+		}
+	}
+
+	oneOf(problems, "APP_LOG_LEVEL", c.App.LogLevel, "debug", "info", "warn", "error")
+	oneOf(problems, "EVENTS_SINK", c.Events.Sink, "redis", "webhook")
+	oneOf(problems, "OTEL_TRACES_EXPORTER", c.Tracing.Exporter, "none", "console", "stdout", "otlp")
+	oneOf(problems, "RATE_LIMIT_BACKEND", c.RateLimit.Backend, "memory", "redis")
+	oneOf(problems, "SETTINGS_SOURCE", c.Settings.Source, "none", "file", "postgres", "all")
+	if c.GRPC.ClientAuth != "" {
+		oneOf(problems, "GRPC_TLS_CLIENT_AUTH", c.GRPC.ClientAuth, "none", "request", "require")
+	}
+
+	if c.Events.Sink == "webhook" && c.Events.WebhookURL == "" {
+		problems.add("EVENTS_WEBHOOK_URL is required when EVENTS_SINK=webhook")
+	}
+
+	if (c.GRPC.TLSCertFile == "") != (c.GRPC.TLSKeyFile == "") {
+		problems.add("GRPC_TLS_CERT_FILE and GRPC_TLS_KEY_FILE must be set together")
+	}
+	if c.GRPC.ClientCAFile != "" && c.GRPC.TLSCertFile == "" {
+		problems.add("GRPC_TLS_CLIENT_CA_FILE requires GRPC_TLS_CERT_FILE and GRPC_TLS_KEY_FILE")
+	}
+
+	for _, proxy := range c.App.TrustedProxies() {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			problems.add("APP_HTTP_TRUSTED_PROXIES entries must be addresses or CIDRs, got %q", proxy)
+		}
+	}
+	if c.App.HTTPProxyHeader != "" && c.App.HTTPTrustedProxies == "" {
+		problems.add("APP_HTTP_PROXY_HEADER requires APP_HTTP_TRUSTED_PROXIES")
+	}
+
+	if _, err := c.Admin.ParseTokens(); err != nil {
+		problems.add("%s", err)
+	}
+
+	if c.Media.MaxFileSize <= 0 {
+		problems.add("MEDIA_MAX_FILE_SIZE must be positive")
+	}
+	if c.Media.URLExpiry < time.Second || c.Media.URLExpiry > maxURLExpiry {
+		problems.add("MEDIA_URL_EXPIRY must be between 1s and %s, got %s", maxURLExpiry, c.Media.URLExpiry)
+	}
+
+	if arg := c.Tracing.SamplerArg; arg != "" {
+		if ratio, err := strconv.ParseFloat(arg, 64); err != nil || ratio < 0 || ratio > 1 {
+			problems.add("OTEL_TRACES_SAMPLER_ARG must be between 0 and 1, got %q", arg)
+		}
+	}
+
+	for key, n := range map[string]float64{
+		"GRPC_MAX_RECV_MSG_SIZE":            float64(c.GRPC.MaxRecvMsgSize),
+		"GRPC_MAX_SEND_MSG_SIZE":            float64(c.GRPC.MaxSendMsgSize),
+		"EVENTS_BATCH_SIZE":                 float64(c.Events.BatchSize),
+		"MEDIA_MAX_VERSIONS":                float64(c.Media.MaxVersions),
+		"RATE_LIMIT_RPS":                    c.RateLimit.Rate,
+		"RATE_LIMIT_BURST":                  float64(c.RateLimit.Burst),
+		"RATE_LIMIT_MAX_UPLOADS":            float64(c.RateLimit.MaxUploads),
+		"RATE_LIMIT_MAX_DOWNLOADS":          float64(c.RateLimit.MaxDownloads),
+		"RATE_LIMIT_DOWNLOAD_BYTES_PER_SEC": float64(c.RateLimit.DownloadBytesPerSec),
+	} {
+		if n < 0 {
+			problems.add("%s must not be negative", key)
+		}
+	}
+}
+
+func oneOf(problems *ValidationError, key, value string, allowed ...string) {
+	for _, candidate := range allowed {
+		if value == candidate {
+			return
+		}
+	}
+	problems.add("%s must be one of %s, got %q", key, strings.Join(allowed, ", "), value)
+}
@@ -0,0 +1,27 @@
+package events
+
+import (
+	"fmt"
+	"github.com/co1seam/ember-backend-media/config"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"github.com/go-redis/redis/v8"
+)
+
+func New(cfg *config.Events, client *redis.Client) (ports.IEventSink, error) {
+	switch cfg.Sink {
+	case "", "redis":
+		stream := cfg.RedisStream
+		if stream == "" {
+			stream = models.DefaultEventsStream
+		}
+		return NewRedisStream(client, stream), nil
+	case "webhook":
+		if cfg.WebhookURL == "" {
+			return nil, fmt.Errorf("EVENTS_WEBHOOK_URL is required for the webhook sink")
+		}
+		return NewWebhook(cfg.WebhookURL), nil
+	default:
+		return nil, fmt.Errorf("unknown events sink: %s", cfg.Sink)
+	}
+}
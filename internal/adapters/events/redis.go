@@ -0,0 +1,35 @@
+package events
+
+import (
+	"context"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/go-redis/redis/v8"
+	"time"
+)
+
+type RedisStream struct {
+	client *redis.Client
+	stream string
+}
+
+func NewRedisStream(client *redis.Client, stream string) *RedisStream {
+	return &RedisStream{
+		client: client,
+		stream: stream,
+	}
+}
+
+func (r *RedisStream) Publish(ctx context.Context, event *models.Event) error {
+	return r.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: r.stream,
+		Values: map[string]interface{}{
+			"event_id":       event.ID,
+			"type":           event.Type,
+			"schema_version": event.SchemaVersion,
+			"aggregate_id":   event.AggregateID,
+			"owner_id":       event.OwnerID,
+			"occurred_at":    event.OccurredAt.Format(time.RFC3339Nano),
+			"data":           string(event.Data),
+		},
+	}).Err()
+}
@@ -0,0 +1,200 @@
+package health
+
+import (
+	"context"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+type Status struct {
+	Healthy   bool      `json:"healthy"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Checker pings every dependency periodically and publishes the result both
+// to the grpc.health.v1 server and to the HTTP probes. Each dependency is a
+// health service of its own; the empty service and the services added with
+// AddService are SERVING only while all dependencies are healthy.
+type Checker struct {
+	deps     map[string]Pinger
+	services []string
+	server   *health.Server
+	opts     *models.Options
+
+	mu       sync.RWMutex
+	statuses map[string]Status
+	stopping bool
+
+	wg     sync.WaitGroup
+	cancel context.CancelFunc
+}
+
+func NewChecker(opts *models.Options) *Checker {
+	server := health.NewServer()
+	server.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	return &Checker{
+		deps:     make(map[string]Pinger),
+		server:   server,
+		opts:     opts,
+		statuses: make(map[string]Status),
+	}
+}
+
+// Register adds a dependency; it must be called before Start.
+func (c *Checker) Register(name string, dep Pinger) {
+	c.deps[name] = dep
+	c.server.SetServingStatus(name, healthpb.HealthCheckResponse_NOT_SERVING)
+}
+
+func (c *Checker) AddService(name string) {
+	c.mu.Lock()
+	c.services = append(c.services, name)
+	c.mu.Unlock()
+
+	c.server.SetServingStatus(name, servingStatus(c.Ready()))
+}
+
+func (c *Checker) Server() healthpb.HealthServer {
+	return c.server
+}
+
+func (c *Checker) Start(ctx context.Context) {
+	ctx, c.cancel = context.WithCancel(ctx)
+
+	c.check(ctx)
+
+	c.wg.Add(1)
+	go c.run(ctx)
+}
+
+func (c *Checker) Stop() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.wg.Wait()
+}
+
+// Shutdown reports NOT_SERVING for every service from now on, so that load
+// balancers stop routing new calls while in-flight ones drain.
+func (c *Checker) Shutdown() {
+	c.mu.Lock()
+	c.stopping = true
+	c.mu.Unlock()
+
+	c.server.Shutdown()
+}
+
+func (c *Checker) Ready() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.stopping {
+		return false
+	}
+	for name := range c.deps {
+		if !c.statuses[name].Healthy {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Checker) Report() map[string]Status {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	report := make(map[string]Status, len(c.statuses))
+	for name, status := range c.statuses {
+		report[name] = status
+	}
+	return report
+}
+
+func (c *Checker) run(ctx context.Context) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(models.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.check(ctx)
+		}
+	}
+}
+
+func (c *Checker) check(ctx context.Context) {
+	names := make([]string, 0, len(c.deps))
+	for name := range c.deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make([]Status, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			pingCtx, cancel := context.WithTimeout(ctx, models.HealthCheckTimeout)
+			defer cancel()
+
+			results[i] = Status{Healthy: true, CheckedAt: time.Now()}
+			if err := c.deps[name].Ping(pingCtx); err != nil {
+				results[i] = Status{Error: err.Error(), CheckedAt: time.Now()}
+			}
+		}()
+	}
+	wg.Wait()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	healthy := true
+	for i, name := range names {
+		previous, seen := c.statuses[name]
+		c.statuses[name] = results[i]
+
+		if !results[i].Healthy {
+			healthy = false
+		}
+		if !seen || previous.Healthy != results[i].Healthy {
+			c.logTransition(ctx, name, results[i])
+		}
+		c.server.SetServingStatus(name, servingStatus(results[i].Healthy))
+	}
+
+	c.server.SetServingStatus("", servingStatus(healthy))
+	for _, service := range c.services {
+		c.server.SetServingStatus(service, servingStatus(healthy))
+	}
+}
+
+func (c *Checker) logTransition(ctx context.Context, name string, status Status) {
+	if status.Healthy {
+		c.opts.Logger.InfoContext(ctx, "dependency healthy", "dependency", name)
+		return
+	}
+	c.opts.Logger.WarnContext(ctx, "dependency unhealthy", "dependency", name, "error", status.Error)
+}
+
+func servingStatus(healthy bool) healthpb.HealthCheckResponse_ServingStatus {
+	if healthy {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
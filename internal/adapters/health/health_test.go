@@ -0,0 +1,131 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+)
+
+type fakePinger struct {
+	err   atomic.Pointer[error]
+	pings atomic.Int32
+}
+
+func (p *fakePinger) Ping(ctx context.Context) error {
+	p.pings.Add(1)
+	if err := p.err.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+func (p *fakePinger) fail(err error) {
+	p.err.Store(&err)
+}
+
+func newTestChecker(t *testing.T, deps map[string]*fakePinger) *Checker {
+	t.Helper()
+	checker := NewChecker(&models.Options{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	for name, dep := range deps {
+		checker.Register(name, dep)
+	}
+	return checker
+}
+
+func servingOf(t *testing.T, checker *Checker, service string) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	resp, err := checker.Server().Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		t.Fatalf("Check(%q) error = %v", service, err)
+	}
+	return resp.Status
+}
+
+func TestCheckerReportsEachDependency(t *testing.T) {
+	postgres, minio := &fakePinger{}, &fakePinger{}
+	minio.fail(errors.New("dial tcp: connection refused"))
+	checker := newTestChecker(t, map[string]*fakePinger{"postgres": postgres, "minio": minio})
+	checker.AddService("media.v1.MediaService")
+
+	if checker.Ready() {
+		t.Error("Ready() before the first check, want false")
+	}
+
+	checker.Start(context.Background())
+	defer checker.Stop()
+
+	if checker.Ready() {
+		t.Error("Ready() with minio down, want false")
+	}
+	want := map[string]healthpb.HealthCheckResponse_ServingStatus{
+		"":                      healthpb.HealthCheckResponse_NOT_SERVING,
+		"media.v1.MediaService": healthpb.HealthCheckResponse_NOT_SERVING,
+		"postgres":              healthpb.HealthCheckResponse_SERVING,
+		"minio":                 healthpb.HealthCheckResponse_NOT_SERVING,
+	}
+	for service, status := range want {
+		if got := servingOf(t, checker, service); got != status {
+			t.Errorf("%q = %s, want %s", service, got, status)
+		}
+	}
+
+	report := checker.Report()
+	if !report["postgres"].Healthy || report["minio"].Healthy || report["minio"].Error != "dial tcp: connection refused" {
+		t.Errorf("Report() = %+v", report)
+	}
+}
+
+func TestCheckerRecoversAndShutsDown(t *testing.T) {
+	redis := &fakePinger{}
+	redis.fail(errors.New("timeout"))
+	checker := newTestChecker(t, map[string]*fakePinger{"redis": redis})
+	checker.AddService("media.v1.MediaService")
+	ctx := context.Background()
+
+	checker.check(ctx)
+	if checker.Ready() {
+		t.Fatal("Ready() with redis down, want false")
+	}
+
+	redis.err.Store(nil)
+	checker.check(ctx)
+	if !checker.Ready() {
+		t.Fatal("Ready() after redis recovered, want true")
+	}
+	if got := servingOf(t, checker, "media.v1.MediaService"); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("service = %s after recovery, want SERVING", got)
+	}
+
+	checker.Shutdown()
+	if checker.Ready() {
+		t.Error("Ready() during shutdown, want false")
+	}
+	for _, service := range []string{"", "media.v1.MediaService", "redis"} {
+		if got := servingOf(t, checker, service); got != healthpb.HealthCheckResponse_NOT_SERVING {
+			t.Errorf("%q = %s during shutdown, want NOT_SERVING", service, got)
+		}
+	}
+
+	checker.check(ctx)
+	if checker.Ready() || servingOf(t, checker, "") != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Error("a check after shutdown brought the service back")
+	}
+}
+
+func TestCheckerStop(t *testing.T) {
+	postgres := &fakePinger{}
+	checker := newTestChecker(t, map[string]*fakePinger{"postgres": postgres})
+
+	checker.Start(context.Background())
+	checker.Stop()
+
+	pings := postgres.pings.Load()
+	if pings != 1 {
+		t.Errorf("pinged %d times on start, want 1", pings)
+	}
+}
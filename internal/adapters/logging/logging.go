@@ -0,0 +1,43 @@
+package logging
+
+import (
+	"context"
+	"github.com/co1seam/ember-backend-media/internal/core/auth"
+	"github.com/google/uuid"
+	"log/slog"
+)
+
+const RequestIDHeader = "x-request-id"
+
+// RequestID returns incoming when it is a reasonable client-supplied ID and
+// a fresh one otherwise.
+func RequestID(incoming string) string {
+	if incoming != "" && len(incoming) <= 128 {
+		return incoming
+	}
+	return uuid.New().String()
+}
+
+// Handler adds the request ID carried by ctx to every record.
+type Handler struct {
+	slog.Handler
+}
+
+func NewHandler(inner slog.Handler) *Handler {
+	return &Handler{Handler: inner}
+}
+
+func (h *Handler) Handle(ctx context.Context, record slog.Record) error {
+	if id := auth.RequestIDFromContext(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,50 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/co1seam/ember-backend-media/internal/core/auth"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	if got := RequestID("req-1"); got != "req-1" {
+		t.Errorf("RequestID(req-1) = %q, want it kept", got)
+	}
+
+	for name, incoming := range map[string]string{"empty": "", "too long": strings.Repeat("x", 129)} {
+		got := RequestID(incoming)
+		if got == "" || got == incoming {
+			t.Errorf("%s: RequestID() = %q, want a fresh ID", name, got)
+		}
+	}
+	if RequestID("") == RequestID("") {
+		t.Error("fresh IDs repeat")
+	}
+}
+
+func TestHandlerAddsRequestID(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(NewHandler(slog.NewJSONHandler(&out, nil))).With("component", "test")
+
+	logger.InfoContext(auth.WithRequestID(context.Background(), "req-1"), "with id")
+	logger.InfoContext(context.Background(), "without id")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("logged %d lines, want 2", len(lines))
+	}
+	for i, want := range []string{"req-1", ""} {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(lines[i]), &record); err != nil {
+			t.Fatal(err)
+		}
+		id, _ := record["request_id"].(string)
+		if id != want || record["component"] != "test" {
+			t.Errorf("record %d = %v, want request_id %q and the logger's attrs", i, record, want)
+		}
+	}
+}
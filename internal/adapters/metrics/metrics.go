@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"database/sql"
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"time"
+)
+
+// Registry holds every metric the service exposes, plus the Go runtime and
+// process collectors.
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+var (
+	GRPCStarted = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_server_started_total",
+		Help: "Total number of RPCs started on the server.",
+	}, []string{"grpc_type", "grpc_service", "grpc_method"})
+	GRPCHandled = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_server_handled_total",
+		Help: "Total number of RPCs completed on the server, regardless of success or failure.",
+	}, []string{"grpc_type", "grpc_service", "grpc_method", "grpc_code"})
+	GRPCHandlingSeconds = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "grpc_server_handling_seconds",
+		Help:    "Histogram of response latency of RPCs handled by the server.",
+		Buckets: DefaultBuckets,
+	}, []string{"grpc_type", "grpc_service", "grpc_method"})
+	ActiveStreams = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "media_active_streams",
+		Help: "Number of upload and download streams currently in flight.",
+	}, []string{"direction", "transport"})
+	BytesUploaded = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "media_bytes_uploaded_total",
+		Help: "Total number of file bytes received from clients.",
+	}, []string{"transport"})
+	BytesDownloaded = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "media_bytes_downloaded_total",
+		Help: "Total number of file bytes sent to clients.",
+	}, []string{"transport"})
+	MinioOperationSeconds = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "media_minio_operation_seconds",
+		Help:    "Latency of MinIO operations.",
+		Buckets: DefaultBuckets,
+	}, []string{"operation", "status"})
+)
+
+// ObserveMinio starts timing a MinIO operation; call the returned func with
+// the operation's error, typically as defer metrics.ObserveMinio("op")(&err).
+func ObserveMinio(operation string) func(err *error) {
+	started := time.Now()
+
+	return func(err *error) {
+		status := "ok"
+		if err != nil && *err != nil {
+			status = "error"
+		}
+		MinioOperationSeconds.WithLabelValues(operation, status).Observe(time.Since(started).Seconds())
+	}
+}
+
+func RegisterDBStats(db *sql.DB) {
+	factory.NewGaugeFunc(prometheus.GaugeOpts{Name: "media_db_max_open_connections", Help: "Maximum number of open connections to the database."}, func() float64 {
+		return float64(db.Stats().MaxOpenConnections)
+	})
+	factory.NewGaugeFunc(prometheus.GaugeOpts{Name: "media_db_open_connections", Help: "The number of established connections both in use and idle."}, func() float64 {
+		return float64(db.Stats().OpenConnections)
+	})
+	factory.NewGaugeFunc(prometheus.GaugeOpts{Name: "media_db_in_use_connections", Help: "The number of connections currently in use."}, func() float64 {
+		return float64(db.Stats().InUse)
+	})
+	factory.NewGaugeFunc(prometheus.GaugeOpts{Name: "media_db_idle_connections", Help: "The number of idle connections."}, func() float64 {
+		return float64(db.Stats().Idle)
+	})
+	factory.NewCounterFunc(prometheus.CounterOpts{Name: "media_db_wait_count_total", Help: "The total number of connections waited for."}, func() float64 {
+		return float64(db.Stats().WaitCount)
+	})
+	factory.NewCounterFunc(prometheus.CounterOpts{Name: "media_db_wait_duration_seconds_total", Help: "The total time blocked waiting for a new connection."}, func() float64 {
+		return db.Stats().WaitDuration.Seconds()
+	})
+	factory.NewCounterFunc(prometheus.CounterOpts{Name: "media_db_max_idle_closed_total", Help: "The total number of connections closed due to SetMaxIdleConns."}, func() float64 {
+		return float64(db.Stats().MaxIdleClosed)
+	})
+	factory.NewCounterFunc(prometheus.CounterOpts{Name: "media_db_max_lifetime_closed_total", Help: "The total number of connections closed due to SetConnMaxLifetime."}, func() float64 {
+		return float64(db.Stats().MaxLifetimeClosed)
+	})
+}
+
+// RegisterRedisStats exports the health of the Redis connection pool. The
+// service keeps no cache in Redis, so there is no hit ratio to report.
+func RegisterRedisStats(client *redis.Client) {
+	factory.NewCounterFunc(prometheus.CounterOpts{Name: "media_redis_pool_timeouts_total", Help: "Number of times a wait timeout occurred."}, func() float64 {
+		return float64(client.PoolStats().Timeouts)
+	})
+	factory.NewGaugeFunc(prometheus.GaugeOpts{Name: "media_redis_pool_total_connections", Help: "Number of total connections in the pool."}, func() float64 {
+		return float64(client.PoolStats().TotalConns)
+	})
+}
+
+func RegisterQueueDepth(name string, depth func() int) {
+	factory.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "media_" + name + "_queue_depth",
+		Help: "Number of jobs waiting in the " + name + " queue.",
+	}, func() float64 {
+		return float64(depth())
+	})
+}
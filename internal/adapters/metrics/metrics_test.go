@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestObserveMinioLabelsStatus(t *testing.T) {
+	var err error
+	ObserveMinio("stat_object")(&err)
+	err = errors.New("no such key")
+	ObserveMinio("stat_object")(&err)
+	ObserveMinio("stat_object")(&err)
+
+	body := scrape(t)
+	for _, want := range []string{
+		`media_minio_operation_seconds_count{operation="stat_object",status="ok"} 1`,
+		`media_minio_operation_seconds_count{operation="stat_object",status="error"} 2`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics does not contain %q", want)
+		}
+	}
+}
+
+func TestHandlerExposesRegistry(t *testing.T) {
+	ActiveStreams.WithLabelValues("upload", "http").Inc()
+	defer ActiveStreams.WithLabelValues("upload", "http").Dec()
+	RegisterQueueDepth("test", func() int { return 3 })
+
+	body := scrape(t)
+	for _, want := range []string{
+		`media_active_streams{direction="upload",transport="http"} 1`,
+		`media_test_queue_depth 3`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics does not contain %q", want)
+		}
+	}
+}
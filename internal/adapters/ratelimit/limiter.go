@@ -0,0 +1,122 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"github.com/co1seam/ember-backend-media/config"
+	"github.com/go-redis/redis/v8"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DirectionUpload   = "upload"
+	DirectionDownload = "download"
+)
+
+// LimitError is returned when a limit is hit. RetryAfter is zero for stream
+// caps, where the wait depends on other streams finishing.
+type LimitError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *LimitError) Error() string {
+	return e.Reason
+}
+
+type Limiter struct {
+	store  Store
+	policy atomic.Pointer[Policy]
+	logger *slog.Logger
+}
+
+func New(cfg *config.RateLimit, client *redis.Client, logger *slog.Logger) (*Limiter, error) {
+	policy, err := PolicyFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var store Store
+	switch cfg.Backend {
+	case "", "memory":
+		store = NewMemoryStore()
+	case "redis":
+		store = NewRedisStore(client)
+	default:
+		return nil, fmt.Errorf("unknown RATE_LIMIT_BACKEND: %s", cfg.Backend)
+	}
+
+	l := &Limiter{store: store, logger: logger}
+	l.policy.Store(policy)
+	return l, nil
+}
+
+func (l *Limiter) Policy() *Policy {
+	return l.policy.Load()
+}
+
+func (l *Limiter) SetPolicy(policy *Policy) {
+	l.policy.Store(policy)
+}
+
+// Allow takes a token from the bucket of owner for method. Store failures
+// let the call through: an unavailable Redis must not take the API down.
+func (l *Limiter) Allow(ctx context.Context, method, owner string) error {
+	rule := l.Policy().rule(method)
+	if rule.Rate <= 0 {
+		return nil
+	}
+
+	ok, wait, err := l.store.Take(ctx, "rate:"+method+":"+owner, rule)
+	if err != nil {
+		l.logger.WarnContext(ctx, "rate limit check failed", "method", method, "error", err)
+		return nil
+	}
+	if !ok {
+		return &LimitError{Reason: "rate limit exceeded for " + method, RetryAfter: wait}
+	}
+	return nil
+}
+
+// AcquireStream reserves one of the concurrent stream slots of owner. The
+// returned release must be called once the stream ends.
+func (l *Limiter) AcquireStream(ctx context.Context, direction, owner string) (func(), error) {
+	limit := l.Policy().MaxUploads
+	if direction == DirectionDownload {
+		limit = l.Policy().MaxDownloads
+	}
+	if limit <= 0 {
+		return func() {}, nil
+	}
+
+	key := "streams:" + direction + ":" + owner
+	ok, err := l.store.Acquire(ctx, key, limit)
+	if err != nil {
+		l.logger.WarnContext(ctx, "stream limit check failed", "direction", direction, "error", err)
+		return func() {}, nil
+	}
+	if !ok {
+		return nil, &LimitError{Reason: fmt.Sprintf("too many concurrent %ss", direction)}
+	}
+
+	return func() {
+		releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second)
+		defer cancel()
+
+		if err := l.store.Release(releaseCtx, key); err != nil {
+			l.logger.WarnContext(ctx, "stream slot not released", "direction", direction, "error", err)
+		}
+	}, nil
+}
+
+// DownloadThrottle returns a throttle for one download stream, or nil when
+// bandwidth is not limited.
+func (l *Limiter) DownloadThrottle() *Throttle {
+	rate := l.Policy().DownloadBytesPerSec
+	if rate <= 0 {
+		return nil
+	}
+	return NewThrottle(rate)
+}
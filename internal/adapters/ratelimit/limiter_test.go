@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"github.com/co1seam/ember-backend-media/config"
+	"github.com/go-redis/redis/v8"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestLimiterAllowUsesMethodRule(t *testing.T) {
+	limiter, err := New(&config.RateLimit{Rate: 100, Burst: 100, Methods: "UploadFile=1:1"}, nil, discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if err := limiter.Allow(ctx, "UploadFile", "owner"); err != nil {
+		t.Fatalf("first upload: %v", err)
+	}
+
+	var limitErr *LimitError
+	if err := limiter.Allow(ctx, "UploadFile", "owner"); !errors.As(err, &limitErr) || limitErr.RetryAfter <= 0 {
+		t.Fatalf("second upload = %v, want a LimitError with RetryAfter", err)
+	}
+	if err := limiter.Allow(ctx, "GetMedia", "owner"); err != nil {
+		t.Errorf("GetMedia shares the UploadFile bucket: %v", err)
+	}
+}
+
+func TestLimiterFailsOpenWithoutRedis(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer client.Close()
+
+	limiter, err := New(&config.RateLimit{Backend: "redis", Rate: 1, Burst: 1, MaxUploads: 1}, client, discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	for range 3 {
+		if err := limiter.Allow(ctx, "GetMedia", "owner"); err != nil {
+			t.Fatalf("Allow = %v, want nil while Redis is down", err)
+		}
+		release, err := limiter.AcquireStream(ctx, DirectionUpload, "owner")
+		if err != nil {
+			t.Fatalf("AcquireStream = %v, want nil while Redis is down", err)
+		}
+		release()
+	}
+}
@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"fmt"
+	"github.com/co1seam/ember-backend-media/config"
+	"strconv"
+	"strings"
+)
+
+type Rule struct {
+	Rate  float64
+	Burst int
+}
+
+// Policy is the set of limits in force. A zero rate, stream cap or bandwidth
+// disables that limit.
+type Policy struct {
+	Default             Rule
+	Methods             map[string]Rule
+	MaxUploads          int
+	MaxDownloads        int
+	DownloadBytesPerSec int64
+}
+
+func PolicyFromConfig(cfg *config.RateLimit) (*Policy, error) {
+	policy := &Policy{
+		Default:             Rule{Rate: cfg.Rate, Burst: cfg.Burst},
+		Methods:             make(map[string]Rule),
+		MaxUploads:          cfg.MaxUploads,
+		MaxDownloads:        cfg.MaxDownloads,
+		DownloadBytesPerSec: cfg.DownloadBytesPerSec,
+	}
+
+	// RATE_LIMIT_METHODS=UploadFile=0.5:2,ListMedia=20:40
+	for _, entry := range strings.Split(cfg.Methods, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		method, spec, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid RATE_LIMIT_METHODS entry: %s", entry)
+		}
+
+		rule, err := parseRule(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RATE_LIMIT_METHODS entry %s: %w", entry, err)
+		}
+		policy.Methods[strings.TrimSpace(method)] = rule
+	}
+
+	return policy, nil
+}
+
+func (p *Policy) rule(method string) Rule {
+	rule, ok := p.Methods[method]
+	if !ok {
+		rule = p.Default
+	}
+	if rule.Burst <= 0 {
+		rule.Burst = max(1, int(rule.Rate))
+	}
+	return rule
+}
+
+func parseRule(spec string) (Rule, error) {
+	rateSpec, burstSpec, hasBurst := strings.Cut(spec, ":")
+
+	rate, err := strconv.ParseFloat(strings.TrimSpace(rateSpec), 64)
+	if err != nil || rate < 0 {
+		return Rule{}, fmt.Errorf("invalid rate %q", rateSpec)
+	}
+
+	rule := Rule{Rate: rate}
+	if hasBurst {
+		rule.Burst, err = strconv.Atoi(strings.TrimSpace(burstSpec))
+		if err != nil || rule.Burst < 0 {
+			return Rule{}, fmt.Errorf("invalid burst %q", burstSpec)
+		}
+	}
+	return rule, nil
+}
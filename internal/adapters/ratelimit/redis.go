@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"time"
+)
+
+// streamTTL bounds how long a stream slot leaked by a crashed pod is held.
+const streamTTL = time.Hour
+
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate * 1000)
+end
+
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+
+return {allowed, wait}
+`)
+
+var acquireScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+redis.call("PEXPIRE", KEYS[1], ARGV[2])
+if count > tonumber(ARGV[1]) then
+	redis.call("DECR", KEYS[1])
+	return 0
+end
+return 1
+`)
+
+// RedisStore shares buckets and stream counters between replicas.
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client, prefix: "ratelimit:"}
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string, rule Rule) (bool, time.Duration, error) {
+	res, err := takeScript.Run(ctx, s.client, []string{s.prefix + key}, rule.Rate, rule.Burst, time.Now().UnixMilli()).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	return res[0] == 1, time.Duration(res[1]) * time.Millisecond, nil
+}
+
+func (s *RedisStore) Acquire(ctx context.Context, key string, limit int) (bool, error) {
+	ok, err := acquireScript.Run(ctx, s.client, []string{s.prefix + key}, limit, streamTTL.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return ok == 1, nil
+}
+
+func (s *RedisStore) Release(ctx context.Context, key string) error {
+	count, err := s.client.Decr(ctx, s.prefix+key).Result()
+	if err != nil {
+		return err
+	}
+	if count <= 0 {
+		return s.client.Del(ctx, s.prefix+key).Err()
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+type Store interface {
+	// Take removes one token from the bucket at key and reports how long to
+	// wait for the next one when the bucket is empty.
+	Take(ctx context.Context, key string, rule Rule) (bool, time.Duration, error)
+	Acquire(ctx context.Context, key string, limit int) (bool, error)
+	Release(ctx context.Context, key string) error
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	streams map[string]int
+	swept   time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+		streams: make(map[string]int),
+		swept:   time.Now(),
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, rule Rule) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rule.Burst), updated: now}
+		s.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(rule.Burst), b.tokens+now.Sub(b.updated).Seconds()*rule.Rate)
+	b.updated = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+
+	wait := time.Duration((1 - b.tokens) / rule.Rate * float64(time.Second))
+	return false, wait, nil
+}
+
+func (s *MemoryStore) Acquire(ctx context.Context, key string, limit int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.streams[key] >= limit {
+		return false, nil
+	}
+	s.streams[key]++
+	return true, nil
+}
+
+func (s *MemoryStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.streams[key] <= 1 {
+		delete(s.streams, key)
+		return nil
+	}
+	s.streams[key]--
+	return nil
+}
+
+// sweep drops buckets idle long enough to have refilled completely.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.swept) < time.Minute {
+		return
+	}
+	s.swept = now
+
+	for key, b := range s.buckets {
+		if now.Sub(b.updated) > 10*time.Minute {
+			delete(s.buckets, key)
+		}
+	}
+}
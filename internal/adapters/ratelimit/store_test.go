@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"testing"
+	"time"
+)
+
+func newRedisStore(t *testing.T) (*RedisStore, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisStore(client), server
+}
+
+func stores(t *testing.T) map[string]Store {
+	redisStore, _ := newRedisStore(t)
+	return map[string]Store{"memory": NewMemoryStore(), "redis": redisStore}
+}
+
+func TestStoreTakeSpendsBurstThenRefuses(t *testing.T) {
+	ctx := context.Background()
+	rule := Rule{Rate: 1, Burst: 3}
+
+	for name, store := range stores(t) {
+		for i := range rule.Burst {
+			ok, _, err := store.Take(ctx, "rate:GetMedia:owner", rule)
+			if err != nil || !ok {
+				t.Fatalf("%s: take %d = %v, %v; want allowed", name, i, ok, err)
+			}
+		}
+
+		ok, wait, err := store.Take(ctx, "rate:GetMedia:owner", rule)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if ok {
+			t.Errorf("%s: take past the burst was allowed", name)
+		}
+		if wait <= 0 || wait > time.Second {
+			t.Errorf("%s: wait = %s, want up to one token interval", name, wait)
+		}
+
+		if ok, _, _ := store.Take(ctx, "rate:GetMedia:other", rule); !ok {
+			t.Errorf("%s: another owner shares the bucket", name)
+		}
+	}
+}
+
+func TestStoreTakeRefills(t *testing.T) {
+	ctx := context.Background()
+	rule := Rule{Rate: 50, Burst: 1}
+
+	for name, store := range stores(t) {
+		if ok, _, _ := store.Take(ctx, "rate:ListMedia:owner", rule); !ok {
+			t.Fatalf("%s: first take refused", name)
+		}
+		if ok, _, _ := store.Take(ctx, "rate:ListMedia:owner", rule); ok {
+			t.Fatalf("%s: empty bucket allowed a take", name)
+		}
+
+		time.Sleep(40 * time.Millisecond)
+
+		if ok, _, _ := store.Take(ctx, "rate:ListMedia:owner", rule); !ok {
+			t.Errorf("%s: bucket did not refill", name)
+		}
+	}
+}
+
+func TestStoreStreamSlots(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range stores(t) {
+		for i := range 2 {
+			if ok, err := store.Acquire(ctx, "streams:upload:owner", 2); err != nil || !ok {
+				t.Fatalf("%s: acquire %d = %v, %v; want granted", name, i, ok, err)
+			}
+		}
+		if ok, _ := store.Acquire(ctx, "streams:upload:owner", 2); ok {
+			t.Fatalf("%s: acquire past the cap was granted", name)
+		}
+
+		if err := store.Release(ctx, "streams:upload:owner"); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if ok, _ := store.Acquire(ctx, "streams:upload:owner", 2); !ok {
+			t.Errorf("%s: released slot was not reusable", name)
+		}
+	}
+}
+
+func TestRedisStoreKeys(t *testing.T) {
+	ctx := context.Background()
+	store, server := newRedisStore(t)
+
+	if ok, _, err := store.Take(ctx, "rate:GetMedia:owner", Rule{Rate: 2, Burst: 4}); err != nil || !ok {
+		t.Fatalf("take = %v, %v", ok, err)
+	}
+	if tokens := server.HGet("ratelimit:rate:GetMedia:owner", "tokens"); tokens != "3" {
+		t.Errorf("tokens = %q, want 3", tokens)
+	}
+	if ttl := server.TTL("ratelimit:rate:GetMedia:owner"); ttl <= 0 || ttl > 3*time.Second {
+		t.Errorf("bucket TTL = %s, want the refill time plus a second", ttl)
+	}
+
+	if ok, err := store.Acquire(ctx, "streams:download:owner", 1); err != nil || !ok {
+		t.Fatalf("acquire = %v, %v", ok, err)
+	}
+	if ttl := server.TTL("ratelimit:streams:download:owner"); ttl != streamTTL {
+		t.Errorf("stream TTL = %s, want %s", ttl, streamTTL)
+	}
+
+	if ok, _ := store.Acquire(ctx, "streams:download:owner", 1); ok {
+		t.Fatal("acquire past the cap was granted")
+	}
+	if count, _ := server.Get("ratelimit:streams:download:owner"); count != "1" {
+		t.Errorf("refused acquire left the counter at %s, want 1", count)
+	}
+
+	if err := store.Release(ctx, "streams:download:owner"); err != nil {
+		t.Fatal(err)
+	}
+	if server.Exists("ratelimit:streams:download:owner") {
+		t.Error("counter kept after the last release")
+	}
+}
@@ -0,0 +1,43 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Throttle paces a single stream to a byte rate, allowing bursts of up to one
+// second worth of data.
+type Throttle struct {
+	rate    float64
+	tokens  float64
+	updated time.Time
+}
+
+func NewThrottle(bytesPerSec int64) *Throttle {
+	return &Throttle{
+		rate:    float64(bytesPerSec),
+		tokens:  float64(bytesPerSec),
+		updated: time.Now(),
+	}
+}
+
+func (t *Throttle) Wait(ctx context.Context, n int) error {
+	now := time.Now()
+	t.tokens = min(t.rate, t.tokens+now.Sub(t.updated).Seconds()*t.rate)
+	t.updated = now
+	t.tokens -= float64(n)
+
+	if t.tokens >= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(time.Duration(-t.tokens / t.rate * float64(time.Second)))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
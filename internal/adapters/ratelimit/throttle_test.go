@@ -0,0 +1,39 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestThrottlePacesAfterBurst(t *testing.T) {
+	throttle := NewThrottle(1000)
+	ctx := context.Background()
+
+	started := time.Now()
+	if err := throttle.Wait(ctx, 1000); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(started); elapsed > 20*time.Millisecond {
+		t.Fatalf("first second of data waited %s", elapsed)
+	}
+
+	started = time.Now()
+	if err := throttle.Wait(ctx, 100); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(started); elapsed < 80*time.Millisecond {
+		t.Errorf("100 bytes past the burst waited %s, want about 100ms", elapsed)
+	}
+}
+
+func TestThrottleStopsOnCancel(t *testing.T) {
+	throttle := NewThrottle(10)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := throttle.Wait(ctx, 1000); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait = %v, want context.Canceled", err)
+	}
+}
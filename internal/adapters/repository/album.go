@@ -0,0 +1,278 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/co1seam/ember-backend-media/internal/adapters/tracing"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"github.com/lib/pq"
+)
+
+type Album struct {
+	db   *sql.DB
+	opts *models.Options
+}
+
+func NewAlbum(db *sql.DB, opts *models.Options) ports.IAlbumRepo {
+	return &Album{
+		db:   db,
+		opts: opts,
+	}
+}
+
+func (a *Album) Create(ctx context.Context, album *models.Album) (err error) {
+	ctx, span := startQuerySpan(ctx, "INSERT", models.AlbumsTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (id, owner_id, title, description, cover_media_id, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		models.AlbumsTable,
+	)
+
+	_, err = conn(ctx, a.db).ExecContext(
+		ctx,
+		query,
+		album.ID,
+		album.OwnerID,
+		album.Title,
+		album.Description,
+		nullString(album.CoverMediaID),
+		album.CreatedAt,
+		album.UpdatedAt,
+	)
+	return err
+}
+
+func (a *Album) GetByID(ctx context.Context, id string) (_ *models.Album, err error) {
+	ctx, span := startQuerySpan(ctx, "SELECT", models.AlbumsTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM %s a WHERE a.id = $1",
+		albumColumns(),
+		models.AlbumsTable,
+	)
+
+	return scanAlbum(conn(ctx, a.db).QueryRowContext(ctx, query, id))
+}
+
+// Update also row-locks the album for the rest of the transaction, which
+// serialises membership changes made after it.
+func (a *Album) Update(ctx context.Context, album *models.Album) (err error) {
+	ctx, span := startQuerySpan(ctx, "UPDATE", models.AlbumsTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET title = $1, description = $2, cover_media_id = $3, updated_at = $4 WHERE id = $5",
+		models.AlbumsTable,
+	)
+
+	_, err = conn(ctx, a.db).ExecContext(
+		ctx,
+		query,
+		album.Title,
+		album.Description,
+		nullString(album.CoverMediaID),
+		album.UpdatedAt,
+		album.ID,
+	)
+	return err
+}
+
+func (a *Album) Delete(ctx context.Context, id string) (err error) {
+	ctx, span := startQuerySpan(ctx, "DELETE", models.AlbumsTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE id = $1",
+		models.AlbumsTable,
+	)
+
+	_, err = conn(ctx, a.db).ExecContext(ctx, query, id)
+	return err
+}
+
+func (a *Album) DeleteByOwner(ctx context.Context, ownerID string) (_ int64, err error) {
+	ctx, span := startQuerySpan(ctx, "DELETE", models.AlbumsTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE owner_id = $1",
+		models.AlbumsTable,
+	)
+
+	result, err := conn(ctx, a.db).ExecContext(ctx, query, ownerID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (a *Album) ListByOwner(ctx context.Context, ownerID string, limit int) (_ []*models.Album, err error) {
+	ctx, span := startQuerySpan(ctx, "SELECT", models.AlbumsTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM %s a WHERE a.owner_id = $1 ORDER BY a.created_at DESC LIMIT $2",
+		albumColumns(),
+		models.AlbumsTable,
+	)
+
+	rows, err := conn(ctx, a.db).QueryContext(ctx, query, ownerID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var albums []*models.Album
+	for rows.Next() {
+		album, err := scanAlbum(rows)
+		if err != nil {
+			return nil, err
+		}
+		albums = append(albums, album)
+	}
+
+	return albums, rows.Err()
+}
+
+// AddMedia appends media to the album in the given order. Media already in
+// the album keep their place.
+func (a *Album) AddMedia(ctx context.Context, albumID string, mediaIDs []string) (err error) {
+	ctx, span := startQuerySpan(ctx, "INSERT", models.AlbumMediaTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		`INSERT INTO %[1]s (album_id, media_id, position)
+		SELECT $1, o.media_id, (SELECT COALESCE(MAX(position), 0) FROM %[1]s WHERE album_id = $1) + o.ord
+		FROM unnest($2::uuid[]) WITH ORDINALITY AS o(media_id, ord)
+		ON CONFLICT DO NOTHING`,
+		models.AlbumMediaTable,
+	)
+
+	_, err = conn(ctx, a.db).ExecContext(ctx, query, albumID, pq.Array(mediaIDs))
+	return err
+}
+
+func (a *Album) RemoveMedia(ctx context.Context, albumID string, mediaIDs []string) (err error) {
+	ctx, span := startQuerySpan(ctx, "DELETE", models.AlbumMediaTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE album_id = $1 AND media_id = ANY($2::uuid[])",
+		models.AlbumMediaTable,
+	)
+
+	_, err = conn(ctx, a.db).ExecContext(ctx, query, albumID, pq.Array(mediaIDs))
+	return err
+}
+
+// Reorder renumbers the album to follow mediaIDs, which must list every member.
+func (a *Album) Reorder(ctx context.Context, albumID string, mediaIDs []string) (err error) {
+	ctx, span := startQuerySpan(ctx, "UPDATE", models.AlbumMediaTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		`UPDATE %s am SET position = o.ord
+		FROM unnest($2::uuid[]) WITH ORDINALITY AS o(media_id, ord)
+		WHERE am.album_id = $1 AND am.media_id = o.media_id`,
+		models.AlbumMediaTable,
+	)
+
+	_, err = conn(ctx, a.db).ExecContext(ctx, query, albumID, pq.Array(mediaIDs))
+	return err
+}
+
+func (a *Album) MediaIDs(ctx context.Context, albumID string) (_ []string, err error) {
+	ctx, span := startQuerySpan(ctx, "SELECT", models.AlbumMediaTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"SELECT media_id FROM %s WHERE album_id = $1 ORDER BY position, added_at",
+		models.AlbumMediaTable,
+	)
+
+	rows, err := conn(ctx, a.db).QueryContext(ctx, query, albumID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// ListMedia returns up to limit members after afterPosition in album order,
+// with the position of the last one as the cursor for the next page.
+func (a *Album) ListMedia(ctx context.Context, albumID string, afterPosition, limit int) (_ []*models.Media, _ int, err error) {
+	ctx, span := startQuerySpan(ctx, "SELECT", models.AlbumMediaTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		`SELECT %[1]s, am.position FROM %[2]s am JOIN %[3]s ON %[3]s.id = am.media_id
+		WHERE am.album_id = $1 AND am.position > $2
+		ORDER BY am.position, am.added_at LIMIT $3`,
+		mediaSelectColumns,
+		models.AlbumMediaTable,
+		models.MediaTable,
+	)
+
+	rows, err := conn(ctx, a.db).QueryContext(ctx, query, albumID, afterPosition, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var mediaList []*models.Media
+	last := afterPosition
+	for rows.Next() {
+		media, err := scanMedia(withExtra{rows, []any{&last}})
+		if err != nil {
+			return nil, 0, err
+		}
+		mediaList = append(mediaList, media)
+	}
+
+	return mediaList, last, rows.Err()
+}
+
+func albumColumns() string {
+	return fmt.Sprintf(
+		"a.id, a.owner_id, a.title, a.description, a.cover_media_id, a.created_at, a.updated_at, (SELECT count(*) FROM %s WHERE album_id = a.id)",
+		models.AlbumMediaTable,
+	)
+}
+
+func scanAlbum(row scanner) (*models.Album, error) {
+	album := &models.Album{}
+	var cover sql.NullString
+	err := row.Scan(
+		&album.ID,
+		&album.OwnerID,
+		&album.Title,
+		&album.Description,
+		&cover,
+		&album.CreatedAt,
+		&album.UpdatedAt,
+		&album.MediaCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	album.CoverMediaID = cover.String
+	return album, nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"testing"
+	"time"
+)
+
+func TestAlbumListMediaReturnsCursor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	rows := sqlmock.NewRows(append(mediaRowColumns, "position"))
+	for i, id := range []string{"m1", "m2"} {
+		rows.AddRow(id, "", "", "image/png", "o/"+id, "o", false, models.MediaStateActive, "", now, 1, 1, []byte("{}"), now, 1, []byte("{}"), 4+i*3)
+	}
+	mock.ExpectQuery(`WHERE am.album_id = \$1 AND am.position > \$2\s+ORDER BY am.position, am.added_at LIMIT \$3`).
+		WithArgs("a1", 3, 2).
+		WillReturnRows(rows)
+
+	media, last, err := NewAlbum(db, &models.Options{}).ListMedia(t.Context(), "a1", 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(media) != 2 || media[0].ID != "m1" || media[1].ID != "m2" || last != 7 {
+		t.Errorf("ListMedia() = %d media, cursor %d; want m1, m2 and cursor 7", len(media), last)
+	}
+}
+
+func TestAlbumListMediaEmptyPageKeepsCursor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("FROM album_media am").WillReturnRows(sqlmock.NewRows(append(mediaRowColumns, "position")))
+
+	media, last, err := NewAlbum(db, &models.Options{}).ListMedia(t.Context(), "a1", 9, 2)
+	if err != nil || len(media) != 0 || last != 9 {
+		t.Errorf("ListMedia() = %d media, cursor %d, %v; want none and cursor 9", len(media), last, err)
+	}
+}
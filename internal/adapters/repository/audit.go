@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/co1seam/ember-backend-media/internal/adapters/tracing"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"time"
+)
+
+const auditColumns = "id, action, media_id, owner_id, actor, client_ip, request_id, changes, created_at"
+
+type Audit struct {
+	db   *sql.DB
+	opts *models.Options
+}
+
+func NewAudit(db *sql.DB, opts *models.Options) ports.IAuditRepo {
+	return &Audit{
+		db:   db,
+		opts: opts,
+	}
+}
+
+func (a *Audit) Add(ctx context.Context, event *models.AuditEvent) (err error) {
+	ctx, span := startQuerySpan(ctx, "INSERT", models.AuditEventsTable)
+	defer tracing.Finish(span, &err)
+
+	changes, err := json.Marshal(event.Changes)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (action, media_id, owner_id, actor, client_ip, request_id, changes) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at",
+		models.AuditEventsTable,
+	)
+
+	return conn(ctx, a.db).QueryRowContext(
+		ctx,
+		query,
+		event.Action,
+		event.MediaID,
+		event.OwnerID,
+		event.Actor,
+		event.ClientIP,
+		event.RequestID,
+		changes,
+	).Scan(&event.ID, &event.CreatedAt)
+}
+
+// List returns the events matching query, newest first.
+func (a *Audit) List(ctx context.Context, query *models.AuditQuery) (_ []*models.AuditEvent, err error) {
+	ctx, span := startQuerySpan(ctx, "SELECT", models.AuditEventsTable)
+	defer tracing.Finish(span, &err)
+
+	sqlQuery := fmt.Sprintf(
+		`SELECT %s FROM %s
+		WHERE ($1::uuid IS NULL OR media_id = $1)
+			AND ($2::text IS NULL OR actor = $2)
+			AND ($3::timestamptz IS NULL OR created_at >= $3)
+			AND ($4::timestamptz IS NULL OR created_at < $4)
+			AND ($5::bigint IS NULL OR id < $5)
+		ORDER BY id DESC
+		LIMIT $6`,
+		auditColumns,
+		models.AuditEventsTable,
+	)
+
+	rows, err := conn(ctx, a.db).QueryContext(
+		ctx,
+		sqlQuery,
+		nullString(query.MediaID),
+		nullString(query.Actor),
+		nullTime(query.Since),
+		nullTime(query.Until),
+		sql.NullInt64{Int64: query.BeforeID, Valid: query.BeforeID > 0},
+		query.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*models.AuditEvent
+	for rows.Next() {
+		event := &models.AuditEvent{}
+		var changes []byte
+		err := rows.Scan(
+			&event.ID,
+			&event.Action,
+			&event.MediaID,
+			&event.OwnerID,
+			&event.Actor,
+			&event.ClientIP,
+			&event.RequestID,
+			&changes,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(changes, &event.Changes); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+func (a *Audit) DeleteBefore(ctx context.Context, before time.Time) (_ int64, err error) {
+	ctx, span := startQuerySpan(ctx, "DELETE", models.AuditEventsTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE created_at < $1",
+		models.AuditEventsTable,
+	)
+
+	res, err := conn(ctx, a.db).ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (a *Audit) CountBefore(ctx context.Context, before time.Time) (_ int64, err error) {
+	ctx, span := startQuerySpan(ctx, "SELECT", models.AuditEventsTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"SELECT count(*) FROM %s WHERE created_at < $1",
+		models.AuditEventsTable,
+	)
+
+	var count int64
+	err = conn(ctx, a.db).QueryRowContext(ctx, query, before).Scan(&count)
+	return count, err
+}
+
+// ScrubOwner clears the recorded field changes and client addresses of the
+// owner's events. The rows themselves stay, so the trail of who did what
+// and when survives an erasure.
+func (a *Audit) ScrubOwner(ctx context.Context, ownerID string) (_ int64, err error) {
+	ctx, span := startQuerySpan(ctx, "UPDATE", models.AuditEventsTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET changes = '{}', client_ip = '' WHERE owner_id = $1 AND (changes <> '{}' OR client_ip <> '')",
+		models.AuditEventsTable,
+	)
+
+	res, err := conn(ctx, a.db).ExecContext(ctx, query, ownerID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
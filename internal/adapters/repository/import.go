@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/co1seam/ember-backend-media/internal/adapters/tracing"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+)
+
+const importJobColumns = "id, owner_id, source, location, prefix, state, cursor, imported, failed, bytes, error, created_at, updated_at, finished_at"
+
+type Import struct {
+	db   *sql.DB
+	opts *models.Options
+}
+
+func NewImport(db *sql.DB, opts *models.Options) ports.IImportRepo {
+	return &Import{
+		db:   db,
+		opts: opts,
+	}
+}
+
+func (i *Import) CreateJob(ctx context.Context, job *models.ImportJob) (err error) {
+	ctx, span := startQuerySpan(ctx, "INSERT", models.ImportJobsTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)",
+		models.ImportJobsTable,
+		importJobColumns,
+	)
+
+	_, err = conn(ctx, i.db).ExecContext(
+		ctx,
+		query,
+		job.ID,
+		job.OwnerID,
+		job.Source,
+		job.Location,
+		job.Prefix,
+		job.State,
+		job.Cursor,
+		job.Imported,
+		job.Failed,
+		job.Bytes,
+		job.Error,
+		job.CreatedAt,
+		job.UpdatedAt,
+		job.FinishedAt,
+	)
+	return err
+}
+
+func (i *Import) GetJob(ctx context.Context, id string) (_ *models.ImportJob, err error) {
+	ctx, span := startQuerySpan(ctx, "SELECT", models.ImportJobsTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE id = $1",
+		importJobColumns,
+		models.ImportJobsTable,
+	)
+
+	return scanImportJob(conn(ctx, i.db).QueryRowContext(ctx, query, id))
+}
+
+func (i *Import) ListByOwner(ctx context.Context, ownerID string) (_ []*models.ImportJob, err error) {
+	ctx, span := startQuerySpan(ctx, "SELECT", models.ImportJobsTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE owner_id = $1 ORDER BY created_at",
+		importJobColumns,
+		models.ImportJobsTable,
+	)
+
+	rows, err := conn(ctx, i.db).QueryContext(ctx, query, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*models.ImportJob
+	for rows.Next() {
+		job, err := scanImportJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+func (i *Import) DeleteByOwner(ctx context.Context, ownerID string) (_ int64, err error) {
+	ctx, span := startQuerySpan(ctx, "DELETE", models.ImportJobsTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE owner_id = $1",
+		models.ImportJobsTable,
+	)
+
+	result, err := conn(ctx, i.db).ExecContext(ctx, query, ownerID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func scanImportJob(row scanner) (*models.ImportJob, error) {
+	job := &models.ImportJob{}
+	var finishedAt sql.NullTime
+	err := row.Scan(
+		&job.ID,
+		&job.OwnerID,
+		&job.Source,
+		&job.Location,
+		&job.Prefix,
+		&job.State,
+		&job.Cursor,
+		&job.Imported,
+		&job.Failed,
+		&job.Bytes,
+		&job.Error,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+		&finishedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+
+	return job, nil
+}
+
+// UpdateJob saves the progress of a job: its state, cursor and counters.
+func (i *Import) UpdateJob(ctx context.Context, job *models.ImportJob) (err error) {
+	ctx, span := startQuerySpan(ctx, "UPDATE", models.ImportJobsTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET state = $1, cursor = $2, imported = $3, failed = $4, bytes = $5, error = $6, updated_at = $7, finished_at = $8 WHERE id = $9",
+		models.ImportJobsTable,
+	)
+
+	_, err = conn(ctx, i.db).ExecContext(
+		ctx,
+		query,
+		job.State,
+		job.Cursor,
+		job.Imported,
+		job.Failed,
+		job.Bytes,
+		job.Error,
+		job.UpdatedAt,
+		job.FinishedAt,
+		job.ID,
+	)
+	return err
+}
+
+// AddItem records the outcome for one source file. A retried file replaces
+// its earlier outcome.
+func (i *Import) AddItem(ctx context.Context, item *models.ImportItem) (err error) {
+	ctx, span := startQuerySpan(ctx, "INSERT", models.ImportItemsTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		`INSERT INTO %s (job_id, source_key, media_id, size_bytes, error, created_at) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (job_id, source_key) DO UPDATE SET media_id = EXCLUDED.media_id, size_bytes = EXCLUDED.size_bytes, error = EXCLUDED.error, created_at = EXCLUDED.created_at`,
+		models.ImportItemsTable,
+	)
+
+	_, err = conn(ctx, i.db).ExecContext(
+		ctx,
+		query,
+		item.JobID,
+		item.SourceKey,
+		nullString(item.MediaID),
+		item.Size,
+		item.Error,
+		item.CreatedAt,
+	)
+	return err
+}
+
+func (i *Import) ListFailures(ctx context.Context, jobID string, limit int) (_ []*models.ImportItem, err error) {
+	ctx, span := startQuerySpan(ctx, "SELECT", models.ImportItemsTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"SELECT job_id, source_key, size_bytes, error, created_at FROM %s WHERE job_id = $1 AND error <> '' ORDER BY source_key LIMIT $2",
+		models.ImportItemsTable,
+	)
+
+	rows, err := conn(ctx, i.db).QueryContext(ctx, query, jobID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*models.ImportItem
+	for rows.Next() {
+		item := &models.ImportItem{}
+		if err := rows.Scan(&item.JobID, &item.SourceKey, &item.Size, &item.Error, &item.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
@@ -3,16 +3,37 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"github.com/co1seam/ember-backend-media/internal/adapters/tracing"
 	"github.com/co1seam/ember-backend-media/internal/core/models"
 	"github.com/co1seam/ember-backend-media/internal/ports"
+	"github.com/lib/pq"
+	"slices"
+	"strings"
 )
 
+const mediaColumns = "id, title, description, content_type, storage_path, owner_id, streaming_optimized, state, quarantine_reason, created_at, size_bytes, current_version, metadata, updated_at, revision"
+
+// mediaSelectColumns adds the tag names to mediaColumns; scanMedia expects it.
+var mediaSelectColumns = mediaColumns + fmt.Sprintf(
+	", ARRAY(SELECT t.name FROM %s mt JOIN %s t ON t.id = mt.tag_id WHERE mt.media_id = %s.id ORDER BY t.name)",
+	models.MediaTagsTable,
+	models.TagsTable,
+	models.MediaTable,
+)
+
+const bumpRevision = "updated_at = CURRENT_TIMESTAMP, revision = revision + 1"
+
 type Media struct {
 	db   *sql.DB
 	opts *models.Options
 }
 
+type scanner interface {
+	Scan(dest ...any) error
+}
+
 func NewMedia(db *sql.DB, opts *models.Options) ports.IMediaRepo {
 	return &Media{
 		db:   db,
@@ -20,13 +41,22 @@ func NewMedia(db *sql.DB, opts *models.Options) ports.IMediaRepo {
 	}
 }
 
-func (m *Media) Create(ctx context.Context, media *models.Media) error {
+func (m *Media) Create(ctx context.Context, media *models.Media) (err error) {
+	ctx, span := startQuerySpan(ctx, "INSERT", models.MediaTable)
+	defer tracing.Finish(span, &err)
+
+	metadata, err := marshalMetadata(media.Metadata)
+	if err != nil {
+		return err
+	}
+
 	query := fmt.Sprintf(
-		"INSERT INTO %s (id, title, description, content_type, storage_path, owner_id, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		"INSERT INTO %s (%s) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)",
 		models.MediaTable,
+		mediaColumns,
 	)
 
-	_, err := m.db.ExecContext(
+	_, err = conn(ctx, m.db).ExecContext(
 		ctx,
 		query,
 		media.ID,
@@ -35,73 +65,393 @@ func (m *Media) Create(ctx context.Context, media *models.Media) error {
 		media.ContentType,
 		media.StoragePath,
 		media.OwnerID,
+		media.StreamingOptimized,
+		media.State,
+		media.QuarantineReason,
 		media.CreatedAt,
+		sizeOf(media),
+		media.Version,
+		metadata,
+		media.UpdatedAt,
+		media.Revision,
 	)
 	return err
 }
 
-func (m *Media) GetByID(ctx context.Context, id string) (*models.Media, error) {
+func (m *Media) GetByID(ctx context.Context, id string) (_ *models.Media, err error) {
+	ctx, span := startQuerySpan(ctx, "SELECT", models.MediaTable)
+	defer tracing.Finish(span, &err)
+
 	query := fmt.Sprintf(
-		"SELECT id, title, description, content_type, storage_path, owner_id, created_at FROM %s WHERE id = $1",
+		"SELECT %s FROM %s WHERE id = $1",
+		mediaSelectColumns,
 		models.MediaTable,
 	)
 
-	row := m.db.QueryRowContext(ctx, query, id)
+	return scanMedia(conn(ctx, m.db).QueryRowContext(ctx, query, id))
+}
 
-	media := &models.Media{}
-	err := row.Scan(
-		&media.ID,
-		&media.Title,
-		&media.Description,
-		&media.ContentType,
-		&media.StoragePath,
-		&media.OwnerID,
-		&media.CreatedAt,
+// GetForUpdate reads the row and locks it until the surrounding transaction
+// ends, so checks made against it still hold when the change is written.
+func (m *Media) GetForUpdate(ctx context.Context, id string) (_ *models.Media, err error) {
+	ctx, span := startQuerySpan(ctx, "SELECT", models.MediaTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE id = $1 FOR UPDATE",
+		mediaSelectColumns,
+		models.MediaTable,
 	)
 
-	if err != nil {
-		return nil, err
-	}
-	return media, nil
+	return scanMedia(conn(ctx, m.db).QueryRowContext(ctx, query, id))
 }
 
-func (m *Media) Update(ctx context.Context, media *models.Media) error {
+// Update writes the columns the file flows change, leaving the title,
+// description and owner alone, and only while the stored revision is still
+// media.Revision. sql.ErrNoRows means it was not.
+func (m *Media) Update(ctx context.Context, media *models.Media) (err error) {
+	ctx, span := startQuerySpan(ctx, "UPDATE", models.MediaTable)
+	defer tracing.Finish(span, &err)
+
 	query := fmt.Sprintf(
-		"UPDATE %s SET title = $1, description = $2, content_type = $3, storage_path = $4, owner_id = $5, created_at = $6 WHERE id = $7",
+		"UPDATE %s SET content_type = $1, storage_path = $2, streaming_optimized = $3, state = $4, quarantine_reason = $5, size_bytes = $6, current_version = $7, %s WHERE id = $8 AND revision = $9 RETURNING updated_at, revision",
 		models.MediaTable,
+		bumpRevision,
 	)
 
-	_, err := m.db.ExecContext(
+	return conn(ctx, m.db).QueryRowContext(
 		ctx,
 		query,
-		media.Title,
-		media.Description,
 		media.ContentType,
 		media.StoragePath,
-		media.OwnerID,
-		media.CreatedAt,
+		media.StreamingOptimized,
+		media.State,
+		media.QuarantineReason,
+		sizeOf(media),
+		media.Version,
 		media.ID,
+		media.Revision,
+	).Scan(&media.UpdatedAt, &media.Revision)
+}
+
+// MarkStreamingOptimized records a faststart remux of the object at
+// storagePath. Only the size and the flag are written, and only while the
+// media is active and still points to storagePath; sql.ErrNoRows means it
+// no longer does.
+func (m *Media) MarkStreamingOptimized(ctx context.Context, id, storagePath string, size int64) (_ *models.Media, err error) {
+	ctx, span := startQuerySpan(ctx, "UPDATE", models.MediaTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET streaming_optimized = TRUE, size_bytes = $1, %s WHERE id = $2 AND storage_path = $3 AND state = $4 RETURNING %s",
+		models.MediaTable,
+		bumpRevision,
+		mediaSelectColumns,
 	)
+
+	return scanMedia(conn(ctx, m.db).QueryRowContext(ctx, query, size, id, storagePath, models.MediaStateActive))
+}
+
+// GetByIDs returns the media among ids that exist, in no particular order.
+func (m *Media) GetByIDs(ctx context.Context, ids []string) (_ []*models.Media, err error) {
+	ctx, span := startQuerySpan(ctx, "SELECT", models.MediaTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE id = ANY($1::uuid[])",
+		mediaSelectColumns,
+		models.MediaTable,
+	)
+
+	rows, err := conn(ctx, m.db).QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mediaList []*models.Media
+	for rows.Next() {
+		media, err := scanMedia(rows)
+		if err != nil {
+			return nil, err
+		}
+		mediaList = append(mediaList, media)
+	}
+
+	return mediaList, rows.Err()
+}
+
+// UpdateFields writes only the columns behind fields, and only while the
+// stored revision is still revision. sql.ErrNoRows means it was not.
+func (m *Media) UpdateFields(ctx context.Context, media *models.Media, fields []string, revision int64) (err error) {
+	ctx, span := startQuerySpan(ctx, "UPDATE", models.MediaTable)
+	defer tracing.Finish(span, &err)
+
+	var sets []string
+	var args []any
+	for _, field := range fields {
+		var value any
+		switch field {
+		case models.MediaFieldTitle:
+			value = media.Title
+		case models.MediaFieldDescription:
+			value = media.Description
+		default:
+			return fmt.Errorf("unknown media field %q", field)
+		}
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", field, len(args)))
+	}
+	sets = append(sets, bumpRevision)
+	args = append(args, media.ID, revision)
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET %s WHERE id = $%d AND revision = $%d RETURNING updated_at, revision",
+		models.MediaTable,
+		strings.Join(sets, ", "),
+		len(args)-1,
+		len(args),
+	)
+
+	return conn(ctx, m.db).QueryRowContext(ctx, query, args...).Scan(&media.UpdatedAt, &media.Revision)
+}
+
+// Touch bumps the revision of media whose tags or metadata changed.
+func (m *Media) Touch(ctx context.Context, id string) (err error) {
+	ctx, span := startQuerySpan(ctx, "UPDATE", models.MediaTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET %s WHERE id = $1",
+		models.MediaTable,
+		bumpRevision,
+	)
+
+	_, err = conn(ctx, m.db).ExecContext(ctx, query, id)
 	return err
 }
 
-func (m *Media) Delete(ctx context.Context, id string) error {
+// UpdateFieldsBatch applies all patches in one statement and returns the IDs
+// it updated; a patch whose revision no longer matches is skipped.
+func (m *Media) UpdateFieldsBatch(ctx context.Context, patches []*models.MediaPatch) (_ []string, err error) {
+	ctx, span := startQuerySpan(ctx, "UPDATE", models.MediaTable)
+	defer tracing.Finish(span, &err)
+
+	var (
+		ids, titles, descriptions []string
+		setTitle, setDescription  []bool
+		revisions                 []int64
+	)
+	byID := make(map[string]*models.Media, len(patches))
+	for _, patch := range patches {
+		ids = append(ids, patch.Media.ID)
+		titles = append(titles, patch.Media.Title)
+		descriptions = append(descriptions, patch.Media.Description)
+		setTitle = append(setTitle, slices.Contains(patch.Fields, models.MediaFieldTitle))
+		setDescription = append(setDescription, slices.Contains(patch.Fields, models.MediaFieldDescription))
+		revisions = append(revisions, patch.Revision)
+		byID[patch.Media.ID] = patch.Media
+	}
+
+	query := fmt.Sprintf(
+		`UPDATE %s m SET
+			title = CASE WHEN u.set_title THEN u.title ELSE m.title END,
+			description = CASE WHEN u.set_description THEN u.description ELSE m.description END,
+			updated_at = CURRENT_TIMESTAMP, revision = m.revision + 1
+		FROM unnest($1::uuid[], $2::text[], $3::text[], $4::bool[], $5::bool[], $6::bigint[])
+			AS u(id, title, description, set_title, set_description, revision)
+		WHERE m.id = u.id AND m.revision = u.revision
+		RETURNING m.id, m.updated_at, m.revision`,
+		models.MediaTable,
+	)
+
+	rows, err := conn(ctx, m.db).QueryContext(
+		ctx,
+		query,
+		pq.Array(ids),
+		pq.Array(titles),
+		pq.Array(descriptions),
+		pq.Array(setTitle),
+		pq.Array(setDescription),
+		pq.Array(revisions),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var updated []string
+	for rows.Next() {
+		var id string
+		var media models.Media
+		if err := rows.Scan(&id, &media.UpdatedAt, &media.Revision); err != nil {
+			return nil, err
+		}
+		if target, ok := byID[id]; ok {
+			target.UpdatedAt = media.UpdatedAt
+			target.Revision = media.Revision
+		}
+		updated = append(updated, id)
+	}
+
+	return updated, rows.Err()
+}
+
+func (m *Media) Delete(ctx context.Context, id string) (err error) {
+	ctx, span := startQuerySpan(ctx, "DELETE", models.MediaTable)
+	defer tracing.Finish(span, &err)
+
 	query := fmt.Sprintf(
 		"DELETE FROM %s WHERE id = $1",
 		models.MediaTable,
 	)
 
-	_, err := m.db.ExecContext(ctx, query, id)
+	_, err = conn(ctx, m.db).ExecContext(ctx, query, id)
+	return err
+}
+
+// DeleteByIDs deletes the given media in one statement and returns the IDs
+// that existed.
+func (m *Media) DeleteByIDs(ctx context.Context, ids []string) (_ []string, err error) {
+	ctx, span := startQuerySpan(ctx, "DELETE", models.MediaTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE id = ANY($1::uuid[]) RETURNING id",
+		models.MediaTable,
+	)
+
+	rows, err := conn(ctx, m.db).QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deleted []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		deleted = append(deleted, id)
+	}
+
+	return deleted, rows.Err()
+}
+
+// ListByOwner returns the newest media of an owner carrying all of req.Tags
+// and whose metadata contains every pair of req.Metadata.
+func (m *Media) ListByOwner(ctx context.Context, req *models.ListMediaRequest) (_ []*models.Media, err error) {
+	ctx, span := startQuerySpan(ctx, "SELECT", models.MediaTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		`SELECT %[1]s FROM %[2]s
+		WHERE owner_id = $1
+			AND ($2::text[] IS NULL OR (
+				SELECT count(*) FROM %[3]s mt JOIN %[4]s t ON t.id = mt.tag_id
+				WHERE mt.media_id = %[2]s.id AND t.name = ANY($2)
+			) = cardinality($2))
+			AND metadata @> $3::jsonb
+		ORDER BY created_at DESC LIMIT $4`,
+		mediaSelectColumns,
+		models.MediaTable,
+		models.MediaTagsTable,
+		models.TagsTable,
+	)
+
+	var tags any
+	if len(req.Tags) > 0 {
+		tags = pq.Array(req.Tags)
+	}
+
+	metadata, err := marshalMetadata(req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn(ctx, m.db).QueryContext(ctx, query, req.OwnerID, tags, metadata, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mediaList []*models.Media
+	for rows.Next() {
+		media, err := scanMedia(rows)
+		if err != nil {
+			return nil, err
+		}
+		mediaList = append(mediaList, media)
+	}
+
+	return mediaList, rows.Err()
+}
+
+func (m *Media) ListByState(ctx context.Context, state string, limit int) (_ []*models.Media, err error) {
+	ctx, span := startQuerySpan(ctx, "SELECT", models.MediaTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE state = $1 ORDER BY created_at DESC LIMIT $2",
+		mediaSelectColumns,
+		models.MediaTable,
+	)
+
+	rows, err := conn(ctx, m.db).QueryContext(ctx, query, state, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mediaList []*models.Media
+	for rows.Next() {
+		media, err := scanMedia(rows)
+		if err != nil {
+			return nil, err
+		}
+		mediaList = append(mediaList, media)
+	}
+
+	return mediaList, rows.Err()
+}
+
+// PatchMetadata merges set into the metadata and drops the remove keys in a
+// single statement, so concurrent patches of different keys both apply.
+func (m *Media) PatchMetadata(ctx context.Context, id string, set map[string]string, remove []string) (err error) {
+	ctx, span := startQuerySpan(ctx, "UPDATE", models.MediaTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET metadata = (metadata || $1::jsonb) - $2::text[] WHERE id = $3",
+		models.MediaTable,
+	)
+
+	patch, err := marshalMetadata(set)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn(ctx, m.db).ExecContext(ctx, query, patch, pq.Array(remove), id)
 	return err
 }
 
-func (m *Media) ListByOwner(ctx context.Context, ownerID string, limit int) ([]*models.Media, error) {
+// ListPage walks all media in id order, starting after afterID.
+func (m *Media) ListPage(ctx context.Context, afterID string, limit int) (_ []*models.Media, err error) {
+	ctx, span := startQuerySpan(ctx, "SELECT", models.MediaTable)
+	defer tracing.Finish(span, &err)
+
 	query := fmt.Sprintf(
-		"SELECT id, title, description, content_type, storage_path, owner_id, created_at FROM %s WHERE owner_id = $1 ORDER BY created_at DESC LIMIT $2",
+		"SELECT %s FROM %s WHERE id > $1 ORDER BY id LIMIT $2",
+		mediaSelectColumns,
 		models.MediaTable,
 	)
 
-	rows, err := m.db.QueryContext(ctx, query, ownerID, limit)
+	if afterID == "" {
+		afterID = "00000000-0000-0000-0000-000000000000"
+	}
+
+	rows, err := conn(ctx, m.db).QueryContext(ctx, query, afterID, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -109,21 +459,154 @@ func (m *Media) ListByOwner(ctx context.Context, ownerID string, limit int) ([]*
 
 	var mediaList []*models.Media
 	for rows.Next() {
-		media := &models.Media{}
-		err := rows.Scan(
-			&media.ID,
-			&media.Title,
-			&media.Description,
-			&media.ContentType,
-			&media.StoragePath,
-			&media.OwnerID,
-			&media.CreatedAt,
-		)
+		media, err := scanMedia(rows)
 		if err != nil {
 			return nil, err
 		}
 		mediaList = append(mediaList, media)
 	}
 
-	return mediaList, nil
+	return mediaList, rows.Err()
+}
+
+// ListOwnerPage walks the media of one owner in id order, starting after
+// afterID.
+func (m *Media) ListOwnerPage(ctx context.Context, ownerID, afterID string, limit int) (_ []*models.Media, err error) {
+	ctx, span := startQuerySpan(ctx, "SELECT", models.MediaTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE owner_id = $1 AND id > $2 ORDER BY id LIMIT $3",
+		mediaSelectColumns,
+		models.MediaTable,
+	)
+
+	if afterID == "" {
+		afterID = "00000000-0000-0000-0000-000000000000"
+	}
+
+	rows, err := conn(ctx, m.db).QueryContext(ctx, query, ownerID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mediaList []*models.Media
+	for rows.Next() {
+		media, err := scanMedia(rows)
+		if err != nil {
+			return nil, err
+		}
+		mediaList = append(mediaList, media)
+	}
+
+	return mediaList, rows.Err()
+}
+
+// DeleteByOwner deletes all media of an owner; their versions, tags and album
+// entries go with them.
+func (m *Media) DeleteByOwner(ctx context.Context, ownerID string) (_ int64, err error) {
+	ctx, span := startQuerySpan(ctx, "DELETE", models.MediaTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE owner_id = $1",
+		models.MediaTable,
+	)
+
+	result, err := conn(ctx, m.db).ExecContext(ctx, query, ownerID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (m *Media) ListMissingSize(ctx context.Context, afterID string, limit int) (_ []*models.Media, err error) {
+	ctx, span := startQuerySpan(ctx, "SELECT", models.MediaTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE size_bytes IS NULL AND storage_path <> '' AND id > $1 ORDER BY id LIMIT $2",
+		mediaSelectColumns,
+		models.MediaTable,
+	)
+
+	if afterID == "" {
+		afterID = "00000000-0000-0000-0000-000000000000"
+	}
+
+	rows, err := conn(ctx, m.db).QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mediaList []*models.Media
+	for rows.Next() {
+		media, err := scanMedia(rows)
+		if err != nil {
+			return nil, err
+		}
+		mediaList = append(mediaList, media)
+	}
+
+	return mediaList, rows.Err()
+}
+
+func (m *Media) SetSize(ctx context.Context, id string, size int64) (err error) {
+	ctx, span := startQuerySpan(ctx, "UPDATE", models.MediaTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET size_bytes = $1 WHERE id = $2",
+		models.MediaTable,
+	)
+
+	_, err = conn(ctx, m.db).ExecContext(ctx, query, size, id)
+	return err
+}
+
+// sizeOf stores NULL until a file was uploaded, so backfills can find rows
+// whose size was never recorded.
+func sizeOf(media *models.Media) sql.NullInt64 {
+	return sql.NullInt64{Int64: media.Size, Valid: media.StoragePath != "" && media.Size > 0}
+}
+
+func marshalMetadata(metadata map[string]string) ([]byte, error) {
+	if metadata == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(metadata)
+}
+
+func scanMedia(row scanner) (*models.Media, error) {
+	media := &models.Media{}
+	var size sql.NullInt64
+	var metadata []byte
+	err := row.Scan(
+		&media.ID,
+		&media.Title,
+		&media.Description,
+		&media.ContentType,
+		&media.StoragePath,
+		&media.OwnerID,
+		&media.StreamingOptimized,
+		&media.State,
+		&media.QuarantineReason,
+		&media.CreatedAt,
+		&size,
+		&media.Version,
+		&metadata,
+		&media.UpdatedAt,
+		&media.Revision,
+		pq.Array(&media.Tags),
+	)
+	if err != nil {
+		return nil, err
+	}
+	media.Size = size.Int64
+	if err := json.Unmarshal(metadata, &media.Metadata); err != nil {
+		return nil, err
+	}
+	return media, nil
 }
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/lib/pq"
+	"regexp"
+	"testing"
+	"time"
+)
+
+var mediaRowColumns = []string{
+	"id", "title", "description", "content_type", "storage_path", "owner_id", "streaming_optimized",
+	"state", "quarantine_reason", "created_at", "size_bytes", "version", "metadata", "updated_at", "revision", "tags",
+}
+
+func TestMarkStreamingOptimizedWritesOnlyFlagAndSize(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE media SET streaming_optimized = TRUE, size_bytes = $1, "+bumpRevision+" WHERE id = $2 AND storage_path = $3 AND state = $4 RETURNING")).
+		WithArgs(int64(42), "m1", "o/m1/1/a.mp4", models.MediaStateActive).
+		WillReturnRows(sqlmock.NewRows(mediaRowColumns).AddRow(
+			"m1", "renamed", "", "video/mp4", "o/m1/1/a.mp4", "o", true,
+			models.MediaStateActive, "", now, 42, 1, []byte("{}"), now, 5, []byte("{clip}"),
+		))
+
+	media, err := NewMedia(db, &models.Options{}).MarkStreamingOptimized(t.Context(), "m1", "o/m1/1/a.mp4", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !media.StreamingOptimized || media.Size != 42 || media.Title != "renamed" || media.Revision != 5 {
+		t.Errorf("media = %+v, want the stored row back", media)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMarkStreamingOptimizedReplacedFile(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("UPDATE media SET streaming_optimized").WillReturnRows(sqlmock.NewRows(mediaRowColumns))
+
+	_, err = NewMedia(db, &models.Options{}).MarkStreamingOptimized(t.Context(), "m1", "o/m1/1/a.mp4", 42)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("MarkStreamingOptimized = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestGetByIDsSingleQuery(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	ids := []string{"m1", "m2", "gone"}
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE id = ANY($1::uuid[])")).
+		WithArgs(pq.Array(ids)).
+		WillReturnRows(sqlmock.NewRows(mediaRowColumns).
+			AddRow("m2", "", "", "image/png", "o/m2", "o", false, models.MediaStateActive, "", now, nil, 1, []byte("{}"), now, 1, []byte("{}")).
+			AddRow("m1", "", "", "image/png", "o/m1", "o", false, models.MediaStateActive, "", now, 3, 1, []byte("{}"), now, 1, []byte("{}")))
+
+	media, err := NewMedia(db, &models.Options{}).GetByIDs(t.Context(), ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(media) != 2 || media[0].ID != "m2" || media[1].Size != 3 {
+		t.Errorf("GetByIDs() = %d media, want the two that exist", len(media))
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetForUpdateLocksRow(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE id = $1 FOR UPDATE")).
+		WithArgs("m1").
+		WillReturnRows(sqlmock.NewRows(mediaRowColumns).
+			AddRow("m1", "", "", "image/png", "o/m1", "o", false, models.MediaStateActive, "", now, nil, 1, []byte(`{"camera":"x100v"}`), now, 2, []byte("{}")))
+
+	media, err := NewMedia(db, &models.Options{}).GetForUpdate(t.Context(), "m1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if media.ID != "m1" || media.Metadata["camera"] != "x100v" {
+		t.Errorf("GetForUpdate() = %+v", media)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUpdateFieldsBatchSkipsStaleRevisions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	first := &models.Media{ID: "m1", Title: "uno", Description: "kept"}
+	second := &models.Media{ID: "m2", Title: "dos", Description: "new"}
+	patches := []*models.MediaPatch{
+		{Media: first, Fields: []string{models.MediaFieldTitle}, Revision: 1},
+		{Media: second, Fields: []string{models.MediaFieldTitle, models.MediaFieldDescription}, Revision: 4},
+	}
+
+	now := time.Now()
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE m.id = u.id AND m.revision = u.revision")).
+		WithArgs(
+			pq.Array([]string{"m1", "m2"}),
+			pq.Array([]string{"uno", "dos"}),
+			pq.Array([]string{"kept", "new"}),
+			pq.Array([]bool{true, true}),
+			pq.Array([]bool{false, true}),
+			pq.Array([]int64{1, 4}),
+		).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "updated_at", "revision"}).AddRow("m1", now, 2))
+
+	updated, err := NewMedia(db, &models.Options{}).UpdateFieldsBatch(t.Context(), patches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updated) != 1 || updated[0] != "m1" {
+		t.Errorf("updated = %v, want only m1", updated)
+	}
+	if first.Revision != 2 || !first.UpdatedAt.Equal(now) || second.Revision != 0 {
+		t.Errorf("revisions = %d, %d; want only the applied patch refreshed", first.Revision, second.Revision)
+	}
+}
+
+func TestDeleteByIDsReturnsDeleted(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("DELETE FROM media WHERE id = ANY($1::uuid[]) RETURNING id")).
+		WithArgs(pq.Array([]string{"m1", "gone"})).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("m1"))
+
+	deleted, err := NewMedia(db, &models.Options{}).DeleteByIDs(t.Context(), []string{"m1", "gone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0] != "m1" {
+		t.Errorf("deleted = %v, want m1", deleted)
+	}
+}
@@ -0,0 +1 @@
+ALTER TABLE media ADD COLUMN IF NOT EXISTS streaming_optimized BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_media_state;
+
+ALTER TABLE media DROP COLUMN IF EXISTS quarantine_reason;
+
+ALTER TABLE media DROP COLUMN IF EXISTS state;
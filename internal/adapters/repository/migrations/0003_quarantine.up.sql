@@ -0,0 +1,5 @@
+ALTER TABLE media ADD COLUMN IF NOT EXISTS state VARCHAR(20) NOT NULL DEFAULT 'active';
+
+ALTER TABLE media ADD COLUMN IF NOT EXISTS quarantine_reason TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_media_state ON media(state) WHERE state <> 'active';
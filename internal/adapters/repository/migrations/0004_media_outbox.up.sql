@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS media_outbox (
+    id UUID PRIMARY KEY,
+    event_type VARCHAR(64) NOT NULL,
+    schema_version INTEGER NOT NULL,
+    aggregate_id UUID NOT NULL,
+    owner_id TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    published_at TIMESTAMP WITH TIME ZONE,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    dead_lettered_at TIMESTAMP WITH TIME ZONE
+    );
+
+CREATE INDEX IF NOT EXISTS idx_media_outbox_pending ON media_outbox(next_attempt_at) WHERE published_at IS NULL AND dead_lettered_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_media_outbox_aggregate ON media_outbox(aggregate_id, occurred_at) WHERE published_at IS NULL AND dead_lettered_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_media_outbox_dead ON media_outbox(dead_lettered_at) WHERE dead_lettered_at IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_media_outbox_published ON media_outbox(published_at) WHERE published_at IS NOT NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_media_size_missing;
+
+ALTER TABLE media DROP COLUMN IF EXISTS size_bytes;
@@ -0,0 +1,3 @@
+ALTER TABLE media ADD COLUMN IF NOT EXISTS size_bytes BIGINT;
+
+CREATE INDEX IF NOT EXISTS idx_media_size_missing ON media(id) WHERE size_bytes IS NULL AND storage_path <> '';
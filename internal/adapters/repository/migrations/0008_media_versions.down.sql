@@ -0,0 +1,5 @@
+ALTER TABLE media DROP COLUMN IF EXISTS last_version;
+
+ALTER TABLE media DROP COLUMN IF EXISTS current_version;
+
+DROP TABLE IF EXISTS media_versions;
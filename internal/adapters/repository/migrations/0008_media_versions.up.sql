@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS media_versions (
+    id UUID PRIMARY KEY,
+    media_id UUID NOT NULL REFERENCES media(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    storage_path TEXT NOT NULL,
+    size_bytes BIGINT,
+    checksum TEXT NOT NULL DEFAULT '',
+    content_type TEXT NOT NULL,
+    uploaded_by TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (media_id, version)
+    );
+
+ALTER TABLE media ADD COLUMN IF NOT EXISTS current_version INTEGER NOT NULL DEFAULT 0;
+
+-- Version numbers come from a per-media counter, so that two concurrent
+-- uploads never pick the same number.
+ALTER TABLE media ADD COLUMN IF NOT EXISTS last_version INTEGER NOT NULL DEFAULT 0;
+
+INSERT INTO media_versions (id, media_id, version, storage_path, size_bytes, content_type, uploaded_by, created_at)
+SELECT md5(id::text || ':1')::uuid, id, 1, storage_path, size_bytes, content_type, owner_id::text, created_at
+FROM media
+WHERE storage_path <> ''
+ON CONFLICT DO NOTHING;
+
+UPDATE media SET current_version = 1, last_version = 1 WHERE storage_path <> '' AND current_version = 0;
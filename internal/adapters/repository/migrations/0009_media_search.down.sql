@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_media_search;
+
+ALTER TABLE media DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_media_metadata;
+
+ALTER TABLE media DROP COLUMN IF EXISTS metadata;
+
+DROP TABLE IF EXISTS media_tags;
+
+DROP TABLE IF EXISTS tags;
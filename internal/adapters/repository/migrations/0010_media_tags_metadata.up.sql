@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS tags (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE
+    );
+
+CREATE TABLE IF NOT EXISTS media_tags (
+    media_id UUID NOT NULL REFERENCES media(id) ON DELETE CASCADE,
+    tag_id BIGINT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (media_id, tag_id)
+    );
+
+CREATE INDEX IF NOT EXISTS idx_media_tags_tag ON media_tags(tag_id);
+
+ALTER TABLE media ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_media_metadata ON media USING GIN (metadata jsonb_path_ops);
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS albums (
+    id UUID PRIMARY KEY,
+    owner_id TEXT NOT NULL,
+    title TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    cover_media_id UUID REFERENCES media(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+    );
+
+CREATE INDEX IF NOT EXISTS idx_albums_owner ON albums(owner_id, created_at);
+
+CREATE TABLE IF NOT EXISTS album_media (
+    album_id UUID NOT NULL REFERENCES albums(id) ON DELETE CASCADE,
+    media_id UUID NOT NULL REFERENCES media(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    added_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (album_id, media_id)
+    );
+
+CREATE INDEX IF NOT EXISTS idx_album_media_position ON album_media(album_id, position);
+
+CREATE INDEX IF NOT EXISTS idx_album_media_media ON album_media(media_id);
@@ -0,0 +1,3 @@
+ALTER TABLE media DROP COLUMN IF EXISTS revision;
+
+ALTER TABLE media DROP COLUMN IF EXISTS updated_at;
@@ -0,0 +1,9 @@
+ALTER TABLE media ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE media SET updated_at = created_at WHERE updated_at IS NULL;
+
+ALTER TABLE media ALTER COLUMN updated_at SET DEFAULT CURRENT_TIMESTAMP;
+
+ALTER TABLE media ALTER COLUMN updated_at SET NOT NULL;
+
+ALTER TABLE media ADD COLUMN IF NOT EXISTS revision BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS import_items;
+
+DROP TABLE IF EXISTS import_jobs;
+
+ALTER TABLE media ALTER COLUMN content_type TYPE VARCHAR(50) USING left(content_type, 50);
@@ -0,0 +1,32 @@
+-- Imported files keep the content type their source recorded, which can be
+-- longer than 50 characters, e.g. with parameters.
+ALTER TABLE media ALTER COLUMN content_type TYPE TEXT;
+
+CREATE TABLE IF NOT EXISTS import_jobs (
+    id UUID PRIMARY KEY,
+    owner_id TEXT NOT NULL,
+    source TEXT NOT NULL,
+    location TEXT NOT NULL,
+    prefix TEXT NOT NULL DEFAULT '',
+    state TEXT NOT NULL,
+    cursor TEXT NOT NULL DEFAULT '',
+    imported INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    bytes BIGINT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP WITH TIME ZONE
+    );
+
+CREATE TABLE IF NOT EXISTS import_items (
+    job_id UUID NOT NULL REFERENCES import_jobs(id) ON DELETE CASCADE,
+    source_key TEXT NOT NULL,
+    media_id UUID,
+    size_bytes BIGINT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (job_id, source_key)
+    );
+
+CREATE INDEX IF NOT EXISTS idx_import_items_failed ON import_items(job_id, source_key) WHERE error <> '';
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS privacy_requests (
+    id UUID PRIMARY KEY,
+    kind TEXT NOT NULL,
+    owner_id TEXT NOT NULL,
+    state TEXT NOT NULL,
+    format TEXT NOT NULL DEFAULT '',
+    storage_path TEXT NOT NULL DEFAULT '',
+    size_bytes BIGINT NOT NULL DEFAULT 0,
+    summary JSONB NOT NULL DEFAULT '{}',
+    requested_by TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP WITH TIME ZONE
+    );
+
+CREATE INDEX IF NOT EXISTS idx_privacy_requests_owner ON privacy_requests(owner_id, created_at);
@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    action TEXT NOT NULL,
+    media_id UUID NOT NULL,
+    owner_id TEXT NOT NULL,
+    actor TEXT NOT NULL,
+    client_ip TEXT NOT NULL DEFAULT '',
+    request_id TEXT NOT NULL DEFAULT '',
+    changes JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+    );
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_media ON audit_events(media_id, id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events(actor, id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events(created_at);
+
+-- Events are only ever added and, once past retention, deleted. The one
+-- permitted update clears the changes and client address of an event when
+-- its owner is erased.
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    IF NEW.changes = '{}'::jsonb AND NEW.client_ip = ''
+        AND (NEW.id, NEW.action, NEW.media_id, NEW.owner_id, NEW.actor, NEW.request_id, NEW.created_at)
+            IS NOT DISTINCT FROM (OLD.id, OLD.action, OLD.media_id, OLD.owner_id, OLD.actor, OLD.request_id, OLD.created_at) THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_no_update
+    BEFORE UPDATE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
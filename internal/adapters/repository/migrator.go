@@ -29,36 +29,48 @@ func NewMigrator() *Migrator {
 }
 
 func (m *Migrator) Up(db *sql.DB) error {
-	if err := db.Ping(); err != nil {
-		return err
-	}
+	return m.run(db, (*migrate.Migrate).Up)
+}
 
-	driver, err := postgres.WithInstance(db, &postgres.Config{})
-	if err != nil {
-		return err
-	}
+func (m *Migrator) Down(db *sql.DB) error {
+	return m.run(db, (*migrate.Migrate).Down)
+}
 
-	migrator, err := migrate.NewWithInstance("embed_migrations", m.srcDriver, "auth", driver)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		if _, err := migrator.Close(); err != nil {
-			return
-		}
-	}()
+// Steps applies n migrations up, or -n down when n is negative.
+func (m *Migrator) Steps(db *sql.DB, n int) error {
+	return m.run(db, func(operation *migrate.Migrate) error {
+		return operation.Steps(n)
+	})
+}
 
-	if err := migrator.Up(); err != nil {
-		if errors.Is(err, migrate.ErrNoChange) {
+func (m *Migrator) Goto(db *sql.DB, version uint) error {
+	return m.run(db, func(operation *migrate.Migrate) error {
+		return operation.Migrate(version)
+	})
+}
+
+// Force sets the recorded version without running anything, which clears the
+// dirty flag left by a failed migration once it was repaired by hand.
+func (m *Migrator) Force(db *sql.DB, version int) error {
+	return m.run(db, func(operation *migrate.Migrate) error {
+		return operation.Force(version)
+	})
+}
+
+// Version returns the applied version, zero when nothing was applied yet.
+func (m *Migrator) Version(db *sql.DB) (version uint, dirty bool, err error) {
+	err = m.run(db, func(operation *migrate.Migrate) error {
+		version, dirty, err = operation.Version()
+		if errors.Is(err, migrate.ErrNilVersion) {
 			return nil
 		}
 		return err
-	}
-
-	return nil
+	})
+	return version, dirty, err
 }
 
-func (m *Migrator) Down(db *sql.DB) error {
+// run closes db when done: the postgres driver owns the handle it is given.
+func (m *Migrator) run(db *sql.DB, fn func(operation *migrate.Migrate) error) error {
 	if err := db.Ping(); err != nil {
 		return err
 	}
@@ -72,18 +84,16 @@ func (m *Migrator) Down(db *sql.DB) error {
 	if err != nil {
 		return err
 	}
-
 	defer func() {
 		if _, err := operation.Close(); err != nil {
 			return
 		}
 	}()
 
-	if err := operation.Down(); err != nil {
-		if !errors.Is(err, migrate.ErrNoChange) {
+	if err := fn(operation); err != nil {
+		if errors.Is(err, migrate.ErrNoChange) {
 			return nil
 		}
-
 		return err
 	}
 
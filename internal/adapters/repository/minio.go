@@ -2,7 +2,10 @@ package repository
 
 import (
 	"context"
+	"fmt"
 	"github.com/co1seam/ember-backend-media/config"
+	"github.com/co1seam/ember-backend-media/internal/adapters/metrics"
+	"github.com/co1seam/ember-backend-media/internal/adapters/tracing"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 	"io"
@@ -37,14 +40,31 @@ func NewMinio(cfg *config.MinIO) (*Minio, error) {
 		}
 	}
 
+	if cfg.QuarantineBucket != "" && cfg.QuarantineBucket != cfg.Bucket {
+		exists, err := client.BucketExists(ctx, cfg.QuarantineBucket)
+		if err != nil {
+			return nil, err
+		}
+
+		if !exists {
+			if err := client.MakeBucket(ctx, cfg.QuarantineBucket, minio.MakeBucketOptions{}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	return &Minio{
 		Client: client,
 		Bucket: cfg.Bucket,
 	}, nil
 }
 
-func (m *Minio) UploadFile(ctx context.Context, bucketName, objectName string, reader io.Reader, objectSize int64, contentType string) error {
-	_, err := m.Client.PutObject(
+func (m *Minio) UploadFile(ctx context.Context, bucketName, objectName string, reader io.Reader, objectSize int64, contentType string) (err error) {
+	defer metrics.ObserveMinio("put_object")(&err)
+	ctx, span := startMinioSpan(ctx, "put_object", bucketName, objectName)
+	defer tracing.Finish(span, &err)
+
+	_, err = m.Client.PutObject(
 		ctx,
 		bucketName,
 		objectName,
@@ -57,7 +77,11 @@ func (m *Minio) UploadFile(ctx context.Context, bucketName, objectName string, r
 	return err
 }
 
-func (m *Minio) DownloadFile(ctx context.Context, bucketName, objectName string) (*minio.Object, error) {
+func (m *Minio) DownloadFile(ctx context.Context, bucketName, objectName string) (_ *minio.Object, err error) {
+	defer metrics.ObserveMinio("get_object")(&err)
+	ctx, span := startMinioSpan(ctx, "get_object", bucketName, objectName)
+	defer tracing.Finish(span, &err)
+
 	media, err := m.Client.GetObject(
 		ctx,
 		bucketName,
@@ -71,7 +95,11 @@ func (m *Minio) DownloadFile(ctx context.Context, bucketName, objectName string)
 	return media, nil
 }
 
-func (m *Minio) DownloadFileRange(ctx context.Context, bucketName, storagePath string, start, end int64) (io.ReadCloser, error) {
+func (m *Minio) DownloadFileRange(ctx context.Context, bucketName, storagePath string, start, end int64) (_ io.ReadCloser, err error) {
+	defer metrics.ObserveMinio("get_object_range")(&err)
+	ctx, span := startMinioSpan(ctx, "get_object_range", bucketName, storagePath)
+	defer tracing.Finish(span, &err)
+
 	opts := minio.GetObjectOptions{}
 	if start > 0 || end > 0 {
 		opts.SetRange(start, end)
@@ -90,7 +118,53 @@ func (m *Minio) DownloadFileRange(ctx context.Context, bucketName, storagePath s
 	return obj, nil
 }
 
-func (m *Minio) GetStatFile(ctx context.Context, bucketName, objectName string) (*minio.ObjectInfo, error) {
+func (m *Minio) CopyFile(ctx context.Context, srcBucket, srcObject, dstBucket, dstObject string) (err error) {
+	defer metrics.ObserveMinio("copy_object")(&err)
+	ctx, span := startMinioSpan(ctx, "copy_object", dstBucket, dstObject)
+	defer tracing.Finish(span, &err)
+
+	_, err = m.Client.CopyObject(
+		ctx,
+		minio.CopyDestOptions{Bucket: dstBucket, Object: dstObject},
+		minio.CopySrcOptions{Bucket: srcBucket, Object: srcObject},
+	)
+	return err
+}
+
+func (m *Minio) DeleteFile(ctx context.Context, bucketName, objectName string) (err error) {
+	defer metrics.ObserveMinio("remove_object")(&err)
+	ctx, span := startMinioSpan(ctx, "remove_object", bucketName, objectName)
+	defer tracing.Finish(span, &err)
+
+	return m.Client.RemoveObject(ctx, bucketName, objectName, minio.RemoveObjectOptions{})
+}
+
+// WalkFiles calls fn for every object under prefix in key order, starting
+// after startAfter when it is set, and stops at its first error.
+func (m *Minio) WalkFiles(ctx context.Context, bucketName, prefix, startAfter string, fn func(minio.ObjectInfo) error) (err error) {
+	defer metrics.ObserveMinio("list_objects")(&err)
+	ctx, span := startMinioSpan(ctx, "list_objects", bucketName, prefix)
+	defer tracing.Finish(span, &err)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for object := range m.Client.ListObjects(ctx, bucketName, minio.ListObjectsOptions{Prefix: prefix, StartAfter: startAfter, Recursive: true}) {
+		if object.Err != nil {
+			return object.Err
+		}
+		if err := fn(object); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Minio) GetStatFile(ctx context.Context, bucketName, objectName string) (_ *minio.ObjectInfo, err error) {
+	defer metrics.ObserveMinio("stat_object")(&err)
+	ctx, span := startMinioSpan(ctx, "stat_object", bucketName, objectName)
+	defer tracing.Finish(span, &err)
+
 	fileInfo, err := m.Client.StatObject(ctx, bucketName, objectName, minio.StatObjectOptions{})
 	if err != nil {
 		return nil, err
@@ -98,7 +172,11 @@ func (m *Minio) GetStatFile(ctx context.Context, bucketName, objectName string)
 	return &fileInfo, nil
 }
 
-func (m *Minio) GenerateUploadURL(ctx context.Context, objectName string, expiry time.Duration) (string, error) {
+func (m *Minio) GenerateUploadURL(ctx context.Context, objectName string, expiry time.Duration) (_ string, err error) {
+	defer metrics.ObserveMinio("presign_put")(&err)
+	ctx, span := startMinioSpan(ctx, "presign_put", m.Bucket, objectName)
+	defer tracing.Finish(span, &err)
+
 	url, err := m.Client.PresignedPutObject(ctx, m.Bucket, objectName, expiry)
 	if err != nil {
 		return "", err
@@ -106,10 +184,25 @@ func (m *Minio) GenerateUploadURL(ctx context.Context, objectName string, expiry
 	return url.String(), nil
 }
 
-func (m *Minio) GenerateDownloadURL(ctx context.Context, objectName string, expiry time.Duration) (string, error) {
+func (m *Minio) GenerateDownloadURL(ctx context.Context, objectName string, expiry time.Duration) (_ string, err error) {
+	defer metrics.ObserveMinio("presign_get")(&err)
+	ctx, span := startMinioSpan(ctx, "presign_get", m.Bucket, objectName)
+	defer tracing.Finish(span, &err)
+
 	url, err := m.Client.PresignedGetObject(ctx, m.Bucket, objectName, expiry, nil)
 	if err != nil {
 		return "", err
 	}
 	return url.String(), nil
 }
+
+func (m *Minio) Ping(ctx context.Context) error {
+	exists, err := m.Client.BucketExists(ctx, m.Bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("bucket %s does not exist", m.Bucket)
+	}
+	return nil
+}
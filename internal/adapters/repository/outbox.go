@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"time"
+)
+
+type Outbox struct {
+	db   *sql.DB
+	opts *models.Options
+}
+
+func NewOutbox(db *sql.DB, opts *models.Options) ports.IOutboxRepo {
+	return &Outbox{
+		db:   db,
+		opts: opts,
+	}
+}
+
+func (o *Outbox) Add(ctx context.Context, event *models.Event) error {
+	query := fmt.Sprintf(
+		"INSERT INTO %s (id, event_type, schema_version, aggregate_id, owner_id, payload, occurred_at) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		models.OutboxTable,
+	)
+
+	_, err := conn(ctx, o.db).ExecContext(
+		ctx,
+		query,
+		event.ID,
+		event.Type,
+		event.SchemaVersion,
+		event.AggregateID,
+		event.OwnerID,
+		[]byte(event.Data),
+		event.OccurredAt,
+	)
+	return err
+}
+
+// ClaimPending leases due events by pushing their next_attempt_at out by
+// lease, in a single statement, so the sink is called with no lock held.
+// Only the oldest undelivered event of each aggregate is eligible, which
+// keeps every aggregate's events in order while a failing event holds back
+// nothing but its own aggregate.
+func (o *Outbox) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]*models.OutboxEvent, error) {
+	query := fmt.Sprintf(
+		`WITH due AS (
+			SELECT o.id FROM %[1]s o
+			WHERE o.published_at IS NULL AND o.dead_lettered_at IS NULL AND o.next_attempt_at <= $1
+			AND NOT EXISTS (
+				SELECT 1 FROM %[1]s e
+				WHERE e.aggregate_id = o.aggregate_id AND e.published_at IS NULL AND e.dead_lettered_at IS NULL
+				AND e.occurred_at < o.occurred_at
+			)
+			ORDER BY o.occurred_at LIMIT $2 FOR UPDATE SKIP LOCKED
+		)
+		UPDATE %[1]s o SET next_attempt_at = $3 FROM due WHERE o.id = due.id
+		RETURNING o.id, o.event_type, o.schema_version, o.aggregate_id, o.owner_id, o.payload, o.occurred_at, o.attempts, o.last_error`,
+		models.OutboxTable,
+	)
+
+	now := time.Now()
+	rows, err := conn(ctx, o.db).QueryContext(ctx, query, now, limit, now.Add(lease))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*models.OutboxEvent
+	for rows.Next() {
+		event := &models.OutboxEvent{}
+		var payload []byte
+		err := rows.Scan(
+			&event.ID,
+			&event.Type,
+			&event.SchemaVersion,
+			&event.AggregateID,
+			&event.OwnerID,
+			&payload,
+			&event.OccurredAt,
+			&event.Attempts,
+			&event.LastError,
+		)
+		if err != nil {
+			return nil, err
+		}
+		event.Data = payload
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+func (o *Outbox) MarkPublished(ctx context.Context, id string) error {
+	query := fmt.Sprintf(
+		"UPDATE %s SET published_at = $1, attempts = attempts + 1, last_error = '' WHERE id = $2",
+		models.OutboxTable,
+	)
+
+	_, err := conn(ctx, o.db).ExecContext(ctx, query, time.Now(), id)
+	return err
+}
+
+// MarkFailed records a failed attempt and schedules the next one at retryAt.
+func (o *Outbox) MarkFailed(ctx context.Context, id string, reason string, retryAt time.Time) error {
+	query := fmt.Sprintf(
+		"UPDATE %s SET attempts = attempts + 1, last_error = $1, next_attempt_at = $2 WHERE id = $3",
+		models.OutboxTable,
+	)
+
+	_, err := conn(ctx, o.db).ExecContext(ctx, query, reason, retryAt, id)
+	return err
+}
+
+// MarkDeadLettered records a final failed attempt. The event is kept for
+// inspection but never relayed again.
+func (o *Outbox) MarkDeadLettered(ctx context.Context, id string, reason string) error {
+	query := fmt.Sprintf(
+		"UPDATE %s SET attempts = attempts + 1, last_error = $1, dead_lettered_at = $2 WHERE id = $3",
+		models.OutboxTable,
+	)
+
+	_, err := conn(ctx, o.db).ExecContext(ctx, query, reason, time.Now(), id)
+	return err
+}
+
+// DeleteByOwner removes the owner's events, published or not, since their
+// payloads carry the owner's media.
+func (o *Outbox) DeleteByOwner(ctx context.Context, ownerID string) (int64, error) {
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE owner_id = $1",
+		models.OutboxTable,
+	)
+
+	res, err := conn(ctx, o.db).ExecContext(ctx, query, ownerID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (o *Outbox) DeletePublishedBefore(ctx context.Context, before time.Time) (int64, error) {
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE published_at IS NOT NULL AND published_at < $1",
+		models.OutboxTable,
+	)
+
+	res, err := conn(ctx, o.db).ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
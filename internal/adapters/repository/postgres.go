@@ -12,7 +12,43 @@ type Postgres struct {
 	migrator *Migrator
 }
 
+// NewPostgres connects and brings the schema up to date.
 func NewPostgres(ctx context.Context, cfg *config.Database) (*Postgres, error) {
+	pg, err := OpenPostgres(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	migrationDB, err := OpenDB(ctx, cfg)
+	if err != nil {
+		pg.Close()
+		return nil, err
+	}
+
+	if err := pg.migrator.Up(migrationDB); err != nil {
+		pg.Close()
+		return nil, fmt.Errorf("error running migrations: %w", err)
+	}
+
+	return pg, nil
+}
+
+// OpenPostgres connects without touching the schema.
+func OpenPostgres(ctx context.Context, cfg *config.Database) (*Postgres, error) {
+	db, err := OpenDB(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Postgres{
+		DB:       db,
+		migrator: NewMigrator(),
+	}, nil
+}
+
+// OpenDB returns a pinged connection pool. The migrator closes the handle it
+// runs on, so migrations get one of their own.
+func OpenDB(ctx context.Context, cfg *config.Database) (*sql.DB, error) {
 	dsn := fmt.Sprintf("host=%s port=%s user=%s dbname=%s password=%s sslmode=disable",
 		cfg.Host,
 		cfg.Port,
@@ -27,27 +63,17 @@ func NewPostgres(ctx context.Context, cfg *config.Database) (*Postgres, error) {
 	}
 
 	if err := db.PingContext(ctx); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("error pinging postgres: %w", err)
 	}
 
-	migrationDB, err := sql.Open("postgres", dsn)
-	if err != nil {
-		return nil, fmt.Errorf("error connecting to postgres: %w", err)
-	}
-	defer migrationDB.Close()
-
-	migrator := NewMigrator()
-
-	if err := migrator.Up(migrationDB); err != nil {
-		return nil, fmt.Errorf("error running migrations: %w", err)
-	}
-
-	return &Postgres{
-		DB:       db,
-		migrator: migrator,
-	}, nil
+	return db, nil
 }
 
 func (pg *Postgres) Close() error {
 	return pg.DB.Close()
 }
+
+func (pg *Postgres) Ping(ctx context.Context) error {
+	return pg.DB.PingContext(ctx)
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/co1seam/ember-backend-media/internal/adapters/tracing"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"time"
+)
+
+const privacyColumns = "id, kind, owner_id, state, format, storage_path, size_bytes, summary, requested_by, error, created_at, finished_at"
+
+type Privacy struct {
+	db   *sql.DB
+	opts *models.Options
+}
+
+func NewPrivacy(db *sql.DB, opts *models.Options) ports.IPrivacyRepo {
+	return &Privacy{
+		db:   db,
+		opts: opts,
+	}
+}
+
+func (p *Privacy) Create(ctx context.Context, req *models.PrivacyRequest) (err error) {
+	ctx, span := startQuerySpan(ctx, "INSERT", models.PrivacyRequestsTable)
+	defer tracing.Finish(span, &err)
+
+	summary, err := json.Marshal(req.Summary)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)",
+		models.PrivacyRequestsTable,
+		privacyColumns,
+	)
+
+	_, err = conn(ctx, p.db).ExecContext(
+		ctx,
+		query,
+		req.ID,
+		req.Kind,
+		req.OwnerID,
+		req.State,
+		req.Format,
+		req.StoragePath,
+		req.Size,
+		summary,
+		req.RequestedBy,
+		req.Error,
+		req.CreatedAt,
+		req.FinishedAt,
+	)
+	return err
+}
+
+func (p *Privacy) Get(ctx context.Context, id string) (_ *models.PrivacyRequest, err error) {
+	ctx, span := startQuerySpan(ctx, "SELECT", models.PrivacyRequestsTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE id = $1",
+		privacyColumns,
+		models.PrivacyRequestsTable,
+	)
+
+	return scanPrivacyRequest(conn(ctx, p.db).QueryRowContext(ctx, query, id))
+}
+
+// ListUnfinished returns the requests still pending or running that were
+// created before the given time.
+func (p *Privacy) ListUnfinished(ctx context.Context, before time.Time) (_ []*models.PrivacyRequest, err error) {
+	ctx, span := startQuerySpan(ctx, "SELECT", models.PrivacyRequestsTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE state IN ($1, $2) AND created_at < $3 ORDER BY created_at",
+		privacyColumns,
+		models.PrivacyRequestsTable,
+	)
+
+	rows, err := conn(ctx, p.db).QueryContext(ctx, query, models.PrivacyStatePending, models.PrivacyStateRunning, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reqs []*models.PrivacyRequest
+	for rows.Next() {
+		req, err := scanPrivacyRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, req)
+	}
+
+	return reqs, rows.Err()
+}
+
+func (p *Privacy) Update(ctx context.Context, req *models.PrivacyRequest) (err error) {
+	ctx, span := startQuerySpan(ctx, "UPDATE", models.PrivacyRequestsTable)
+	defer tracing.Finish(span, &err)
+
+	summary, err := json.Marshal(req.Summary)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET state = $1, storage_path = $2, size_bytes = $3, summary = $4, error = $5, finished_at = $6 WHERE id = $7",
+		models.PrivacyRequestsTable,
+	)
+
+	_, err = conn(ctx, p.db).ExecContext(
+		ctx,
+		query,
+		req.State,
+		req.StoragePath,
+		req.Size,
+		summary,
+		req.Error,
+		req.FinishedAt,
+		req.ID,
+	)
+	return err
+}
+
+func scanPrivacyRequest(row scanner) (*models.PrivacyRequest, error) {
+	req := &models.PrivacyRequest{}
+	var summary []byte
+	var finishedAt sql.NullTime
+	err := row.Scan(
+		&req.ID,
+		&req.Kind,
+		&req.OwnerID,
+		&req.State,
+		&req.Format,
+		&req.StoragePath,
+		&req.Size,
+		&summary,
+		&req.RequestedBy,
+		&req.Error,
+		&req.CreatedAt,
+		&finishedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(summary, &req.Summary); err != nil {
+		return nil, err
+	}
+	if finishedAt.Valid {
+		req.FinishedAt = &finishedAt.Time
+	}
+
+	return req, nil
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"github.com/go-redis/redis/v8"
 )
 
@@ -16,3 +17,11 @@ func NewRedis(host, port string) *Redis {
 	})
 	return &Redis{Redis: client}
 }
+
+func (r *Redis) Ping(ctx context.Context) error {
+	return r.Redis.Ping(ctx).Err()
+}
+
+func (r *Redis) Close() error {
+	return r.Redis.Close()
+}
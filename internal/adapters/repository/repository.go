@@ -7,15 +7,35 @@ import (
 )
 
 type Repository struct {
-	Media ports.IMediaRepo
-	MinIO *Minio
-	Cache *Redis
+	Media      ports.IMediaRepo
+	Versions   ports.IMediaVersionRepo
+	Tags       ports.ITagRepo
+	Albums     ports.IAlbumRepo
+	Outbox     ports.IOutboxRepo
+	Webhooks   ports.IWebhookRepo
+	Settings   ports.ISettingsRepo
+	Imports    ports.IImportRepo
+	Privacy    ports.IPrivacyRepo
+	Audit      ports.IAuditRepo
+	Transactor ports.ITransactor
+	MinIO      *Minio
+	Cache      *Redis
 }
 
 func NewRepository(db *sql.DB, minio *Minio, cache *Redis, opts *models.Options) *Repository {
 	return &Repository{
-		Media: NewMedia(db, opts),
-		MinIO: minio,
-		Cache: cache,
+		Media:      NewMedia(db, opts),
+		Versions:   NewMediaVersion(db, opts),
+		Tags:       NewTag(db, opts),
+		Albums:     NewAlbum(db, opts),
+		Outbox:     NewOutbox(db, opts),
+		Webhooks:   NewWebhook(db, opts),
+		Settings:   NewSettings(db, opts),
+		Imports:    NewImport(db, opts),
+		Privacy:    NewPrivacy(db, opts),
+		Audit:      NewAudit(db, opts),
+		Transactor: NewTransactor(db),
+		MinIO:      minio,
+		Cache:      cache,
 	}
 }
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/co1seam/ember-backend-media/internal/adapters/tracing"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/lib/pq"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// ts_headline marks matches with control characters, stripped from the text
+// beforehand, so the snippet can be HTML-escaped before the markers become
+// <mark> tags.
+const (
+	highlightStart  = "\x02"
+	highlightStop   = "\x03"
+	headlineOptions = "StartSel=" + highlightStart + ", StopSel=" + highlightStop + ", MaxWords=30, MinWords=10, MaxFragments=2"
+)
+
+var highlighter = strings.NewReplacer(highlightStart, "<mark>", highlightStop, "</mark>")
+
+// Search matches every term of the query as a prefix against the titles and
+// descriptions of active media, best matches first.
+func (m *Media) Search(ctx context.Context, req *models.SearchMediaRequest) (_ []*models.MediaSearchHit, err error) {
+	ctx, span := startQuerySpan(ctx, "SELECT", models.MediaTable)
+	defer tracing.Finish(span, &err)
+
+	query := prefixQuery(req.Query)
+	if query == "" {
+		return nil, nil
+	}
+
+	var contentTypes []string
+	for _, contentType := range req.ContentTypes {
+		contentTypes = append(contentTypes, contentTypePattern(contentType))
+	}
+
+	sqlQuery := fmt.Sprintf(
+		`SELECT %s, ts_rank(search_vector, q) AS rank,
+			ts_headline('simple', translate(concat_ws(' ', title, description), $6, ''), q, $7) AS snippet
+		FROM %s, to_tsquery('simple', $1) q
+		WHERE search_vector @@ q AND owner_id = $2 AND state = $8 AND ($3::text[] IS NULL OR content_type LIKE ANY($3))
+		ORDER BY rank DESC, created_at DESC
+		LIMIT $4 OFFSET $5`,
+		mediaSelectColumns,
+		models.MediaTable,
+	)
+
+	var filter any
+	if len(contentTypes) > 0 {
+		filter = pq.Array(contentTypes)
+	}
+
+	rows, err := conn(ctx, m.db).QueryContext(
+		ctx,
+		sqlQuery,
+		query,
+		req.OwnerID,
+		filter,
+		req.Limit,
+		req.Offset,
+		highlightStart+highlightStop,
+		headlineOptions,
+		models.MediaStateActive,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []*models.MediaSearchHit
+	for rows.Next() {
+		hit := &models.MediaSearchHit{}
+		hit.Media, err = scanMedia(withExtra{rows, []any{&hit.Rank, &hit.Snippet}})
+		if err != nil {
+			return nil, err
+		}
+		hit.Snippet = highlighter.Replace(html.EscapeString(hit.Snippet))
+		hits = append(hits, hit)
+	}
+
+	return hits, rows.Err()
+}
+
+// withExtra scans columns selected after the media ones into extra.
+type withExtra struct {
+	scanner
+	extra []any
+}
+
+func (r withExtra) Scan(dest ...any) error {
+	return r.scanner.Scan(append(dest, r.extra...)...)
+}
+
+// prefixQuery turns free text into a tsquery requiring every word as a
+// prefix. Only letters and digits are kept, so user input cannot inject
+// tsquery operators.
+func prefixQuery(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		terms = append(terms, word+":*")
+	}
+	return strings.Join(terms, " & ")
+}
+
+// contentTypePattern maps "video/mp4" to itself and "video/*" to "video/%".
+func contentTypePattern(contentType string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(contentType)
+	if prefix, ok := strings.CutSuffix(escaped, "/*"); ok {
+		return prefix + "/%"
+	}
+	return escaped
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"testing"
+	"time"
+)
+
+func TestSearchEscapesSnippets(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	columns := []string{
+		"id", "title", "description", "content_type", "storage_path", "owner_id", "streaming_optimized", "state",
+		"quarantine_reason", "created_at", "size_bytes", "current_version", "metadata", "updated_at", "revision", "tags",
+		"rank", "snippet",
+	}
+	now := time.Now()
+	mock.ExpectQuery("state = \\$8").
+		WithArgs("cat:*", "owner", nil, 10, 0, highlightStart+highlightStop, headlineOptions, models.MediaStateActive).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(
+			"m1", "<b>cat</b>", "", "image/png", "owner/m1", "owner", false, models.MediaStateActive,
+			"", now, 4, 1, []byte("{}"), now, 1, "{}",
+			0.5, "<b>\x02cat\x03</b> & \x02cats\x03",
+		))
+
+	hits, err := NewMedia(db, &models.Options{}).Search(t.Context(), &models.SearchMediaRequest{
+		Query: "cat", OwnerID: "owner", Limit: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 {
+		t.Fatalf("hits = %d, want 1", len(hits))
+	}
+
+	want := "&lt;b&gt;<mark>cat</mark>&lt;/b&gt; &amp; <mark>cats</mark>"
+	if hits[0].Snippet != want {
+		t.Errorf("snippet = %q, want %q", hits[0].Snippet, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPrefixQuery(t *testing.T) {
+	for text, want := range map[string]string{
+		"Cat videos":      "cat:* & videos:*",
+		"a & !b | c:*":    "a:* & b:* & c:*",
+		"<mark>":          "mark:*",
+		"  ":              "",
+		"фото 2024-01-02": "фото:* & 2024:* & 01:* & 02:*",
+	} {
+		if got := prefixQuery(text); got != want {
+			t.Errorf("prefixQuery(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
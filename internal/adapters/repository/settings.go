@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/co1seam/ember-backend-media/internal/adapters/tracing"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+)
+
+type Settings struct {
+	db   *sql.DB
+	opts *models.Options
+}
+
+func NewSettings(db *sql.DB, opts *models.Options) ports.ISettingsRepo {
+	return &Settings{
+		db:   db,
+		opts: opts,
+	}
+}
+
+func (s *Settings) List(ctx context.Context) (_ map[string]string, err error) {
+	ctx, span := startQuerySpan(ctx, "SELECT", models.SettingsTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf("SELECT key, value FROM %s", models.SettingsTable)
+
+	rows, err := conn(ctx, s.db).QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	settings := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		settings[key] = value
+	}
+
+	return settings, rows.Err()
+}
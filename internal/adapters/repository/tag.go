@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/co1seam/ember-backend-media/internal/adapters/tracing"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"github.com/lib/pq"
+)
+
+type Tag struct {
+	db   *sql.DB
+	opts *models.Options
+}
+
+func NewTag(db *sql.DB, opts *models.Options) ports.ITagRepo {
+	return &Tag{
+		db:   db,
+		opts: opts,
+	}
+}
+
+func (t *Tag) Add(ctx context.Context, mediaID string, names []string) (err error) {
+	ctx, span := startQuerySpan(ctx, "INSERT", models.MediaTagsTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (name) SELECT unnest($1::text[]) ON CONFLICT (name) DO NOTHING",
+		models.TagsTable,
+	)
+	if _, err := conn(ctx, t.db).ExecContext(ctx, query, pq.Array(names)); err != nil {
+		return err
+	}
+
+	query = fmt.Sprintf(
+		"INSERT INTO %s (media_id, tag_id) SELECT $1, id FROM %s WHERE name = ANY($2) ON CONFLICT DO NOTHING",
+		models.MediaTagsTable,
+		models.TagsTable,
+	)
+	_, err = conn(ctx, t.db).ExecContext(ctx, query, mediaID, pq.Array(names))
+	return err
+}
+
+func (t *Tag) Remove(ctx context.Context, mediaID string, names []string) (err error) {
+	ctx, span := startQuerySpan(ctx, "DELETE", models.MediaTagsTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE media_id = $1 AND tag_id IN (SELECT id FROM %s WHERE name = ANY($2))",
+		models.MediaTagsTable,
+		models.TagsTable,
+	)
+
+	_, err = conn(ctx, t.db).ExecContext(ctx, query, mediaID, pq.Array(names))
+	return err
+}
+
+func (t *Tag) Count(ctx context.Context, mediaID string) (_ int, err error) {
+	ctx, span := startQuerySpan(ctx, "SELECT", models.MediaTagsTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"SELECT count(*) FROM %s WHERE media_id = $1",
+		models.MediaTagsTable,
+	)
+
+	var count int
+	err = conn(ctx, t.db).QueryRowContext(ctx, query, mediaID).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/lib/pq"
+	"regexp"
+	"testing"
+)
+
+func TestTagAddCreatesMissingTags(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	names := []string{"beach", "sunset"}
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO tags (name) SELECT unnest($1::text[]) ON CONFLICT (name) DO NOTHING")).
+		WithArgs(pq.Array(names)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO media_tags (media_id, tag_id) SELECT $1, id FROM tags WHERE name = ANY($2) ON CONFLICT DO NOTHING")).
+		WithArgs("m1", pq.Array(names)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	if err := NewTag(db, &models.Options{}).Add(t.Context(), "m1", names); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPatchMetadataMergesInOneStatement(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE media SET metadata = (metadata || $1::jsonb) - $2::text[] WHERE id = $3")).
+		WithArgs([]byte(`{"iso":"400"}`), pq.Array([]string{"lens"}), "m1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = NewMedia(db, &models.Options{}).PatchMetadata(t.Context(), "m1", map[string]string{"iso": "400"}, []string{"lens"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestListByOwnerFilters(t *testing.T) {
+	for name, tc := range map[string]struct {
+		req      *models.ListMediaRequest
+		tags     any
+		metadata []byte
+	}{
+		"unfiltered": {&models.ListMediaRequest{OwnerID: "o", Limit: 10}, nil, []byte("{}")},
+		"filtered": {
+			&models.ListMediaRequest{OwnerID: "o", Tags: []string{"beach"}, Metadata: map[string]string{"camera": "x100v"}, Limit: 10},
+			pq.Array([]string{"beach"}),
+			[]byte(`{"camera":"x100v"}`),
+		},
+	} {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		mock.ExpectQuery(`WHERE owner_id = \$1\s+AND \(\$2::text\[\] IS NULL OR .+ = cardinality\(\$2\)\)\s+AND metadata @> \$3::jsonb`).
+			WithArgs("o", tc.tags, tc.metadata, 10).
+			WillReturnRows(sqlmock.NewRows(mediaRowColumns))
+
+		if _, err := NewMedia(db, &models.Options{}).ListByOwner(t.Context(), tc.req); err != nil {
+			t.Errorf("%s: ListByOwner() error = %v", name, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		db.Close()
+	}
+}
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/co1seam/ember-backend-media/internal/adapters/repository")
+
+func startQuerySpan(ctx context.Context, operation, table string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, operation+" "+table,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation", operation),
+			attribute.String("db.sql.table", table),
+		),
+	)
+}
+
+func startMinioSpan(ctx context.Context, operation, bucket, object string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "minio."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "s3"),
+			attribute.String("rpc.method", operation),
+			attribute.String("aws.s3.bucket", bucket),
+			attribute.String("aws.s3.key", object),
+		),
+	)
+}
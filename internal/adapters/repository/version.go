@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/co1seam/ember-backend-media/internal/adapters/tracing"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"github.com/lib/pq"
+)
+
+const versionColumns = "id, media_id, version, storage_path, size_bytes, checksum, content_type, uploaded_by, created_at"
+
+type MediaVersion struct {
+	db   *sql.DB
+	opts *models.Options
+}
+
+func NewMediaVersion(db *sql.DB, opts *models.Options) ports.IMediaVersionRepo {
+	return &MediaVersion{
+		db:   db,
+		opts: opts,
+	}
+}
+
+// Create numbers the version from the last_version counter of the media and
+// stores the number in version.Version. Bumping the counter locks the media
+// row, so concurrent uploads get consecutive numbers; numbers of deleted
+// versions are never reused.
+func (v *MediaVersion) Create(ctx context.Context, version *models.MediaVersion) (err error) {
+	ctx, span := startQuerySpan(ctx, "INSERT", models.MediaVersionsTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"WITH next AS (UPDATE %[1]s SET last_version = last_version + 1 WHERE id = $2 RETURNING last_version) "+
+			"INSERT INTO %[2]s (%[3]s) SELECT $1, $2, last_version, $3, $4, $5, $6, $7, $8 FROM next RETURNING version",
+		models.MediaTable,
+		models.MediaVersionsTable,
+		versionColumns,
+	)
+
+	return conn(ctx, v.db).QueryRowContext(
+		ctx,
+		query,
+		version.ID,
+		version.MediaID,
+		version.StoragePath,
+		version.Size,
+		version.Checksum,
+		version.ContentType,
+		version.UploadedBy,
+		version.CreatedAt,
+	).Scan(&version.Version)
+}
+
+func (v *MediaVersion) Get(ctx context.Context, mediaID string, number int) (_ *models.MediaVersion, err error) {
+	ctx, span := startQuerySpan(ctx, "SELECT", models.MediaVersionsTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE media_id = $1 AND version = $2",
+		versionColumns,
+		models.MediaVersionsTable,
+	)
+
+	return scanVersion(conn(ctx, v.db).QueryRowContext(ctx, query, mediaID, number))
+}
+
+// List returns the versions of a media, newest first.
+func (v *MediaVersion) List(ctx context.Context, mediaID string) (_ []*models.MediaVersion, err error) {
+	ctx, span := startQuerySpan(ctx, "SELECT", models.MediaVersionsTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE media_id = $1 ORDER BY version DESC",
+		versionColumns,
+		models.MediaVersionsTable,
+	)
+
+	rows, err := conn(ctx, v.db).QueryContext(ctx, query, mediaID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []*models.MediaVersion
+	for rows.Next() {
+		version, err := scanVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, rows.Err()
+}
+
+// ListForMedia returns the versions of all the given media.
+func (v *MediaVersion) ListForMedia(ctx context.Context, mediaIDs []string) (_ []*models.MediaVersion, err error) {
+	ctx, span := startQuerySpan(ctx, "SELECT", models.MediaVersionsTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE media_id = ANY($1::uuid[]) ORDER BY media_id, version DESC",
+		versionColumns,
+		models.MediaVersionsTable,
+	)
+
+	rows, err := conn(ctx, v.db).QueryContext(ctx, query, pq.Array(mediaIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []*models.MediaVersion
+	for rows.Next() {
+		version, err := scanVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, rows.Err()
+}
+
+// ListPage walks all versions in id order, starting after afterID.
+func (v *MediaVersion) ListPage(ctx context.Context, afterID string, limit int) (_ []*models.MediaVersion, err error) {
+	ctx, span := startQuerySpan(ctx, "SELECT", models.MediaVersionsTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE id > $1 ORDER BY id LIMIT $2",
+		versionColumns,
+		models.MediaVersionsTable,
+	)
+
+	if afterID == "" {
+		afterID = "00000000-0000-0000-0000-000000000000"
+	}
+
+	rows, err := conn(ctx, v.db).QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []*models.MediaVersion
+	for rows.Next() {
+		version, err := scanVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, rows.Err()
+}
+
+// UpdateObject records a rewrite of the object in place, as done by the
+// faststart remux.
+func (v *MediaVersion) UpdateObject(ctx context.Context, mediaID string, number int, size int64, checksum string) (err error) {
+	ctx, span := startQuerySpan(ctx, "UPDATE", models.MediaVersionsTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET size_bytes = $1, checksum = $2 WHERE media_id = $3 AND version = $4",
+		models.MediaVersionsTable,
+	)
+
+	_, err = conn(ctx, v.db).ExecContext(ctx, query, size, checksum, mediaID, number)
+	return err
+}
+
+func (v *MediaVersion) Delete(ctx context.Context, mediaID string, number int) (err error) {
+	ctx, span := startQuerySpan(ctx, "DELETE", models.MediaVersionsTable)
+	defer tracing.Finish(span, &err)
+
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE media_id = $1 AND version = $2",
+		models.MediaVersionsTable,
+	)
+
+	_, err = conn(ctx, v.db).ExecContext(ctx, query, mediaID, number)
+	return err
+}
+
+func scanVersion(row scanner) (*models.MediaVersion, error) {
+	version := &models.MediaVersion{}
+	var size sql.NullInt64
+	err := row.Scan(
+		&version.ID,
+		&version.MediaID,
+		&version.Version,
+		&version.StoragePath,
+		&size,
+		&version.Checksum,
+		&version.ContentType,
+		&version.UploadedBy,
+		&version.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	version.Size = size.Int64
+	return version, nil
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestMediaVersionCreateBumpsCounter(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	version := &models.MediaVersion{
+		ID:          "v1",
+		MediaID:     "m1",
+		StoragePath: "owner/m1/v1/a.txt",
+		Size:        4,
+		ContentType: "text/plain",
+		UploadedBy:  "owner",
+		CreatedAt:   time.Now(),
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta("WITH next AS (UPDATE media SET last_version = last_version + 1 WHERE id = $2 RETURNING last_version) INSERT INTO media_versions")).
+		WithArgs(version.ID, version.MediaID, version.StoragePath, version.Size, version.Checksum, version.ContentType, version.UploadedBy, version.CreatedAt).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(7))
+
+	if err := NewMediaVersion(db, &models.Options{}).Create(t.Context(), version); err != nil {
+		t.Fatal(err)
+	}
+	if version.Version != 7 {
+		t.Errorf("Version = %d, want the counter value 7", version.Version)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMediaVersionCreateMissingMedia(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("WITH next AS").WillReturnRows(sqlmock.NewRows([]string{"version"}))
+
+	err = NewMediaVersion(db, &models.Options{}).Create(t.Context(), &models.MediaVersion{ID: "v1", MediaID: "gone"})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("Create = %v, want sql.ErrNoRows", err)
+	}
+}
@@ -0,0 +1,360 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"time"
+)
+
+const deliveryColumns = "d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.state, d.attempts, d.last_status_code, d.last_error, d.last_duration_ms, d.next_attempt_at, d.delivered_at, d.created_at"
+
+type Webhook struct {
+	db   *sql.DB
+	opts *models.Options
+}
+
+func NewWebhook(db *sql.DB, opts *models.Options) ports.IWebhookRepo {
+	return &Webhook{
+		db:   db,
+		opts: opts,
+	}
+}
+
+func (w *Webhook) Create(ctx context.Context, webhook *models.Webhook) error {
+	query := fmt.Sprintf(
+		"INSERT INTO %s (id, owner_id, url, secret, events, active, consecutive_failures, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+		models.WebhooksTable,
+	)
+
+	_, err := conn(ctx, w.db).ExecContext(
+		ctx,
+		query,
+		webhook.ID,
+		webhook.OwnerID,
+		webhook.URL,
+		webhook.Secret,
+		pq.Array(webhook.Events),
+		webhook.Active,
+		webhook.ConsecutiveFailures,
+		webhook.CreatedAt,
+	)
+	return err
+}
+
+func (w *Webhook) GetByID(ctx context.Context, id string) (*models.Webhook, error) {
+	query := fmt.Sprintf(
+		"SELECT id, owner_id, url, secret, events, active, consecutive_failures, created_at FROM %s WHERE id = $1",
+		models.WebhooksTable,
+	)
+
+	return scanWebhook(conn(ctx, w.db).QueryRowContext(ctx, query, id))
+}
+
+func (w *Webhook) ListByOwner(ctx context.Context, ownerID string) ([]*models.Webhook, error) {
+	query := fmt.Sprintf(
+		"SELECT id, owner_id, url, secret, events, active, consecutive_failures, created_at FROM %s WHERE owner_id = $1 ORDER BY created_at",
+		models.WebhooksTable,
+	)
+
+	rows, err := conn(ctx, w.db).QueryContext(ctx, query, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []*models.Webhook
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+func (w *Webhook) Delete(ctx context.Context, id string) error {
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE id = $1",
+		models.WebhooksTable,
+	)
+
+	_, err := conn(ctx, w.db).ExecContext(ctx, query, id)
+	return err
+}
+
+func (w *Webhook) DeleteByOwner(ctx context.Context, ownerID string) (int64, error) {
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE owner_id = $1",
+		models.WebhooksTable,
+	)
+
+	result, err := conn(ctx, w.db).ExecContext(ctx, query, ownerID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (w *Webhook) SetActive(ctx context.Context, id string, active bool) error {
+	query := fmt.Sprintf(
+		"UPDATE %s SET active = $1, consecutive_failures = 0 WHERE id = $2",
+		models.WebhooksTable,
+	)
+
+	_, err := conn(ctx, w.db).ExecContext(ctx, query, active, id)
+	return err
+}
+
+// RecordResult tracks consecutive failures and disables the webhook once
+// disableAfter is reached. It reports whether the webhook is still active.
+func (w *Webhook) RecordResult(ctx context.Context, id string, success bool, disableAfter int) (bool, error) {
+	query := fmt.Sprintf(
+		`UPDATE %s SET
+			consecutive_failures = CASE WHEN $1 THEN 0 ELSE consecutive_failures + 1 END,
+			active = CASE WHEN NOT $1 AND consecutive_failures + 1 >= $2 THEN FALSE ELSE active END
+		WHERE id = $3 RETURNING active`,
+		models.WebhooksTable,
+	)
+
+	var active bool
+	err := conn(ctx, w.db).QueryRowContext(ctx, query, success, disableAfter, id).Scan(&active)
+	return active, err
+}
+
+func (w *Webhook) EnqueueDeliveries(ctx context.Context, event *models.Event) error {
+	query := fmt.Sprintf(
+		"INSERT INTO %s (id, webhook_id, event_id, event_type, payload, next_attempt_at) VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (webhook_id, event_id) DO NOTHING",
+		models.WebhookDeliveriesTable,
+	)
+
+	webhooks, err := w.ListByOwner(ctx, event.OwnerID)
+	if err != nil || len(webhooks) == 0 {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	for _, webhook := range webhooks {
+		if !webhook.Active || !matchesEvent(webhook.Events, event.Type) {
+			continue
+		}
+
+		_, err := conn(ctx, w.db).ExecContext(
+			ctx,
+			query,
+			uuid.New().String(),
+			webhook.ID,
+			event.ID,
+			event.Type,
+			payload,
+			time.Now(),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *Webhook) CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	query := fmt.Sprintf(
+		"INSERT INTO %s (id, webhook_id, event_id, event_type, payload, state, next_attempt_at, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+		models.WebhookDeliveriesTable,
+	)
+
+	_, err := conn(ctx, w.db).ExecContext(
+		ctx,
+		query,
+		delivery.ID,
+		delivery.WebhookID,
+		delivery.EventID,
+		delivery.EventType,
+		[]byte(delivery.Payload),
+		delivery.State,
+		delivery.NextAttemptAt,
+		delivery.CreatedAt,
+	)
+	return err
+}
+
+// ClaimDueDeliveries leases due deliveries of active webhooks by pushing
+// their next_attempt_at out by lease, so that other dispatchers skip them
+// while they are being sent. The claim is a single statement; no lock is
+// held during the sends, and a dispatcher that dies mid-batch only delays
+// its deliveries until the lease runs out.
+func (w *Webhook) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*models.WebhookDelivery, error) {
+	query := fmt.Sprintf(
+		`WITH due AS (
+			SELECT d.id FROM %[2]s d JOIN %[3]s h ON h.id = d.webhook_id
+			WHERE d.state = $1 AND d.next_attempt_at <= $2 AND h.active
+			ORDER BY d.next_attempt_at LIMIT $3 FOR UPDATE OF d SKIP LOCKED
+		)
+		UPDATE %[2]s d SET next_attempt_at = $4 FROM due, %[3]s h
+		WHERE d.id = due.id AND h.id = d.webhook_id
+		RETURNING %[1]s, h.url, h.secret`,
+		deliveryColumns,
+		models.WebhookDeliveriesTable,
+		models.WebhooksTable,
+	)
+
+	now := time.Now()
+	rows, err := conn(ctx, w.db).QueryContext(ctx, query, models.DeliveryStatePending, now, limit, now.Add(lease))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanDelivery(rows, true)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// SkipInactiveDeliveries moves pending deliveries of disabled webhooks to the
+// skipped state, so they do not wait forever. Re-enabling a webhook does not
+// revive them.
+func (w *Webhook) SkipInactiveDeliveries(ctx context.Context) (int64, error) {
+	query := fmt.Sprintf(
+		`UPDATE %s d SET state = $1, last_error = $2 FROM %s h
+		WHERE h.id = d.webhook_id AND NOT h.active AND d.state = $3`,
+		models.WebhookDeliveriesTable,
+		models.WebhooksTable,
+	)
+
+	result, err := conn(ctx, w.db).ExecContext(ctx, query, models.DeliveryStateSkipped, "webhook disabled", models.DeliveryStatePending)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (w *Webhook) UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	query := fmt.Sprintf(
+		"UPDATE %s SET state = $1, attempts = $2, last_status_code = $3, last_error = $4, last_duration_ms = $5, next_attempt_at = $6, delivered_at = $7 WHERE id = $8",
+		models.WebhookDeliveriesTable,
+	)
+
+	_, err := conn(ctx, w.db).ExecContext(
+		ctx,
+		query,
+		delivery.State,
+		delivery.Attempts,
+		delivery.LastStatusCode,
+		delivery.LastError,
+		delivery.LastDuration.Milliseconds(),
+		delivery.NextAttemptAt,
+		delivery.DeliveredAt,
+		delivery.ID,
+	)
+	return err
+}
+
+func (w *Webhook) ListDeliveries(ctx context.Context, webhookID string, limit int) ([]*models.WebhookDelivery, error) {
+	query := fmt.Sprintf(
+		"SELECT %s FROM %s d WHERE d.webhook_id = $1 ORDER BY d.created_at DESC LIMIT $2",
+		deliveryColumns,
+		models.WebhookDeliveriesTable,
+	)
+
+	rows, err := conn(ctx, w.db).QueryContext(ctx, query, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanDelivery(rows, false)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func scanWebhook(row scanner) (*models.Webhook, error) {
+	webhook := &models.Webhook{}
+	err := row.Scan(
+		&webhook.ID,
+		&webhook.OwnerID,
+		&webhook.URL,
+		&webhook.Secret,
+		pq.Array(&webhook.Events),
+		&webhook.Active,
+		&webhook.ConsecutiveFailures,
+		&webhook.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func scanDelivery(row scanner, withTarget bool) (*models.WebhookDelivery, error) {
+	delivery := &models.WebhookDelivery{}
+	var payload []byte
+	var durationMs int64
+	var deliveredAt sql.NullTime
+
+	dest := []any{
+		&delivery.ID,
+		&delivery.WebhookID,
+		&delivery.EventID,
+		&delivery.EventType,
+		&payload,
+		&delivery.State,
+		&delivery.Attempts,
+		&delivery.LastStatusCode,
+		&delivery.LastError,
+		&durationMs,
+		&delivery.NextAttemptAt,
+		&deliveredAt,
+		&delivery.CreatedAt,
+	}
+	if withTarget {
+		dest = append(dest, &delivery.URL, &delivery.Secret)
+	}
+
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	delivery.Payload = payload
+	delivery.LastDuration = time.Duration(durationMs) * time.Millisecond
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+	return delivery, nil
+}
+
+func matchesEvent(filters []string, eventType string) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	for _, filter := range filters {
+		if filter == eventType || filter == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package rest
+
+import (
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"github.com/gofiber/fiber/v2"
+	"strings"
+)
+
+type AlbumHandler struct {
+	service ports.IAlbumService
+	opts    *models.Options
+}
+
+func NewAlbumHandler(service ports.IAlbumService, opts *models.Options) *AlbumHandler {
+	return &AlbumHandler{
+		service: service,
+		opts:    opts,
+	}
+}
+
+func (h *AlbumHandler) CreateAlbum(c *fiber.Ctx) error {
+	var req models.CreateAlbumRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	album, err := h.service.CreateAlbum(c.UserContext(), &req)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(album)
+}
+
+func (h *AlbumHandler) ListAlbums(c *fiber.Ctx) error {
+	albums, err := h.service.ListAlbums(c.UserContext(), c.Query("owner_id"), c.QueryInt("limit", 0))
+	if err != nil {
+		return err
+	}
+
+	if albums == nil {
+		albums = []*models.Album{}
+	}
+
+	return c.JSON(fiber.Map{"albums": albums})
+}
+
+func (h *AlbumHandler) GetAlbum(c *fiber.Ctx) error {
+	album, err := h.service.GetAlbum(c.UserContext(), c.Query("owner_id"), c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(album)
+}
+
+// updateAlbumBody tells omitted fields from empty ones, so a PATCH only
+// changes the fields present in the body.
+type updateAlbumBody struct {
+	Title        *string `json:"title"`
+	Description  *string `json:"description"`
+	CoverMediaID *string `json:"cover_media_id"`
+}
+
+func (b *updateAlbumBody) request(id, ownerID string) *models.UpdateAlbumRequest {
+	req := &models.UpdateAlbumRequest{ID: id, OwnerID: ownerID}
+	if b.Title != nil {
+		req.Title = *b.Title
+		req.UpdateMask = append(req.UpdateMask, models.AlbumFieldTitle)
+	}
+	if b.Description != nil {
+		req.Description = *b.Description
+		req.UpdateMask = append(req.UpdateMask, models.AlbumFieldDescription)
+	}
+	if b.CoverMediaID != nil {
+		req.CoverMediaID = *b.CoverMediaID
+		req.UpdateMask = append(req.UpdateMask, models.AlbumFieldCover)
+	}
+	return req
+}
+
+func (h *AlbumHandler) UpdateAlbum(c *fiber.Ctx) error {
+	var body updateAlbumBody
+	if err := c.BodyParser(&body); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	album, err := h.service.UpdateAlbum(c.UserContext(), body.request(c.Params("id"), c.Query("owner_id")))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(album)
+}
+
+func (h *AlbumHandler) DeleteAlbum(c *fiber.Ctx) error {
+	if err := h.service.DeleteAlbum(c.UserContext(), c.Query("owner_id"), c.Params("id")); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *AlbumHandler) AddMedia(c *fiber.Ctx) error {
+	var req models.AlbumMediaRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	album, err := h.service.AddMedia(c.UserContext(), c.Query("owner_id"), c.Params("id"), req.MediaIDs)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(album)
+}
+
+func (h *AlbumHandler) RemoveMedia(c *fiber.Ctx) error {
+	album, err := h.service.RemoveMedia(c.UserContext(), c.Query("owner_id"), c.Params("id"), strings.Split(c.Query("media_ids"), ","))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(album)
+}
+
+func (h *AlbumHandler) ReorderMedia(c *fiber.Ctx) error {
+	var req models.AlbumMediaRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	album, err := h.service.ReorderMedia(c.UserContext(), c.Query("owner_id"), c.Params("id"), req.MediaIDs)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(album)
+}
+
+func (h *AlbumHandler) ListAlbumMedia(c *fiber.Ctx) error {
+	page, err := h.service.ListAlbumMedia(c.UserContext(), c.Query("owner_id"), c.Params("id"), c.Query("page_token"), c.QueryInt("limit", 0))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(page)
+}
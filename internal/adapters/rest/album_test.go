@@ -0,0 +1,26 @@
+package rest
+
+import (
+	"encoding/json"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"slices"
+	"testing"
+)
+
+func TestUpdateAlbumBodyMasksPresentFields(t *testing.T) {
+	for body, want := range map[string][]string{
+		`{}`:                                   nil,
+		`{"title":"Trip"}`:                     {models.AlbumFieldTitle},
+		`{"description":""}`:                   {models.AlbumFieldDescription},
+		`{"cover_media_id":"","title":"Trip"}`: {models.AlbumFieldTitle, models.AlbumFieldCover},
+	} {
+		var parsed updateAlbumBody
+		if err := json.Unmarshal([]byte(body), &parsed); err != nil {
+			t.Fatal(err)
+		}
+		req := parsed.request("a1", "alice")
+		if req.ID != "a1" || req.OwnerID != "alice" || !slices.Equal(req.UpdateMask, want) {
+			t.Errorf("%s: request() = %+v, want mask %v", body, req, want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package rest
+
+import (
+	"fmt"
+	"github.com/co1seam/ember-backend-media/internal/adapters/ratelimit"
+	"github.com/gofiber/fiber/v2"
+	"io"
+	"strings"
+	"time"
+)
+
+// ArchiveMedia streams a ZIP of the media listed in ids (comma separated).
+// Each item is checked against owner_id before the response starts; the
+// archive itself is built while it is sent, so its length is not known.
+// Archives are only offered here: the gRPC contract comes from the shared
+// api-contracts module, which has no archive operation to implement.
+func (h *MediaHandler) ArchiveMedia(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	archive, err := h.service.PlanArchive(ctx, c.Query("owner_id"), strings.Split(c.Query("ids"), ","))
+	if err != nil {
+		return err
+	}
+
+	release, err := h.limiter.AcquireStream(ctx, ratelimit.DirectionDownload, limitOwner(c))
+	if err != nil {
+		return limitResponse(c, err)
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		err := h.service.WriteArchive(ctx, archive, writer)
+		if err != nil {
+			h.opts.Logger.WarnContext(ctx, "archive stream aborted", "error", err)
+		}
+		writer.CloseWithError(err)
+	}()
+
+	name := fmt.Sprintf("media-%s.zip", archive.CreatedAt.Format(time.DateOnly))
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", name))
+
+	return h.sendDownload(c, reader, release, -1)
+}
@@ -0,0 +1,58 @@
+package rest
+
+import (
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"github.com/gofiber/fiber/v2"
+	"time"
+)
+
+type AuditHandler struct {
+	service ports.IAuditService
+	opts    *models.Options
+}
+
+func NewAuditHandler(service ports.IAuditService, opts *models.Options) *AuditHandler {
+	return &AuditHandler{
+		service: service,
+		opts:    opts,
+	}
+}
+
+// ListAuditEvents filters by media_id, actor and an RFC 3339 since/until
+// range, newest first.
+func (h *AuditHandler) ListAuditEvents(c *fiber.Ctx) error {
+	query := &models.AuditQuery{
+		MediaID: c.Query("media_id"),
+		Actor:   c.Query("actor"),
+		Limit:   c.QueryInt("limit", 0),
+	}
+
+	var err error
+	if query.Since, err = queryTime(c, "since"); err != nil {
+		return err
+	}
+	if query.Until, err = queryTime(c, "until"); err != nil {
+		return err
+	}
+
+	page, err := h.service.ListAuditEvents(c.UserContext(), query, c.Query("page_token"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(page)
+}
+
+func queryTime(c *fiber.Ctx, key string) (time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fiber.NewError(fiber.StatusBadRequest, "invalid "+key+" time")
+	}
+	return t, nil
+}
@@ -0,0 +1,88 @@
+package rest
+
+import (
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+type batchError struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+type batchItem struct {
+	ID    string        `json:"id"`
+	Media *models.Media `json:"media,omitempty"`
+	Error *batchError   `json:"error,omitempty"`
+}
+
+type batchIDsRequest struct {
+	IDs []string `json:"ids"`
+}
+
+func (h *MediaHandler) BatchGetMedia(c *fiber.Ctx) error {
+	var req batchIDsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	results, err := h.service.BatchGetMedia(c.UserContext(), req.IDs)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"results": batchItems(results)})
+}
+
+func (h *MediaHandler) BatchUpdateMedia(c *fiber.Ctx) error {
+	var body struct {
+		Requests []*updateMediaBody `json:"requests"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	reqs := make([]*models.UpdateMediaRequest, 0, len(body.Requests))
+	for _, item := range body.Requests {
+		if item == nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		}
+		reqs = append(reqs, item.request())
+	}
+
+	results, err := h.service.BatchUpdateMedia(c.UserContext(), reqs)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"results": batchItems(results)})
+}
+
+func (h *MediaHandler) BatchDeleteMedia(c *fiber.Ctx) error {
+	var req batchIDsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	results, err := h.service.BatchDeleteMedia(c.UserContext(), req.IDs)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"results": batchItems(results)})
+}
+
+// batchItems renders per-item errors the way the error handler renders a
+// failed request, so internal details stay hidden.
+func batchItems(results []*models.BatchResult) []*batchItem {
+	items := make([]*batchItem, 0, len(results))
+	for _, result := range results {
+		item := &batchItem{ID: result.ID, Media: result.Media}
+		if result.Err != nil {
+			status, message := statusFromError(result.Err)
+			item.Error = &batchError{Status: status, Message: message}
+		}
+		items = append(items, item)
+	}
+	return items
+}
@@ -0,0 +1,32 @@
+package rest
+
+import (
+	"errors"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/gofiber/fiber/v2"
+	"testing"
+)
+
+func TestBatchItemsHideInternalErrors(t *testing.T) {
+	items := batchItems([]*models.BatchResult{
+		{ID: "a", Media: &models.Media{ID: "a"}},
+		{ID: "b", Err: models.ErrMediaNotFound},
+		{ID: "c", Err: models.ErrMediaModified},
+		{ID: "d", Err: errors.New("pq: deadlock detected")},
+	})
+
+	if len(items) != 4 || items[0].Error != nil || items[0].Media == nil {
+		t.Fatalf("items = %+v", items)
+	}
+	want := []batchError{
+		{fiber.StatusNotFound, "media not found"},
+		{fiber.StatusPreconditionFailed, "media was modified concurrently"},
+		{fiber.StatusInternalServerError, "internal error"},
+	}
+	for i, w := range want {
+		item := items[i+1]
+		if item.Media != nil || item.Error == nil || *item.Error != w {
+			t.Errorf("item %s = %+v, want error %+v", item.ID, item.Error, w)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package rest
+
+import (
+	"github.com/co1seam/ember-backend-media/internal/core/errs"
+	"github.com/gofiber/fiber/v2"
+)
+
+func statusFromError(err error) (int, string) {
+	switch errs.KindOf(err) {
+	case errs.KindNotFound:
+		return fiber.StatusNotFound, errs.Message(err)
+	case errs.KindInvalidArgument:
+		return fiber.StatusBadRequest, errs.Message(err)
+	case errs.KindPermissionDenied:
+		return fiber.StatusForbidden, errs.Message(err)
+	case errs.KindConflict, errs.KindFailedPrecondition:
+		return fiber.StatusConflict, errs.Message(err)
+	case errs.KindAborted:
+		return fiber.StatusPreconditionFailed, errs.Message(err)
+	default:
+		return fiber.StatusInternalServerError, "internal error"
+	}
+}
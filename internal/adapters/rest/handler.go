@@ -0,0 +1,34 @@
+package rest
+
+import (
+	"github.com/co1seam/ember-backend-media/internal/adapters/health"
+	"github.com/co1seam/ember-backend-media/internal/adapters/ratelimit"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/core/services"
+)
+
+type Handler struct {
+	Media    *MediaHandler
+	Albums   *AlbumHandler
+	Webhooks *WebhookHandler
+	Privacy  *PrivacyHandler
+	Audit    *AuditHandler
+	Health   *HealthHandler
+	Settings *SettingsHandler
+	Limiter  *ratelimit.Limiter
+	opts     *models.Options
+}
+
+func NewHandler(service *services.Services, checker *health.Checker, limiter *ratelimit.Limiter, opts *models.Options) *Handler {
+	return &Handler{
+		Limiter:  limiter,
+		Media:    NewMediaHandler(service.Media, limiter, opts),
+		Albums:   NewAlbumHandler(service.Albums, opts),
+		Webhooks: NewWebhookHandler(service.Webhooks, opts),
+		Privacy:  NewPrivacyHandler(service.Privacy, opts),
+		Audit:    NewAuditHandler(service.Audit, opts),
+		Health:   NewHealthHandler(checker),
+		Settings: NewSettingsHandler(opts.Settings),
+		opts:     opts,
+	}
+}
@@ -0,0 +1,32 @@
+package rest
+
+import (
+	"github.com/co1seam/ember-backend-media/internal/adapters/health"
+	"github.com/gofiber/fiber/v2"
+)
+
+type HealthHandler struct {
+	checker *health.Checker
+}
+
+func NewHealthHandler(checker *health.Checker) *HealthHandler {
+	return &HealthHandler{checker: checker}
+}
+
+// Live only tells that the process serves HTTP; dependency outages must not
+// get the pod restarted.
+func (h *HealthHandler) Live(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+func (h *HealthHandler) Ready(c *fiber.Ctx) error {
+	status, code := "ready", fiber.StatusOK
+	if !h.checker.Ready() {
+		status, code = "not ready", fiber.StatusServiceUnavailable
+	}
+
+	return c.Status(code).JSON(fiber.Map{
+		"status":       status,
+		"dependencies": h.checker.Report(),
+	})
+}
@@ -0,0 +1,87 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/co1seam/ember-backend-media/internal/adapters/health"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/gofiber/fiber/v2"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+)
+
+type pingFunc func(ctx context.Context) error
+
+func (f pingFunc) Ping(ctx context.Context) error {
+	return f(ctx)
+}
+
+func TestHealthProbes(t *testing.T) {
+	var minioErr error
+	checker := health.NewChecker(&models.Options{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	checker.Register("postgres", pingFunc(func(ctx context.Context) error { return nil }))
+	checker.Register("minio", pingFunc(func(ctx context.Context) error { return minioErr }))
+
+	handler := NewHealthHandler(checker)
+	app := fiber.New()
+	app.Get("/healthz", handler.Live)
+	app.Get("/readyz", handler.Ready)
+
+	probe := func(path string) (int, map[string]any) {
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, body
+	}
+
+	minioErr = errors.New("connection refused")
+	checker.Start(context.Background())
+	defer checker.Stop()
+
+	if status, _ := probe("/healthz"); status != fiber.StatusOK {
+		t.Errorf("/healthz = %d with minio down, want 200", status)
+	}
+	status, body := probe("/readyz")
+	if status != fiber.StatusServiceUnavailable || body["status"] != "not ready" {
+		t.Errorf("/readyz = %d %v, want 503", status, body)
+	}
+	deps, _ := body["dependencies"].(map[string]any)
+	minio, _ := deps["minio"].(map[string]any)
+	if minio["healthy"] != false || minio["error"] != "connection refused" {
+		t.Errorf("minio = %v, want it reported down", minio)
+	}
+
+	checker.Shutdown()
+	if status, _ := probe("/readyz"); status != fiber.StatusServiceUnavailable {
+		t.Errorf("/readyz = %d during shutdown, want 503", status)
+	}
+	if status, _ := probe("/healthz"); status != fiber.StatusOK {
+		t.Errorf("/healthz = %d during shutdown, want 200", status)
+	}
+}
+
+func TestReadyWhenDependenciesAreUp(t *testing.T) {
+	checker := health.NewChecker(&models.Options{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	checker.Register("postgres", pingFunc(func(ctx context.Context) error { return nil }))
+	checker.Start(context.Background())
+	defer checker.Stop()
+
+	app := fiber.New()
+	app.Get("/readyz", NewHealthHandler(checker).Ready)
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/readyz", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Errorf("/readyz = %d, want 200", resp.StatusCode)
+	}
+}
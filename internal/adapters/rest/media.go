@@ -0,0 +1,480 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/co1seam/ember-backend-media/internal/adapters/metrics"
+	"github.com/co1seam/ember-backend-media/internal/adapters/ratelimit"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"github.com/gofiber/fiber/v2"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// metadataQueryPrefix marks ListMedia query parameters filtering on
+// metadata, as in ?meta.camera=x100.
+const metadataQueryPrefix = "meta."
+
+// multipartOverhead allows for the boundaries and part headers around the
+// file when a multipart upload is checked by its Content-Length.
+const multipartOverhead = 64 << 10
+
+type MediaHandler struct {
+	service ports.IMediaService
+	limiter *ratelimit.Limiter
+	opts    *models.Options
+}
+
+type mediaResponse struct {
+	*models.Media
+	Size int64 `json:"size"`
+}
+
+func NewMediaHandler(service ports.IMediaService, limiter *ratelimit.Limiter, opts *models.Options) *MediaHandler {
+	return &MediaHandler{
+		service: service,
+		limiter: limiter,
+		opts:    opts,
+	}
+}
+
+func (h *MediaHandler) CreateMedia(c *fiber.Ctx) error {
+	var req models.CreateMediaRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	media, err := h.service.CreateMedia(c.UserContext(), &req)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(media)
+}
+
+func (h *MediaHandler) GetMedia(c *fiber.Ctx) error {
+	media, err := h.service.GetMedia(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	resp := mediaResponse{Media: media, Size: media.Size}
+	if resp.Size == 0 && media.ServeError() == nil && media.StoragePath != "" {
+		info, err := h.service.GetStatFile(c.UserContext(), media.StoragePath)
+		if err == nil {
+			resp.Size = info.Size
+		}
+	}
+
+	return c.JSON(resp)
+}
+
+// updateMediaBody tells omitted fields from empty ones. Without an
+// update_mask, the fields present in the body are updated, so an omitted
+// field is never blanked.
+type updateMediaBody struct {
+	ID          string   `json:"id"`
+	Title       *string  `json:"title"`
+	Description *string  `json:"description"`
+	UpdateMask  []string `json:"update_mask"`
+	Revision    int64    `json:"revision"`
+}
+
+func (b *updateMediaBody) request() *models.UpdateMediaRequest {
+	req := &models.UpdateMediaRequest{
+		ID:         b.ID,
+		UpdateMask: b.UpdateMask,
+		Revision:   b.Revision,
+	}
+	if b.Title != nil {
+		req.Title = *b.Title
+		if b.UpdateMask == nil {
+			req.UpdateMask = append(req.UpdateMask, models.MediaFieldTitle)
+		}
+	}
+	if b.Description != nil {
+		req.Description = *b.Description
+		if b.UpdateMask == nil {
+			req.UpdateMask = append(req.UpdateMask, models.MediaFieldDescription)
+		}
+	}
+	return req
+}
+
+func (h *MediaHandler) UpdateMedia(c *fiber.Ctx) error {
+	var body updateMediaBody
+	if err := c.BodyParser(&body); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+	body.ID = c.Params("id")
+
+	media, err := h.service.UpdateMedia(c.UserContext(), body.request())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(media)
+}
+
+func (h *MediaHandler) DeleteMedia(c *fiber.Ctx) error {
+	if err := h.service.DeleteMedia(c.UserContext(), c.Params("id")); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *MediaHandler) ListMedia(c *fiber.Ctx) error {
+	ownerID := c.Query("owner_id")
+	if ownerID == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "owner_id is required")
+	}
+
+	req := &models.ListMediaRequest{
+		OwnerID: ownerID,
+		Limit:   c.QueryInt("limit", models.DefaultListLimit),
+	}
+	if tags := c.Query("tags"); tags != "" {
+		req.Tags = strings.Split(tags, ",")
+	}
+	for key, value := range c.Queries() {
+		if name, ok := strings.CutPrefix(key, metadataQueryPrefix); ok {
+			if req.Metadata == nil {
+				req.Metadata = make(map[string]string)
+			}
+			req.Metadata[name] = value
+		}
+	}
+
+	mediaList, err := h.service.ListMedia(c.UserContext(), req)
+	if err != nil {
+		return err
+	}
+
+	if mediaList == nil {
+		mediaList = []*models.Media{}
+	}
+
+	return c.JSON(fiber.Map{"media": mediaList})
+}
+
+func (h *MediaHandler) AddTags(c *fiber.Ctx) error {
+	var req models.TagsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	media, err := h.service.AddTags(c.UserContext(), c.Params("id"), req.Tags)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(media)
+}
+
+func (h *MediaHandler) RemoveTags(c *fiber.Ctx) error {
+	media, err := h.service.RemoveTags(c.UserContext(), c.Params("id"), strings.Split(c.Query("tags"), ","))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(media)
+}
+
+func (h *MediaHandler) PatchMetadata(c *fiber.Ctx) error {
+	var req models.PatchMetadataRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	media, err := h.service.PatchMetadata(c.UserContext(), c.Params("id"), &req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(media)
+}
+
+func (h *MediaHandler) SearchMedia(c *fiber.Ctx) error {
+	req := &models.SearchMediaRequest{
+		Query:   c.Query("q"),
+		OwnerID: c.Query("owner_id"),
+		Limit:   c.QueryInt("limit", models.DefaultSearchLimit),
+		Offset:  c.QueryInt("offset", 0),
+	}
+	if contentTypes := c.Query("content_type"); contentTypes != "" {
+		req.ContentTypes = strings.Split(contentTypes, ",")
+	}
+
+	hits, err := h.service.SearchMedia(c.UserContext(), req)
+	if err != nil {
+		return err
+	}
+
+	if hits == nil {
+		hits = []*models.MediaSearchHit{}
+	}
+
+	return c.JSON(fiber.Map{"results": hits})
+}
+
+func (h *MediaHandler) UploadRaw(c *fiber.Ctx) error {
+	release, err := h.limiter.AcquireStream(c.UserContext(), ratelimit.DirectionUpload, limitOwner(c))
+	if err != nil {
+		return limitResponse(c, err)
+	}
+	defer release()
+
+	metrics.ActiveStreams.WithLabelValues("upload", "http").Inc()
+	defer metrics.ActiveStreams.WithLabelValues("upload", "http").Dec()
+
+	fileName := c.Query("filename")
+	if fileName == "" {
+		if _, params, err := mime.ParseMediaType(c.Get(fiber.HeaderContentDisposition)); err == nil {
+			fileName = params["filename"]
+		}
+	}
+	if fileName == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "filename is required")
+	}
+	fileName = filepath.Base(fileName)
+
+	limit := h.opts.Settings.Load().Media.MaxFileSize
+	if int64(c.Request().Header.ContentLength()) > limit {
+		return fileTooLarge(limit)
+	}
+
+	tempFile, err := os.CreateTemp("", fileName+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+	defer tempFile.Close()
+
+	var size int64
+	if stream := c.Context().RequestBodyStream(); stream != nil {
+		size, err = io.Copy(tempFile, io.LimitReader(stream, limit+1))
+	} else {
+		var n int
+		n, err = tempFile.Write(c.Body())
+		size = int64(n)
+	}
+	if err != nil {
+		return err
+	}
+	if size > limit {
+		return fileTooLarge(limit)
+	}
+
+	if _, err := tempFile.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	return h.upload(c, fileName, size, tempFile)
+}
+
+func (h *MediaHandler) UploadMultipart(c *fiber.Ctx) error {
+	release, err := h.limiter.AcquireStream(c.UserContext(), ratelimit.DirectionUpload, limitOwner(c))
+	if err != nil {
+		return limitResponse(c, err)
+	}
+	defer release()
+
+	metrics.ActiveStreams.WithLabelValues("upload", "http").Inc()
+	defer metrics.ActiveStreams.WithLabelValues("upload", "http").Dec()
+
+	limit := h.opts.Settings.Load().Media.MaxFileSize
+	if int64(c.Request().Header.ContentLength()) > limit+multipartOverhead {
+		return fileTooLarge(limit)
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "multipart field \"file\" is required")
+	}
+	if header.Size > limit {
+		return fileTooLarge(limit)
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return h.upload(c, filepath.Base(header.Filename), header.Size, file)
+}
+
+// fileTooLarge rejects an upload over MEDIA_MAX_FILE_SIZE. The limit is read
+// per request, so a reloaded value applies to the next upload.
+func fileTooLarge(limit int64) error {
+	return fiber.NewError(fiber.StatusRequestEntityTooLarge, fmt.Sprintf("file exceeds the %d byte limit", limit))
+}
+
+func (h *MediaHandler) upload(c *fiber.Ctx, fileName string, size int64, body io.Reader) error {
+	fileID := c.Params("id")
+	metrics.BytesUploaded.WithLabelValues("http").Add(float64(size))
+
+	path, err := h.service.UploadFile(c.UserContext(), fileID, fileName, size, body)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"file_id": fileID,
+		"url":     path,
+	})
+}
+
+func (h *MediaHandler) DownloadFile(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	media, err := h.service.GetMedia(ctx, c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	if media.OwnerID != c.Query("owner_id") {
+		return models.ErrPermissionDenied
+	}
+
+	if err := media.ServeError(); err != nil {
+		return err
+	}
+
+	info, err := h.service.GetStatFile(ctx, media.StoragePath)
+	if err != nil {
+		return err
+	}
+
+	etag := strconv.Quote(info.ETag)
+	c.Set(fiber.HeaderETag, etag)
+	c.Set(fiber.HeaderLastModified, info.LastModified.UTC().Format(http.TimeFormat))
+	c.Set(fiber.HeaderAcceptRanges, "bytes")
+	c.Set(fiber.HeaderContentType, media.ContentType)
+
+	if etagMatches(c.Get(fiber.HeaderIfNoneMatch), etag) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	start, end := int64(0), info.Size-1
+	status := fiber.StatusOK
+
+	rangeHeader := c.Get(fiber.HeaderRange)
+	if rangeHeader != "" && ifRangeMatches(c.Get(fiber.HeaderIfRange), etag, info.LastModified) {
+		r, err := parseRange(rangeHeader, info.Size)
+		switch {
+		case errors.Is(err, errUnsatisfiableRange):
+			c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes */%d", info.Size))
+			return c.SendStatus(fiber.StatusRequestedRangeNotSatisfiable)
+		case err == nil && r != nil:
+			start, end = r.start, r.end
+			status = fiber.StatusPartialContent
+			c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes %d-%d/%d", start, end, info.Size))
+		}
+	}
+
+	length := end - start + 1
+	c.Status(status)
+
+	if c.Method() == fiber.MethodHead || length <= 0 {
+		c.Context().Response.Header.SetContentLength(int(max(length, 0)))
+		return nil
+	}
+
+	release, err := h.limiter.AcquireStream(ctx, ratelimit.DirectionDownload, limitOwner(c))
+	if err != nil {
+		return limitResponse(c, err)
+	}
+
+	reader, err := h.service.DownloadFileRange(ctx, media.StoragePath, start, end)
+	if err != nil {
+		release()
+		return err
+	}
+
+	return h.sendDownload(c, reader, release, int(length))
+}
+
+func (h *MediaHandler) ListQuarantined(c *fiber.Ctx) error {
+	mediaList, err := h.service.ListQuarantined(c.UserContext(), c.QueryInt("limit", 50))
+	if err != nil {
+		return err
+	}
+
+	if mediaList == nil {
+		mediaList = []*models.Media{}
+	}
+
+	return c.JSON(fiber.Map{"media": mediaList})
+}
+
+func (h *MediaHandler) ReleaseQuarantined(c *fiber.Ctx) error {
+	media, err := h.service.ReleaseQuarantined(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(media)
+}
+
+func (h *MediaHandler) PurgeQuarantined(c *fiber.Ctx) error {
+	if err := h.service.PurgeQuarantined(c.UserContext(), c.Params("id")); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// sendDownload streams reader as the response body, paced by the download
+// bandwidth limit. release frees the stream slot once the body is closed.
+func (h *MediaHandler) sendDownload(c *fiber.Ctx, reader io.ReadCloser, release func(), size int) error {
+	metrics.ActiveStreams.WithLabelValues("download", "http").Inc()
+	return c.SendStream(&countingReader{
+		ReadCloser: reader,
+		transport:  "http",
+		ctx:        c.UserContext(),
+		throttle:   h.limiter.DownloadThrottle(),
+		release:    release,
+	}, size)
+}
+
+// countingReader records streamed bytes and releases the active stream gauge
+// and stream slot once fasthttp closes the body after writing the response.
+type countingReader struct {
+	io.ReadCloser
+	transport string
+	ctx       context.Context
+	throttle  *ratelimit.Throttle
+	release   func()
+	closed    bool
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	metrics.BytesDownloaded.WithLabelValues(r.transport).Add(float64(n))
+	if n > 0 && r.throttle != nil {
+		if waitErr := r.throttle.Wait(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+func (r *countingReader) Close() error {
+	if !r.closed {
+		r.closed = true
+		metrics.ActiveStreams.WithLabelValues("download", r.transport).Dec()
+		if r.release != nil {
+			r.release()
+		}
+	}
+	return r.ReadCloser.Close()
+}
@@ -0,0 +1,186 @@
+package rest
+
+import (
+	"context"
+	"github.com/co1seam/ember-backend-media/config"
+	"github.com/co1seam/ember-backend-media/internal/adapters/ratelimit"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"github.com/gofiber/fiber/v2"
+	"github.com/minio/minio-go/v7"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const fileContent = "0123456789abcdefghij"
+
+type fakeMediaService struct {
+	ports.IMediaService
+	media    *models.Media
+	modified time.Time
+}
+
+func (s *fakeMediaService) GetMedia(ctx context.Context, id string) (*models.Media, error) {
+	if id != s.media.ID {
+		return nil, models.ErrMediaNotFound
+	}
+	media := *s.media
+	return &media, nil
+}
+
+func (s *fakeMediaService) GetStatFile(ctx context.Context, objectName string) (*minio.ObjectInfo, error) {
+	return &minio.ObjectInfo{ETag: "v1", Size: int64(len(fileContent)), LastModified: s.modified}, nil
+}
+
+func (s *fakeMediaService) DownloadFileRange(ctx context.Context, objectName string, start, end int64) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(fileContent[start : end+1])), nil
+}
+
+func newDownloadApp(t *testing.T, cfg config.RateLimit) (*fiber.App, *ratelimit.Limiter) {
+	t.Helper()
+	opts := &models.Options{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	limiter, err := ratelimit.New(&cfg, nil, opts.Logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := &fakeMediaService{
+		media:    &models.Media{ID: "m1", OwnerID: "owner", StoragePath: "owner/m1", ContentType: "text/plain"},
+		modified: time.Date(2024, 5, 1, 10, 30, 15, 0, time.UTC),
+	}
+
+	app := fiber.New(fiber.Config{ErrorHandler: errorHandler(opts)})
+	handler := NewMediaHandler(service, limiter, opts)
+	app.Get("/media/:id/file", handler.DownloadFile)
+	app.Put("/media/:id/file", handler.UploadRaw)
+	return app, limiter
+}
+
+func download(t *testing.T, app *fiber.App, headers map[string]string) (*http.Response, string) {
+	t.Helper()
+	req := httptest.NewRequest(fiber.MethodGet, "/media/m1/file?owner_id=owner", nil)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return resp, string(body)
+}
+
+func TestDownloadFileRanges(t *testing.T) {
+	app, _ := newDownloadApp(t, config.RateLimit{})
+	lastModified := "Wed, 01 May 2024 10:30:15 GMT"
+
+	for name, tc := range map[string]struct {
+		headers      map[string]string
+		status       int
+		body         string
+		contentRange string
+	}{
+		"full": {
+			status: fiber.StatusOK, body: fileContent,
+		},
+		"range": {
+			headers: map[string]string{"Range": "bytes=2-5"},
+			status:  fiber.StatusPartialContent, body: "2345", contentRange: "bytes 2-5/20",
+		},
+		"suffix": {
+			headers: map[string]string{"Range": "bytes=-3"},
+			status:  fiber.StatusPartialContent, body: "hij", contentRange: "bytes 17-19/20",
+		},
+		"unsatisfiable": {
+			headers: map[string]string{"Range": "bytes=20-"},
+			status:  fiber.StatusRequestedRangeNotSatisfiable, body: "Requested Range Not Satisfiable", contentRange: "bytes */20",
+		},
+		"if-range etag matches": {
+			headers: map[string]string{"Range": "bytes=0-1", "If-Range": `"v1"`},
+			status:  fiber.StatusPartialContent, body: "01", contentRange: "bytes 0-1/20",
+		},
+		"if-range etag changed": {
+			headers: map[string]string{"Range": "bytes=0-1", "If-Range": `"v0"`},
+			status:  fiber.StatusOK, body: fileContent,
+		},
+		"if-range date matches": {
+			headers: map[string]string{"Range": "bytes=0-1", "If-Range": lastModified},
+			status:  fiber.StatusPartialContent, body: "01", contentRange: "bytes 0-1/20",
+		},
+		"if-range stale date": {
+			headers: map[string]string{"Range": "bytes=20-", "If-Range": "Tue, 30 Apr 2024 10:30:15 GMT"},
+			status:  fiber.StatusOK, body: fileContent,
+		},
+		"not modified": {
+			headers: map[string]string{"If-None-Match": `"v1"`},
+			status:  fiber.StatusNotModified,
+		},
+	} {
+		resp, body := download(t, app, tc.headers)
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s: status = %d, want %d", name, resp.StatusCode, tc.status)
+			continue
+		}
+		if body != tc.body {
+			t.Errorf("%s: body = %q, want %q", name, body, tc.body)
+		}
+		if got := resp.Header.Get("Content-Range"); got != tc.contentRange {
+			t.Errorf("%s: Content-Range = %q, want %q", name, got, tc.contentRange)
+		}
+	}
+}
+
+func TestDownloadFileChecksOwner(t *testing.T) {
+	req := httptest.NewRequest(fiber.MethodGet, "/media/m1/file?owner_id=intruder", nil)
+	app, _ := newDownloadApp(t, config.RateLimit{})
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusForbidden {
+		t.Fatalf("status = %d, want 403", resp.StatusCode)
+	}
+}
+
+func TestTransfersHonourStreamCaps(t *testing.T) {
+	app, limiter := newDownloadApp(t, config.RateLimit{MaxUploads: 1, MaxDownloads: 1})
+	ctx := context.Background()
+
+	held, err := limiter.AcquireStream(ctx, ratelimit.DirectionDownload, "owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, _ := download(t, app, nil); resp.StatusCode != fiber.StatusTooManyRequests {
+		t.Fatalf("download with the slot taken: status = %d, want 429", resp.StatusCode)
+	}
+	held()
+
+	if resp, body := download(t, app, nil); resp.StatusCode != fiber.StatusOK || body != fileContent {
+		t.Fatalf("download: status = %d, body = %q", resp.StatusCode, body)
+	}
+	release, err := limiter.AcquireStream(ctx, ratelimit.DirectionDownload, "owner")
+	if err != nil {
+		t.Fatalf("slot not released after the download: %v", err)
+	}
+	release()
+
+	held, err = limiter.AcquireStream(ctx, ratelimit.DirectionUpload, "owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held()
+
+	req := httptest.NewRequest(fiber.MethodPut, "/media/m1/file?owner_id=owner&filename=a.txt", strings.NewReader("data"))
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusTooManyRequests {
+		t.Fatalf("upload with the slot taken: status = %d, want 429", resp.StatusCode)
+	}
+}
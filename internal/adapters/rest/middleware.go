@@ -0,0 +1,170 @@
+package rest
+
+import (
+	"crypto/subtle"
+	"errors"
+	"github.com/co1seam/ember-backend-media/internal/adapters/logging"
+	"github.com/co1seam/ember-backend-media/internal/adapters/ratelimit"
+	"github.com/co1seam/ember-backend-media/internal/core/auth"
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var tracer = otel.Tracer("github.com/co1seam/ember-backend-media/internal/adapters/rest")
+
+// headerCarrier exposes the request headers to the otel propagator.
+type headerCarrier struct {
+	c *fiber.Ctx
+}
+
+func (h headerCarrier) Get(key string) string { return h.c.Get(key) }
+
+func (h headerCarrier) Set(key, value string) { h.c.Request().Header.Set(key, value) }
+
+func (h headerCarrier) Keys() []string {
+	keys := make([]string, 0)
+	h.c.Request().Header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
+
+func tracingMiddleware(c *fiber.Ctx) error {
+	ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), headerCarrier{c: c})
+
+	ctx, span := tracer.Start(ctx, c.Method(),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", c.Method()),
+			attribute.String("url.path", c.Path()),
+		),
+	)
+	defer span.End()
+	c.SetUserContext(ctx)
+
+	err := c.Next()
+
+	span.SetName(c.Method() + " " + c.Route().Path)
+	span.SetAttributes(attribute.String("http.route", c.Route().Path))
+	status := c.Response().StatusCode()
+	if err != nil {
+		span.RecordError(err)
+		status, _ = statusFromError(err)
+		if e, ok := err.(*fiber.Error); ok {
+			status = e.Code
+		}
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", status))
+	if status >= fiber.StatusInternalServerError {
+		span.SetStatus(codes.Error, "")
+	}
+
+	return err
+}
+
+func loggingMiddleware(logger *slog.Logger) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := logging.RequestID(c.Get(fiber.HeaderXRequestID))
+		c.Set(fiber.HeaderXRequestID, id)
+		c.SetUserContext(auth.WithClientIP(auth.WithRequestID(c.UserContext(), id), c.IP()))
+		started := time.Now()
+
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			status, _ = statusFromError(err)
+			if e, ok := err.(*fiber.Error); ok {
+				status = e.Code
+			}
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		logger.Log(c.UserContext(), level, "http request",
+			"method", c.Method(),
+			"route", c.Route().Path,
+			"status", status,
+			"duration_ms", time.Since(started).Milliseconds(),
+			"peer", c.IP(),
+		)
+
+		return err
+	}
+}
+
+// rateLimitMiddleware returns a per-route middleware sharing the buckets of
+// the gRPC method of the same name, keyed by owner_id when the request names
+// one and by client IP otherwise.
+func rateLimitMiddleware(limiter *ratelimit.Limiter) func(method string) fiber.Handler {
+	return func(method string) fiber.Handler {
+		return func(c *fiber.Ctx) error {
+			if err := limitResponse(c, limiter.Allow(c.UserContext(), method, limitOwner(c))); err != nil {
+				return err
+			}
+
+			return c.Next()
+		}
+	}
+}
+
+// limitOwner is the key rate limits and stream caps are counted under.
+func limitOwner(c *fiber.Ctx) string {
+	if owner := c.Query("owner_id"); owner != "" {
+		return owner
+	}
+	return "peer:" + c.IP()
+}
+
+// limitResponse turns a limiter refusal into a 429, with Retry-After set
+// when the limiter knows how long to wait.
+func limitResponse(c *fiber.Ctx, err error) error {
+	var limitErr *ratelimit.LimitError
+	if !errors.As(err, &limitErr) {
+		return err
+	}
+
+	if limitErr.RetryAfter > 0 {
+		c.Set(fiber.HeaderRetryAfter, strconv.FormatInt(int64(math.Ceil(limitErr.RetryAfter.Seconds())), 10))
+	}
+	return fiber.NewError(fiber.StatusTooManyRequests, limitErr.Reason)
+}
+
+// adminMiddleware admits requests carrying one of the configured admin
+// bearer tokens and exposes the token's subject as the caller identity.
+func adminMiddleware(tokens map[string]string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if len(tokens) == 0 {
+			return fiber.NewError(fiber.StatusForbidden, "admin API is disabled")
+		}
+
+		presented, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		subject := ""
+		for token, candidate := range tokens {
+			if ok && subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1 {
+				subject = candidate
+			}
+		}
+		if subject == "" {
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="admin"`)
+			return fiber.NewError(fiber.StatusUnauthorized, "admin token required")
+		}
+
+		c.SetUserContext(auth.WithIdentity(c.UserContext(), &auth.Identity{Subject: subject}))
+		return c.Next()
+	}
+}
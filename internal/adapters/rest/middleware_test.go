@@ -0,0 +1,133 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/co1seam/ember-backend-media/internal/adapters/logging"
+	"github.com/co1seam/ember-backend-media/internal/core/auth"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTracingMiddlewareContinuesIncomingTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	app := fiber.New()
+	app.Use(tracingMiddleware)
+	app.Get("/v1/media/:id", func(c *fiber.Ctx) error {
+		return fiber.NewError(fiber.StatusNotFound, "not found")
+	})
+
+	req := httptest.NewRequest(fiber.MethodGet, "/v1/media/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if _, err := app.Test(req); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("ended spans = %d, want 1", len(spans))
+	}
+	span := spans[0]
+	if got := span.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace ID = %s, want the incoming one", got)
+	}
+	if got := span.Parent().SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("parent span ID = %s, want the incoming one", got)
+	}
+	if span.Name() != "GET /v1/media/:id" {
+		t.Errorf("span name = %q, want the route", span.Name())
+	}
+	want := attribute.Int("http.response.status_code", fiber.StatusNotFound)
+	found := false
+	for _, attr := range span.Attributes() {
+		found = found || attr == want
+	}
+	if !found {
+		t.Errorf("attributes = %v, want %v", span.Attributes(), want)
+	}
+}
+
+func TestAdminMiddleware(t *testing.T) {
+	newApp := func(tokens map[string]string) *fiber.App {
+		app := fiber.New()
+		app.Get("/admin", adminMiddleware(tokens), func(c *fiber.Ctx) error {
+			identity, _ := auth.FromContext(c.UserContext())
+			return c.SendString(identity.Subject)
+		})
+		return app
+	}
+
+	for name, tc := range map[string]struct {
+		tokens map[string]string
+		header string
+		status int
+		body   string
+	}{
+		"disabled":  {tokens: nil, header: "Bearer anything", status: fiber.StatusForbidden},
+		"missing":   {tokens: map[string]string{"t0k3n": "ops"}, status: fiber.StatusUnauthorized},
+		"wrong":     {tokens: map[string]string{"t0k3n": "ops"}, header: "Bearer t0k3m", status: fiber.StatusUnauthorized},
+		"no scheme": {tokens: map[string]string{"t0k3n": "ops"}, header: "t0k3n", status: fiber.StatusUnauthorized},
+		"valid":     {tokens: map[string]string{"t0k3n": "ops", "other": "dpo"}, header: "Bearer t0k3n", status: fiber.StatusOK, body: "ops"},
+	} {
+		req := httptest.NewRequest(fiber.MethodGet, "/admin", nil)
+		if tc.header != "" {
+			req.Header.Set(fiber.HeaderAuthorization, tc.header)
+		}
+		resp, err := newApp(tc.tokens).Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != tc.status || (tc.body != "" && string(body) != tc.body) {
+			t.Errorf("%s: got %d %q, want %d %q", name, resp.StatusCode, body, tc.status, tc.body)
+		}
+	}
+}
+
+func TestLoggingMiddleware(t *testing.T) {
+	var out bytes.Buffer
+	app := fiber.New()
+	app.Use(loggingMiddleware(slog.New(logging.NewHandler(slog.NewJSONHandler(&out, nil)))))
+	app.Get("/v1/media/:id", func(c *fiber.Ctx) error {
+		return fmt.Errorf("get: %w", models.ErrMediaNotFound)
+	})
+
+	req := httptest.NewRequest(fiber.MethodGet, "/v1/media/42", nil)
+	req.Header.Set(fiber.HeaderXRequestID, "req-1")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Header.Get(fiber.HeaderXRequestID); got != "req-1" {
+		t.Errorf("X-Request-ID = %q, want the incoming one echoed", got)
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"level":      "WARN",
+		"route":      "/v1/media/:id",
+		"status":     float64(fiber.StatusNotFound),
+		"request_id": "req-1",
+	}
+	for key, value := range want {
+		if record[key] != value {
+			t.Errorf("%s = %v, want %v", key, record[key], value)
+		}
+	}
+}
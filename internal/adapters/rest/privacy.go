@@ -0,0 +1,48 @@
+package rest
+
+import (
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"github.com/gofiber/fiber/v2"
+)
+
+type PrivacyHandler struct {
+	service ports.IPrivacyService
+	opts    *models.Options
+}
+
+func NewPrivacyHandler(service ports.IPrivacyService, opts *models.Options) *PrivacyHandler {
+	return &PrivacyHandler{
+		service: service,
+		opts:    opts,
+	}
+}
+
+// ExportOwner queues an export; its progress and download link are read
+// back from GetRequest.
+func (h *PrivacyHandler) ExportOwner(c *fiber.Ctx) error {
+	req, err := h.service.ExportOwner(c.UserContext(), c.Params("owner_id"), c.Query("format"))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(req)
+}
+
+func (h *PrivacyHandler) GetRequest(c *fiber.Ctx) error {
+	req, err := h.service.GetRequest(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(req)
+}
+
+func (h *PrivacyHandler) EraseOwner(c *fiber.Ctx) error {
+	req, err := h.service.EraseOwner(c.UserContext(), c.Params("owner_id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(req)
+}
@@ -0,0 +1,97 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errUnsatisfiableRange = errors.New("unsatisfiable range")
+
+type byteRange struct {
+	start, end int64
+}
+
+// parseRange supports a single byte range. Malformed or multi-range headers
+// return a nil range so the caller falls back to serving the full body.
+func parseRange(header string, size int64) (*byteRange, error) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return nil, nil
+	}
+
+	startStr, endStr, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return nil, nil
+	}
+
+	if startStr == "" {
+		suffix, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || suffix < 0 {
+			return nil, nil
+		}
+		if suffix == 0 || size == 0 {
+			return nil, errUnsatisfiableRange
+		}
+		if suffix > size {
+			suffix = size
+		}
+		return &byteRange{start: size - suffix, end: size - 1}, nil
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return nil, nil
+	}
+	if start >= size {
+		return nil, errUnsatisfiableRange
+	}
+
+	end := size - 1
+	if endStr != "" {
+		end, err = strconv.ParseInt(endStr, 10, 64)
+		if err != nil || end < start {
+			return nil, nil
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+
+	return &byteRange{start: start, end: end}, nil
+}
+
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ifRangeMatches reports whether a Range request should be honored. An absent
+// If-Range always matches; otherwise it must carry the current strong ETag or
+// the exact Last-Modified date.
+func ifRangeMatches(header, etag string, modified time.Time) bool {
+	if header == "" {
+		return true
+	}
+
+	if strings.HasPrefix(header, `"`) {
+		return header == etag
+	}
+
+	date, err := http.ParseTime(header)
+	if err != nil {
+		return false
+	}
+	return modified.Truncate(time.Second).Equal(date)
+}
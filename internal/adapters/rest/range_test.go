@@ -0,0 +1,85 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRange(t *testing.T) {
+	for header, want := range map[string]*byteRange{
+		"bytes=0-99":     {start: 0, end: 99},
+		"bytes=100-":     {start: 100, end: 999},
+		"bytes=-100":     {start: 900, end: 999},
+		"bytes=-5000":    {start: 0, end: 999},
+		"bytes=990-5000": {start: 990, end: 999},
+		"bytes= 10-20":   {start: 10, end: 20},
+		"bytes=0-0":      {start: 0, end: 0},
+		"items=0-10":     nil,
+		"bytes=0-10,20-": nil,
+		"bytes=abc-":     nil,
+		"bytes=20-10":    nil,
+		"bytes=5":        nil,
+		"bytes=-x":       nil,
+		"":               nil,
+	} {
+		got, err := parseRange(header, 1000)
+		if err != nil {
+			t.Errorf("parseRange(%q) error = %v", header, err)
+			continue
+		}
+		if (got == nil) != (want == nil) || (got != nil && *got != *want) {
+			t.Errorf("parseRange(%q) = %v, want %v", header, got, want)
+		}
+	}
+
+	for _, tc := range []struct {
+		header string
+		size   int64
+	}{
+		{"bytes=1000-", 1000},
+		{"bytes=5000-6000", 1000},
+		{"bytes=-0", 1000},
+		{"bytes=-10", 0},
+		{"bytes=0-", 0},
+	} {
+		if _, err := parseRange(tc.header, tc.size); !errors.Is(err, errUnsatisfiableRange) {
+			t.Errorf("parseRange(%q, %d) error = %v, want errUnsatisfiableRange", tc.header, tc.size, err)
+		}
+	}
+}
+
+func TestIfRangeMatches(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 10, 30, 15, 500, time.UTC)
+	etag := `"abc"`
+
+	for header, want := range map[string]bool{
+		"":                               true,
+		`"abc"`:                          true,
+		`"abd"`:                          false,
+		`W/"abc"`:                        false,
+		modified.Format(http.TimeFormat): true,
+		modified.Add(time.Second).Format(http.TimeFormat): false,
+		"not a date": false,
+	} {
+		if got := ifRangeMatches(header, etag, modified); got != want {
+			t.Errorf("ifRangeMatches(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	for header, want := range map[string]bool{
+		"":           false,
+		`"abc"`:      true,
+		`W/"abc"`:    true,
+		`"x", "abc"`: true,
+		"*":          true,
+		`"abcd"`:     false,
+	} {
+		if got := etagMatches(header, `"abc"`); got != want {
+			t.Errorf("etagMatches(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
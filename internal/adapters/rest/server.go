@@ -0,0 +1,146 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/co1seam/ember-backend-media/internal/adapters/metrics"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"net"
+)
+
+type Server struct {
+	app  *fiber.App
+	opts *models.Options
+}
+
+func NewServer(opts *models.Options) *Server {
+	// Behind a proxy, c.IP() reads the client address from ProxyHeader, but
+	// only on requests coming from a trusted proxy.
+	app := fiber.New(fiber.Config{
+		AppName:                 "ember-backend-media",
+		BodyLimit:               models.RequestBufferSize,
+		DisableStartupMessage:   true,
+		StreamRequestBody:       true,
+		ErrorHandler:            errorHandler(opts),
+		ProxyHeader:             opts.Config.App.HTTPProxyHeader,
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          opts.Config.App.TrustedProxies(),
+		EnableIPValidation:      true,
+	})
+
+	return &Server{
+		app:  app,
+		opts: opts,
+	}
+}
+
+func (s *Server) Run(handler *Handler) error {
+	s.app.Get("/metrics", adaptor.HTTPHandler(metrics.Handler()))
+
+	s.app.Get("/healthz", handler.Health.Live)
+	s.app.Get("/readyz", handler.Health.Ready)
+
+	v1 := s.app.Group("/v1", tracingMiddleware, loggingMiddleware(s.opts.Logger))
+	limit := rateLimitMiddleware(handler.Limiter)
+
+	media := v1.Group("/media")
+	media.Post("/", limit("CreateMedia"), handler.Media.CreateMedia)
+	media.Get("/", limit("ListMedia"), handler.Media.ListMedia)
+	media.Get("/search", limit("SearchMedia"), handler.Media.SearchMedia)
+	media.Get("/archive", limit("ArchiveMedia"), handler.Media.ArchiveMedia)
+	media.Post("/batch/get", limit("BatchGetMedia"), handler.Media.BatchGetMedia)
+	media.Post("/batch/update", limit("BatchUpdateMedia"), handler.Media.BatchUpdateMedia)
+	media.Post("/batch/delete", limit("BatchDeleteMedia"), handler.Media.BatchDeleteMedia)
+	media.Get("/:id", limit("GetMedia"), handler.Media.GetMedia)
+	media.Patch("/:id", limit("UpdateMedia"), handler.Media.UpdateMedia)
+	media.Delete("/:id", limit("DeleteMedia"), handler.Media.DeleteMedia)
+	media.Post("/:id/tags", limit("AddTags"), handler.Media.AddTags)
+	media.Delete("/:id/tags", limit("RemoveTags"), handler.Media.RemoveTags)
+	media.Patch("/:id/metadata", limit("PatchMetadata"), handler.Media.PatchMetadata)
+	media.Put("/:id/file", limit("UploadFile"), handler.Media.UploadRaw)
+	media.Post("/:id/file", limit("UploadFile"), handler.Media.UploadMultipart)
+	media.Get("/:id/file", limit("DownloadFile"), handler.Media.DownloadFile)
+	media.Get("/:id/versions", limit("ListVersions"), handler.Media.ListVersions)
+	media.Get("/:id/versions/:version/file", limit("DownloadFile"), handler.Media.DownloadVersion)
+	media.Post("/:id/versions/:version/rollback", limit("RollbackVersion"), handler.Media.RollbackVersion)
+
+	albums := v1.Group("/albums")
+	albums.Post("/", limit("CreateAlbum"), handler.Albums.CreateAlbum)
+	albums.Get("/", limit("ListAlbums"), handler.Albums.ListAlbums)
+	albums.Get("/:id", limit("GetAlbum"), handler.Albums.GetAlbum)
+	albums.Patch("/:id", limit("UpdateAlbum"), handler.Albums.UpdateAlbum)
+	albums.Delete("/:id", limit("DeleteAlbum"), handler.Albums.DeleteAlbum)
+	albums.Get("/:id/media", limit("ListAlbumMedia"), handler.Albums.ListAlbumMedia)
+	albums.Post("/:id/media", limit("AddAlbumMedia"), handler.Albums.AddMedia)
+	albums.Delete("/:id/media", limit("RemoveAlbumMedia"), handler.Albums.RemoveMedia)
+	albums.Put("/:id/media/order", limit("ReorderAlbumMedia"), handler.Albums.ReorderMedia)
+
+	webhooks := v1.Group("/webhooks")
+	webhooks.Post("/", limit("RegisterWebhook"), handler.Webhooks.RegisterWebhook)
+	webhooks.Get("/", limit("ListWebhooks"), handler.Webhooks.ListWebhooks)
+	webhooks.Delete("/:id", limit("DeleteWebhook"), handler.Webhooks.DeleteWebhook)
+	webhooks.Post("/:id/ping", limit("PingWebhook"), handler.Webhooks.PingWebhook)
+	webhooks.Get("/:id/deliveries", limit("ListDeliveries"), handler.Webhooks.ListDeliveries)
+
+	adminTokens, err := s.opts.Config.Admin.ParseTokens()
+	if err != nil {
+		return err
+	}
+
+	admin := v1.Group("/admin", adminMiddleware(adminTokens))
+	admin.Get("/quarantine", limit("ListQuarantined"), handler.Media.ListQuarantined)
+	admin.Post("/quarantine/:id/release", limit("ReleaseQuarantined"), handler.Media.ReleaseQuarantined)
+	admin.Delete("/quarantine/:id", limit("PurgeQuarantined"), handler.Media.PurgeQuarantined)
+	admin.Get("/config", limit("GetConfig"), handler.Settings.GetConfig)
+	admin.Post("/owners/:owner_id/export", limit("ExportOwner"), handler.Privacy.ExportOwner)
+	admin.Delete("/owners/:owner_id", limit("EraseOwner"), handler.Privacy.EraseOwner)
+	admin.Get("/privacy/:id", limit("GetPrivacyRequest"), handler.Privacy.GetRequest)
+	admin.Get("/audit", limit("ListAuditEvents"), handler.Audit.ListAuditEvents)
+
+	conn, err := net.Listen("tcp", s.address())
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n\n",
+		lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#98FF98")).
+			Bold(true).
+			Render("⇨ HTTP gateway started on "+conn.Addr().String()))
+
+	return s.app.Listener(conn)
+}
+
+// address binds the gateway to the same host as the gRPC server.
+func (s *Server) address() string {
+	port := s.opts.Config.App.HTTPPort
+	if port == "" {
+		port = models.DefaultHTTPPort
+	}
+
+	return net.JoinHostPort(s.opts.Config.App.Host, port)
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.app.ShutdownWithContext(ctx)
+}
+
+func errorHandler(opts *models.Options) fiber.ErrorHandler {
+	return func(c *fiber.Ctx, err error) error {
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			return c.Status(fiberErr.Code).JSON(fiber.Map{"error": fiberErr.Message})
+		}
+
+		code, message := statusFromError(err)
+		if code == fiber.StatusInternalServerError {
+			opts.Logger.ErrorContext(c.UserContext(), "http request failed", "method", c.Method(), "path", c.Path(), "error", err)
+		}
+
+		return c.Status(code).JSON(fiber.Map{"error": message})
+	}
+}
@@ -0,0 +1,65 @@
+package rest
+
+import (
+	"github.com/co1seam/ember-backend-media/config"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/gofiber/fiber/v2"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServerTrustsProxyHeaderOnlyFromProxies(t *testing.T) {
+	for name, tc := range map[string]struct {
+		proxies string
+		want    string
+	}{
+		"trusted proxy":   {proxies: "0.0.0.0/8", want: "203.0.113.7"},
+		"untrusted peer":  {proxies: "10.0.0.1", want: "0.0.0.0"},
+		"no proxy header": {want: "0.0.0.0"},
+	} {
+		app := config.App{HTTPTrustedProxies: tc.proxies}
+		if tc.proxies != "" {
+			app.HTTPProxyHeader = fiber.HeaderXForwardedFor
+		}
+		server := NewServer(&models.Options{
+			Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+			Config: &config.Config{App: app},
+		})
+		server.app.Get("/ip", func(c *fiber.Ctx) error {
+			return c.SendString(c.IP())
+		})
+
+		req := httptest.NewRequest(fiber.MethodGet, "/ip", nil)
+		req.Header.Set(fiber.HeaderXForwardedFor, "203.0.113.7, 198.51.100.2")
+		resp, err := server.app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != tc.want {
+			t.Errorf("%s: c.IP() = %q, want %q", name, body, tc.want)
+		}
+	}
+}
+
+func TestServerAddressHonoursHost(t *testing.T) {
+	for name, tc := range map[string]struct {
+		app  config.App
+		want string
+	}{
+		"all interfaces": {config.App{HTTPPort: "8081"}, ":8081"},
+		"host":           {config.App{Host: "127.0.0.1", HTTPPort: "8081"}, "127.0.0.1:8081"},
+		"ipv6 host":      {config.App{Host: "::1", HTTPPort: "8081"}, "[::1]:8081"},
+		"default port":   {config.App{Host: "127.0.0.1"}, "127.0.0.1:" + models.DefaultHTTPPort},
+	} {
+		server := NewServer(&models.Options{
+			Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+			Config: &config.Config{App: tc.app},
+		})
+		if got := server.address(); got != tc.want {
+			t.Errorf("%s: address() = %q, want %q", name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package rest
+
+import (
+	"github.com/co1seam/ember-backend-media/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+type SettingsHandler struct {
+	store *config.Store
+}
+
+func NewSettingsHandler(store *config.Store) *SettingsHandler {
+	return &SettingsHandler{store: store}
+}
+
+// GetConfig shows the effective configuration with secrets redacted.
+func (h *SettingsHandler) GetConfig(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"loaded_at":  h.store.LoadedAt(),
+		"reloadable": config.Reloadable(),
+		"settings":   h.store.Load().Redacted(),
+	})
+}
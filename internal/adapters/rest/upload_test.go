@@ -0,0 +1,68 @@
+package rest
+
+import (
+	"context"
+	"github.com/co1seam/ember-backend-media/config"
+	"github.com/co1seam/ember-backend-media/internal/adapters/ratelimit"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/gofiber/fiber/v2"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type fakeUploadService struct {
+	fakeMediaService
+	uploaded string
+}
+
+func (s *fakeUploadService) UploadFile(ctx context.Context, fileID, fileName string, size int64, stream io.Reader) (string, error) {
+	data, err := io.ReadAll(stream)
+	s.uploaded = string(data)
+	return "owner/" + fileID + "/" + fileName, err
+}
+
+func TestUploadRawFollowsReloadedLimit(t *testing.T) {
+	cfg := &config.Config{Media: config.Media{MaxFileSize: 8}}
+	store := config.NewStore(cfg)
+	opts := &models.Options{Logger: slog.New(slog.NewTextHandler(io.Discard, nil)), Settings: store}
+	limiter, err := ratelimit.New(&config.RateLimit{}, nil, opts.Logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := &fakeUploadService{}
+	app := fiber.New(fiber.Config{ErrorHandler: errorHandler(opts), StreamRequestBody: true})
+	app.Put("/media/:id/file", NewMediaHandler(service, limiter, opts).UploadRaw)
+
+	upload := func(body io.Reader) int {
+		t.Helper()
+		req := httptest.NewRequest(fiber.MethodPut, "/media/m1/file?owner_id=owner&filename=a.txt", body)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+
+	const content = "0123456789abcdef"
+	if status := upload(strings.NewReader(content)); status != fiber.StatusRequestEntityTooLarge {
+		t.Fatalf("upload over the limit: status = %d, want 413", status)
+	}
+	if service.uploaded != "" {
+		t.Fatalf("oversized upload reached the service: %q", service.uploaded)
+	}
+
+	next := *cfg
+	next.Media.MaxFileSize = 32
+	store.Update(&next)
+
+	if status := upload(strings.NewReader(content)); status != fiber.StatusCreated {
+		t.Fatalf("upload after raising the limit: status = %d, want 201", status)
+	}
+	if service.uploaded != content {
+		t.Errorf("uploaded = %q, want %q", service.uploaded, content)
+	}
+}
@@ -0,0 +1,97 @@
+package rest
+
+import (
+	"github.com/co1seam/ember-backend-media/internal/adapters/ratelimit"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/gofiber/fiber/v2"
+	"strconv"
+)
+
+func (h *MediaHandler) ListVersions(c *fiber.Ctx) error {
+	media, err := h.ownedMedia(c)
+	if err != nil {
+		return err
+	}
+
+	versions, err := h.service.ListVersions(c.UserContext(), media.ID)
+	if err != nil {
+		return err
+	}
+
+	if versions == nil {
+		versions = []*models.MediaVersion{}
+	}
+
+	return c.JSON(fiber.Map{"versions": versions})
+}
+
+func (h *MediaHandler) DownloadVersion(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	number, err := c.ParamsInt("version")
+	if err != nil || number < 1 {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid version")
+	}
+
+	media, err := h.ownedMedia(c)
+	if err != nil {
+		return err
+	}
+
+	release, err := h.limiter.AcquireStream(ctx, ratelimit.DirectionDownload, limitOwner(c))
+	if err != nil {
+		return limitResponse(c, err)
+	}
+
+	object, version, err := h.service.DownloadVersion(ctx, media.ID, number)
+	if err != nil {
+		release()
+		return err
+	}
+
+	c.Set(fiber.HeaderContentType, version.ContentType)
+	if version.Checksum != "" {
+		c.Set(fiber.HeaderETag, strconv.Quote(version.Checksum))
+	}
+
+	size := -1
+	if version.Size > 0 {
+		size = int(version.Size)
+	}
+
+	return h.sendDownload(c, object, release, size)
+}
+
+func (h *MediaHandler) RollbackVersion(c *fiber.Ctx) error {
+	number, err := c.ParamsInt("version")
+	if err != nil || number < 1 {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid version")
+	}
+
+	media, err := h.ownedMedia(c)
+	if err != nil {
+		return err
+	}
+
+	media, err = h.service.RollbackVersion(c.UserContext(), media.ID, number)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(media)
+}
+
+// ownedMedia loads the media named in the path and checks that it belongs to
+// the owner_id of the request.
+func (h *MediaHandler) ownedMedia(c *fiber.Ctx) (*models.Media, error) {
+	media, err := h.service.GetMedia(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return nil, err
+	}
+
+	if media.OwnerID != c.Query("owner_id") {
+		return nil, models.ErrPermissionDenied
+	}
+
+	return media, nil
+}
@@ -0,0 +1,66 @@
+package rest
+
+import (
+	"context"
+	"github.com/co1seam/ember-backend-media/config"
+	"github.com/co1seam/ember-backend-media/internal/adapters/ratelimit"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/gofiber/fiber/v2"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+)
+
+type fakeVersionService struct {
+	fakeMediaService
+	rolledBack int
+}
+
+func (s *fakeVersionService) ListVersions(ctx context.Context, id string) ([]*models.MediaVersion, error) {
+	return []*models.MediaVersion{{MediaID: id, Version: 1}}, nil
+}
+
+func (s *fakeVersionService) RollbackVersion(ctx context.Context, id string, number int) (*models.Media, error) {
+	s.rolledBack = number
+	return s.GetMedia(ctx, id)
+}
+
+func TestVersionRoutesCheckOwner(t *testing.T) {
+	service := &fakeVersionService{fakeMediaService: fakeMediaService{
+		media: &models.Media{ID: "m1", OwnerID: "owner"},
+	}}
+	opts := &models.Options{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	limiter, err := ratelimit.New(&config.RateLimit{}, nil, opts.Logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := NewMediaHandler(service, limiter, opts)
+
+	app := fiber.New(fiber.Config{ErrorHandler: errorHandler(opts)})
+	app.Get("/media/:id/versions", handler.ListVersions)
+	app.Post("/media/:id/versions/:version/rollback", handler.RollbackVersion)
+
+	for _, tc := range []struct {
+		method, target string
+		status         int
+	}{
+		{fiber.MethodGet, "/media/m1/versions?owner_id=intruder", fiber.StatusForbidden},
+		{fiber.MethodGet, "/media/m1/versions", fiber.StatusForbidden},
+		{fiber.MethodPost, "/media/m1/versions/1/rollback?owner_id=intruder", fiber.StatusForbidden},
+		{fiber.MethodGet, "/media/m1/versions?owner_id=owner", fiber.StatusOK},
+		{fiber.MethodPost, "/media/m1/versions/1/rollback?owner_id=owner", fiber.StatusOK},
+	} {
+		resp, err := app.Test(httptest.NewRequest(tc.method, tc.target, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s %s: status = %d, want %d", tc.method, tc.target, resp.StatusCode, tc.status)
+		}
+	}
+
+	if service.rolledBack != 1 {
+		t.Errorf("rolled back to %d, want only the owner's rollback to 1", service.rolledBack)
+	}
+}
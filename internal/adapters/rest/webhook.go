@@ -0,0 +1,76 @@
+package rest
+
+import (
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"github.com/gofiber/fiber/v2"
+)
+
+type WebhookHandler struct {
+	service ports.IWebhookService
+	opts    *models.Options
+}
+
+func NewWebhookHandler(service ports.IWebhookService, opts *models.Options) *WebhookHandler {
+	return &WebhookHandler{
+		service: service,
+		opts:    opts,
+	}
+}
+
+func (h *WebhookHandler) RegisterWebhook(c *fiber.Ctx) error {
+	var req models.RegisterWebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	webhook, err := h.service.RegisterWebhook(c.UserContext(), &req)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(webhook)
+}
+
+func (h *WebhookHandler) ListWebhooks(c *fiber.Ctx) error {
+	webhooks, err := h.service.ListWebhooks(c.UserContext(), c.Query("owner_id"))
+	if err != nil {
+		return err
+	}
+
+	if webhooks == nil {
+		webhooks = []*models.Webhook{}
+	}
+
+	return c.JSON(fiber.Map{"webhooks": webhooks})
+}
+
+func (h *WebhookHandler) DeleteWebhook(c *fiber.Ctx) error {
+	if err := h.service.DeleteWebhook(c.UserContext(), c.Query("owner_id"), c.Params("id")); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *WebhookHandler) PingWebhook(c *fiber.Ctx) error {
+	delivery, err := h.service.PingWebhook(c.UserContext(), c.Query("owner_id"), c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(delivery)
+}
+
+func (h *WebhookHandler) ListDeliveries(c *fiber.Ctx) error {
+	deliveries, err := h.service.ListDeliveries(c.UserContext(), c.Query("owner_id"), c.Params("id"), c.QueryInt("limit", 50))
+	if err != nil {
+		return err
+	}
+
+	if deliveries == nil {
+		deliveries = []*models.WebhookDelivery{}
+	}
+
+	return c.JSON(fiber.Map{"deliveries": deliveries})
+}
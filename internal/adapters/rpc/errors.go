@@ -0,0 +1,73 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"github.com/co1seam/ember-backend-media/internal/adapters/ratelimit"
+	"github.com/co1seam/ember-backend-media/internal/core/errs"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"math"
+	"strconv"
+)
+
+// statusFromError maps domain errors onto gRPC statuses. Errors that are not
+// classified become Internal without their message reaching the client.
+func statusFromError(err error) *status.Status {
+	if s, ok := status.FromError(err); ok {
+		return s
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status.New(codes.Canceled, "request canceled")
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.New(codes.DeadlineExceeded, "deadline exceeded")
+	}
+
+	code := codes.Internal
+	switch errs.KindOf(err) {
+	case errs.KindNotFound:
+		code = codes.NotFound
+	case errs.KindConflict:
+		code = codes.AlreadyExists
+	case errs.KindInvalidArgument:
+		code = codes.InvalidArgument
+	case errs.KindPermissionDenied:
+		code = codes.PermissionDenied
+	case errs.KindFailedPrecondition:
+		code = codes.FailedPrecondition
+	case errs.KindAborted:
+		code = codes.Aborted
+	}
+
+	return status.New(code, errs.Message(err))
+}
+
+// limitStatus turns a rate limit error into ResourceExhausted carrying a
+// RetryInfo detail and a retry-after header (in seconds) for clients that do
+// not decode details.
+func limitStatus(ctx context.Context, err error) error {
+	var limitErr *ratelimit.LimitError
+	if !errors.As(err, &limitErr) {
+		return err
+	}
+
+	st := status.New(codes.ResourceExhausted, limitErr.Reason)
+	if limitErr.RetryAfter <= 0 {
+		return st.Err()
+	}
+
+	seconds := int64(math.Ceil(limitErr.RetryAfter.Seconds()))
+	_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.FormatInt(seconds, 10)))
+
+	detailed, detailErr := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(limitErr.RetryAfter)})
+	if detailErr != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
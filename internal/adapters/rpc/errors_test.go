@@ -0,0 +1,79 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/co1seam/ember-backend-media/internal/adapters/logging"
+	"github.com/co1seam/ember-backend-media/internal/core/errs"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"testing"
+)
+
+func TestStatusFromError(t *testing.T) {
+	for name, tc := range map[string]struct {
+		err     error
+		code    codes.Code
+		message string
+	}{
+		"not found":  {fmt.Errorf("get: %w", models.ErrMediaNotFound), codes.NotFound, "media not found"},
+		"no rows":    {fmt.Errorf("scan: %w", sql.ErrNoRows), codes.NotFound, "not found"},
+		"invalid":    {models.ErrInvalidMedia, codes.InvalidArgument, "invalid media"},
+		"denied":     {models.ErrPermissionDenied, codes.PermissionDenied, "permission denied"},
+		"conflict":   {errs.Conflict("webhook exists"), codes.AlreadyExists, "webhook exists"},
+		"modified":   {models.ErrMediaModified, codes.Aborted, "media was modified concurrently"},
+		"quarantine": {models.ErrMediaQuarantined, codes.FailedPrecondition, "media is quarantined"},
+		"canceled":   {fmt.Errorf("upload: %w", context.Canceled), codes.Canceled, "request canceled"},
+		"status":     {status.Error(codes.ResourceExhausted, "slow down"), codes.ResourceExhausted, "slow down"},
+		"internal":   {errors.New("dial tcp 10.0.0.5:5432: connection refused"), codes.Internal, "internal error"},
+	} {
+		st := statusFromError(tc.err)
+		if st.Code() != tc.code || st.Message() != tc.message {
+			t.Errorf("%s: status = %s %q, want %s %q", name, st.Code(), st.Message(), tc.code, tc.message)
+		}
+	}
+}
+
+func TestLoggingInterceptorLogsUnmappedError(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(logging.NewHandler(slog.NewJSONHandler(&out, nil)))
+	logged := loggingUnaryInterceptor(logger)
+	info := &grpc.UnaryServerInfo{FullMethod: "/media.v1.MediaService/GetMedia"}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(logging.RequestIDHeader, "req-1"))
+	cause := errors.New("dial tcp 10.0.0.5:5432: connection refused")
+
+	_, err := errorsUnaryInterceptor(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+		return logged(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+			return nil, cause
+		})
+	})
+	if st := status.Convert(err); st.Code() != codes.Internal || st.Message() != "internal error" {
+		t.Errorf("client got %s %q, want a bare Internal", st.Code(), st.Message())
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"level":      "ERROR",
+		"method":     info.FullMethod,
+		"code":       "Internal",
+		"request_id": "req-1",
+		"error":      cause.Error(),
+	}
+	for key, value := range want {
+		if record[key] != value {
+			t.Errorf("%s = %v, want %v", key, record[key], value)
+		}
+	}
+}
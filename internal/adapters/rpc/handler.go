@@ -2,18 +2,26 @@ package rpc
 
 import (
 	mediav1 "github.com/co1seam/ember-backend-api-contracts/gen/go/media"
+	"github.com/co1seam/ember-backend-media/internal/adapters/health"
+	"github.com/co1seam/ember-backend-media/internal/adapters/ratelimit"
 	"github.com/co1seam/ember-backend-media/internal/core/models"
 	"github.com/co1seam/ember-backend-media/internal/core/services"
 )
 
 type Handler struct {
-	Media mediav1.MediaServiceServer
-	opts  *models.Options
+	Media  mediav1.MediaServiceServer
+	Health *health.Checker
+	owners *ownerAuthorizer
+	limits *rateLimiter
+	opts   *models.Options
 }
 
-func NewHandler(service *services.Services, opts *models.Options) *Handler {
+func NewHandler(service *services.Services, checker *health.Checker, limiter *ratelimit.Limiter, opts *models.Options) *Handler {
 	return &Handler{
-		Media: NewMediaHandler(service.Media, opts),
-		opts:  opts,
+		Media:  NewMediaHandler(service.Media, limiter, opts),
+		Health: checker,
+		owners: &ownerAuthorizer{service: service.Media},
+		limits: &rateLimiter{limiter: limiter, service: service.Media},
+		opts:   opts,
 	}
 }
@@ -0,0 +1,375 @@
+package rpc
+
+import (
+	"context"
+	"github.com/co1seam/ember-backend-media/internal/adapters/logging"
+	"github.com/co1seam/ember-backend-media/internal/adapters/metrics"
+	"github.com/co1seam/ember-backend-media/internal/adapters/ratelimit"
+	"github.com/co1seam/ember-backend-media/internal/core/auth"
+	"github.com/co1seam/ember-backend-media/internal/core/errs"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+)
+
+func metricsUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	service, method := splitMethod(info.FullMethod)
+	started := time.Now()
+
+	metrics.GRPCStarted.WithLabelValues("unary", service, method).Inc()
+	resp, err := handler(ctx, req)
+	metrics.GRPCHandled.WithLabelValues("unary", service, method, status.Code(err).String()).Inc()
+	metrics.GRPCHandlingSeconds.WithLabelValues("unary", service, method).Observe(time.Since(started).Seconds())
+
+	return resp, err
+}
+
+func metricsStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	service, method := splitMethod(info.FullMethod)
+	started := time.Now()
+
+	streamType := "bidi_stream"
+	switch {
+	case info.IsClientStream && !info.IsServerStream:
+		streamType = "client_stream"
+	case info.IsServerStream && !info.IsClientStream:
+		streamType = "server_stream"
+	}
+
+	metrics.GRPCStarted.WithLabelValues(streamType, service, method).Inc()
+	err := handler(srv, ss)
+	metrics.GRPCHandled.WithLabelValues(streamType, service, method, status.Code(err).String()).Inc()
+	metrics.GRPCHandlingSeconds.WithLabelValues(streamType, service, method).Observe(time.Since(started).Seconds())
+
+	return err
+}
+
+func splitMethod(fullMethod string) (string, string) {
+	service, method, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		return "unknown", fullMethod
+	}
+	return service, method
+}
+
+func errorsUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	resp, err := handler(ctx, req)
+	if err != nil {
+		return resp, statusFromError(err).Err()
+	}
+	return resp, nil
+}
+
+func errorsStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := handler(srv, ss); err != nil {
+		return statusFromError(err).Err()
+	}
+	return nil
+}
+
+// loggingUnaryInterceptor runs inside errorsUnaryInterceptor so that the
+// unmapped error, which is never sent to the client, still reaches the log.
+func loggingUnaryInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx = withRequestID(ctx)
+		started := time.Now()
+
+		resp, err := handler(ctx, req)
+		logCall(ctx, logger, info.FullMethod, started, err)
+
+		return resp, err
+	}
+}
+
+func loggingStreamInterceptor(logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := withRequestID(ss.Context())
+		started := time.Now()
+
+		err := handler(srv, &wrappedStream{ServerStream: ss, ctx: ctx})
+		logCall(ctx, logger, info.FullMethod, started, err)
+
+		return err
+	}
+}
+
+func withRequestID(ctx context.Context) context.Context {
+	var incoming string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(logging.RequestIDHeader); len(values) > 0 {
+			incoming = values[0]
+		}
+	}
+
+	id := logging.RequestID(incoming)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(logging.RequestIDHeader, id))
+
+	return auth.WithRequestID(ctx, id)
+}
+
+func logCall(ctx context.Context, logger *slog.Logger, fullMethod string, started time.Time, err error) {
+	code := statusFromError(err).Code()
+
+	attrs := []any{
+		"method", fullMethod,
+		"code", code.String(),
+		"duration_ms", time.Since(started).Milliseconds(),
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		attrs = append(attrs, "peer", p.Addr.String())
+	}
+	if identity, ok := auth.FromContext(ctx); ok {
+		attrs = append(attrs, "client", identity.Subject)
+	}
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+
+	level := slog.LevelInfo
+	switch code {
+	case codes.OK, codes.Canceled:
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+		level = slog.LevelError
+	default:
+		level = slog.LevelWarn
+	}
+
+	logger.Log(ctx, level, "grpc call", attrs...)
+}
+
+func identityUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return handler(withIdentity(ctx), req)
+}
+
+func identityStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &wrappedStream{ServerStream: ss, ctx: withIdentity(ss.Context())})
+}
+
+// withIdentity exposes the verified mTLS client certificate of the peer, if
+// any, as the caller identity, along with the peer's address.
+func withIdentity(ctx context.Context) context.Context {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ctx
+	}
+
+	if p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		ctx = auth.WithClientIP(ctx, host)
+	}
+
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return ctx
+	}
+
+	cert := info.State.VerifiedChains[0][0]
+	identity := &auth.Identity{
+		Subject:  cert.Subject.CommonName,
+		DNSNames: cert.DNSNames,
+	}
+	for _, uri := range cert.URIs {
+		identity.URIs = append(identity.URIs, uri.String())
+	}
+
+	return auth.WithIdentity(ctx, identity)
+}
+
+type wrappedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *wrappedStream) Context() context.Context {
+	return s.ctx
+}
+
+type ownerRequest interface {
+	GetOwnerId() string
+}
+
+type idRequest interface {
+	GetId() string
+}
+
+type fileRequest interface {
+	GetFileId() string
+}
+
+// ownerAuthorizer refuses requests from an mTLS client that name another
+// owner or address media another owner holds. Streams are checked on their
+// first message, which carries the owner and file.
+type ownerAuthorizer struct {
+	service ports.IMediaService
+}
+
+func (a *ownerAuthorizer) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := a.authorize(ctx, req); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *ownerAuthorizer) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &authorizedStream{ServerStream: ss, authorizer: a})
+}
+
+// authorize compares the client certificate with the owner named in the
+// request and with the owner of the media it addresses. Missing media is left
+// for the handler to report.
+func (a *ownerAuthorizer) authorize(ctx context.Context, req any) error {
+	identity, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	if req, ok := req.(ownerRequest); ok && req.GetOwnerId() != "" && req.GetOwnerId() != identity.Subject {
+		return status.Error(codes.PermissionDenied, "owner does not match the client certificate")
+	}
+
+	var id string
+	switch req := req.(type) {
+	case idRequest:
+		id = req.GetId()
+	case fileRequest:
+		id = req.GetFileId()
+	}
+	if id == "" {
+		return nil
+	}
+
+	media, err := a.service.GetMedia(ctx, id)
+	if errs.KindOf(err) == errs.KindNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if media.OwnerID != identity.Subject {
+		return status.Error(codes.PermissionDenied, "media belongs to another owner")
+	}
+	return nil
+}
+
+type authorizedStream struct {
+	grpc.ServerStream
+	authorizer *ownerAuthorizer
+	checked    bool
+}
+
+func (s *authorizedStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil || s.checked {
+		return err
+	}
+	s.checked = true
+
+	return s.authorizer.authorize(s.Context(), m)
+}
+
+// rateLimiter applies the per-owner method rate limits and, for streams, the
+// concurrent stream caps. Streams are checked on their first message, which
+// is the first point where the owner can be known.
+type rateLimiter struct {
+	limiter *ratelimit.Limiter
+	service ports.IMediaService
+}
+
+func (r *rateLimiter) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	_, method := splitMethod(info.FullMethod)
+	if err := r.limiter.Allow(ctx, method, r.owner(ctx, req, false)); err != nil {
+		return nil, limitStatus(ctx, err)
+	}
+	return handler(ctx, req)
+}
+
+func (r *rateLimiter) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	_, method := splitMethod(info.FullMethod)
+
+	direction := ratelimit.DirectionDownload
+	if info.IsClientStream {
+		direction = ratelimit.DirectionUpload
+	}
+
+	limited := &rateLimitedStream{ServerStream: ss, limiter: r, method: method, direction: direction}
+	defer limited.release()
+
+	return handler(srv, limited)
+}
+
+// owner names whose limits a request counts against: the client certificate
+// whenever there is one, which ownerAuthorizer has already matched against
+// the request, then the owner the request names or, with resolve, the owner
+// of the file it touches.
+func (r *rateLimiter) owner(ctx context.Context, req any, resolve bool) string {
+	if identity, ok := auth.FromContext(ctx); ok {
+		return identity.Subject
+	}
+
+	if req, ok := req.(ownerRequest); ok && req.GetOwnerId() != "" {
+		return req.GetOwnerId()
+	}
+	if resolve {
+		if req, ok := req.(fileRequest); ok && req.GetFileId() != "" {
+			if media, err := r.service.GetMedia(ctx, req.GetFileId()); err == nil {
+				return media.OwnerID
+			}
+		}
+	}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		return "peer:" + host
+	}
+	return "anonymous"
+}
+
+type rateLimitedStream struct {
+	grpc.ServerStream
+	limiter   *rateLimiter
+	method    string
+	direction string
+	checked   bool
+	done      func()
+}
+
+func (s *rateLimitedStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil || s.checked {
+		return err
+	}
+	s.checked = true
+
+	ctx := s.Context()
+	owner := s.limiter.owner(ctx, m, true)
+
+	if err := s.limiter.limiter.Allow(ctx, s.method, owner); err != nil {
+		return limitStatus(ctx, err)
+	}
+
+	release, err := s.limiter.limiter.AcquireStream(ctx, s.direction, owner)
+	if err != nil {
+		return limitStatus(ctx, err)
+	}
+	s.done = release
+
+	return nil
+}
+
+func (s *rateLimitedStream) release() {
+	if s.done != nil {
+		s.done()
+	}
+}
@@ -0,0 +1,91 @@
+package rpc
+
+import (
+	"context"
+	mediav1 "github.com/co1seam/ember-backend-api-contracts/gen/go/media"
+	"github.com/co1seam/ember-backend-media/internal/core/auth"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+type fakeMediaService struct {
+	ports.IMediaService
+	media map[string]*models.Media
+}
+
+func (s *fakeMediaService) GetMedia(ctx context.Context, id string) (*models.Media, error) {
+	if media, ok := s.media[id]; ok {
+		return media, nil
+	}
+	return nil, models.ErrMediaNotFound
+}
+
+func TestOwnerAuthorizer(t *testing.T) {
+	authorizer := &ownerAuthorizer{service: &fakeMediaService{media: map[string]*models.Media{
+		"f1": {ID: "f1", OwnerID: "alice"},
+		"f2": {ID: "f2", OwnerID: "bob"},
+	}}}
+	alice := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "alice"})
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		req  any
+		code codes.Code
+	}{
+		{"own owner", alice, &mediav1.ListMediaRequest{OwnerId: "alice"}, codes.OK},
+		{"other owner", alice, &mediav1.ListMediaRequest{OwnerId: "bob"}, codes.PermissionDenied},
+		{"own get", alice, &mediav1.GetMediaRequest{Id: "f1"}, codes.OK},
+		{"foreign get", alice, &mediav1.GetMediaRequest{Id: "f2"}, codes.PermissionDenied},
+		{"foreign update", alice, &mediav1.UpdateMediaRequest{Id: "f2", Title: "mine now"}, codes.PermissionDenied},
+		{"foreign delete", alice, &mediav1.DeleteMediaRequest{Id: "f2"}, codes.PermissionDenied},
+		{"missing media", alice, &mediav1.GetMediaRequest{Id: "gone"}, codes.OK},
+		{"own file", alice, &mediav1.FileChunk{FileId: "f1"}, codes.OK},
+		{"foreign file", alice, &mediav1.FileRequest{FileId: "f2", OwnerId: "alice"}, codes.PermissionDenied},
+		{"no certificate", context.Background(), &mediav1.DeleteMediaRequest{Id: "f2"}, codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			_, err := authorizer.unary(tt.ctx, tt.req, &grpc.UnaryServerInfo{}, func(ctx context.Context, req any) (any, error) {
+				called = true
+				return nil, nil
+			})
+			if status.Code(err) != tt.code || called != (tt.code == codes.OK) {
+				t.Errorf("unary() = %v, handler called %v, want %v", err, called, tt.code)
+			}
+		})
+	}
+}
+
+func TestRateLimiterOwner(t *testing.T) {
+	limiter := &rateLimiter{service: &fakeMediaService{media: map[string]*models.Media{
+		"f2": {ID: "f2", OwnerID: "bob"},
+	}}}
+	alice := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "alice"})
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		req     any
+		resolve bool
+		want    string
+	}{
+		{"certificate", alice, &mediav1.ListMediaRequest{}, false, "alice"},
+		{"certificate and owner", alice, &mediav1.ListMediaRequest{OwnerId: "alice"}, false, "alice"},
+		{"no certificate", context.Background(), &mediav1.ListMediaRequest{OwnerId: "bob"}, false, "bob"},
+		{"no certificate, file", context.Background(), &mediav1.FileChunk{FileId: "f2"}, true, "bob"},
+		{"nothing known", context.Background(), &mediav1.FileChunk{}, true, "anonymous"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := limiter.owner(tt.ctx, tt.req, tt.resolve); got != tt.want {
+				t.Errorf("owner() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
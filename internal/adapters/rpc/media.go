@@ -2,21 +2,36 @@ package rpc
 
 import (
 	"context"
+	"errors"
 	mediav1 "github.com/co1seam/ember-backend-api-contracts/gen/go/media"
+	"github.com/co1seam/ember-backend-media/internal/adapters/metrics"
+	"github.com/co1seam/ember-backend-media/internal/adapters/ratelimit"
+	"github.com/co1seam/ember-backend-media/internal/core/auth"
 	"github.com/co1seam/ember-backend-media/internal/core/models"
 	"github.com/co1seam/ember-backend-media/internal/ports"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 )
 
+const (
+	UpdateMaskHeader = "update-mask"
+	IfMatchHeader    = "if-match"
+	RevisionHeader   = "revision"
+)
+
 type MediaHandler struct {
 	mediav1.UnimplementedMediaServiceServer
 	service ports.IMediaService
+	limiter *ratelimit.Limiter
 	opts    *models.Options
 }
 
@@ -27,9 +42,10 @@ type chanReader struct {
 	ctx  context.Context
 }
 
-func NewMediaHandler(service ports.IMediaService, opts *models.Options) *MediaHandler {
+func NewMediaHandler(service ports.IMediaService, limiter *ratelimit.Limiter, opts *models.Options) *MediaHandler {
 	return &MediaHandler{
 		service: service,
+		limiter: limiter,
 		opts:    opts,
 	}
 }
@@ -43,7 +59,7 @@ func (h *MediaHandler) CreateMedia(ctx context.Context, req *mediav1.CreateMedia
 	})
 
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, err
 	}
 
 	return &mediav1.MediaResponse{
@@ -54,29 +70,51 @@ func (h *MediaHandler) CreateMedia(ctx context.Context, req *mediav1.CreateMedia
 func (h *MediaHandler) GetMedia(ctx context.Context, req *mediav1.GetMediaRequest) (*mediav1.MediaResponse, error) {
 	media, err := h.service.GetMedia(ctx, req.Id)
 	if err != nil {
-		return nil, status.Error(codes.NotFound, "media not found")
-	}
-
-	mediaInfo, err := h.service.GetStatFile(ctx, media.StoragePath)
-	if err != nil {
-		return nil, status.Error(codes.NotFound, "media not found")
+		return nil, err
 	}
+	setRevision(ctx, media)
 
 	return &mediav1.MediaResponse{
-		Media: toProtoMedia(media, mediaInfo.Size),
+		Media: toProtoMedia(media, h.fileSize(ctx, media)),
 	}, nil
 }
 
+// UpdateMedia takes its field mask and expected revision from the
+// update-mask and if-match metadata, as the request message has no room for
+// them. Without a mask only the non-empty fields are updated.
 func (h *MediaHandler) UpdateMedia(ctx context.Context, req *mediav1.UpdateMediaRequest) (*mediav1.MediaResponse, error) {
-	media, err := h.service.UpdateMedia(ctx, &models.UpdateMediaRequest{
+	update := &models.UpdateMediaRequest{
 		ID:          req.Id,
 		Title:       req.Title,
 		Description: req.Description,
-	})
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get(UpdateMaskHeader) {
+		update.UpdateMask = append(update.UpdateMask, strings.Split(value, ",")...)
+	}
+	if update.UpdateMask == nil {
+		if req.Title != "" {
+			update.UpdateMask = append(update.UpdateMask, models.MediaFieldTitle)
+		}
+		if req.Description != "" {
+			update.UpdateMask = append(update.UpdateMask, models.MediaFieldDescription)
+		}
+	}
+	if values := md.Get(IfMatchHeader); len(values) > 0 {
+		revision, err := strconv.ParseInt(values[0], 10, 64)
+		if err != nil || revision <= 0 {
+			return nil, status.Error(codes.InvalidArgument, "invalid if-match revision")
+		}
+		update.Revision = revision
+	}
+
+	media, err := h.service.UpdateMedia(ctx, update)
 
 	if err != nil {
-		return nil, status.Error(codes.Internal, "update failed")
+		return nil, err
 	}
+	setRevision(ctx, media)
 
 	return &mediav1.MediaResponse{
 		Media: toProtoMedia(media, -1),
@@ -85,24 +123,20 @@ func (h *MediaHandler) UpdateMedia(ctx context.Context, req *mediav1.UpdateMedia
 
 func (h *MediaHandler) DeleteMedia(ctx context.Context, req *mediav1.DeleteMediaRequest) (*emptypb.Empty, error) {
 	if err := h.service.DeleteMedia(ctx, req.Id); err != nil {
-		return nil, status.Error(codes.Internal, "delete failed")
+		return nil, err
 	}
 	return &emptypb.Empty{}, nil
 }
 
 func (h *MediaHandler) ListMedia(ctx context.Context, req *mediav1.ListMediaRequest) (*mediav1.ListMediaResponse, error) {
-	mediaList, err := h.service.ListMedia(ctx, req.OwnerId, int(req.Limit))
+	mediaList, err := h.service.ListMedia(ctx, &models.ListMediaRequest{OwnerID: req.OwnerId, Limit: int(req.Limit)})
 	if err != nil {
-		return nil, status.Error(codes.Internal, "list failed")
+		return nil, err
 	}
 
 	protoMedia := make([]*mediav1.Media, len(mediaList))
 	for i, media := range mediaList {
-		mediaInfo, err := h.service.GetStatFile(ctx, media.ID)
-		if err != nil {
-			return nil, status.Error(codes.Internal, "list failed")
-		}
-		protoMedia[i] = toProtoMedia(media, mediaInfo.Size)
+		protoMedia[i] = toProtoMedia(media, h.fileSize(ctx, media))
 	}
 
 	return &mediav1.ListMediaResponse{
@@ -110,6 +144,21 @@ func (h *MediaHandler) ListMedia(ctx context.Context, req *mediav1.ListMediaRequ
 	}, nil
 }
 
+// fileSize reports 0 for media without a readable object instead of failing
+// the whole call, matching the HTTP gateway.
+func (h *MediaHandler) fileSize(ctx context.Context, media *models.Media) int64 {
+	if media.ServeError() != nil || media.StoragePath == "" {
+		return 0
+	}
+
+	info, err := h.service.GetStatFile(ctx, media.StoragePath)
+	if err != nil {
+		h.opts.Logger.WarnContext(ctx, "media object stat failed", "media_id", media.ID, "error", err)
+		return 0
+	}
+	return info.Size
+}
+
 func toProtoMedia(media *models.Media, size int64) *mediav1.Media {
 	return &mediav1.Media{
 		Id:          media.ID,
@@ -124,46 +173,71 @@ func toProtoMedia(media *models.Media, size int64) *mediav1.Media {
 }
 
 func (h *MediaHandler) UploadFile(stream mediav1.MediaService_UploadFileServer) error {
+	metrics.ActiveStreams.WithLabelValues("upload", "grpc").Inc()
+	defer metrics.ActiveStreams.WithLabelValues("upload", "grpc").Dec()
+
 	var FileID string
 	var fileName string
 	var tempFile *os.File
 	var totalSize int64
+	var received int64
 	var err error
 
+	// Read per upload, so a reloaded MEDIA_MAX_FILE_SIZE applies at once.
+	limit := h.opts.Settings.Load().Media.MaxFileSize
+
 	for {
 		chunk, err := stream.Recv()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return status.Error(codes.Internal, err.Error())
+			return err
 		}
 
 		if chunk.IsFirst {
 			FileID = chunk.FileId
 			fileName = chunk.FileName
 			totalSize = chunk.TotalSize
+			if totalSize > limit {
+				return status.Errorf(codes.InvalidArgument, "file exceeds the %d byte limit", limit)
+			}
 			tempFile, err = os.CreateTemp("", filepath.Base(fileName)+"-*")
 			if err != nil {
-				return status.Error(codes.Internal, "failed to create temp file")
+				return err
 			}
 			defer os.Remove(tempFile.Name())
 			defer tempFile.Close()
 			continue
 		}
 
+		if tempFile == nil {
+			return status.Error(codes.InvalidArgument, "first chunk must carry file metadata")
+		}
+		received += int64(len(chunk.Content))
+		if received > limit {
+			return status.Errorf(codes.InvalidArgument, "file exceeds the %d byte limit", limit)
+		}
 		if _, err := tempFile.Write(chunk.Content); err != nil {
-			return status.Error(codes.Internal, "write error: "+err.Error())
+			return err
 		}
+		metrics.BytesUploaded.WithLabelValues("grpc").Add(float64(len(chunk.Content)))
+	}
+
+	if tempFile == nil {
+		return status.Error(codes.InvalidArgument, "no file received")
 	}
 
 	if _, err := tempFile.Seek(0, 0); err != nil {
-		return status.Error(codes.Internal, "seek error: "+err.Error())
+		return err
 	}
 
 	Url, err := h.service.UploadFile(stream.Context(), FileID, fileName, totalSize, tempFile)
+	if errors.Is(err, models.ErrMediaQuarantined) {
+		return status.Error(codes.FailedPrecondition, "file rejected by malware scan")
+	}
 	if err != nil {
-		return status.Error(codes.Internal, err.Error())
+		return err
 	}
 
 	return stream.SendAndClose(&mediav1.FileResponse{
@@ -173,18 +247,25 @@ func (h *MediaHandler) UploadFile(stream mediav1.MediaService_UploadFileServer)
 }
 
 func (h *MediaHandler) DownloadFile(req *mediav1.FileRequest, stream mediav1.MediaService_DownloadFileServer) error {
+	metrics.ActiveStreams.WithLabelValues("download", "grpc").Inc()
+	defer metrics.ActiveStreams.WithLabelValues("download", "grpc").Dec()
+
 	meta, err := h.service.GetMedia(stream.Context(), req.FileId)
 	if err != nil {
-		return status.Error(codes.Internal, err.Error())
+		return err
 	}
 
-	fileInfo, err := h.service.GetStatFile(stream.Context(), meta.StoragePath)
-	if err != nil {
-		return status.Error(codes.Internal, err.Error())
+	if !authorized(stream.Context(), meta.OwnerID, req.OwnerId) {
+		return models.ErrPermissionDenied
 	}
 
-	if meta.OwnerID != req.OwnerId {
-		return status.Error(codes.PermissionDenied, "permission denied")
+	if err := meta.ServeError(); err != nil {
+		return err
+	}
+
+	fileInfo, err := h.service.GetStatFile(stream.Context(), meta.StoragePath)
+	if err != nil {
+		return err
 	}
 
 	start := req.Start
@@ -201,24 +282,31 @@ func (h *MediaHandler) DownloadFile(req *mediav1.FileRequest, stream mediav1.Med
 
 	reader, err := h.service.DownloadFileRange(stream.Context(), meta.StoragePath, start, end)
 	if err != nil {
-		return status.Error(codes.Internal, err.Error())
+		return err
 	}
 	defer reader.Close()
 
 	buf := make([]byte, 64*1024)
 	var totalSent int64
+	throttle := h.limiter.DownloadThrottle()
 
 	for {
 		n, err := reader.Read(buf)
 		if err != nil && err != io.EOF {
-			return status.Error(codes.Internal, err.Error())
+			return err
 		}
 
 		if n > 0 {
+			if throttle != nil {
+				if err := throttle.Wait(stream.Context(), n); err != nil {
+					return err
+				}
+			}
 			if err := stream.Send(&mediav1.DownloadResponse{Chunk: buf[:n]}); err != nil {
-				return status.Error(codes.Internal, err.Error())
+				return err
 			}
 			totalSent += int64(n)
+			metrics.BytesDownloaded.WithLabelValues("grpc").Add(float64(n))
 		}
 
 		// Проверяем завершение чтения
@@ -230,6 +318,18 @@ func (h *MediaHandler) DownloadFile(req *mediav1.FileRequest, stream mediav1.Med
 	return nil
 }
 
+// authorized allows access to media of ownerID when the request names that
+// owner or, for calls that name no owner, when the mTLS client certificate
+// was issued to it.
+func authorized(ctx context.Context, ownerID, requested string) bool {
+	if requested == "" {
+		if identity, ok := auth.FromContext(ctx); ok {
+			requested = identity.Subject
+		}
+	}
+	return requested != "" && requested == ownerID
+}
+
 func (r *chanReader) Read(p []byte) (int, error) {
 	select {
 	case <-r.ctx.Done():
@@ -252,3 +352,9 @@ func (r *chanReader) Read(p []byte) (int, error) {
 		return n, nil
 	}
 }
+
+// setRevision reports the media revision in the response header, since the
+// Media message does not carry it; clients echo it back as if-match.
+func setRevision(ctx context.Context, media *models.Media) {
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RevisionHeader, strconv.FormatInt(media.Revision, 10)))
+}
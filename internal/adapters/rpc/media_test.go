@@ -0,0 +1,56 @@
+package rpc
+
+import (
+	"context"
+	mediav1 "github.com/co1seam/ember-backend-api-contracts/gen/go/media"
+	"github.com/co1seam/ember-backend-media/config"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"testing"
+)
+
+type fakeUploadStream struct {
+	grpc.ServerStream
+	chunks []*mediav1.FileChunk
+}
+
+func (s *fakeUploadStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *fakeUploadStream) Recv() (*mediav1.FileChunk, error) {
+	if len(s.chunks) == 0 {
+		return nil, io.EOF
+	}
+	chunk := s.chunks[0]
+	s.chunks = s.chunks[1:]
+	return chunk, nil
+}
+
+func (s *fakeUploadStream) SendAndClose(*mediav1.FileResponse) error {
+	return nil
+}
+
+func TestUploadFileEnforcesLiveLimit(t *testing.T) {
+	store := config.NewStore(&config.Config{Media: config.Media{MaxFileSize: 8}})
+	handler := NewMediaHandler(&fakeMediaService{}, nil, &models.Options{Settings: store})
+
+	for name, chunks := range map[string][]*mediav1.FileChunk{
+		"declared": {
+			{IsFirst: true, FileId: "f1", FileName: "a.txt", TotalSize: 16},
+		},
+		"received": {
+			{IsFirst: true, FileId: "f1", FileName: "a.txt", TotalSize: 4},
+			{Content: []byte("01234")},
+			{Content: []byte("56789")},
+		},
+	} {
+		err := handler.UploadFile(&fakeUploadStream{chunks: chunks})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s: UploadFile = %v, want InvalidArgument", name, err)
+		}
+	}
+}
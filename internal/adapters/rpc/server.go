@@ -1,16 +1,21 @@
 package rpc
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/table"
 	mediav1 "github.com/co1seam/ember-backend-api-contracts/gen/go/media"
+	"github.com/co1seam/ember-backend-media/internal/adapters/health"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/reflection"
 	"net"
-	"os"
-	"os/signal"
-	"syscall"
 )
 
 var (
@@ -27,34 +32,87 @@ var (
 )
 
 type Server struct {
-	grpc *grpc.Server
+	grpc   *grpc.Server
+	health *health.Checker
+	opts   *models.Options
 }
 
-func NewServer() *Server {
-	return &Server{grpc: grpc.NewServer(
-		grpc.MaxRecvMsgSize(100*1024*1024),
-		grpc.MaxSendMsgSize(100*1024*1024))}
-}
+func NewServer(handler *Handler, opts *models.Options) (*Server, error) {
+	cfg := &opts.Config.GRPC
+
+	serverOpts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(orDefault(cfg.MaxRecvMsgSize, models.DefaultGRPCMaxMsgSize)),
+		grpc.MaxSendMsgSize(orDefault(cfg.MaxSendMsgSize, models.DefaultGRPCMaxMsgSize)),
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:              orDefault(cfg.KeepaliveTime, models.DefaultGRPCKeepaliveTime),
+			Timeout:           orDefault(cfg.KeepaliveTimeout, models.DefaultGRPCKeepaliveTimeout),
+			MaxConnectionIdle: cfg.MaxConnectionIdle,
+			MaxConnectionAge:  cfg.MaxConnectionAge,
+		}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             orDefault(cfg.KeepaliveMinTime, models.DefaultGRPCKeepaliveMinTime),
+			PermitWithoutStream: true,
+		}),
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(
+			identityUnaryInterceptor,
+			metricsUnaryInterceptor,
+			errorsUnaryInterceptor,
+			loggingUnaryInterceptor(opts.Logger),
+			handler.owners.unary,
+			handler.limits.unary,
+		),
+		grpc.ChainStreamInterceptor(
+			identityStreamInterceptor,
+			metricsStreamInterceptor,
+			errorsStreamInterceptor,
+			loggingStreamInterceptor(opts.Logger),
+			handler.owners.stream,
+			handler.limits.stream,
+		),
+	}
 
-func (s *Server) Run(handler *Handler) error {
-	conn, err := net.Listen("tcp", ":50052")
-	if err != nil {
-		return err
+	if cfg.MaxConcurrentStreams > 0 {
+		serverOpts = append(serverOpts, grpc.MaxConcurrentStreams(cfg.MaxConcurrentStreams))
 	}
 
-	mediav1.RegisterMediaServiceServer(s.grpc, handler.Media)
+	if cfg.TLSCertFile != "" || cfg.TLSKeyFile != "" {
+		reloader, err := newCertReloader(cfg, opts.Logger)
+		if err != nil {
+			return nil, err
+		}
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(reloader.TLSConfig())))
+	} else if cfg.ClientCAFile != "" {
+		return nil, errors.New("GRPC_TLS_CLIENT_CA_FILE requires GRPC_TLS_CERT_FILE and GRPC_TLS_KEY_FILE")
+	}
 
-	reflection.Register(s.grpc)
+	server := grpc.NewServer(serverOpts...)
 
-	printServicesTable(s.grpc)
+	mediav1.RegisterMediaServiceServer(server, handler.Media)
+	healthpb.RegisterHealthServer(server, handler.Health.Server())
+	handler.Health.AddService(mediav1.MediaService_ServiceDesc.ServiceName)
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	reflection.Register(server)
 
-	go func() {
-		<-quit
-		s.grpc.GracefulStop()
-	}()
+	return &Server{
+		grpc:   server,
+		health: handler.Health,
+		opts:   opts,
+	}, nil
+}
+
+func (s *Server) Run() error {
+	port := s.opts.Config.App.Port
+	if port == "" {
+		port = models.DefaultGRPCPort
+	}
+
+	conn, err := net.Listen("tcp", net.JoinHostPort(s.opts.Config.App.Host, port))
+	if err != nil {
+		return err
+	}
+
+	printServicesTable(s.grpc)
 
 	fmt.Printf("\n%s\n\n",
 		lipgloss.NewStyle().
@@ -65,6 +123,27 @@ func (s *Server) Run(handler *Handler) error {
 	return s.grpc.Serve(conn)
 }
 
+// Shutdown reports NOT_SERVING, stops accepting calls and waits for in-flight
+// ones; streams still open when ctx expires are closed forcibly.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.health.Shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.grpc.Stop()
+		<-done
+		return ctx.Err()
+	}
+}
+
 func printServicesTable(server *grpc.Server) {
 	services := server.GetServiceInfo()
 	if len(services) == 0 {
@@ -114,3 +193,11 @@ func getMethodType(m grpc.MethodInfo) string {
 		return "UNARY"
 	}
 }
+
+func orDefault[T comparable](value, fallback T) T {
+	var zero T
+	if value == zero {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,89 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"github.com/co1seam/ember-backend-media/internal/adapters/health"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+)
+
+// newHealthOnlyServer serves just the health service over an in-memory
+// listener, which is enough to hold a stream open across Shutdown.
+func newHealthOnlyServer(t *testing.T) (*Server, healthpb.HealthClient) {
+	t.Helper()
+
+	checker := health.NewChecker(&models.Options{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	checker.Start(context.Background())
+	t.Cleanup(checker.Stop)
+
+	server := &Server{grpc: grpc.NewServer(), health: checker}
+	healthpb.RegisterHealthServer(server.grpc, checker.Server())
+
+	listener := bufconn.Listen(1 << 20)
+	go server.grpc.Serve(listener)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return server, healthpb.NewHealthClient(conn)
+}
+
+func TestShutdownDrainsIdleServer(t *testing.T) {
+	server, client := newHealthOnlyServer(t)
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v, want a clean drain", err)
+	}
+	if server.health.Ready() {
+		t.Error("health still ready after Shutdown")
+	}
+}
+
+func TestShutdownForcesOpenStreams(t *testing.T) {
+	server, client := newHealthOnlyServer(t)
+
+	stream, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	if err := server.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() error = %v, want the drain deadline", err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("Shutdown() took %s with a stream open", elapsed)
+	}
+
+	for {
+		if _, err := stream.Recv(); err != nil {
+			break
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package rpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/co1seam/ember-backend-media/config"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// certReloader serves the certificate, key and client CA bundle from disk and
+// picks up rotated files without a restart. Files are re-read at most once
+// per TLSReloadInterval and only when their modification time changed; a
+// broken rotation keeps the previous configuration in use.
+type certReloader struct {
+	cfg    *config.GRPC
+	auth   tls.ClientAuthType
+	logger *slog.Logger
+
+	mu        sync.Mutex
+	current   *tls.Config
+	modTimes  [3]time.Time
+	checkedAt time.Time
+}
+
+func newCertReloader(cfg *config.GRPC, logger *slog.Logger) (*certReloader, error) {
+	auth, err := clientAuthType(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &certReloader{cfg: cfg, auth: auth, logger: logger}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.config(), nil
+		},
+	}
+}
+
+func (r *certReloader) config() *tls.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checkedAt) >= models.TLSReloadInterval {
+		r.checkedAt = time.Now()
+		if r.changed() {
+			if err := r.reloadLocked(); err != nil {
+				r.logger.Error("tls reload failed, keeping previous certificate", "error", err)
+			} else {
+				r.logger.Info("tls certificate reloaded")
+			}
+		}
+	}
+
+	return r.current
+}
+
+func (r *certReloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checkedAt = time.Now()
+	return r.reloadLocked()
+}
+
+func (r *certReloader) reloadLocked() error {
+	modTimes, err := r.stat()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.cfg.TLSCertFile, r.cfg.TLSKeyFile)
+	if err != nil {
+		return fmt.Errorf("load tls key pair: %w", err)
+	}
+
+	next := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   r.auth,
+		NextProtos:   []string{"h2"},
+	}
+
+	if r.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("read client ca: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("client ca file contains no certificates")
+		}
+		next.ClientCAs = pool
+	}
+
+	r.current = next
+	r.modTimes = modTimes
+	return nil
+}
+
+func (r *certReloader) changed() bool {
+	modTimes, err := r.stat()
+	return err == nil && modTimes != r.modTimes
+}
+
+func (r *certReloader) stat() ([3]time.Time, error) {
+	var modTimes [3]time.Time
+	for i, path := range []string{r.cfg.TLSCertFile, r.cfg.TLSKeyFile, r.cfg.ClientCAFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return modTimes, err
+		}
+		modTimes[i] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+func clientAuthType(cfg *config.GRPC) (tls.ClientAuthType, error) {
+	mode := cfg.ClientAuth
+	if mode == "" {
+		mode = "none"
+		if cfg.ClientCAFile != "" {
+			mode = "require"
+		}
+	}
+
+	switch mode {
+	case "none":
+		return tls.NoClientCert, nil
+	case "request":
+		if cfg.ClientCAFile == "" {
+			return tls.RequestClientCert, nil
+		}
+		return tls.VerifyClientCertIfGiven, nil
+	case "require":
+		if cfg.ClientCAFile == "" {
+			return 0, errors.New("GRPC_TLS_CLIENT_AUTH=require needs GRPC_TLS_CLIENT_CA_FILE")
+		}
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return 0, fmt.Errorf("unknown GRPC_TLS_CLIENT_AUTH: %s", mode)
+	}
+}
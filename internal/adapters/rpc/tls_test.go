@@ -0,0 +1,192 @@
+package rpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/co1seam/ember-backend-media/config"
+	"github.com/co1seam/ember-backend-media/internal/core/auth"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// issue creates a certificate for name, self-signed when parent is nil.
+func issue(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{name},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func writePEM(t *testing.T, path string, cert *x509.Certificate, key *ecdsa.PrivateKey, modTime time.Time) {
+	t.Helper()
+
+	var block *pem.Block
+	if key != nil {
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+	} else {
+		block = &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func servedName(t *testing.T, cfg *tls.Config) string {
+	t.Helper()
+	cert, err := x509.ParseCertificate(cfg.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert.Subject.CommonName
+}
+
+func TestClientAuthType(t *testing.T) {
+	for name, tc := range map[string]struct {
+		cfg  config.GRPC
+		want tls.ClientAuthType
+		ok   bool
+	}{
+		"default":            {config.GRPC{}, tls.NoClientCert, true},
+		"default with ca":    {config.GRPC{ClientCAFile: "ca.pem"}, tls.RequireAndVerifyClientCert, true},
+		"request":            {config.GRPC{ClientAuth: "request"}, tls.RequestClientCert, true},
+		"request with ca":    {config.GRPC{ClientAuth: "request", ClientCAFile: "ca.pem"}, tls.VerifyClientCertIfGiven, true},
+		"require without ca": {config.GRPC{ClientAuth: "require"}, 0, false},
+		"unknown":            {config.GRPC{ClientAuth: "sometimes"}, 0, false},
+	} {
+		got, err := clientAuthType(&tc.cfg)
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("%s: clientAuthType() = %v, %v; want %v, ok=%v", name, got, err, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestCertReloaderPicksUpRotation(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.GRPC{
+		TLSCertFile:  filepath.Join(dir, "server.pem"),
+		TLSKeyFile:   filepath.Join(dir, "server-key.pem"),
+		ClientCAFile: filepath.Join(dir, "ca.pem"),
+	}
+	ca, caKey := issue(t, "clients-ca", nil, nil)
+	writePEM(t, cfg.ClientCAFile, ca, nil, time.Now())
+
+	written := time.Now().Add(-time.Minute)
+	rotate := func(name string) {
+		written = written.Add(time.Second)
+		cert, key := issue(t, name, ca, caKey)
+		writePEM(t, cfg.TLSCertFile, cert, nil, written)
+		writePEM(t, cfg.TLSKeyFile, nil, key, written)
+	}
+	rotate("server-1")
+
+	reloader, err := newCertReloader(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("newCertReloader() error = %v", err)
+	}
+	current := reloader.config()
+	if servedName(t, current) != "server-1" || current.ClientAuth != tls.RequireAndVerifyClientCert || current.ClientCAs == nil {
+		t.Fatalf("config = %+v, want server-1 requiring client certificates", current)
+	}
+
+	rotate("server-2")
+	if got := servedName(t, reloader.config()); got != "server-1" {
+		t.Errorf("served %s before the reload interval passed, want server-1", got)
+	}
+
+	reloader.checkedAt = time.Time{}
+	if got := servedName(t, reloader.config()); got != "server-2" {
+		t.Errorf("served %s after rotation, want server-2", got)
+	}
+
+	written = written.Add(time.Second)
+	if err := os.WriteFile(cfg.TLSKeyFile, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(cfg.TLSKeyFile, written, written); err != nil {
+		t.Fatal(err)
+	}
+	reloader.checkedAt = time.Time{}
+	if got := servedName(t, reloader.config()); got != "server-2" {
+		t.Errorf("served %s after a broken rotation, want server-2 kept", got)
+	}
+}
+
+func TestWithIdentityFromClientCertificate(t *testing.T) {
+	ca, caKey := issue(t, "clients-ca", nil, nil)
+	client, _ := issue(t, "alice", ca, caKey)
+	client.URIs = []*url.URL{{Scheme: "spiffe", Host: "ember", Path: "/media-client"}}
+
+	addr := &net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 50123}
+	verified := peer.NewContext(context.Background(), &peer.Peer{
+		Addr:     addr,
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{client, ca}}}},
+	})
+
+	ctx := withIdentity(verified)
+	identity, ok := auth.FromContext(ctx)
+	if !ok {
+		t.Fatal("no identity for a verified client certificate")
+	}
+	if identity.Subject != "alice" || !slices.Equal(identity.DNSNames, []string{"alice"}) || !slices.Equal(identity.URIs, []string{"spiffe://ember/media-client"}) {
+		t.Errorf("identity = %+v", identity)
+	}
+	if ip := auth.ClientIPFromContext(ctx); ip != "10.1.2.3" {
+		t.Errorf("client IP = %q, want 10.1.2.3", ip)
+	}
+
+	unverified := peer.NewContext(context.Background(), &peer.Peer{
+		Addr:     addr,
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{client}}},
+	})
+	if _, ok := auth.FromContext(withIdentity(unverified)); ok {
+		t.Error("identity taken from an unverified certificate")
+	}
+}
@@ -0,0 +1,132 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/co1seam/ember-backend-media/config"
+	"github.com/co1seam/ember-backend-media/internal/core/errs"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const chunkSize = 64 * 1024
+
+type Clamd struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+func New(cfg *config.Scanner) (ports.IScanner, error) {
+	if cfg.Address == "" {
+		return NewNoop(), nil
+	}
+
+	return NewClamd(cfg.Address, cfg.Timeout)
+}
+
+// NewClamd accepts addresses in the form tcp://host:port or unix:///path/to/clamd.sock.
+func NewClamd(address string, timeout time.Duration) (*Clamd, error) {
+	network, addr, ok := strings.Cut(address, "://")
+	if !ok {
+		network, addr = "tcp", address
+	}
+	if network != "tcp" && network != "unix" {
+		return nil, fmt.Errorf("unsupported clamd network: %s", network)
+	}
+
+	if timeout <= 0 {
+		timeout = 5 * time.Minute
+	}
+
+	return &Clamd{
+		network: network,
+		address: addr,
+		timeout: timeout,
+	}, nil
+}
+
+func (c *Clamd) Scan(ctx context.Context, r io.Reader) (*models.ScanResult, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return nil, fmt.Errorf("clamd dial: %w", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(c.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, fmt.Errorf("clamd write: %w", err)
+	}
+
+	buf := make([]byte, chunkSize)
+	header := make([]byte, 4)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(header, uint32(n))
+			if _, err := conn.Write(header); err != nil {
+				return nil, fmt.Errorf("clamd write: %w", err)
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return nil, fmt.Errorf("clamd write: %w", err)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	binary.BigEndian.PutUint32(header, 0)
+	if _, err := conn.Write(header); err != nil {
+		return nil, fmt.Errorf("clamd write: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("clamd read: %w", err)
+	}
+
+	return parseReply(string(bytes.TrimRight(reply, "\x00\n")))
+}
+
+// parseReply reads clamd's verdict. An ERROR reply, such as the one for a
+// stream over StreamMaxLength, is about the file rather than the scanner and
+// is reported as models.ErrMediaScanFailed.
+func parseReply(reply string) (*models.ScanResult, error) {
+	if reason, ok := strings.CutSuffix(reply, " ERROR"); ok {
+		return nil, errs.Wrap(models.ErrMediaScanFailed, fmt.Errorf("clamd: %s", strings.TrimPrefix(reason, "stream: ")))
+	}
+
+	_, verdict, ok := strings.Cut(reply, ": ")
+	if !ok {
+		return nil, fmt.Errorf("clamd: unexpected reply %q", reply)
+	}
+
+	switch {
+	case verdict == "OK":
+		return &models.ScanResult{Clean: true}, nil
+	case strings.HasSuffix(verdict, " FOUND"):
+		return &models.ScanResult{Signature: strings.TrimSuffix(verdict, " FOUND")}, nil
+	default:
+		return nil, fmt.Errorf("clamd: %s", verdict)
+	}
+}
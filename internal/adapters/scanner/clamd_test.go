@@ -0,0 +1,147 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// standIn speaks enough of the clamd INSTREAM protocol to replace it: it
+// reads the chunks and reports a signature when the stream contains one.
+func standIn(t *testing.T, network, address string) string {
+	t.Helper()
+
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveClamd(conn)
+		}
+	}()
+
+	return network + "://" + listener.Addr().String()
+}
+
+func serveClamd(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	command, err := reader.ReadString(0)
+	if err != nil || command != "zINSTREAM\x00" {
+		io.WriteString(conn, "UNKNOWN COMMAND\x00")
+		return
+	}
+
+	var stream bytes.Buffer
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			return
+		}
+		size := binary.BigEndian.Uint32(header)
+		if size == 0 {
+			break
+		}
+		if _, err := io.CopyN(&stream, reader, int64(size)); err != nil {
+			return
+		}
+	}
+
+	switch {
+	case strings.Contains(stream.String(), "EICAR"):
+		io.WriteString(conn, "stream: Eicar-Test-Signature FOUND\x00")
+	case strings.Contains(stream.String(), "BROKEN"):
+		io.WriteString(conn, "INSTREAM size limit exceeded. ERROR\x00")
+	default:
+		io.WriteString(conn, "stream: OK\x00")
+	}
+}
+
+func TestClamdScan(t *testing.T) {
+	addresses := map[string]string{
+		"tcp":  standIn(t, "tcp", "127.0.0.1:0"),
+		"unix": standIn(t, "unix", filepath.Join(t.TempDir(), "clamd.sock")),
+	}
+
+	for network, address := range addresses {
+		t.Run(network, func(t *testing.T) {
+			clamd, err := NewClamd(address, time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			large := strings.Repeat("x", 3*chunkSize) + "EICAR"
+			result, err := clamd.Scan(context.Background(), strings.NewReader(large))
+			if err != nil || result.Clean || result.Signature != "Eicar-Test-Signature" {
+				t.Errorf("infected Scan() = %+v, %v", result, err)
+			}
+
+			result, err = clamd.Scan(context.Background(), strings.NewReader("hello"))
+			if err != nil || !result.Clean {
+				t.Errorf("clean Scan() = %+v, %v", result, err)
+			}
+
+			if _, err := clamd.Scan(context.Background(), strings.NewReader("BROKEN")); !errors.Is(err, models.ErrMediaScanFailed) {
+				t.Errorf("Scan() of a clamd error reply = %v, want ErrMediaScanFailed", err)
+			}
+		})
+	}
+}
+
+func TestClamdScanUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	clamd, err := NewClamd(address, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = clamd.Scan(context.Background(), strings.NewReader("hello"))
+	if err == nil || errors.Is(err, models.ErrMediaScanFailed) {
+		t.Errorf("Scan() against a stopped clamd = %v, want a scanner error", err)
+	}
+}
+
+func TestParseReply(t *testing.T) {
+	for reply, want := range map[string]error{
+		"stream: OK":                          nil,
+		"stream: Eicar-Test-Signature FOUND":  nil,
+		"INSTREAM size limit exceeded. ERROR": models.ErrMediaScanFailed,
+		"stream: Can't allocate memory ERROR": models.ErrMediaScanFailed,
+	} {
+		if _, err := parseReply(reply); !errors.Is(err, want) {
+			t.Errorf("parseReply(%q) error = %v, want %v", reply, err, want)
+		}
+	}
+
+	if _, err := parseReply("garbage"); err == nil || errors.Is(err, models.ErrMediaScanFailed) {
+		t.Errorf("parseReply(garbage) error = %v, want an unexpected reply", err)
+	}
+}
+
+func TestNewClamdRejectsUnknownNetwork(t *testing.T) {
+	if _, err := NewClamd("udp://127.0.0.1:3310", 0); err == nil {
+		t.Error("NewClamd() accepted a udp address")
+	}
+}
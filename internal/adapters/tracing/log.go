@@ -0,0 +1,35 @@
+package tracing
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+)
+
+// LogHandler adds trace_id and span_id to records logged with a context
+// carrying a span, e.g. logger.InfoContext(ctx, ...).
+type LogHandler struct {
+	slog.Handler
+}
+
+func NewLogHandler(inner slog.Handler) *LogHandler {
+	return &LogHandler{Handler: inner}
+}
+
+func (h *LogHandler) Handle(ctx context.Context, record slog.Record) error {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		record.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &LogHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *LogHandler) WithGroup(name string) slog.Handler {
+	return &LogHandler{Handler: h.Handler.WithGroup(name)}
+}
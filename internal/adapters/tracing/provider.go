@@ -0,0 +1,117 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"github.com/co1seam/ember-backend-media/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// Provider owns the SDK tracer provider installed as the otel global.
+type Provider struct {
+	tp *sdktrace.TracerProvider
+}
+
+// Setup installs the W3C trace context propagator and, when an exporter is
+// configured, a global tracer provider sampling the given ratio of new
+// traces. With no exporter the otel no-op provider stays in place, but
+// incoming trace context is still propagated. Export failures are logged.
+func Setup(cfg *config.Tracing, logger *slog.Logger) (*Provider, error) {
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logger.Warn("tracing error", slog.String("error", err.Error()))
+	}))
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case "", "none":
+		return nil, nil
+	case "console", "stdout":
+		exporter, err = stdouttrace.New()
+	case "otlp":
+		exporter, err = newOTLPExporter(cfg.Endpoint, cfg.Headers)
+	default:
+		return nil, fmt.Errorf("unknown traces exporter: %s", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ratio := 1.0
+	if cfg.SamplerArg != "" {
+		ratio, err = strconv.ParseFloat(cfg.SamplerArg, 64)
+		if err != nil || ratio < 0 || ratio > 1 {
+			return nil, fmt.Errorf("invalid OTEL_TRACES_SAMPLER_ARG: %s", cfg.SamplerArg)
+		}
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = "ember-backend-media"
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+	otel.SetTracerProvider(tp)
+
+	return &Provider{tp: tp}, nil
+}
+
+// Shutdown flushes pending spans; it is a no-op on a nil provider.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	return p.tp.Shutdown(ctx)
+}
+
+func newOTLPExporter(endpoint, headers string) (sdktrace.SpanExporter, error) {
+	if endpoint == "" {
+		endpoint = "http://localhost:4318"
+	}
+	if !strings.HasSuffix(endpoint, "/v1/traces") {
+		endpoint = strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+	}
+
+	parsed := make(map[string]string)
+	for _, pair := range strings.Split(headers, ",") {
+		if pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid OTEL_EXPORTER_OTLP_HEADERS entry: %s", pair)
+		}
+		parsed[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	return otlptracehttp.New(context.Background(),
+		otlptracehttp.WithEndpointURL(endpoint),
+		otlptracehttp.WithHeaders(parsed),
+	)
+}
+
+// Finish records *err, if any, and ends the span; it is meant to be deferred
+// from functions with a named error result: defer tracing.Finish(span, &err).
+func Finish(span trace.Span, err *error) {
+	if err != nil && *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}
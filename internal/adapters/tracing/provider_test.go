@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/co1seam/ember-backend-media/config"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestSetupRejectsBadConfig(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for name, cfg := range map[string]config.Tracing{
+		"exporter": {Exporter: "zipkin"},
+		"sampler":  {Exporter: "stdout", SamplerArg: "1.5"},
+		"headers":  {Exporter: "otlp", Headers: "authorization"},
+	} {
+		if _, err := Setup(&cfg, logger); err == nil {
+			t.Errorf("%s: Setup() error = nil, want an error", name)
+		}
+	}
+
+	provider, err := Setup(&config.Tracing{Exporter: "none"}, logger)
+	if err != nil || provider != nil {
+		t.Fatalf("Setup(none) = %v, %v, want nil, nil", provider, err)
+	}
+	if err := provider.Shutdown(context.Background()); err != nil {
+		t.Fatalf("nil Provider.Shutdown() error = %v", err)
+	}
+}
+
+func TestFinishRecordsError(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	_, span := tracer.Start(context.Background(), "ok")
+	var err error
+	Finish(span, &err)
+
+	_, span = tracer.Start(context.Background(), "failed")
+	err = errors.New("boom")
+	Finish(span, &err)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("ended spans = %d, want 2", len(spans))
+	}
+	if spans[0].Status().Code != codes.Unset {
+		t.Errorf("ok span status = %v, want Unset", spans[0].Status().Code)
+	}
+	if spans[1].Status().Code != codes.Error || len(spans[1].Events()) != 1 {
+		t.Errorf("failed span status = %v with %d events, want Error with 1", spans[1].Status().Code, len(spans[1].Events()))
+	}
+}
+
+func TestLogHandlerAddsTraceIDs(t *testing.T) {
+	tracer := sdktrace.NewTracerProvider().Tracer("test")
+	ctx, span := tracer.Start(context.Background(), "request")
+	defer span.End()
+
+	var buf bytes.Buffer
+	logger := slog.New(NewLogHandler(slog.NewJSONHandler(&buf, nil)))
+	logger.InfoContext(ctx, "traced")
+	logger.Info("untraced")
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	var traced, untraced map[string]any
+	if err := json.Unmarshal(lines[0], &traced); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(lines[1], &untraced); err != nil {
+		t.Fatal(err)
+	}
+
+	if traced["trace_id"] != span.SpanContext().TraceID().String() || traced["span_id"] != span.SpanContext().SpanID().String() {
+		t.Errorf("traced record = %v, want the span's IDs", traced)
+	}
+	if _, ok := untraced["trace_id"]; ok {
+		t.Errorf("untraced record = %v, want no trace_id", untraced)
+	}
+}
@@ -0,0 +1,148 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Ember-Signature"
+	EventHeader     = "X-Ember-Event"
+	DeliveryHeader  = "X-Ember-Delivery"
+)
+
+// ErrPrivateTarget is returned for webhook URLs whose host resolves to a
+// loopback, private, link-local or otherwise non-public address.
+var ErrPrivateTarget = errors.New("webhook target is not a public address")
+
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+type Sender struct {
+	client   *http.Client
+	resolver *net.Resolver
+	// allowPrivate turns off the address checks; tests use it to reach
+	// httptest servers on loopback.
+	allowPrivate bool
+}
+
+// NewSender returns a sender that refuses to connect to non-public
+// addresses. The check runs on the address actually dialed, so it also
+// covers redirects and hosts that resolve differently after registration.
+func NewSender(timeout time.Duration) *Sender {
+	s := &Sender{resolver: net.DefaultResolver}
+
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			return s.checkAddress(address)
+		},
+	}
+	s.client = &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+
+	return s
+}
+
+// CheckTarget resolves the host of target and rejects it when any of its
+// addresses is not public.
+func (s *Sender) CheckTarget(ctx context.Context, target string) error {
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+	if s.allowPrivate {
+		return nil
+	}
+
+	addrs, err := s.resolver.LookupNetIP(ctx, "ip", parsed.Hostname())
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !public(addr) {
+			return ErrPrivateTarget
+		}
+	}
+
+	return nil
+}
+
+func (s *Sender) checkAddress(address string) error {
+	if s.allowPrivate {
+		return nil
+	}
+
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !public(addrPort.Addr()) {
+		return ErrPrivateTarget
+	}
+	return nil
+}
+
+func public(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() &&
+		!addr.IsPrivate() &&
+		!sharedAddressSpace.Contains(addr)
+}
+
+func (s *Sender) Send(ctx context.Context, delivery *models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ember-media-webhooks/1")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	req.Header.Set(SignatureHeader, fmt.Sprintf("t=%d,v1=%s", timestamp, Sign(delivery.Secret, timestamp, delivery.Payload)))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Sign computes the hex HMAC-SHA256 of "<timestamp>.<body>", which receivers
+// recompute to verify authenticity and reject replays outside their tolerance.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
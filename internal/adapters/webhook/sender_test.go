@@ -0,0 +1,126 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendSignsPayload(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sender := NewSender(time.Second)
+	sender.allowPrivate = true
+
+	delivery := &models.WebhookDelivery{
+		ID:        "d1",
+		EventType: "media.created",
+		Payload:   []byte(`{"id":"e1"}`),
+		URL:       server.URL,
+		Secret:    "s3cret",
+	}
+	code, err := sender.Send(context.Background(), delivery)
+	if err != nil || code != http.StatusNoContent {
+		t.Fatalf("Send() = %d, %v, want 204, nil", code, err)
+	}
+
+	if string(body) != `{"id":"e1"}` {
+		t.Errorf("body = %s, want the payload", body)
+	}
+	if got.Header.Get(EventHeader) != "media.created" || got.Header.Get(DeliveryHeader) != "d1" {
+		t.Errorf("event headers = %v", got.Header)
+	}
+	var timestamp int64
+	var signature string
+	if _, err := fmt.Sscanf(strings.Replace(got.Header.Get(SignatureHeader), ",v1=", " ", 1), "t=%d %s", &timestamp, &signature); err != nil {
+		t.Fatalf("signature header %q: %v", got.Header.Get(SignatureHeader), err)
+	}
+	if signature != Sign("s3cret", timestamp, body) {
+		t.Errorf("signature = %s, want %s", signature, Sign("s3cret", timestamp, body))
+	}
+}
+
+func TestSendReportsStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	sender := NewSender(time.Second)
+	sender.allowPrivate = true
+
+	code, err := sender.Send(context.Background(), &models.WebhookDelivery{URL: server.URL})
+	if err == nil || code != http.StatusBadGateway {
+		t.Fatalf("Send() = %d, %v, want 502 and an error", code, err)
+	}
+}
+
+func TestSendRefusesPrivateAddresses(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	_, err := NewSender(time.Second).Send(context.Background(), &models.WebhookDelivery{URL: server.URL})
+	if !errors.Is(err, ErrPrivateTarget) {
+		t.Fatalf("Send() error = %v, want ErrPrivateTarget", err)
+	}
+	if called {
+		t.Fatal("the loopback endpoint was called")
+	}
+}
+
+func TestCheckTarget(t *testing.T) {
+	sender := NewSender(time.Second)
+	for _, target := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://[::1]/hook",
+		"http://10.1.2.3/hook",
+		"http://192.168.0.10/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://100.64.0.1/hook",
+		"http://0.0.0.0/hook",
+		"http://[::ffff:127.0.0.1]/hook",
+	} {
+		if err := sender.CheckTarget(context.Background(), target); !errors.Is(err, ErrPrivateTarget) {
+			t.Errorf("CheckTarget(%s) = %v, want ErrPrivateTarget", target, err)
+		}
+	}
+
+	if err := sender.CheckTarget(context.Background(), "https://93.184.215.14/hook"); err != nil {
+		t.Errorf("CheckTarget(public) = %v, want nil", err)
+	}
+}
+
+func TestPublic(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"8.8.8.8":              true,
+		"2606:4700::1111":      true,
+		"172.16.0.1":           false,
+		"fd00::1":              false,
+		"fe80::1":              false,
+		"224.0.0.1":            false,
+		"::ffff:10.0.0.1":      false,
+		"::ffff:93.184.215.14": true,
+	} {
+		if got := public(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("public(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package auth
+
+import "context"
+
+// Identity is the authenticated caller, currently only derived from a
+// verified mTLS client certificate.
+type Identity struct {
+	Subject  string
+	DNSNames []string
+	URIs     []string
+}
+
+type identityKey struct{}
+
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+func FromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(*Identity)
+	return identity, ok
+}
+
+type clientIPKey struct{}
+
+// WithClientIP records the network address the request came from.
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+func ClientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
+
+type requestIDKey struct{}
+
+// WithRequestID records the ID the transport assigned to the request.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
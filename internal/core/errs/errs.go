@@ -0,0 +1,121 @@
+package errs
+
+import (
+	"database/sql"
+	"errors"
+)
+
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindNotFound
+	KindConflict
+	KindInvalidArgument
+	KindPermissionDenied
+	KindFailedPrecondition
+	KindAborted
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindNotFound:
+		return "not_found"
+	case KindConflict:
+		return "conflict"
+	case KindInvalidArgument:
+		return "invalid_argument"
+	case KindPermissionDenied:
+		return "permission_denied"
+	case KindFailedPrecondition:
+		return "failed_precondition"
+	case KindAborted:
+		return "aborted"
+	default:
+		return "internal"
+	}
+}
+
+// Error is a domain error whose Message is safe to return to clients. The
+// wrapped cause, if any, is only meant for logs.
+type Error struct {
+	Kind    Kind
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches errors of the same kind and message, so that a sentinel still
+// matches after being re-created by Wrap.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Kind == e.Kind && t.Message == e.Message
+}
+
+func New(kind Kind, message string) *Error {
+	return &Error{Kind: kind, Message: message}
+}
+
+func NotFound(message string) *Error {
+	return New(KindNotFound, message)
+}
+
+func Conflict(message string) *Error {
+	return New(KindConflict, message)
+}
+
+func InvalidArgument(message string) *Error {
+	return New(KindInvalidArgument, message)
+}
+
+func PermissionDenied(message string) *Error {
+	return New(KindPermissionDenied, message)
+}
+
+func FailedPrecondition(message string) *Error {
+	return New(KindFailedPrecondition, message)
+}
+
+func Aborted(message string) *Error {
+	return New(KindAborted, message)
+}
+
+// Wrap attaches cause to a copy of e.
+func Wrap(e *Error, cause error) *Error {
+	return &Error{Kind: e.Kind, Message: e.Message, Err: cause}
+}
+
+// KindOf classifies err. Errors that are not domain errors are internal,
+// except sql.ErrNoRows which always means the row does not exist.
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return KindNotFound
+	}
+	return KindInternal
+}
+
+// Message returns the client-facing message for err, hiding internal details.
+func Message(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Message
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return "not found"
+	}
+	return "internal error"
+}
@@ -0,0 +1,52 @@
+package errs
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+var errGone = NotFound("media not found")
+
+func TestKindAndMessage(t *testing.T) {
+	for name, tc := range map[string]struct {
+		err     error
+		kind    Kind
+		message string
+	}{
+		"domain":  {errGone, KindNotFound, "media not found"},
+		"wrapped": {fmt.Errorf("get media: %w", Wrap(errGone, errors.New("row 7 deleted"))), KindNotFound, "media not found"},
+		"no rows": {fmt.Errorf("scan: %w", sql.ErrNoRows), KindNotFound, "not found"},
+		"other":   {errors.New("pq: password authentication failed"), KindInternal, "internal error"},
+		"invalid": {InvalidArgument("title is required"), KindInvalidArgument, "title is required"},
+	} {
+		if got := KindOf(tc.err); got != tc.kind {
+			t.Errorf("%s: KindOf() = %s, want %s", name, got, tc.kind)
+		}
+		if got := Message(tc.err); got != tc.message {
+			t.Errorf("%s: Message() = %q, want %q", name, got, tc.message)
+		}
+	}
+}
+
+func TestWrapKeepsSentinel(t *testing.T) {
+	cause := errors.New("connection reset")
+	err := Wrap(errGone, cause)
+
+	if !errors.Is(err, errGone) {
+		t.Error("wrapped error no longer matches its sentinel")
+	}
+	if !errors.Is(err, cause) {
+		t.Error("wrapped error lost its cause")
+	}
+	if errors.Is(err, NotFound("webhook not found")) || errors.Is(err, Conflict("media not found")) {
+		t.Error("error matches a sentinel of another message or kind")
+	}
+	if err.Error() != "media not found: connection reset" {
+		t.Errorf("Error() = %q", err.Error())
+	}
+	if errGone.Err != nil {
+		t.Error("Wrap modified the sentinel")
+	}
+}
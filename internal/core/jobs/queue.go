@@ -0,0 +1,79 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+)
+
+var ErrQueueFull = errors.New("job queue is full")
+
+type Job struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+type Queue struct {
+	jobs    chan Job
+	workers int
+	logger  *slog.Logger
+	wg      sync.WaitGroup
+	cancel  context.CancelFunc
+}
+
+func NewQueue(workers, size int, logger *slog.Logger) *Queue {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	return &Queue{
+		jobs:    make(chan Job, size),
+		workers: workers,
+		logger:  logger,
+	}
+}
+
+func (q *Queue) Start(ctx context.Context) {
+	ctx, q.cancel = context.WithCancel(ctx)
+
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.work(ctx)
+	}
+}
+
+func (q *Queue) Stop() {
+	if q.cancel != nil {
+		q.cancel()
+	}
+	q.wg.Wait()
+}
+
+func (q *Queue) Enqueue(name string, run func(ctx context.Context) error) error {
+	select {
+	case q.jobs <- Job{Name: name, Run: run}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (q *Queue) Len() int {
+	return len(q.jobs)
+}
+
+func (q *Queue) work(ctx context.Context) {
+	defer q.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-q.jobs:
+			if err := job.Run(ctx); err != nil {
+				q.logger.Error("job failed", "job", job.Name, "error", err)
+			}
+		}
+	}
+}
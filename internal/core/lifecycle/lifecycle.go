@@ -0,0 +1,144 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// forceStopTimeout bounds each stop once the drain deadline has passed, so
+// that resources are still released after a slow server drain.
+const forceStopTimeout = 5 * time.Second
+
+type component struct {
+	name  string
+	start func(ctx context.Context) error
+	run   func(ctx context.Context) error
+	stop  func(ctx context.Context) error
+}
+
+// Manager starts components in registration order and stops them in reverse
+// order once a signal arrives, the parent context is cancelled or a server
+// exits on its own. Components are started with the parent context rather
+// than the signal context, so they keep working while the ones registered
+// after them drain.
+type Manager struct {
+	components []component
+	timeout    time.Duration
+	logger     *slog.Logger
+}
+
+func NewManager(timeout time.Duration, logger *slog.Logger) *Manager {
+	return &Manager{
+		timeout: timeout,
+		logger:  logger,
+	}
+}
+
+// Add registers a component whose start returns once it is running. Either
+// function may be nil.
+func (m *Manager) Add(name string, start, stop func(ctx context.Context) error) {
+	m.components = append(m.components, component{name: name, start: start, stop: stop})
+}
+
+// Go registers a component whose run blocks until stop is called, such as a
+// network server.
+func (m *Manager) Go(name string, run, stop func(ctx context.Context) error) {
+	m.components = append(m.components, component{name: name, run: run, stop: stop})
+}
+
+func (m *Manager) Run(ctx context.Context) error {
+	sigCtx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	failed := make(chan error, len(m.components))
+	started := 0
+
+	var startErr error
+	for _, c := range m.components {
+		if c.start != nil {
+			if err := c.start(ctx); err != nil {
+				startErr = fmt.Errorf("start %s: %w", c.name, err)
+				break
+			}
+		}
+
+		if c.run != nil {
+			go func() {
+				if err := c.run(ctx); err != nil {
+					failed <- fmt.Errorf("%s: %w", c.name, err)
+					return
+				}
+				failed <- nil
+			}()
+		}
+
+		started++
+	}
+
+	var runErr error
+	if startErr == nil {
+		select {
+		case <-sigCtx.Done():
+			m.logger.Info("shutdown requested")
+		case runErr = <-failed:
+			if runErr != nil {
+				m.logger.Error("component failed, shutting down", "error", runErr)
+			}
+		}
+	}
+
+	return errors.Join(startErr, runErr, m.shutdown(m.components[:started]))
+}
+
+// Close stops everything registered so far without running it, to release
+// resources when start-up is aborted before Run.
+func (m *Manager) Close() error {
+	return m.shutdown(m.components)
+}
+
+// shutdown stops components in reverse order under a single drain deadline.
+// Stop functions receive the deadline context and are expected to force
+// their shutdown once it expires; one that still hangs is abandoned.
+func (m *Manager) shutdown(components []component) error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	var errs []error
+	for i := len(components) - 1; i >= 0; i-- {
+		c := components[i]
+		if c.stop == nil {
+			continue
+		}
+
+		started := time.Now()
+		done := make(chan error, 1)
+		go func() { done <- c.stop(ctx) }()
+
+		deadline := time.NewTimer(time.Until(started.Add(forceStopTimeout)))
+		if remaining, ok := ctx.Deadline(); ok && ctx.Err() == nil {
+			deadline.Reset(time.Until(remaining) + forceStopTimeout)
+		}
+
+		select {
+		case err := <-done:
+			if err != nil {
+				errs = append(errs, fmt.Errorf("stop %s: %w", c.name, err))
+				m.logger.Error("component stop failed", "component", c.name, "error", err)
+				continue
+			}
+			m.logger.Info("component stopped", "component", c.name, "duration_ms", time.Since(started).Milliseconds())
+		case <-deadline.C:
+			errs = append(errs, fmt.Errorf("stop %s: %w", c.name, context.DeadlineExceeded))
+			m.logger.Error("component stop timed out", "component", c.name)
+		}
+		deadline.Stop()
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,149 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder notes the order in which components start and stop.
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) note(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) step(event string, err error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		r.note(event)
+		return err
+	}
+}
+
+func newTestManager(timeout time.Duration) *Manager {
+	return NewManager(timeout, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestRunStopsInReverseOrder(t *testing.T) {
+	var rec recorder
+	m := newTestManager(time.Second)
+
+	stopped := make(chan struct{})
+	m.Add("db", rec.step("start db", nil), rec.step("stop db", nil))
+	m.Add("workers", rec.step("start workers", nil), rec.step("stop workers", nil))
+	m.Go("grpc", func(ctx context.Context) error {
+		rec.note("run grpc")
+		<-stopped
+		return nil
+	}, func(ctx context.Context) error {
+		rec.note("stop grpc")
+		close(stopped)
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- m.Run(ctx) }()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run() did not return after the context was cancelled")
+	}
+
+	want := []string{"start db", "start workers", "run grpc", "stop grpc", "stop workers", "stop db"}
+	if !slices.Equal(rec.events, want) {
+		t.Errorf("events = %v, want %v", rec.events, want)
+	}
+}
+
+func TestRunStopsOnlyStartedComponents(t *testing.T) {
+	var rec recorder
+	m := newTestManager(time.Second)
+	boom := errors.New("connection refused")
+
+	m.Add("db", rec.step("start db", nil), rec.step("stop db", nil))
+	m.Add("redis", rec.step("start redis", boom), rec.step("stop redis", nil))
+	m.Add("workers", rec.step("start workers", nil), rec.step("stop workers", nil))
+
+	err := m.Run(context.Background())
+	if !errors.Is(err, boom) || err.Error() != "start redis: connection refused" {
+		t.Errorf("Run() error = %v, want the start failure", err)
+	}
+
+	want := []string{"start db", "start redis", "stop db"}
+	if !slices.Equal(rec.events, want) {
+		t.Errorf("events = %v, want %v", rec.events, want)
+	}
+}
+
+func TestRunShutsDownWhenAServerExits(t *testing.T) {
+	var rec recorder
+	m := newTestManager(time.Second)
+	boom := errors.New("address already in use")
+
+	m.Add("db", nil, rec.step("stop db", nil))
+	m.Go("grpc", func(ctx context.Context) error { return boom }, rec.step("stop grpc", nil))
+
+	done := make(chan error, 1)
+	go func() { done <- m.Run(context.Background()) }()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, boom) {
+			t.Errorf("Run() error = %v, want the server's", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run() kept running after a server exited")
+	}
+
+	want := []string{"stop grpc", "stop db"}
+	if !slices.Equal(rec.events, want) {
+		t.Errorf("events = %v, want %v", rec.events, want)
+	}
+}
+
+func TestShutdownDrainDeadline(t *testing.T) {
+	var rec recorder
+	m := newTestManager(20 * time.Millisecond)
+
+	m.Add("db", nil, rec.step("stop db", nil))
+	m.Add("grpc", nil, func(ctx context.Context) error {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("stop got no drain deadline")
+		}
+		<-ctx.Done()
+		rec.note("force grpc")
+		return ctx.Err()
+	})
+
+	started := time.Now()
+	err := m.Close()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Close() error = %v, want the drain deadline", err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("Close() took %s", elapsed)
+	}
+
+	want := []string{"force grpc", "stop db"}
+	if !slices.Equal(rec.events, want) {
+		t.Errorf("events = %v, want the rest stopped after the deadline", rec.events)
+	}
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+type Album struct {
+	ID           string    `json:"id"`
+	OwnerID      string    `json:"owner_id"`
+	Title        string    `json:"title"`
+	Description  string    `json:"description"`
+	CoverMediaID string    `json:"cover_media_id,omitempty"`
+	MediaCount   int       `json:"media_count"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type CreateAlbumRequest struct {
+	OwnerID     string `json:"owner_id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// UpdateAlbumRequest changes only the fields named in UpdateMask.
+type UpdateAlbumRequest struct {
+	ID           string   `json:"id"`
+	OwnerID      string   `json:"owner_id"`
+	Title        string   `json:"title"`
+	Description  string   `json:"description"`
+	CoverMediaID string   `json:"cover_media_id"`
+	UpdateMask   []string `json:"update_mask"`
+}
+
+const (
+	AlbumFieldTitle       = "title"
+	AlbumFieldDescription = "description"
+	AlbumFieldCover       = "cover_media_id"
+)
+
+type AlbumMediaRequest struct {
+	MediaIDs []string `json:"media_ids"`
+}
+
+// AlbumPage is one page of album contents; NextPageToken is empty on the last.
+type AlbumPage struct {
+	Media         []*Media `json:"media"`
+	NextPageToken string   `json:"next_page_token"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Archive is a planned ZIP download. Entries without an Error are written in
+// order; the rest are only listed in the manifest.
+type Archive struct {
+	OwnerID   string          `json:"owner_id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Entries   []*ArchiveEntry `json:"entries"`
+}
+
+type ArchiveEntry struct {
+	MediaID     string `json:"media_id"`
+	Name        string `json:"name,omitempty"`
+	Title       string `json:"title,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Size        int64  `json:"size,omitempty"`
+	Version     int    `json:"version,omitempty"`
+	Stored      bool   `json:"stored,omitempty"`
+	Error       string `json:"error,omitempty"`
+	StoragePath string `json:"-"`
+}
+
+const (
+	MaxArchiveMedia     = 500
+	ArchiveManifestName = "manifest.json"
+)
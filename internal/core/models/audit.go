@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+const (
+	AuditActorAnonymous = "anonymous"
+	AuditActorSystem    = "system"
+)
+
+// Actions of changes that publish no event.
+const (
+	AuditActionMediaImported = "media.imported"
+	// AuditActionMediaErased records media removed by an owner erasure,
+	// without the changes, which would be personal data.
+	AuditActionMediaErased = "media.erased"
+)
+
+// AuditEvent records one change to media: who made it, from where, and the
+// fields it changed. Action is the type of the event published with it, or
+// an AuditAction for changes that publish none.
+type AuditEvent struct {
+	ID        int64                  `json:"id"`
+	Action    string                 `json:"action"`
+	MediaID   string                 `json:"media_id"`
+	OwnerID   string                 `json:"owner_id"`
+	Actor     string                 `json:"actor"`
+	ClientIP  string                 `json:"client_ip,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Changes   map[string]AuditChange `json:"changes"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// AuditChange is the value of a field before and after a change; null
+// stands for an empty value.
+type AuditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// AuditQuery filters the audit log. Zero fields do not filter; BeforeID
+// continues a listing from an earlier page.
+type AuditQuery struct {
+	MediaID  string
+	Actor    string
+	Since    time.Time
+	Until    time.Time
+	BeforeID int64
+	Limit    int
+}
+
+// AuditPage is one page of audit events, newest first; NextPageToken is
+// empty on the last.
+type AuditPage struct {
+	Events        []*AuditEvent `json:"events"`
+	NextPageToken string        `json:"next_page_token"`
+}
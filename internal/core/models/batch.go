@@ -0,0 +1,17 @@
+package models
+
+// BatchResult is the outcome of one item of a batch call, in request order.
+// Err is set instead of Media when that item failed.
+type BatchResult struct {
+	ID    string
+	Media *Media
+	Err   error
+}
+
+// MediaPatch is one row of a batch update: Media holds the new values of
+// Fields, applied only while the stored revision is still Revision.
+type MediaPatch struct {
+	Media    *Media
+	Fields   []string
+	Revision int64
+}
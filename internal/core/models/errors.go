@@ -0,0 +1,21 @@
+package models
+
+import "github.com/co1seam/ember-backend-media/internal/core/errs"
+
+var (
+	ErrMediaNotFound       = errs.NotFound("media not found")
+	ErrVersionNotFound     = errs.NotFound("media version not found")
+	ErrMediaQuarantined    = errs.FailedPrecondition("media is quarantined")
+	ErrMediaNotQuarantined = errs.FailedPrecondition("media is not quarantined")
+	ErrMediaPendingScan    = errs.FailedPrecondition("media is waiting for a malware scan")
+	ErrMediaScanFailed     = errs.FailedPrecondition("media could not be scanned for malware")
+	ErrMediaModified       = errs.Aborted("media was modified concurrently")
+	ErrInvalidMedia        = errs.InvalidArgument("invalid media")
+	ErrPermissionDenied    = errs.PermissionDenied("permission denied")
+	ErrAlbumNotFound       = errs.NotFound("album not found")
+	ErrImportJobNotFound   = errs.NotFound("import job not found")
+	ErrPrivacyNotFound     = errs.NotFound("privacy request not found")
+	ErrWebhookNotFound     = errs.NotFound("webhook not found")
+	ErrInvalidWebhook      = errs.InvalidArgument("invalid webhook")
+	ErrWebhookTarget       = errs.InvalidArgument("webhook URL must resolve to a public address")
+)
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const EventSchemaVersion = 1
+
+const (
+	EventMediaCreated     = "media.created"
+	EventMediaUploaded    = "media.uploaded"
+	EventMediaProcessed   = "media.processed"
+	EventMediaUpdated     = "media.updated"
+	EventMediaDeleted     = "media.deleted"
+	EventMediaQuarantined = "media.quarantined"
+	EventMediaRolledBack  = "media.rolled_back"
+)
+
+type Event struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	SchemaVersion int             `json:"schema_version"`
+	AggregateID   string          `json:"aggregate_id"`
+	OwnerID       string          `json:"owner_id"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Data          json.RawMessage `json:"data"`
+}
+
+type OutboxEvent struct {
+	Event
+	Attempts  int
+	LastError string
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// ImportJob tracks a bulk import. Cursor is the last source key handled, so
+// a resumed job carries on after it.
+type ImportJob struct {
+	ID         string     `json:"id"`
+	OwnerID    string     `json:"owner_id"`
+	Source     string     `json:"source"`
+	Location   string     `json:"location"`
+	Prefix     string     `json:"prefix"`
+	State      string     `json:"state"`
+	Cursor     string     `json:"cursor"`
+	Imported   int        `json:"imported"`
+	Failed     int        `json:"failed"`
+	Bytes      int64      `json:"bytes"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+type ImportItem struct {
+	JobID     string    `json:"job_id"`
+	SourceKey string    `json:"source_key"`
+	MediaID   string    `json:"media_id,omitempty"`
+	Size      int64     `json:"size"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const (
+	ImportSourceBucket = "bucket"
+	ImportSourceDir    = "dir"
+)
+
+const (
+	ImportStateRunning   = "running"
+	ImportStateCompleted = "completed"
+	ImportStateFailed    = "failed"
+)
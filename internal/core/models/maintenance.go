@@ -0,0 +1,22 @@
+package models
+
+type ReconcileReport struct {
+	Media           int      `json:"media"`
+	Objects         int      `json:"objects"`
+	MissingObjects  []string `json:"missing_objects"`
+	OrphanedObjects []string `json:"orphaned_objects"`
+	Recent          int      `json:"recent"`
+	Trashed         int      `json:"trashed"`
+}
+
+type BackfillReport struct {
+	Checked int `json:"checked"`
+	Updated int `json:"updated"`
+	Failed  int `json:"failed"`
+}
+
+type PurgeReport struct {
+	Objects int   `json:"objects"`
+	Bytes   int64 `json:"bytes"`
+	Purged  int   `json:"purged"`
+}
@@ -3,33 +3,123 @@ package models
 import "time"
 
 type Media struct {
+	ID                 string            `json:"id"`
+	Title              string            `json:"title"`
+	Description        string            `json:"description"`
+	ContentType        string            `json:"content_type"`
+	StoragePath        string            `json:"storage_path"`
+	OwnerID            string            `json:"owner_id"`
+	StreamingOptimized bool              `json:"streaming_optimized"`
+	State              string            `json:"state"`
+	QuarantineReason   string            `json:"quarantine_reason,omitempty"`
+	Size               int64             `json:"size"`
+	Version            int               `json:"version"`
+	Revision           int64             `json:"revision"`
+	Tags               []string          `json:"tags"`
+	Metadata           map[string]string `json:"metadata"`
+	CreatedAt          time.Time         `json:"created_at"`
+	UpdatedAt          time.Time         `json:"updated_at"`
+	URL                string            `json:"url"`
+}
+
+const (
+	MediaStateActive      = "active"
+	MediaStateQuarantined = "quarantined"
+	// MediaStatePendingScan holds an upload the malware scanner could not
+	// check yet; it is scanned again until it is.
+	MediaStatePendingScan = "pending_scan"
+	// MediaStateScanFailed holds an upload the scanner refused to check,
+	// typically one larger than clamd's StreamMaxLength. Retrying would not
+	// change the outcome, so it is not rescanned.
+	MediaStateScanFailed = "scan_failed"
+)
+
+// ServeError reports why the file of m may not be handed out, or nil when
+// it may.
+func (m *Media) ServeError() error {
+	switch m.State {
+	case MediaStateQuarantined:
+		return ErrMediaQuarantined
+	case MediaStatePendingScan:
+		return ErrMediaPendingScan
+	case MediaStateScanFailed:
+		return ErrMediaScanFailed
+	}
+	return nil
+}
+
+type MediaVersion struct {
 	ID          string    `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	ContentType string    `json:"content_type"`
+	MediaID     string    `json:"media_id"`
+	Version     int       `json:"version"`
 	StoragePath string    `json:"storage_path"`
-	OwnerID     string    `json:"owner_id"`
+	Size        int64     `json:"size"`
+	Checksum    string    `json:"checksum"`
+	ContentType string    `json:"content_type"`
+	UploadedBy  string    `json:"uploaded_by"`
 	CreatedAt   time.Time `json:"created_at"`
-	URL         string    `json:"url"`
+}
+
+type ScanResult struct {
+	Clean     bool
+	Signature string
 }
 
 type CreateMediaRequest struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	ContentType string `json:"content_type"`
-	OwnerID     string `json:"owner_id"`
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	ContentType string            `json:"content_type"`
+	OwnerID     string            `json:"owner_id"`
+	Tags        []string          `json:"tags"`
+	Metadata    map[string]string `json:"metadata"`
 }
 
+// UpdateMediaRequest changes only the fields named in UpdateMask. A non-zero
+// Revision must match the stored one, otherwise the update is aborted.
 type UpdateMediaRequest struct {
-	ID          string `json:"id"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
+	ID          string   `json:"id"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	UpdateMask  []string `json:"update_mask"`
+	Revision    int64    `json:"revision"`
 }
 
+const (
+	MediaFieldTitle       = "title"
+	MediaFieldDescription = "description"
+)
+
 type GetMediaRequest struct {
 	ID string `json:"id"`
 }
 
+type SearchMediaRequest struct {
+	Query        string   `json:"query"`
+	OwnerID      string   `json:"owner_id"`
+	ContentTypes []string `json:"content_types"`
+	Limit        int      `json:"limit"`
+	Offset       int      `json:"offset"`
+}
+
+type MediaSearchHit struct {
+	Media *Media  `json:"media"`
+	Rank  float64 `json:"rank"`
+	// Snippet is HTML-escaped text with the matches wrapped in <mark>.
+	Snippet string `json:"snippet"`
+}
+
 type ListMediaRequest struct {
-	OwnerID string `json:"owner_id"`
+	OwnerID  string            `json:"owner_id"`
+	Tags     []string          `json:"tags"`
+	Metadata map[string]string `json:"metadata"`
+	Limit    int               `json:"limit"`
+}
+
+type TagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+type PatchMetadataRequest struct {
+	Set    map[string]string `json:"set"`
+	Remove []string          `json:"remove"`
 }
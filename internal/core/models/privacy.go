@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// PrivacyRequest is an export or erasure of everything an owner has. Erasure
+// records are kept after the owner's data is gone, as the audit trail.
+type PrivacyRequest struct {
+	ID          string           `json:"id"`
+	Kind        string           `json:"kind"`
+	OwnerID     string           `json:"owner_id"`
+	State       string           `json:"state"`
+	Format      string           `json:"format,omitempty"`
+	StoragePath string           `json:"-"`
+	Size        int64            `json:"size,omitempty"`
+	Summary     map[string]int64 `json:"summary"`
+	RequestedBy string           `json:"requested_by"`
+	Error       string           `json:"error,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+	FinishedAt  *time.Time       `json:"finished_at,omitempty"`
+	URL         string           `json:"url,omitempty"`
+}
+
+// OwnerExport is the data document at the root of an export archive.
+type OwnerExport struct {
+	OwnerID    string           `json:"owner_id"`
+	ExportedAt time.Time        `json:"exported_at"`
+	Media      []*ExportedMedia `json:"media"`
+	Albums     []*ExportedAlbum `json:"albums"`
+	Webhooks   []*Webhook       `json:"webhooks"`
+	ImportJobs []*ImportJob     `json:"import_jobs"`
+}
+
+type ExportedMedia struct {
+	*Media
+	Versions []*MediaVersion `json:"versions"`
+}
+
+type ExportedAlbum struct {
+	*Album
+	MediaIDs []string `json:"media_ids"`
+}
+
+const (
+	PrivacyExport = "export"
+	PrivacyErase  = "erase"
+)
+
+const (
+	PrivacyStatePending   = "pending"
+	PrivacyStateRunning   = "running"
+	PrivacyStateCompleted = "completed"
+	PrivacyStateFailed    = "failed"
+)
+
+const (
+	ExportFormatZip = "zip"
+	ExportFormatTar = "tar"
+)
@@ -3,15 +3,144 @@ package models
 import (
 	"github.com/co1seam/ember-backend-media/config"
 	"log/slog"
+	"time"
 )
 
 type Options struct {
-	Logger *slog.Logger
-	Config *config.Config
+	Logger   *slog.Logger
+	Config   *config.Config
+	Settings *config.Store
 }
 
-const MaxFileSize = 100 << 20
+// RequestBufferSize is how much of an HTTP request body is read into memory;
+// larger bodies are streamed. Upload sizes are checked against
+// MEDIA_MAX_FILE_SIZE by the handlers, so a reloaded limit applies at once.
+const RequestBufferSize = 4 << 20
+
+// DefaultGRPCMaxMsgSize bounds a single gRPC message. Files are sent in
+// chunks, so it does not limit the file size.
+const DefaultGRPCMaxMsgSize = 100 << 20
+
+const (
+	DefaultGRPCPort = "50052"
+	DefaultHTTPPort = "8080"
+)
+
+const DefaultShutdownTimeout = 30 * time.Second
+
+const (
+	DefaultGRPCKeepaliveTime    = 2 * time.Hour
+	DefaultGRPCKeepaliveTimeout = 20 * time.Second
+	DefaultGRPCKeepaliveMinTime = 5 * time.Minute
+	TLSReloadInterval           = 30 * time.Second
+)
+
+const SettingsReloadTimeout = 10 * time.Second
 
 const (
-	MediaTable = "media"
+	JobWorkers   = 2
+	JobQueueSize = 256
+)
+
+const DefaultQuarantinePrefix = "quarantine/"
+
+const (
+	RescanInterval  = time.Minute
+	RescanBatchSize = 50
+)
+
+const DefaultListLimit = 50
+
+const (
+	MaxBatchSize     = 100
+	BatchConcurrency = 8
+)
+
+const MaxAlbumMedia = 10000
+
+// MediaUpdateAttempts bounds how often a file change is reapplied after
+// losing a race with another update of the same media.
+const MediaUpdateAttempts = 5
+
+const (
+	MaxTagsPerMedia        = 50
+	MaxTagLength           = 64
+	MaxMetadataKeys        = 64
+	MaxMetadataKeyLength   = 128
+	MaxMetadataValueLength = 1024
+	MaxMetadataBytes       = 16 << 10
+)
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+const (
+	TrashPrefix    = "trash/"
+	TrashRetention = 7 * 24 * time.Hour
+	ReconcileGrace = time.Hour
+
+	MaintenanceBatchSize = 500
+)
+
+const ImportProgressEvery = 100
+
+const (
+	AuditRetention = 365 * 24 * time.Hour
+	MaxAuditLimit  = 500
+)
+
+const (
+	ExportPrefix   = "exports/"
+	ExportDataName = "data.json"
+)
+
+const (
+	DefaultEventsStream       = "media.events"
+	DefaultEventsPollInterval = 2 * time.Second
+	DefaultEventsBatchSize    = 100
+	OutboxRetention           = 7 * 24 * time.Hour
+	OutboxClaimLease          = 5 * time.Minute
+	OutboxMaxAttempts         = 10
+	OutboxBackoffBase         = 5 * time.Second
+	OutboxBackoffMax          = time.Hour
+)
+
+const (
+	WebhookPollInterval = 5 * time.Second
+	WebhookBatchSize    = 20
+	WebhookTimeout      = 10 * time.Second
+	WebhookMaxAttempts  = 8
+	WebhookBackoffBase  = 30 * time.Second
+	WebhookBackoffMax   = 6 * time.Hour
+	WebhookDisableAfter = 20
+	WebhookClaimLease   = 5 * time.Minute
+)
+
+const (
+	HealthCheckInterval = 10 * time.Second
+	HealthCheckTimeout  = 3 * time.Second
+)
+
+const (
+	MediaTable         = "media"
+	MediaVersionsTable = "media_versions"
+	TagsTable          = "tags"
+	MediaTagsTable     = "media_tags"
+	AlbumsTable        = "albums"
+	AlbumMediaTable    = "album_media"
+	OutboxTable        = "media_outbox"
+
+	WebhooksTable          = "webhooks"
+	WebhookDeliveriesTable = "webhook_deliveries"
+
+	SettingsTable = "runtime_settings"
+
+	ImportJobsTable  = "import_jobs"
+	ImportItemsTable = "import_items"
+
+	PrivacyRequestsTable = "privacy_requests"
+
+	AuditEventsTable = "audit_events"
 )
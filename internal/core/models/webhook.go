@@ -0,0 +1,51 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const EventWebhookPing = "webhook.ping"
+
+const (
+	DeliveryStatePending   = "pending"
+	DeliveryStateDelivered = "delivered"
+	DeliveryStateFailed    = "failed"
+	DeliveryStateSkipped   = "skipped"
+)
+
+type Webhook struct {
+	ID                  string    `json:"id"`
+	OwnerID             string    `json:"owner_id"`
+	URL                 string    `json:"url"`
+	Secret              string    `json:"secret,omitempty"`
+	Events              []string  `json:"events"`
+	Active              bool      `json:"active"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID             string          `json:"id"`
+	WebhookID      string          `json:"webhook_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	State          string          `json:"state"`
+	Attempts       int             `json:"attempts"`
+	LastStatusCode int             `json:"last_status_code"`
+	LastError      string          `json:"last_error,omitempty"`
+	LastDuration   time.Duration   `json:"last_duration"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	URL            string          `json:"-"`
+	Secret         string          `json:"-"`
+}
+
+type RegisterWebhookRequest struct {
+	OwnerID string   `json:"owner_id"`
+	URL     string   `json:"url"`
+	Secret  string   `json:"secret"`
+	Events  []string `json:"events"`
+}
@@ -0,0 +1,334 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+const maxMoovSize = 64 << 20
+
+var (
+	ErrInvalidFile      = errors.New("mp4: invalid file structure")
+	ErrMoovNotFound     = errors.New("mp4: moov atom not found")
+	ErrCompressedMoov   = errors.New("mp4: compressed moov atom is not supported")
+	ErrMoovTooLarge     = errors.New("mp4: moov atom is too large")
+	ErrAlreadyFaststart = errors.New("mp4: file is already faststart")
+)
+
+type atom struct {
+	typ    string
+	offset int64
+	size   int64
+}
+
+type box struct {
+	typ      string
+	payload  []byte
+	children []*box
+}
+
+var containers = map[string]bool{
+	"moov": true,
+	"trak": true,
+	"mdia": true,
+	"minf": true,
+	"stbl": true,
+}
+
+// IsFaststart reports whether the moov atom is placed before the first mdat atom.
+func IsFaststart(r io.ReaderAt, size int64) (bool, error) {
+	atoms, err := readTopLevel(r, size)
+	if err != nil {
+		return false, err
+	}
+
+	moov, mdat := indexOf(atoms, "moov"), indexOf(atoms, "mdat")
+	if moov < 0 {
+		return false, ErrMoovNotFound
+	}
+
+	return mdat < 0 || moov < mdat, nil
+}
+
+// Faststart writes a copy of the file with the moov atom relocated in front of
+// the first mdat atom and chunk offsets (stco/co64) rewritten accordingly.
+// ErrAlreadyFaststart is returned without writing anything if no remux is needed.
+func Faststart(r io.ReaderAt, size int64, w io.Writer) error {
+	atoms, err := readTopLevel(r, size)
+	if err != nil {
+		return err
+	}
+
+	moovIdx, mdatIdx := indexOf(atoms, "moov"), indexOf(atoms, "mdat")
+	if moovIdx < 0 {
+		return ErrMoovNotFound
+	}
+	if mdatIdx < 0 || moovIdx < mdatIdx {
+		return ErrAlreadyFaststart
+	}
+
+	moovAtom := atoms[moovIdx]
+	if moovAtom.size > maxMoovSize {
+		return ErrMoovTooLarge
+	}
+
+	raw := make([]byte, moovAtom.size)
+	if _, err := r.ReadAt(raw, moovAtom.offset); err != nil {
+		return fmt.Errorf("mp4: read moov: %w", err)
+	}
+
+	moov, err := parseBox(clone(raw))
+	if err != nil {
+		return err
+	}
+
+	if findChild(moov, "cmov") != nil {
+		return ErrCompressedMoov
+	}
+
+	insertAt := atoms[mdatIdx].offset
+	moveEnd := moovAtom.offset
+	moovEnd := moovAtom.offset + moovAtom.size
+
+	// Upgrading stco to co64 grows the moov atom, which changes the shift,
+	// so iterate until the layout is stable.
+	var encoded []byte
+	for i := 0; i < 4; i++ {
+		delta := int64(len(serialize(moov)))
+		// Data between the first mdat and the old moov moves down by the
+		// whole moov; data after the old moov only by how much it grew.
+		shift := func(v int64) int64 {
+			switch {
+			case v >= insertAt && v < moveEnd:
+				return v + delta
+			case v >= moovEnd:
+				return v + delta - moovAtom.size
+			}
+			return v
+		}
+		upgraded, err := shiftOffsets(moov, shift)
+		if err != nil {
+			return err
+		}
+		if !upgraded {
+			encoded = serialize(moov)
+			break
+		}
+		moov, err = parseBox(clone(raw))
+		if err != nil {
+			return err
+		}
+		upgradeAll(moov)
+	}
+	if encoded == nil {
+		return ErrInvalidFile
+	}
+
+	for i, a := range atoms {
+		if i == mdatIdx {
+			if _, err := w.Write(encoded); err != nil {
+				return err
+			}
+		}
+		if i == moovIdx {
+			continue
+		}
+		if _, err := io.Copy(w, io.NewSectionReader(r, a.offset, a.size)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readTopLevel(r io.ReaderAt, size int64) ([]atom, error) {
+	var atoms []atom
+	header := make([]byte, 16)
+
+	for offset := int64(0); offset < size; {
+		if size-offset < 8 {
+			return nil, ErrInvalidFile
+		}
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return nil, fmt.Errorf("mp4: read atom header: %w", err)
+		}
+
+		atomSize := int64(binary.BigEndian.Uint32(header[:4]))
+		typ := string(header[4:8])
+
+		switch atomSize {
+		case 0:
+			atomSize = size - offset
+		case 1:
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return nil, fmt.Errorf("mp4: read atom header: %w", err)
+			}
+			atomSize = int64(binary.BigEndian.Uint64(header[8:16]))
+		}
+
+		if atomSize < 8 || offset+atomSize > size {
+			return nil, ErrInvalidFile
+		}
+
+		atoms = append(atoms, atom{typ: typ, offset: offset, size: atomSize})
+		offset += atomSize
+	}
+
+	return atoms, nil
+}
+
+func parseBox(data []byte) (*box, error) {
+	if len(data) < 8 {
+		return nil, ErrInvalidFile
+	}
+
+	size := int64(binary.BigEndian.Uint32(data[:4]))
+	headerLen := int64(8)
+	if size == 1 {
+		if len(data) < 16 {
+			return nil, ErrInvalidFile
+		}
+		size = int64(binary.BigEndian.Uint64(data[8:16]))
+		headerLen = 16
+	}
+	if size == 0 {
+		size = int64(len(data))
+	}
+	if size < headerLen || size > int64(len(data)) {
+		return nil, ErrInvalidFile
+	}
+
+	b := &box{typ: string(data[4:8])}
+	body := data[headerLen:size]
+
+	if !containers[b.typ] {
+		b.payload = body
+		return b, nil
+	}
+
+	for len(body) > 0 {
+		child, err := parseBox(body)
+		if err != nil {
+			return nil, err
+		}
+		b.children = append(b.children, child)
+
+		childSize := int64(binary.BigEndian.Uint32(body[:4]))
+		if childSize == 1 {
+			childSize = int64(binary.BigEndian.Uint64(body[8:16]))
+		}
+		if childSize == 0 {
+			childSize = int64(len(body))
+		}
+		body = body[childSize:]
+	}
+
+	return b, nil
+}
+
+func serialize(b *box) []byte {
+	var body []byte
+	if b.children == nil {
+		body = b.payload
+	} else {
+		for _, child := range b.children {
+			body = append(body, serialize(child)...)
+		}
+	}
+
+	out := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(out[:4], uint32(8+len(body)))
+	copy(out[4:8], b.typ)
+	return append(out, body...)
+}
+
+// shiftOffsets rewrites every chunk offset with shift. It reports true if a
+// 32-bit stco table would overflow and needs to be upgraded to co64 first.
+func shiftOffsets(b *box, shift func(int64) int64) (bool, error) {
+	switch b.typ {
+	case "stco":
+		if len(b.payload) < 8 {
+			return false, ErrInvalidFile
+		}
+		count := int64(binary.BigEndian.Uint32(b.payload[4:8]))
+		if int64(len(b.payload)) < 8+count*4 {
+			return false, ErrInvalidFile
+		}
+		for i := int64(0); i < count; i++ {
+			pos := 8 + i*4
+			v := shift(int64(binary.BigEndian.Uint32(b.payload[pos:])))
+			if v > math.MaxUint32 {
+				return true, nil
+			}
+			binary.BigEndian.PutUint32(b.payload[pos:], uint32(v))
+		}
+	case "co64":
+		if len(b.payload) < 8 {
+			return false, ErrInvalidFile
+		}
+		count := int64(binary.BigEndian.Uint32(b.payload[4:8]))
+		if int64(len(b.payload)) < 8+count*8 {
+			return false, ErrInvalidFile
+		}
+		for i := int64(0); i < count; i++ {
+			pos := 8 + i*8
+			v := shift(int64(binary.BigEndian.Uint64(b.payload[pos:])))
+			binary.BigEndian.PutUint64(b.payload[pos:], uint64(v))
+		}
+	}
+
+	for _, child := range b.children {
+		upgrade, err := shiftOffsets(child, shift)
+		if err != nil || upgrade {
+			return upgrade, err
+		}
+	}
+
+	return false, nil
+}
+
+func upgradeAll(b *box) {
+	if b.typ == "stco" && len(b.payload) >= 8 {
+		count := binary.BigEndian.Uint32(b.payload[4:8])
+		payload := make([]byte, 8+int(count)*8)
+		copy(payload[:8], b.payload[:8])
+		for i := 0; i < int(count); i++ {
+			v := binary.BigEndian.Uint32(b.payload[8+i*4:])
+			binary.BigEndian.PutUint64(payload[8+i*8:], uint64(v))
+		}
+		b.typ = "co64"
+		b.payload = payload
+	}
+
+	for _, child := range b.children {
+		upgradeAll(child)
+	}
+}
+
+func findChild(b *box, typ string) *box {
+	for _, child := range b.children {
+		if child.typ == typ {
+			return child
+		}
+		if found := findChild(child, typ); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func clone(data []byte) []byte {
+	return append([]byte(nil), data...)
+}
+
+func indexOf(atoms []atom, typ string) int {
+	for i, a := range atoms {
+		if a.typ == typ {
+			return i
+		}
+	}
+	return -1
+}
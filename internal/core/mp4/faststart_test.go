@@ -0,0 +1,203 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func atomOf(typ string, body ...[]byte) []byte {
+	payload := bytes.Join(body, nil)
+	out := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(out, uint32(8+len(payload)))
+	copy(out[4:], typ)
+	return append(out, payload...)
+}
+
+// chunkTable builds the payload of a stco (4-byte) or co64 (8-byte) table.
+func chunkTable(width int, offsets ...int64) []byte {
+	payload := make([]byte, 8+width*len(offsets))
+	binary.BigEndian.PutUint32(payload[4:], uint32(len(offsets)))
+	for i, offset := range offsets {
+		if width == 4 {
+			binary.BigEndian.PutUint32(payload[8+i*4:], uint32(offset))
+		} else {
+			binary.BigEndian.PutUint64(payload[8+i*8:], uint64(offset))
+		}
+	}
+	return payload
+}
+
+func moovWith(table string, width int, offsets ...int64) []byte {
+	stbl := atomOf("stbl", atomOf(table, chunkTable(width, offsets...)))
+	return atomOf("moov", atomOf("mvhd", make([]byte, 12)), atomOf("trak", atomOf("mdia", atomOf("minf", stbl))))
+}
+
+var (
+	ftyp   = atomOf("ftyp", []byte("isom"), make([]byte, 4))
+	chunks = []string{"first chunk", "second chunk"}
+	mdat   = atomOf("mdat", []byte(chunks[0]), []byte(chunks[1]))
+)
+
+// trailingMoov lays a file out as cameras write it: ftyp, mdat, moov.
+func trailingMoov(table string, width int) []byte {
+	first := int64(len(ftyp) + 8)
+	second := first + int64(len(chunks[0]))
+	return bytes.Join([][]byte{ftyp, mdat, moovWith(table, width, first, second)}, nil)
+}
+
+// chunkOffsets reads the chunk table of a remuxed file.
+func chunkOffsets(t *testing.T, file []byte) (string, []int64) {
+	t.Helper()
+	atoms, err := readTopLevel(bytes.NewReader(file), int64(len(file)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	moovAtom := atoms[indexOf(atoms, "moov")]
+	moov, err := parseBox(file[moovAtom.offset : moovAtom.offset+moovAtom.size])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	table := findChild(moov, "stco")
+	width := 4
+	if table == nil {
+		table, width = findChild(moov, "co64"), 8
+	}
+	count := int(binary.BigEndian.Uint32(table.payload[4:]))
+	offsets := make([]int64, count)
+	for i := range offsets {
+		if width == 4 {
+			offsets[i] = int64(binary.BigEndian.Uint32(table.payload[8+i*4:]))
+		} else {
+			offsets[i] = int64(binary.BigEndian.Uint64(table.payload[8+i*8:]))
+		}
+	}
+	return table.typ, offsets
+}
+
+func TestFaststartMovesMoovAndShiftsOffsets(t *testing.T) {
+	for _, tc := range []struct {
+		table string
+		width int
+	}{{"stco", 4}, {"co64", 8}} {
+		input := trailingMoov(tc.table, tc.width)
+
+		var out bytes.Buffer
+		if err := Faststart(bytes.NewReader(input), int64(len(input)), &out); err != nil {
+			t.Fatalf("%s: Faststart() error = %v", tc.table, err)
+		}
+		remuxed := out.Bytes()
+
+		if len(remuxed) != len(input) {
+			t.Errorf("%s: remuxed %d bytes, want %d", tc.table, len(remuxed), len(input))
+		}
+		if ok, err := IsFaststart(bytes.NewReader(remuxed), int64(len(remuxed))); err != nil || !ok {
+			t.Errorf("%s: IsFaststart(remuxed) = %v, %v; want true", tc.table, ok, err)
+		}
+		if !bytes.HasPrefix(remuxed, ftyp) {
+			t.Errorf("%s: ftyp no longer leads the file", tc.table)
+		}
+
+		typ, offsets := chunkOffsets(t, remuxed)
+		if typ != tc.table || len(offsets) != len(chunks) {
+			t.Fatalf("%s: chunk table %s with %d entries", tc.table, typ, len(offsets))
+		}
+		for i, chunk := range chunks {
+			got := string(remuxed[offsets[i] : offsets[i]+int64(len(chunk))])
+			if got != chunk {
+				t.Errorf("%s: chunk %d offset points at %q, want %q", tc.table, i, got, chunk)
+			}
+		}
+	}
+}
+
+// largeHeader re-encodes an atom with a 64-bit size header, which the remux
+// writes back as a plain 8-byte header.
+func largeHeader(atom []byte) []byte {
+	out := make([]byte, 16, 8+len(atom))
+	binary.BigEndian.PutUint32(out, 1)
+	copy(out[4:8], atom[4:8])
+	binary.BigEndian.PutUint64(out[8:], uint64(len(atom)+8))
+	return append(out, atom[8:]...)
+}
+
+func TestFaststartShiftsOffsetsAfterMoov(t *testing.T) {
+	// Some muxers keep writing media after the moov: ftyp, mdat, moov, mdat.
+	tail := atomOf("mdat", []byte("tail chunk"))
+	want := append(append([]string(nil), chunks...), "tail chunk")
+
+	for name, tc := range map[string]struct {
+		table  string
+		width  int
+		header func([]byte) []byte
+	}{
+		"stco":        {"stco", 4, func(b []byte) []byte { return b }},
+		"co64":        {"co64", 8, func(b []byte) []byte { return b }},
+		"64-bit moov": {"stco", 4, largeHeader},
+		"64-bit co64": {"co64", 8, largeHeader},
+	} {
+		first := int64(len(ftyp) + 8)
+		second := first + int64(len(chunks[0]))
+		moovLen := int64(len(tc.header(moovWith(tc.table, tc.width, 0, 0, 0))))
+		third := int64(len(ftyp)+len(mdat)) + moovLen + 8
+		moov := tc.header(moovWith(tc.table, tc.width, first, second, third))
+		input := bytes.Join([][]byte{ftyp, mdat, moov, tail}, nil)
+
+		var out bytes.Buffer
+		if err := Faststart(bytes.NewReader(input), int64(len(input)), &out); err != nil {
+			t.Fatalf("%s: Faststart() error = %v", name, err)
+		}
+		remuxed := out.Bytes()
+
+		_, offsets := chunkOffsets(t, remuxed)
+		if len(offsets) != len(want) {
+			t.Fatalf("%s: chunk table has %d entries, want %d", name, len(offsets), len(want))
+		}
+		for i, chunk := range want {
+			got := string(remuxed[offsets[i] : offsets[i]+int64(len(chunk))])
+			if got != chunk {
+				t.Errorf("%s: chunk %d offset points at %q, want %q", name, i, got, chunk)
+			}
+		}
+	}
+}
+
+func TestFaststartLeavesOptimizedFilesAlone(t *testing.T) {
+	input := trailingMoov("stco", 4)
+	var remuxed bytes.Buffer
+	if err := Faststart(bytes.NewReader(input), int64(len(input)), &remuxed); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	err := Faststart(bytes.NewReader(remuxed.Bytes()), int64(remuxed.Len()), &out)
+	if !errors.Is(err, ErrAlreadyFaststart) || out.Len() != 0 {
+		t.Errorf("Faststart(faststart file) = %v after writing %d bytes, want ErrAlreadyFaststart and nothing", err, out.Len())
+	}
+}
+
+func TestFaststartRejectsUnsupportedFiles(t *testing.T) {
+	valid := trailingMoov("stco", 4)
+	compressed := bytes.Join([][]byte{ftyp, mdat, atomOf("moov", atomOf("cmov", make([]byte, 8)))}, nil)
+
+	for name, tc := range map[string]struct {
+		input []byte
+		want  error
+	}{
+		"no moov":    {bytes.Join([][]byte{ftyp, mdat}, nil), ErrMoovNotFound},
+		"compressed": {compressed, ErrCompressedMoov},
+		"truncated":  {valid[:len(valid)-3], ErrInvalidFile},
+		"garbage":    {[]byte("not an mp4 file at all"), ErrInvalidFile},
+	} {
+		var out bytes.Buffer
+		err := Faststart(bytes.NewReader(tc.input), int64(len(tc.input)), &out)
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: Faststart() error = %v, want %v", name, err, tc.want)
+		}
+		if out.Len() != 0 {
+			t.Errorf("%s: wrote %d bytes of a file it rejected", name, out.Len())
+		}
+	}
+}
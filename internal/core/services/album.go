@@ -0,0 +1,352 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/co1seam/ember-backend-media/internal/core/errs"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Albums struct {
+	repo  ports.IAlbumRepo
+	media ports.IMediaRepo
+	tx    ports.ITransactor
+	opts  *models.Options
+}
+
+func NewAlbums(repo ports.IAlbumRepo, media ports.IMediaRepo, tx ports.ITransactor, opts *models.Options) ports.IAlbumService {
+	return &Albums{
+		repo:  repo,
+		media: media,
+		tx:    tx,
+		opts:  opts,
+	}
+}
+
+func (a *Albums) CreateAlbum(ctx context.Context, req *models.CreateAlbumRequest) (_ *models.Album, err error) {
+	ctx, span := startSpan(ctx, "services.Albums.CreateAlbum", attribute.String("owner.id", req.OwnerID))
+	defer finish(span, &err)
+
+	if req.OwnerID == "" {
+		return nil, errs.InvalidArgument("owner id is required")
+	}
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		return nil, errs.InvalidArgument("title is required")
+	}
+
+	now := time.Now()
+	album := &models.Album{
+		ID:          uuid.New().String(),
+		OwnerID:     req.OwnerID,
+		Title:       title,
+		Description: req.Description,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if err := a.repo.Create(ctx, album); err != nil {
+		return nil, err
+	}
+
+	return album, nil
+}
+
+func (a *Albums) GetAlbum(ctx context.Context, ownerID, id string) (_ *models.Album, err error) {
+	ctx, span := startSpan(ctx, "services.Albums.GetAlbum", attribute.String("album.id", id))
+	defer finish(span, &err)
+
+	return a.owned(ctx, ownerID, id)
+}
+
+// UpdateAlbum changes the fields named in the update mask. A new cover must
+// already be in the album; an empty cover clears it.
+func (a *Albums) UpdateAlbum(ctx context.Context, req *models.UpdateAlbumRequest) (_ *models.Album, err error) {
+	ctx, span := startSpan(ctx, "services.Albums.UpdateAlbum", attribute.String("album.id", req.ID))
+	defer finish(span, &err)
+
+	mask, err := updateMask(req.UpdateMask, models.AlbumFieldTitle, models.AlbumFieldDescription, models.AlbumFieldCover)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(mask) == 0 {
+		return a.owned(ctx, req.OwnerID, req.ID)
+	}
+
+	title := strings.TrimSpace(req.Title)
+	if contains(mask, models.AlbumFieldTitle) && title == "" {
+		return nil, errs.InvalidArgument("title is required")
+	}
+
+	return a.modify(ctx, req.OwnerID, req.ID, func(ctx context.Context, album *models.Album) error {
+		for _, field := range mask {
+			switch field {
+			case models.AlbumFieldTitle:
+				album.Title = title
+			case models.AlbumFieldDescription:
+				album.Description = req.Description
+			case models.AlbumFieldCover:
+				if req.CoverMediaID != "" {
+					ids, err := a.repo.MediaIDs(ctx, album.ID)
+					if err != nil {
+						return err
+					}
+					if !contains(ids, req.CoverMediaID) {
+						return errs.InvalidArgument("cover media must belong to the album")
+					}
+				}
+				album.CoverMediaID = req.CoverMediaID
+			}
+		}
+		return a.repo.Update(ctx, album)
+	})
+}
+
+func (a *Albums) DeleteAlbum(ctx context.Context, ownerID, id string) (err error) {
+	ctx, span := startSpan(ctx, "services.Albums.DeleteAlbum", attribute.String("album.id", id))
+	defer finish(span, &err)
+
+	if _, err := a.owned(ctx, ownerID, id); err != nil {
+		return err
+	}
+
+	return a.repo.Delete(ctx, id)
+}
+
+func (a *Albums) ListAlbums(ctx context.Context, ownerID string, limit int) (_ []*models.Album, err error) {
+	ctx, span := startSpan(ctx, "services.Albums.ListAlbums", attribute.String("owner.id", ownerID))
+	defer finish(span, &err)
+
+	if ownerID == "" {
+		return nil, errs.InvalidArgument("owner id is required")
+	}
+
+	if limit <= 0 {
+		limit = models.DefaultListLimit
+	}
+
+	return a.repo.ListByOwner(ctx, ownerID, limit)
+}
+
+// AddMedia appends media to the end of the album. Every item must belong to
+// the album owner; items already in the album are left where they are.
+func (a *Albums) AddMedia(ctx context.Context, ownerID, id string, mediaIDs []string) (_ *models.Album, err error) {
+	ctx, span := startSpan(ctx, "services.Albums.AddMedia", attribute.String("album.id", id))
+	defer finish(span, &err)
+
+	mediaIDs, err = normalizeIDs(mediaIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.modify(ctx, ownerID, id, func(ctx context.Context, album *models.Album) error {
+		for _, mediaID := range mediaIDs {
+			media, err := a.media.GetByID(ctx, mediaID)
+			if errors.Is(err, sql.ErrNoRows) {
+				return models.ErrMediaNotFound
+			}
+			if err != nil {
+				return err
+			}
+			if media.OwnerID != album.OwnerID {
+				return models.ErrPermissionDenied
+			}
+		}
+
+		if err := a.repo.Update(ctx, album); err != nil {
+			return err
+		}
+		if err := a.repo.AddMedia(ctx, album.ID, mediaIDs); err != nil {
+			return err
+		}
+
+		ids, err := a.repo.MediaIDs(ctx, album.ID)
+		if err != nil {
+			return err
+		}
+		if len(ids) > models.MaxAlbumMedia {
+			return errs.InvalidArgument(fmt.Sprintf("album can hold at most %d media", models.MaxAlbumMedia))
+		}
+		return nil
+	})
+}
+
+// RemoveMedia drops media from the album, clearing the cover if it was one
+// of them.
+func (a *Albums) RemoveMedia(ctx context.Context, ownerID, id string, mediaIDs []string) (_ *models.Album, err error) {
+	ctx, span := startSpan(ctx, "services.Albums.RemoveMedia", attribute.String("album.id", id))
+	defer finish(span, &err)
+
+	mediaIDs, err = normalizeIDs(mediaIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.modify(ctx, ownerID, id, func(ctx context.Context, album *models.Album) error {
+		if contains(mediaIDs, album.CoverMediaID) {
+			album.CoverMediaID = ""
+		}
+		if err := a.repo.Update(ctx, album); err != nil {
+			return err
+		}
+		return a.repo.RemoveMedia(ctx, album.ID, mediaIDs)
+	})
+}
+
+// ReorderMedia sets the album order. mediaIDs must list every member exactly
+// once.
+func (a *Albums) ReorderMedia(ctx context.Context, ownerID, id string, mediaIDs []string) (_ *models.Album, err error) {
+	ctx, span := startSpan(ctx, "services.Albums.ReorderMedia", attribute.String("album.id", id))
+	defer finish(span, &err)
+
+	return a.modify(ctx, ownerID, id, func(ctx context.Context, album *models.Album) error {
+		if err := a.repo.Update(ctx, album); err != nil {
+			return err
+		}
+
+		current, err := a.repo.MediaIDs(ctx, album.ID)
+		if err != nil {
+			return err
+		}
+		if !samePermutation(current, mediaIDs) {
+			return errs.InvalidArgument("order must list every media in the album exactly once")
+		}
+
+		return a.repo.Reorder(ctx, album.ID, mediaIDs)
+	})
+}
+
+func (a *Albums) ListAlbumMedia(ctx context.Context, ownerID, id, pageToken string, limit int) (_ *models.AlbumPage, err error) {
+	ctx, span := startSpan(ctx, "services.Albums.ListAlbumMedia", attribute.String("album.id", id))
+	defer finish(span, &err)
+
+	after := 0
+	if pageToken != "" {
+		after, err = strconv.Atoi(pageToken)
+		if err != nil || after < 0 {
+			return nil, errs.InvalidArgument("invalid page token")
+		}
+	}
+
+	album, err := a.owned(ctx, ownerID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = models.DefaultListLimit
+	}
+	mediaList, last, err := a.repo.ListMedia(ctx, album.ID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.AlbumPage{Media: mediaList}
+	if page.Media == nil {
+		page.Media = []*models.Media{}
+	}
+	if len(mediaList) == limit {
+		page.NextPageToken = strconv.Itoa(last)
+	}
+
+	return page, nil
+}
+
+// modify runs fn on the owned album inside a transaction, bumping updated_at,
+// and returns the album as stored afterwards.
+func (a *Albums) modify(ctx context.Context, ownerID, id string, fn func(ctx context.Context, album *models.Album) error) (*models.Album, error) {
+	err := a.tx.WithinTx(ctx, func(ctx context.Context) error {
+		album, err := a.owned(ctx, ownerID, id)
+		if err != nil {
+			return err
+		}
+
+		album.UpdatedAt = time.Now()
+		return fn(ctx, album)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return a.owned(ctx, ownerID, id)
+}
+
+func (a *Albums) owned(ctx context.Context, ownerID, id string) (*models.Album, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, models.ErrAlbumNotFound
+	}
+
+	album, err := a.repo.GetByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ErrAlbumNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if album.OwnerID != ownerID {
+		return nil, models.ErrAlbumNotFound
+	}
+
+	return album, nil
+}
+
+func normalizeIDs(ids []string) ([]string, error) {
+	seen := make(map[string]bool, len(ids))
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if _, err := uuid.Parse(id); err != nil {
+			return nil, errs.InvalidArgument(fmt.Sprintf("invalid media id %q", id))
+		}
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+
+	if len(out) == 0 {
+		return nil, errs.InvalidArgument("at least one media id is required")
+	}
+	if len(out) > models.MaxAlbumMedia {
+		return nil, errs.InvalidArgument(fmt.Sprintf("album can hold at most %d media", models.MaxAlbumMedia))
+	}
+
+	return out, nil
+}
+
+func samePermutation(current, order []string) bool {
+	if len(current) != len(order) {
+		return false
+	}
+
+	members := make(map[string]bool, len(current))
+	for _, id := range current {
+		members[id] = true
+	}
+	for _, id := range order {
+		if !members[id] {
+			return false
+		}
+		delete(members, id)
+	}
+
+	return true
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,251 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/co1seam/ember-backend-media/internal/core/errs"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"slices"
+	"testing"
+)
+
+const (
+	albumID = "a0000000-0000-0000-0000-000000000001"
+	photo1  = "10000000-0000-0000-0000-000000000001"
+	photo2  = "10000000-0000-0000-0000-000000000002"
+	photo3  = "10000000-0000-0000-0000-000000000003"
+	foreign = "20000000-0000-0000-0000-000000000001"
+)
+
+// fakeAlbumRepo keeps members in album order; a member's position is its
+// index plus one.
+type fakeAlbumRepo struct {
+	ports.IAlbumRepo
+	albums  map[string]models.Album
+	members map[string][]string
+}
+
+func (r *fakeAlbumRepo) Create(ctx context.Context, album *models.Album) error {
+	r.albums[album.ID] = *album
+	return nil
+}
+
+func (r *fakeAlbumRepo) GetByID(ctx context.Context, id string) (*models.Album, error) {
+	album, ok := r.albums[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	album.MediaCount = len(r.members[id])
+	return &album, nil
+}
+
+func (r *fakeAlbumRepo) Update(ctx context.Context, album *models.Album) error {
+	r.albums[album.ID] = *album
+	return nil
+}
+
+func (r *fakeAlbumRepo) AddMedia(ctx context.Context, albumID string, mediaIDs []string) error {
+	for _, id := range mediaIDs {
+		if !slices.Contains(r.members[albumID], id) {
+			r.members[albumID] = append(r.members[albumID], id)
+		}
+	}
+	return nil
+}
+
+func (r *fakeAlbumRepo) RemoveMedia(ctx context.Context, albumID string, mediaIDs []string) error {
+	r.members[albumID] = slices.DeleteFunc(r.members[albumID], func(id string) bool { return slices.Contains(mediaIDs, id) })
+	return nil
+}
+
+func (r *fakeAlbumRepo) Reorder(ctx context.Context, albumID string, mediaIDs []string) error {
+	r.members[albumID] = slices.Clone(mediaIDs)
+	return nil
+}
+
+func (r *fakeAlbumRepo) MediaIDs(ctx context.Context, albumID string) ([]string, error) {
+	return slices.Clone(r.members[albumID]), nil
+}
+
+func (r *fakeAlbumRepo) ListMedia(ctx context.Context, albumID string, afterPosition, limit int) ([]*models.Media, int, error) {
+	var page []*models.Media
+	last := afterPosition
+	for i, id := range r.members[albumID] {
+		if position := i + 1; position > afterPosition && len(page) < limit {
+			page = append(page, &models.Media{ID: id})
+			last = position
+		}
+	}
+	return page, last, nil
+}
+
+func newTestAlbums(members ...string) (*Albums, *fakeAlbumRepo) {
+	media := &fakeMediaRepo{rows: map[string]models.Media{
+		photo1:  {ID: photo1, OwnerID: "alice"},
+		photo2:  {ID: photo2, OwnerID: "alice"},
+		photo3:  {ID: photo3, OwnerID: "alice"},
+		foreign: {ID: foreign, OwnerID: "bob"},
+	}}
+	repo := &fakeAlbumRepo{
+		albums:  map[string]models.Album{albumID: {ID: albumID, OwnerID: "alice", Title: "Trip"}},
+		members: map[string][]string{albumID: members},
+	}
+	return NewAlbums(repo, media, fakeTx{}, testOptions()).(*Albums), repo
+}
+
+func TestCreateAlbum(t *testing.T) {
+	albums, repo := newTestAlbums()
+
+	album, err := albums.CreateAlbum(context.Background(), &models.CreateAlbumRequest{OwnerID: "alice", Title: "  Summer  "})
+	if err != nil {
+		t.Fatalf("CreateAlbum() error = %v", err)
+	}
+	if album.Title != "Summer" || repo.albums[album.ID].OwnerID != "alice" {
+		t.Errorf("album = %+v, want it stored with a trimmed title", album)
+	}
+
+	for _, req := range []*models.CreateAlbumRequest{{Title: "x"}, {OwnerID: "alice", Title: " "}} {
+		if _, err := albums.CreateAlbum(context.Background(), req); errs.KindOf(err) != errs.KindInvalidArgument {
+			t.Errorf("CreateAlbum(%+v) error = %v, want InvalidArgument", req, err)
+		}
+	}
+}
+
+func TestAlbumAddMedia(t *testing.T) {
+	albums, repo := newTestAlbums(photo1)
+	ctx := context.Background()
+
+	album, err := albums.AddMedia(ctx, "alice", albumID, []string{photo2, photo1, photo2, photo3})
+	if err != nil {
+		t.Fatalf("AddMedia() error = %v", err)
+	}
+	if want := []string{photo1, photo2, photo3}; !slices.Equal(repo.members[albumID], want) || album.MediaCount != 3 {
+		t.Errorf("members = %v, count = %d, want %v", repo.members[albumID], album.MediaCount, want)
+	}
+
+	for name, tc := range map[string]struct {
+		owner string
+		ids   []string
+		want  error
+	}{
+		"other owner's media": {"alice", []string{foreign}, models.ErrPermissionDenied},
+		"missing media":       {"alice", []string{"30000000-0000-0000-0000-000000000001"}, models.ErrMediaNotFound},
+		"other owner's album": {"bob", []string{foreign}, models.ErrAlbumNotFound},
+	} {
+		if _, err := albums.AddMedia(ctx, tc.owner, albumID, tc.ids); !errors.Is(err, tc.want) {
+			t.Errorf("%s: AddMedia() error = %v, want %v", name, err, tc.want)
+		}
+	}
+	if _, err := albums.AddMedia(ctx, "alice", albumID, []string{"not-a-uuid"}); errs.KindOf(err) != errs.KindInvalidArgument {
+		t.Errorf("AddMedia(bad id) error = %v, want InvalidArgument", err)
+	}
+	if len(repo.members[albumID]) != 3 {
+		t.Errorf("members = %v after rejected adds", repo.members[albumID])
+	}
+}
+
+func TestUpdateAlbumChangesOnlyMaskedFields(t *testing.T) {
+	albums, repo := newTestAlbums(photo1)
+	stored := repo.albums[albumID]
+	stored.Description, stored.CoverMediaID = "beach", photo1
+	repo.albums[albumID] = stored
+	ctx := context.Background()
+
+	album, err := albums.UpdateAlbum(ctx, &models.UpdateAlbumRequest{ID: albumID, OwnerID: "alice", Title: "Holiday", UpdateMask: []string{models.AlbumFieldTitle}})
+	if err != nil {
+		t.Fatalf("UpdateAlbum() error = %v", err)
+	}
+	if album.Title != "Holiday" || album.Description != "beach" || album.CoverMediaID != photo1 {
+		t.Errorf("album = %+v, want only the title changed", album)
+	}
+
+	for name, req := range map[string]*models.UpdateAlbumRequest{
+		"blank title":  {ID: albumID, OwnerID: "alice", UpdateMask: []string{models.AlbumFieldTitle}},
+		"unknown path": {ID: albumID, OwnerID: "alice", UpdateMask: []string{"owner_id"}},
+	} {
+		if _, err := albums.UpdateAlbum(ctx, req); errs.KindOf(err) != errs.KindInvalidArgument {
+			t.Errorf("%s: UpdateAlbum() error = %v, want InvalidArgument", name, err)
+		}
+	}
+}
+
+func TestAlbumCover(t *testing.T) {
+	albums, repo := newTestAlbums(photo1, photo2)
+	ctx := context.Background()
+
+	_, err := albums.UpdateAlbum(ctx, &models.UpdateAlbumRequest{ID: albumID, OwnerID: "alice", CoverMediaID: photo3, UpdateMask: []string{models.AlbumFieldCover}})
+	if errs.KindOf(err) != errs.KindInvalidArgument {
+		t.Errorf("UpdateAlbum(cover outside the album) error = %v, want InvalidArgument", err)
+	}
+
+	album, err := albums.UpdateAlbum(ctx, &models.UpdateAlbumRequest{ID: albumID, OwnerID: "alice", CoverMediaID: photo2, UpdateMask: []string{models.AlbumFieldCover}})
+	if err != nil || album.CoverMediaID != photo2 {
+		t.Fatalf("UpdateAlbum() = %+v, %v, want the cover set", album, err)
+	}
+
+	album, err = albums.RemoveMedia(ctx, "alice", albumID, []string{photo2})
+	if err != nil {
+		t.Fatalf("RemoveMedia() error = %v", err)
+	}
+	if album.CoverMediaID != "" || !slices.Equal(repo.members[albumID], []string{photo1}) {
+		t.Errorf("album = %+v, members = %v, want the cover cleared with its media", album, repo.members[albumID])
+	}
+}
+
+func TestAlbumReorderMedia(t *testing.T) {
+	albums, repo := newTestAlbums(photo1, photo2, photo3)
+	ctx := context.Background()
+
+	for name, order := range map[string][]string{
+		"missing member": {photo3, photo1},
+		"duplicate":      {photo3, photo1, photo1},
+		"stranger":       {photo3, photo1, foreign},
+	} {
+		if _, err := albums.ReorderMedia(ctx, "alice", albumID, order); errs.KindOf(err) != errs.KindInvalidArgument {
+			t.Errorf("%s: ReorderMedia() error = %v, want InvalidArgument", name, err)
+		}
+	}
+
+	if _, err := albums.ReorderMedia(ctx, "alice", albumID, []string{photo3, photo1, photo2}); err != nil {
+		t.Fatalf("ReorderMedia() error = %v", err)
+	}
+	if want := []string{photo3, photo1, photo2}; !slices.Equal(repo.members[albumID], want) {
+		t.Errorf("members = %v, want %v", repo.members[albumID], want)
+	}
+}
+
+func TestListAlbumMediaPages(t *testing.T) {
+	albums, _ := newTestAlbums(photo1, photo2, photo3)
+	ctx := context.Background()
+
+	var got []string
+	token := ""
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("pagination does not end")
+		}
+		page, err := albums.ListAlbumMedia(ctx, "alice", albumID, token, 2)
+		if err != nil {
+			t.Fatalf("ListAlbumMedia(%q) error = %v", token, err)
+		}
+		for _, media := range page.Media {
+			got = append(got, media.ID)
+		}
+		if page.NextPageToken == "" {
+			break
+		}
+		token = page.NextPageToken
+	}
+	if want := []string{photo1, photo2, photo3}; !slices.Equal(got, want) {
+		t.Errorf("listed %v, want %v", got, want)
+	}
+
+	if _, err := albums.ListAlbumMedia(ctx, "alice", albumID, "-1", 2); errs.KindOf(err) != errs.KindInvalidArgument {
+		t.Errorf("ListAlbumMedia(bad token) error = %v, want InvalidArgument", err)
+	}
+	if _, err := albums.ListAlbumMedia(ctx, "bob", albumID, "", 2); !errors.Is(err, models.ErrAlbumNotFound) {
+		t.Errorf("ListAlbumMedia(other owner) error = %v, want ErrAlbumNotFound", err)
+	}
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/co1seam/ember-backend-media/internal/core/errs"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// PlanArchive checks every requested media on its own: media that is
+// missing, owned by someone else, quarantined or without a file is kept out
+// of the archive and reported in its manifest. Media of other owners is
+// reported as not found.
+func (m *Media) PlanArchive(ctx context.Context, ownerID string, ids []string) (_ *models.Archive, err error) {
+	ctx, span := startSpan(ctx, "services.Media.PlanArchive", attribute.Int64("archive.size", int64(len(ids))))
+	defer finish(span, &err)
+
+	if ownerID == "" {
+		return nil, errs.InvalidArgument("owner id is required")
+	}
+	if len(ids) == 0 {
+		return nil, errs.InvalidArgument("at least one media id is required")
+	}
+	if len(ids) > models.MaxArchiveMedia {
+		return nil, errs.InvalidArgument(fmt.Sprintf("an archive can hold at most %d media", models.MaxArchiveMedia))
+	}
+
+	archive := &models.Archive{OwnerID: ownerID, CreatedAt: time.Now().UTC()}
+	seen := make(map[string]bool, len(ids))
+	var query []string
+	for _, id := range ids {
+		parsed, err := uuid.Parse(id)
+		if err != nil {
+			archive.Entries = append(archive.Entries, &models.ArchiveEntry{MediaID: id, Error: "invalid media id"})
+			continue
+		}
+		if id = parsed.String(); !seen[id] {
+			seen[id] = true
+			query = append(query, id)
+		}
+	}
+
+	found := make(map[string]*models.Media, len(query))
+	if len(query) > 0 {
+		mediaList, err := m.repo.GetByIDs(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		for _, media := range mediaList {
+			found[media.ID] = media
+		}
+	}
+
+	names := make(map[string]bool, len(query))
+	included := 0
+	for _, id := range query {
+		entry := &models.ArchiveEntry{MediaID: id}
+		archive.Entries = append(archive.Entries, entry)
+
+		media, ok := found[id]
+		switch {
+		case !ok || media.OwnerID != ownerID:
+			entry.Error = models.ErrMediaNotFound.Message
+			continue
+		case media.ServeError() != nil:
+			entry.Error = media.ServeError().Error()
+			continue
+		case media.StoragePath == "":
+			entry.Error = "media has no file"
+			continue
+		}
+
+		entry.Name = archiveName(names, media)
+		entry.Title = media.Title
+		entry.ContentType = media.ContentType
+		entry.Size = media.Size
+		entry.Version = media.Version
+		entry.Stored = precompressed(media.ContentType)
+		entry.StoragePath = media.StoragePath
+		included++
+	}
+
+	if included == 0 {
+		return nil, errs.NotFound("no media to archive")
+	}
+
+	return archive, nil
+}
+
+// WriteArchive streams the planned archive to w, copying each object from
+// storage straight into the ZIP. An object that cannot be opened is skipped
+// and noted in the manifest, which is written last.
+func (m *Media) WriteArchive(ctx context.Context, archive *models.Archive, w io.Writer) (err error) {
+	ctx, span := startSpan(ctx, "services.Media.WriteArchive", attribute.Int64("archive.size", int64(len(archive.Entries))))
+	defer finish(span, &err)
+
+	zw := zip.NewWriter(w)
+	bucket := m.opts.Config.MinIO.Bucket
+
+	for _, entry := range archive.Entries {
+		if entry.Error != "" {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Opening an object only prepares the request; Stat makes it, so a
+		// missing object is found before its entry is started.
+		object, err := m.minio.DownloadFile(ctx, bucket, entry.StoragePath)
+		if err == nil {
+			if _, err = object.Stat(); err != nil {
+				object.Close()
+			}
+		}
+		if err != nil {
+			m.opts.Logger.WarnContext(ctx, "media left out of archive", "media_id", entry.MediaID, "error", err)
+			entry.Error = "file unavailable"
+			continue
+		}
+
+		method := zip.Deflate
+		if entry.Stored {
+			method = zip.Store
+		}
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     entry.Name,
+			Method:   method,
+			Modified: archive.CreatedAt,
+		})
+		if err != nil {
+			object.Close()
+			return err
+		}
+
+		_, err = io.Copy(fw, object)
+		object.Close()
+		if err != nil {
+			return fmt.Errorf("archive %s: %w", entry.MediaID, err)
+		}
+	}
+
+	fw, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     models.ArchiveManifestName,
+		Method:   zip.Deflate,
+		Modified: archive.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(fw)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(archive); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// archiveName picks a unique file name for media from its uploaded name,
+// numbering repeats the way browsers do.
+func archiveName(names map[string]bool, media *models.Media) string {
+	base := path.Base(media.StoragePath)
+	if base == "." || base == "/" || base == models.ArchiveManifestName {
+		base = media.ID
+	}
+
+	name := base
+	ext := path.Ext(base)
+	for i := 2; names[name]; i++ {
+		name = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(base, ext), i, ext)
+	}
+	names[name] = true
+
+	return name
+}
+
+// precompressed reports whether content of this type gains nothing from
+// deflate and is better stored as is.
+func precompressed(contentType string) bool {
+	contentType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+
+	switch contentType {
+	case "image/svg+xml", "image/bmp", "image/tiff", "audio/wav", "audio/x-wav":
+		return false
+	case "application/zip", "application/gzip", "application/x-7z-compressed",
+		"application/x-rar-compressed", "application/x-bzip2", "application/x-xz", "application/zstd":
+		return true
+	}
+
+	return strings.HasPrefix(contentType, "image/") ||
+		strings.HasPrefix(contentType, "video/") ||
+		strings.HasPrefix(contentType, "audio/")
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"strings"
+	"testing"
+)
+
+func TestWriteArchiveSkipsMissingObjects(t *testing.T) {
+	media := newTestMedia(t, nil, map[string]string{"o/a/1/a.txt": "hello"}, nil).media
+
+	archive := &models.Archive{OwnerID: "o", Entries: []*models.ArchiveEntry{
+		{MediaID: "a", Name: "a.txt", StoragePath: "o/a/1/a.txt"},
+		{MediaID: "b", Name: "b.txt", StoragePath: "o/b/1/b.txt"},
+	}}
+
+	var out bytes.Buffer
+	if err := media.WriteArchive(context.Background(), archive, &out); err != nil {
+		t.Fatalf("WriteArchive() error = %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, file := range zr.File {
+		names = append(names, file.Name)
+	}
+	if strings.Join(names, ",") != "a.txt,"+models.ArchiveManifestName {
+		t.Fatalf("archive holds %v, want only a.txt and the manifest", names)
+	}
+
+	manifest, err := zr.File[1].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written models.Archive
+	if err := json.NewDecoder(manifest).Decode(&written); err != nil {
+		t.Fatal(err)
+	}
+	if written.Entries[0].Error != "" || written.Entries[1].Error != "file unavailable" {
+		t.Errorf("manifest entries = %+v, %+v", written.Entries[0], written.Entries[1])
+	}
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/co1seam/ember-backend-media/internal/core/auth"
+	"github.com/co1seam/ember-backend-media/internal/core/errs"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"time"
+)
+
+type Audit struct {
+	repo ports.IAuditRepo
+	opts *models.Options
+}
+
+func NewAudit(repo ports.IAuditRepo, opts *models.Options) ports.IAuditService {
+	return &Audit{
+		repo: repo,
+		opts: opts,
+	}
+}
+
+func (a *Audit) ListAuditEvents(ctx context.Context, query *models.AuditQuery, pageToken string) (_ *models.AuditPage, err error) {
+	ctx, span := startSpan(ctx, "services.Audit.ListAuditEvents", attribute.String("media.id", query.MediaID), attribute.String("audit.actor", query.Actor))
+	defer finish(span, &err)
+
+	if query.MediaID != "" {
+		if _, err := uuid.Parse(query.MediaID); err != nil {
+			return nil, errs.InvalidArgument("invalid media id")
+		}
+	}
+	if !query.Since.IsZero() && !query.Until.IsZero() && !query.Since.Before(query.Until) {
+		return nil, errs.InvalidArgument("since must be before until")
+	}
+	if pageToken != "" {
+		query.BeforeID, err = strconv.ParseInt(pageToken, 10, 64)
+		if err != nil || query.BeforeID <= 0 {
+			return nil, errs.InvalidArgument("invalid page token")
+		}
+	}
+
+	if query.Limit <= 0 {
+		query.Limit = models.DefaultListLimit
+	}
+	query.Limit = min(query.Limit, models.MaxAuditLimit)
+
+	events, err := a.repo.List(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.AuditPage{Events: events}
+	if page.Events == nil {
+		page.Events = []*models.AuditEvent{}
+	}
+	if len(events) == query.Limit {
+		page.NextPageToken = strconv.FormatInt(events[len(events)-1].ID, 10)
+	}
+
+	return page, nil
+}
+
+// PruneAuditEvents deletes the events older than olderThan and returns how
+// many there were. With dryRun set it only counts them.
+func (a *Audit) PruneAuditEvents(ctx context.Context, olderThan time.Duration, dryRun bool) (_ int64, err error) {
+	ctx, span := startSpan(ctx, "services.Audit.PruneAuditEvents")
+	defer finish(span, &err)
+
+	if olderThan <= 0 {
+		return 0, errs.InvalidArgument(fmt.Sprintf("retention must be positive, got %s", olderThan))
+	}
+
+	before := time.Now().Add(-olderThan)
+	if dryRun {
+		return a.repo.CountBefore(ctx, before)
+	}
+	return a.repo.DeleteBefore(ctx, before)
+}
+
+// audit records a change to media in the caller's transaction. before is nil
+// for created media and after is nil for deleted media.
+func (m *Media) audit(ctx context.Context, action string, before, after *models.Media) error {
+	return m.auditLog.Add(ctx, auditEvent(ctx, action, before, after))
+}
+
+// auditEvent describes a change to media, stamped with who made it and from
+// where.
+func auditEvent(ctx context.Context, action string, before, after *models.Media) *models.AuditEvent {
+	media := after
+	if media == nil {
+		media = before
+	}
+
+	return &models.AuditEvent{
+		Action:    action,
+		MediaID:   media.ID,
+		OwnerID:   media.OwnerID,
+		Actor:     actor(ctx),
+		ClientIP:  auth.ClientIPFromContext(ctx),
+		RequestID: auth.RequestIDFromContext(ctx),
+		Changes:   auditChanges(before, after),
+	}
+}
+
+// actor names who made a change: the verified client, anonymous for other
+// requests and system for background jobs, which run outside any request.
+func actor(ctx context.Context) string {
+	if identity, ok := auth.FromContext(ctx); ok && identity.Subject != "" {
+		return identity.Subject
+	}
+	if auth.RequestIDFromContext(ctx) != "" {
+		return models.AuditActorAnonymous
+	}
+	return models.AuditActorSystem
+}
+
+// auditChanges lists the fields that differ between before and after.
+// Metadata is compared key by key.
+func auditChanges(before, after *models.Media) map[string]models.AuditChange {
+	old, current := auditFields(before), auditFields(after)
+
+	changes := make(map[string]models.AuditChange)
+	for field, value := range current {
+		if !reflect.DeepEqual(old[field], value) {
+			changes[field] = models.AuditChange{Before: old[field], After: value}
+		}
+	}
+	for field, value := range old {
+		if _, ok := current[field]; !ok {
+			changes[field] = models.AuditChange{Before: value}
+		}
+	}
+
+	return changes
+}
+
+// auditFields flattens the audited fields of media, leaving out empty ones.
+func auditFields(media *models.Media) map[string]any {
+	fields := make(map[string]any)
+	if media == nil {
+		return fields
+	}
+
+	set := func(field string, value any, empty bool) {
+		if !empty {
+			fields[field] = value
+		}
+	}
+	set("title", media.Title, media.Title == "")
+	set("description", media.Description, media.Description == "")
+	set("content_type", media.ContentType, media.ContentType == "")
+	set("storage_path", media.StoragePath, media.StoragePath == "")
+	set("state", media.State, media.State == "")
+	set("quarantine_reason", media.QuarantineReason, media.QuarantineReason == "")
+	set("size", media.Size, media.Size == 0)
+	set("version", media.Version, media.Version == 0)
+	set("streaming_optimized", media.StreamingOptimized, !media.StreamingOptimized)
+	set("tags", slices.Sorted(slices.Values(media.Tags)), len(media.Tags) == 0)
+	for key, value := range media.Metadata {
+		fields["metadata."+key] = value
+	}
+
+	return fields
+}
+
+// snapshot copies media before it is changed in place.
+func snapshot(media *models.Media) *models.Media {
+	copied := *media
+	copied.Tags = slices.Clone(media.Tags)
+	copied.Metadata = maps.Clone(media.Metadata)
+	return &copied
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"github.com/co1seam/ember-backend-media/internal/core/errs"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"slices"
+	"testing"
+	"time"
+)
+
+func (a *fakeAuditLog) CountBefore(ctx context.Context, before time.Time) (int64, error) {
+	var count int64
+	for _, event := range a.events {
+		if event.CreatedAt.Before(before) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (a *fakeAuditLog) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	count, _ := a.CountBefore(ctx, before)
+	a.events = slices.DeleteFunc(a.events, func(event *models.AuditEvent) bool { return event.CreatedAt.Before(before) })
+	return count, nil
+}
+
+func TestPruneAuditEvents(t *testing.T) {
+	now := time.Now()
+	auditLog := &fakeAuditLog{events: []*models.AuditEvent{
+		{ID: 1, CreatedAt: now.Add(-400 * 24 * time.Hour)},
+		{ID: 2, CreatedAt: now.Add(-100 * 24 * time.Hour)},
+		{ID: 3, CreatedAt: now.Add(-time.Hour)},
+	}}
+	audit := NewAudit(auditLog, testOptions())
+	ctx := context.Background()
+
+	count, err := audit.PruneAuditEvents(ctx, 30*24*time.Hour, true)
+	if err != nil || count != 2 || len(auditLog.events) != 3 {
+		t.Fatalf("dry run = %d, %v with %d events left, want 2 counted and none deleted", count, err, len(auditLog.events))
+	}
+
+	count, err = audit.PruneAuditEvents(ctx, 30*24*time.Hour, false)
+	if err != nil || count != 2 || len(auditLog.events) != 1 || auditLog.events[0].ID != 3 {
+		t.Errorf("prune = %d, %v with %v left, want the two old events deleted", count, err, auditLog.events)
+	}
+
+	if _, err := audit.PruneAuditEvents(ctx, 0, true); errs.KindOf(err) != errs.KindInvalidArgument {
+		t.Errorf("PruneAuditEvents(0) error = %v, want InvalidArgument", err)
+	}
+}
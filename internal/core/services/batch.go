@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/co1seam/ember-backend-media/internal/core/errs"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"sync"
+)
+
+var errDuplicateBatchItem = errs.InvalidArgument("media appears more than once in the batch")
+
+// BatchGetMedia loads all media in one query and signs their download URLs
+// in parallel. Results follow the order of ids.
+func (m *Media) BatchGetMedia(ctx context.Context, ids []string) (_ []*models.BatchResult, err error) {
+	ctx, span := startSpan(ctx, "services.Media.BatchGetMedia", attribute.Int64("batch.size", int64(len(ids))))
+	defer finish(span, &err)
+
+	results, found, err := m.batchLookup(ctx, ids, false)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, result := range results {
+		if media, ok := found[result.ID]; ok && result.Err == nil {
+			copied := *media
+			result.Media = &copied
+		}
+	}
+
+	forEach(len(results), func(i int) {
+		result := results[i]
+		if result.Media == nil {
+			return
+		}
+		if err := m.sign(ctx, result.Media); err != nil {
+			m.opts.Logger.WarnContext(ctx, "download url not signed", "media_id", result.ID, "error", err)
+			result.Media, result.Err = nil, err
+		}
+	})
+
+	return results, nil
+}
+
+// BatchUpdateMedia applies each request's field mask in one statement. An
+// item fails on its own when it is missing, invalid or its revision is stale.
+func (m *Media) BatchUpdateMedia(ctx context.Context, reqs []*models.UpdateMediaRequest) (_ []*models.BatchResult, err error) {
+	ctx, span := startSpan(ctx, "services.Media.BatchUpdateMedia", attribute.Int64("batch.size", int64(len(reqs))))
+	defer finish(span, &err)
+
+	ids := make([]string, len(reqs))
+	for i, req := range reqs {
+		ids[i] = req.ID
+	}
+
+	results, found, err := m.batchLookup(ctx, ids, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var patches []*models.MediaPatch
+	byID := make(map[string]*models.BatchResult, len(results))
+	before := make(map[string]*models.Media, len(results))
+	for i, result := range results {
+		if result.Err != nil {
+			continue
+		}
+		req, media := reqs[i], found[result.ID]
+
+		mask, err := updateMask(req.UpdateMask, models.MediaFieldTitle, models.MediaFieldDescription)
+		if err != nil {
+			result.Err = err
+			continue
+		}
+		if req.Revision != 0 && req.Revision != media.Revision {
+			result.Err = models.ErrMediaModified
+			continue
+		}
+
+		result.Media = media
+		if len(mask) == 0 {
+			continue
+		}
+		before[media.ID] = snapshot(media)
+		for _, field := range mask {
+			switch field {
+			case models.MediaFieldTitle:
+				media.Title = req.Title
+			case models.MediaFieldDescription:
+				media.Description = req.Description
+			}
+		}
+		patches = append(patches, &models.MediaPatch{Media: media, Fields: mask, Revision: media.Revision})
+		byID[media.ID] = result
+	}
+
+	if len(patches) == 0 {
+		return results, nil
+	}
+
+	err = m.tx.WithinTx(ctx, func(ctx context.Context) error {
+		updated, err := m.repo.UpdateFieldsBatch(ctx, patches)
+		if err != nil {
+			return err
+		}
+
+		applied := make(map[string]bool, len(updated))
+		for _, id := range updated {
+			applied[id] = true
+			if err := m.audit(ctx, models.EventMediaUpdated, before[id], found[id]); err != nil {
+				return err
+			}
+			if err := m.publish(ctx, models.EventMediaUpdated, found[id]); err != nil {
+				return err
+			}
+		}
+		for id, result := range byID {
+			if !applied[id] {
+				result.Media, result.Err = nil, models.ErrMediaModified
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// BatchDeleteMedia deletes all media in one statement. Like DeleteMedia it
+// leaves their objects to reconcile.
+func (m *Media) BatchDeleteMedia(ctx context.Context, ids []string) (_ []*models.BatchResult, err error) {
+	ctx, span := startSpan(ctx, "services.Media.BatchDeleteMedia", attribute.Int64("batch.size", int64(len(ids))))
+	defer finish(span, &err)
+
+	results, found, err := m.batchLookup(ctx, ids, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return results, nil
+	}
+
+	mediaIDs := make([]string, 0, len(found))
+	for id := range found {
+		mediaIDs = append(mediaIDs, id)
+	}
+
+	var deleted []string
+	err = m.tx.WithinTx(ctx, func(ctx context.Context) error {
+		deleted, err = m.repo.DeleteByIDs(ctx, mediaIDs)
+		if err != nil {
+			return err
+		}
+		for _, id := range deleted {
+			if err := m.audit(ctx, models.EventMediaDeleted, found[id], nil); err != nil {
+				return err
+			}
+			if err := m.publish(ctx, models.EventMediaDeleted, found[id]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	removed := make(map[string]bool, len(deleted))
+	for _, id := range deleted {
+		removed[id] = true
+	}
+	for _, result := range results {
+		if result.Err == nil && !removed[result.ID] {
+			result.Err = models.ErrMediaNotFound
+		}
+	}
+
+	return results, nil
+}
+
+// batchLookup validates ids and loads the media behind them in one query.
+// Every id gets a result; those that are invalid or missing already carry
+// their error. With unique set, repeated ids fail after the first.
+func (m *Media) batchLookup(ctx context.Context, ids []string, unique bool) ([]*models.BatchResult, map[string]*models.Media, error) {
+	if len(ids) == 0 {
+		return nil, nil, errs.InvalidArgument("at least one media id is required")
+	}
+	if len(ids) > models.MaxBatchSize {
+		return nil, nil, errs.InvalidArgument(fmt.Sprintf("a batch can hold at most %d items", models.MaxBatchSize))
+	}
+
+	results := make([]*models.BatchResult, len(ids))
+	seen := make(map[string]bool, len(ids))
+	var query []string
+	for i, id := range ids {
+		results[i] = &models.BatchResult{ID: id}
+		parsed, err := uuid.Parse(id)
+		if err != nil {
+			results[i].Err = errs.InvalidArgument("invalid media id")
+			continue
+		}
+		id = parsed.String()
+		results[i].ID = id
+		if seen[id] {
+			if unique {
+				results[i].Err = errDuplicateBatchItem
+			}
+			continue
+		}
+		seen[id] = true
+		query = append(query, id)
+	}
+
+	found := make(map[string]*models.Media, len(query))
+	if len(query) > 0 {
+		mediaList, err := m.repo.GetByIDs(ctx, query)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, media := range mediaList {
+			found[media.ID] = media
+		}
+	}
+
+	for _, result := range results {
+		if _, ok := found[result.ID]; !ok && result.Err == nil {
+			result.Err = models.ErrMediaNotFound
+		}
+	}
+
+	return results, found, nil
+}
+
+// forEach calls fn for every index in [0, n), at most BatchConcurrency at a
+// time, and waits for all of them.
+func forEach(n int, fn func(i int)) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, models.BatchConcurrency)
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fn(i)
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/co1seam/ember-backend-media/internal/core/errs"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	batch1 = "b0000000-0000-0000-0000-000000000001"
+	batch2 = "b0000000-0000-0000-0000-000000000002"
+	batch3 = "b0000000-0000-0000-0000-000000000003"
+	absent = "b0000000-0000-0000-0000-0000000000ff"
+)
+
+func (r *fakeMediaRepo) GetByIDs(ctx context.Context, ids []string) ([]*models.Media, error) {
+	r.lookups++
+	var list []*models.Media
+	for _, id := range ids {
+		if row, ok := r.rows[id]; ok {
+			list = append(list, &row)
+		}
+	}
+	return list, nil
+}
+
+func (r *fakeMediaRepo) UpdateFieldsBatch(ctx context.Context, patches []*models.MediaPatch) ([]string, error) {
+	r.updates++
+	var updated []string
+	for _, patch := range patches {
+		row := r.rows[patch.Media.ID]
+		if r.race != nil {
+			r.race(&row)
+		}
+		if row.Revision != patch.Revision {
+			r.rows[row.ID] = row
+			continue
+		}
+		row.Title = patch.Media.Title
+		row.Description = patch.Media.Description
+		row.Revision++
+		r.rows[row.ID] = row
+		patch.Media.Revision = row.Revision
+		updated = append(updated, row.ID)
+	}
+	return updated, nil
+}
+
+func (r *fakeMediaRepo) DeleteByIDs(ctx context.Context, ids []string) ([]string, error) {
+	var deleted []string
+	for _, id := range ids {
+		if _, ok := r.rows[id]; ok {
+			delete(r.rows, id)
+			deleted = append(deleted, id)
+		}
+	}
+	return deleted, nil
+}
+
+func batchErrors(results []*models.BatchResult) map[string]error {
+	byID := make(map[string]error, len(results))
+	for _, result := range results {
+		byID[result.ID] = result.Err
+	}
+	return byID
+}
+
+func TestBatchGetMedia(t *testing.T) {
+	f := newTestMedia(t, map[string]models.Media{
+		batch1: {ID: batch1, StoragePath: "o/1/a.png", State: models.MediaStateActive},
+		batch2: {ID: batch2, StoragePath: "broken/2/b.png", State: models.MediaStateActive},
+		batch3: {ID: batch3, StoragePath: "o/3/c.png", State: models.MediaStateQuarantined},
+	}, nil, nil)
+	media, repo := f.media, f.repo
+
+	ids := []string{batch3, "not-a-uuid", absent, strings.ToUpper(batch1), batch2, batch1}
+	results, err := media.BatchGetMedia(context.Background(), ids)
+	if err != nil {
+		t.Fatalf("BatchGetMedia() error = %v", err)
+	}
+	if repo.lookups != 1 {
+		t.Errorf("looked up %d times, want one query", repo.lookups)
+	}
+	if len(results) != len(ids) {
+		t.Fatalf("got %d results for %d ids", len(results), len(ids))
+	}
+
+	want := []struct {
+		id  string
+		url string
+		ok  bool
+	}{
+		{batch3, "", true},
+		{"not-a-uuid", "", false},
+		{absent, "", false},
+		{batch1, "https://minio/o/1/a.png", true},
+		{batch2, "", false},
+		{batch1, "https://minio/o/1/a.png", true},
+	}
+	for i, w := range want {
+		result := results[i]
+		if result.ID != w.id || (result.Err == nil) != w.ok {
+			t.Errorf("result %d = %s, %v; want %s ok=%v", i, result.ID, result.Err, w.id, w.ok)
+			continue
+		}
+		if w.ok && result.Media.URL != w.url {
+			t.Errorf("result %d URL = %q, want %q", i, result.Media.URL, w.url)
+		}
+	}
+	if !errors.Is(results[2].Err, models.ErrMediaNotFound) {
+		t.Errorf("missing media error = %v, want ErrMediaNotFound", results[2].Err)
+	}
+	if results[3].Media == results[5].Media {
+		t.Error("repeated ids share one media value")
+	}
+
+	if _, err := media.BatchGetMedia(context.Background(), nil); err == nil {
+		t.Error("BatchGetMedia(nil) succeeded")
+	}
+	if _, err := media.BatchGetMedia(context.Background(), make([]string, models.MaxBatchSize+1)); err == nil {
+		t.Error("BatchGetMedia() over MaxBatchSize succeeded")
+	}
+}
+
+func TestBatchUpdateMedia(t *testing.T) {
+	f := newTestMedia(t, map[string]models.Media{
+		batch1: {ID: batch1, Title: "one", Description: "first", State: models.MediaStateActive, Revision: 1},
+		batch2: {ID: batch2, Title: "two", State: models.MediaStateActive, Revision: 4},
+		batch3: {ID: batch3, Title: "three", State: models.MediaStateActive, Revision: 2},
+	}, nil, nil)
+	media, repo, outbox := f.media, f.repo, f.outbox
+	repo.race = func(row *models.Media) {
+		if row.ID == batch3 {
+			row.Title = "renamed elsewhere"
+			row.Revision++
+		}
+	}
+
+	title := []string{models.MediaFieldTitle}
+	results, err := media.BatchUpdateMedia(context.Background(), []*models.UpdateMediaRequest{
+		{ID: batch1, Title: "uno", Description: "ignored", UpdateMask: title},
+		{ID: batch2, Title: "dos", UpdateMask: title, Revision: 3},
+		{ID: batch3, Title: "tres", UpdateMask: title},
+		{ID: batch1, Title: "again", UpdateMask: title},
+		{ID: absent, Title: "nobody", UpdateMask: title},
+		{ID: batch2, UpdateMask: []string{"owner_id"}},
+	})
+	if err != nil {
+		t.Fatalf("BatchUpdateMedia() error = %v", err)
+	}
+	if repo.updates != 1 {
+		t.Errorf("UpdateFieldsBatch called %d times, want once", repo.updates)
+	}
+
+	wantErrs := []error{nil, models.ErrMediaModified, models.ErrMediaModified, errDuplicateBatchItem, models.ErrMediaNotFound}
+	for i, want := range wantErrs {
+		if got := results[i].Err; !errors.Is(got, want) {
+			t.Errorf("result %d error = %v, want %v", i, got, want)
+		}
+	}
+	if errs.KindOf(results[5].Err) != errs.KindInvalidArgument {
+		t.Errorf("unknown mask path error = %v, want InvalidArgument", results[5].Err)
+	}
+
+	if row := repo.rows[batch1]; row.Title != "uno" || row.Description != "first" || results[0].Media.Revision != 2 {
+		t.Errorf("row = %+v, want only the title changed", row)
+	}
+	if row := repo.rows[batch3]; row.Title != "renamed elsewhere" {
+		t.Errorf("title = %q, want the concurrent edit kept", row.Title)
+	}
+	if len(outbox.events) != 1 {
+		t.Errorf("published %d events, want 1", len(outbox.events))
+	}
+}
+
+func TestBatchDeleteMedia(t *testing.T) {
+	f := newTestMedia(t, map[string]models.Media{
+		batch1: {ID: batch1, State: models.MediaStateActive},
+		batch2: {ID: batch2, State: models.MediaStateActive},
+	}, nil, nil)
+	media, repo, outbox := f.media, f.repo, f.outbox
+
+	results, err := media.BatchDeleteMedia(context.Background(), []string{batch1, absent, batch2, batch1})
+	if err != nil {
+		t.Fatalf("BatchDeleteMedia() error = %v", err)
+	}
+
+	got := batchErrors(results)
+	if got[batch1] != nil || got[batch2] != nil || !errors.Is(got[absent], models.ErrMediaNotFound) {
+		t.Errorf("errors = %v", got)
+	}
+	if len(repo.rows) != 0 {
+		t.Errorf("rows left = %v", repo.rows)
+	}
+	if len(outbox.events) != 2 || outbox.events[0].Type != models.EventMediaDeleted {
+		t.Errorf("published %d events, want 2 deletions", len(outbox.events))
+	}
+}
+
+func TestForEachBoundsParallelism(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		running  int
+		peak     int
+		finished atomic.Int32
+	)
+	release := make(chan struct{})
+
+	done := make(chan struct{})
+	go func() {
+		forEach(models.BatchConcurrency*3, func(i int) {
+			mu.Lock()
+			running++
+			peak = max(peak, running)
+			mu.Unlock()
+
+			<-release
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+			finished.Add(1)
+		})
+		close(done)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	<-done
+
+	if peak != models.BatchConcurrency {
+		t.Errorf("peak parallelism = %d, want %d", peak, models.BatchConcurrency)
+	}
+	if got := finished.Load(); got != models.BatchConcurrency*3 {
+		t.Errorf("ran %d items, want %d", got, models.BatchConcurrency*3)
+	}
+}
@@ -0,0 +1,416 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/co1seam/ember-backend-media/internal/core/errs"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"github.com/google/uuid"
+	"github.com/minio/minio-go/v7"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Importer brings files of an older system into the media table, either by
+// server-side copy from another bucket on the same MinIO or by upload from
+// a local directory. Imported files are scanned like uploads; infected ones
+// are not imported. Imports publish no events, like the other maintenance
+// jobs, but every imported media is recorded in the audit log.
+type Importer struct {
+	repo     ports.IImportRepo
+	media    ports.IMediaRepo
+	versions ports.IMediaVersionRepo
+	tx       ports.ITransactor
+	minio    ports.IMinio
+	scanner  ports.IScanner
+	auditLog ports.IAuditRepo
+	opts     *models.Options
+}
+
+// importFile is one file found in the import source.
+type importFile struct {
+	key         string
+	size        int64
+	contentType string
+}
+
+func NewImporter(repo ports.IImportRepo, media ports.IMediaRepo, versions ports.IMediaVersionRepo, auditLog ports.IAuditRepo, tx ports.ITransactor, minio ports.IMinio, scanner ports.IScanner, opts *models.Options) *Importer {
+	return &Importer{
+		repo:     repo,
+		media:    media,
+		versions: versions,
+		tx:       tx,
+		minio:    minio,
+		scanner:  scanner,
+		auditLog: auditLog,
+		opts:     opts,
+	}
+}
+
+// Create records a new import job. Location is a bucket name or a directory
+// depending on source; prefix only applies to buckets.
+func (i *Importer) Create(ctx context.Context, ownerID, source, location, prefix string) (*models.ImportJob, error) {
+	if ownerID == "" {
+		return nil, errs.InvalidArgument("owner id is required")
+	}
+	if location == "" {
+		return nil, errs.InvalidArgument("import location is required")
+	}
+
+	switch source {
+	case models.ImportSourceBucket:
+		if location == i.opts.Config.MinIO.Bucket {
+			return nil, errs.InvalidArgument("cannot import from the media bucket")
+		}
+	case models.ImportSourceDir:
+		info, err := os.Stat(location)
+		if err != nil || !info.IsDir() {
+			return nil, errs.InvalidArgument(fmt.Sprintf("%s is not a directory", location))
+		}
+		if prefix != "" {
+			return nil, errs.InvalidArgument("prefix only applies to bucket imports")
+		}
+	default:
+		return nil, errs.InvalidArgument(fmt.Sprintf("unknown import source %q", source))
+	}
+
+	now := time.Now()
+	job := &models.ImportJob{
+		ID:        uuid.New().String(),
+		OwnerID:   ownerID,
+		Source:    source,
+		Location:  location,
+		Prefix:    prefix,
+		State:     models.ImportStateRunning,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := i.repo.CreateJob(ctx, job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func (i *Importer) Job(ctx context.Context, id string) (*models.ImportJob, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, models.ErrImportJobNotFound
+	}
+
+	job, err := i.repo.GetJob(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ErrImportJobNotFound
+	}
+	return job, err
+}
+
+func (i *Importer) Failures(ctx context.Context, id string, limit int) ([]*models.ImportItem, error) {
+	return i.repo.ListFailures(ctx, id, limit)
+}
+
+// Run imports the files after the job's cursor in key order. Progress is
+// saved with every file, in the transaction that creates its media, so an
+// interrupted run is resumed by calling Run again; progress is also passed
+// to the callback every ImportProgressEvery files. A file that fails is
+// recorded and skipped.
+func (i *Importer) Run(ctx context.Context, job *models.ImportJob, progress func(*models.ImportJob)) error {
+	if job.State == models.ImportStateCompleted {
+		return errs.FailedPrecondition("import job already completed")
+	}
+
+	job.State = models.ImportStateRunning
+	job.Error = ""
+	job.FinishedAt = nil
+
+	handled := 0
+	err := i.walk(ctx, job, func(file *importFile) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := i.importOne(ctx, job, file); err != nil {
+			return err
+		}
+
+		handled++
+		if progress != nil && handled%models.ImportProgressEvery == 0 {
+			progress(job)
+		}
+		return nil
+	})
+
+	now := time.Now()
+	job.UpdatedAt = now
+	switch {
+	case errors.Is(err, context.Canceled):
+		// Left running so that it can be resumed.
+	case err != nil:
+		job.State = models.ImportStateFailed
+		job.Error = err.Error()
+	default:
+		job.State = models.ImportStateCompleted
+		job.FinishedAt = &now
+	}
+
+	if saveErr := i.repo.UpdateJob(context.WithoutCancel(ctx), job); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	if progress != nil {
+		progress(job)
+	}
+
+	return err
+}
+
+// walk lists the source in key order from the job's cursor. Directories are
+// listed in full and sorted first, since a directory walk does not visit
+// paths in plain string order.
+func (i *Importer) walk(ctx context.Context, job *models.ImportJob, fn func(file *importFile) error) error {
+	if job.Source == models.ImportSourceBucket {
+		return i.minio.WalkFiles(ctx, job.Location, job.Prefix, job.Cursor, func(object minio.ObjectInfo) error {
+			if strings.HasSuffix(object.Key, "/") {
+				return nil
+			}
+			return fn(&importFile{key: object.Key, size: object.Size, contentType: object.ContentType})
+		})
+	}
+
+	var files []*importFile
+	err := filepath.WalkDir(job.Location, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(job.Location, name)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if key <= job.Cursor {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, &importFile{key: key, size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	slices.SortFunc(files, func(a, b *importFile) int { return strings.Compare(a.key, b.key) })
+	for _, file := range files {
+		if err := fn(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importOne imports a file as owner/mediaID/name. A file that cannot be
+// imported is recorded as failed; only errors that should stop the run are
+// returned.
+func (i *Importer) importOne(ctx context.Context, job *models.ImportJob, file *importFile) error {
+	item := &models.ImportItem{JobID: job.ID, SourceKey: file.key, CreatedAt: time.Now()}
+
+	name := path.Base(file.key)
+	now := time.Now()
+	media := &models.Media{
+		ID:        uuid.New().String(),
+		Title:     strings.TrimSuffix(name, path.Ext(name)),
+		OwnerID:   job.OwnerID,
+		State:     models.MediaStateActive,
+		Version:   1,
+		Revision:  1,
+		Metadata:  map[string]string{"import_job": job.ID},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	media.StoragePath = fmt.Sprintf("%s/%s/%s", job.OwnerID, media.ID, name)
+
+	err := i.store(ctx, job, file, item, media)
+	if err == nil {
+		return nil
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		// The file is retried on resume rather than counted as failed.
+		return ctxErr
+	}
+
+	item.Error = err.Error()
+	i.opts.Logger.WarnContext(ctx, "file not imported", "job_id", job.ID, "key", file.key, "error", item.Error)
+	advance(job, file, item)
+	return i.record(ctx, job, item)
+}
+
+// store copies and scans the file, then writes the media, its version, the
+// item and the job's progress in one transaction. Whatever it did is undone
+// when it fails.
+func (i *Importer) store(ctx context.Context, job *models.ImportJob, file *importFile, item *models.ImportItem, media *models.Media) error {
+	version, err := i.copy(ctx, job, file, media)
+	if err != nil {
+		return err
+	}
+
+	progress := *job
+	err = i.scan(ctx, media)
+	if err == nil {
+		err = i.tx.WithinTx(ctx, func(ctx context.Context) error {
+			if err := i.media.Create(ctx, media); err != nil {
+				return err
+			}
+			if err := i.versions.Create(ctx, version); err != nil {
+				return err
+			}
+			if err := i.auditLog.Add(ctx, auditEvent(ctx, models.AuditActionMediaImported, nil, media)); err != nil {
+				return err
+			}
+			item.MediaID = media.ID
+			item.Size = media.Size
+			advance(job, file, item)
+			if err := i.repo.AddItem(ctx, item); err != nil {
+				return err
+			}
+			return i.repo.UpdateJob(ctx, job)
+		})
+	}
+	if err != nil {
+		*job = progress
+		item.MediaID, item.Size = "", 0
+		if delErr := i.minio.DeleteFile(context.WithoutCancel(ctx), i.opts.Config.MinIO.Bucket, media.StoragePath); delErr != nil {
+			i.opts.Logger.WarnContext(ctx, "imported object not removed", "object", media.StoragePath, "error", delErr)
+		}
+	}
+	return err
+}
+
+// scan refuses infected files. A file the scanner could not check is
+// imported pending a scan, and one it refused as not servable, as an upload
+// would be.
+func (i *Importer) scan(ctx context.Context, media *models.Media) error {
+	object, err := i.minio.DownloadFile(ctx, i.opts.Config.MinIO.Bucket, media.StoragePath)
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+
+	result, err := i.scanner.Scan(ctx, object)
+	if errors.Is(err, models.ErrMediaScanFailed) {
+		i.opts.Logger.WarnContext(ctx, "file could not be scanned, import not served", "media_id", media.ID, "error", err)
+		media.State = models.MediaStateScanFailed
+		return nil
+	}
+	if err != nil {
+		i.opts.Logger.WarnContext(ctx, "malware scan failed, import left pending", "media_id", media.ID, "error", err)
+		media.State = models.MediaStatePendingScan
+		return nil
+	}
+	if !result.Clean {
+		return fmt.Errorf("malware found: %s", result.Signature)
+	}
+	return nil
+}
+
+// advance moves the job's cursor past file and counts its outcome.
+func advance(job *models.ImportJob, file *importFile, item *models.ImportItem) {
+	job.Cursor = file.key
+	job.UpdatedAt = time.Now()
+	if item.Error == "" {
+		job.Imported++
+		job.Bytes += item.Size
+	} else {
+		job.Failed++
+	}
+}
+
+// copy puts the file in place and fills in the content type and size of
+// media, returning its first version.
+func (i *Importer) copy(ctx context.Context, job *models.ImportJob, file *importFile, media *models.Media) (*models.MediaVersion, error) {
+	bucket := i.opts.Config.MinIO.Bucket
+	version := &models.MediaVersion{
+		ID:          uuid.New().String(),
+		MediaID:     media.ID,
+		StoragePath: media.StoragePath,
+		UploadedBy:  job.OwnerID,
+		CreatedAt:   media.CreatedAt,
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(file.key))
+	size := file.size
+
+	if job.Source == models.ImportSourceBucket {
+		if contentType == "" {
+			contentType = file.contentType
+		}
+		if contentType == "" {
+			info, err := i.minio.GetStatFile(ctx, job.Location, file.key)
+			if err != nil {
+				return nil, err
+			}
+			contentType = info.ContentType
+		}
+		if err := i.minio.CopyFile(ctx, job.Location, file.key, bucket, media.StoragePath); err != nil {
+			return nil, err
+		}
+	} else {
+		f, err := os.Open(filepath.Join(job.Location, filepath.FromSlash(file.key)))
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		reader := bufio.NewReader(f)
+		if contentType == "" {
+			head, _ := reader.Peek(512)
+			contentType = http.DetectContentType(head)
+		}
+
+		hash := sha256.New()
+		if err := i.minio.UploadFile(ctx, bucket, media.StoragePath, io.TeeReader(reader, hash), size, contentType); err != nil {
+			return nil, err
+		}
+		version.Checksum = hex.EncodeToString(hash.Sum(nil))
+	}
+
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	media.ContentType = contentType
+	media.Size = size
+	version.ContentType = contentType
+	version.Size = size
+
+	return version, nil
+}
+
+// record saves a failed item and the job's progress together, so the cursor
+// never runs ahead of the recorded outcomes.
+func (i *Importer) record(ctx context.Context, job *models.ImportJob, item *models.ImportItem) error {
+	return i.tx.WithinTx(context.WithoutCancel(ctx), func(ctx context.Context) error {
+		if err := i.repo.AddItem(ctx, item); err != nil {
+			return err
+		}
+		return i.repo.UpdateJob(ctx, job)
+	})
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/co1seam/ember-backend-media/config"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeImportRepo records items and job saves, noting whether each happened
+// inside a transaction.
+type fakeImportRepo struct {
+	ports.IImportRepo
+	items    []models.ImportItem
+	inTx     []bool
+	jobSaves int
+}
+
+func (r *fakeImportRepo) AddItem(ctx context.Context, item *models.ImportItem) error {
+	r.items = append(r.items, *item)
+	r.inTx = append(r.inTx, ctx.Value(txKey{}) != nil)
+	return nil
+}
+
+func (r *fakeImportRepo) UpdateJob(ctx context.Context, job *models.ImportJob) error {
+	r.jobSaves++
+	return nil
+}
+
+// contentScanner finds the EICAR marker in a stream.
+type contentScanner struct{}
+
+func (contentScanner) Scan(ctx context.Context, r io.Reader) (*models.ScanResult, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(string(body), "EICAR") {
+		return &models.ScanResult{Signature: "Eicar-Test-Signature"}, nil
+	}
+	return &models.ScanResult{Clean: true}, nil
+}
+
+type failingMediaRepo struct {
+	fakeMediaRepo
+}
+
+func (r *failingMediaRepo) Create(ctx context.Context, media *models.Media) error {
+	return errors.New("value too long for type character varying(50)")
+}
+
+func newTestImport(t *testing.T, files map[string]string, media ports.IMediaRepo) (*Importer, *fakeImportRepo, *fakeStore, *models.ImportJob) {
+	dir := t.TempDir()
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store := newFakeStore(t, nil)
+
+	opts := testOptions()
+	opts.Config = &config.Config{MinIO: config.MinIO{Bucket: "media"}}
+	repo := &fakeImportRepo{}
+	importer := NewImporter(repo, media, &fakeVersionRepo{}, &fakeAuditLog{}, fakeTx{}, store, contentScanner{}, opts)
+
+	job := &models.ImportJob{ID: "job", OwnerID: "o", Source: models.ImportSourceDir, Location: dir, State: models.ImportStateRunning}
+	return importer, repo, store, job
+}
+
+func TestImportScansFilesAndCommitsProgressWithMedia(t *testing.T) {
+	media := &fakeMediaRepo{}
+	importer, repo, store, job := newTestImport(t, map[string]string{
+		"a.txt": "hello",
+		"b.txt": "X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR",
+	}, media)
+
+	if err := importer.Run(context.Background(), job, nil); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if job.Imported != 1 || job.Failed != 1 || job.Cursor != "b.txt" || job.State != models.ImportStateCompleted {
+		t.Fatalf("job = %+v, want one imported and one failed", job)
+	}
+	if len(repo.items) != 2 || !repo.inTx[0] {
+		t.Fatalf("items = %+v, want the imported item saved in the import transaction", repo.items)
+	}
+	if repo.items[0].MediaID == "" || len(media.rows) != 1 {
+		t.Errorf("imported item = %+v, media rows = %d", repo.items[0], len(media.rows))
+	}
+	if !strings.Contains(repo.items[1].Error, "malware found") || repo.items[1].MediaID != "" {
+		t.Errorf("infected item = %+v, want a malware failure", repo.items[1])
+	}
+	if len(store.deleted) != 1 || !strings.HasSuffix(store.deleted[0], "/b.txt") {
+		t.Errorf("deleted %v, want the infected copy removed", store.deleted)
+	}
+
+	audit := importer.auditLog.(*fakeAuditLog).events
+	if len(audit) != 1 || audit[0].Action != models.AuditActionMediaImported || audit[0].MediaID != repo.items[0].MediaID {
+		t.Fatalf("audit events = %+v, want the imported media recorded", audit)
+	}
+	if audit[0].Actor != models.AuditActorSystem || audit[0].Changes["storage_path"].After == nil {
+		t.Errorf("audit event = %+v, want a system import with the new fields", audit[0])
+	}
+}
+
+func TestImportFailedCommitLeavesCountsAlone(t *testing.T) {
+	importer, repo, store, job := newTestImport(t, map[string]string{"a.txt": "hello"}, &failingMediaRepo{})
+
+	if err := importer.Run(context.Background(), job, nil); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if job.Imported != 0 || job.Failed != 1 || job.Bytes != 0 {
+		t.Errorf("job = %+v, want only the failure counted", job)
+	}
+	if len(repo.items) != 1 || repo.items[0].MediaID != "" || repo.items[0].Error == "" {
+		t.Errorf("items = %+v, want one failed item", repo.items)
+	}
+	if len(store.deleted) != 1 {
+		t.Errorf("deleted %v, want the copy removed", store.deleted)
+	}
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"github.com/minio/minio-go/v7"
+	"io"
+	"strings"
+	"time"
+)
+
+// Maintenance holds the offline jobs run from the admin CLI. With dryRun set
+// they only report what they would change.
+type Maintenance struct {
+	repo     ports.IMediaRepo
+	versions ports.IMediaVersionRepo
+	minio    ports.IMinio
+	opts     *models.Options
+}
+
+func NewMaintenance(repo ports.IMediaRepo, versions ports.IMediaVersionRepo, minio ports.IMinio, opts *models.Options) *Maintenance {
+	return &Maintenance{
+		repo:     repo,
+		versions: versions,
+		minio:    minio,
+		opts:     opts,
+	}
+}
+
+// Reconcile compares the bucket with the media table. Objects no row points
+// to are moved to the trash; rows whose object is gone are only reported.
+// Objects younger than minAge are left alone, since an upload stores its
+// object before the row that points to it.
+func (m *Maintenance) Reconcile(ctx context.Context, minAge time.Duration, dryRun bool) (*models.ReconcileReport, error) {
+	report := &models.ReconcileReport{}
+	bucket := m.opts.Config.MinIO.Bucket
+	cutoff := time.Now().Add(-minAge)
+
+	expected := make(map[string]string)
+	quarantined := make(map[string]bool)
+	err := m.walkMedia(ctx, m.repo.ListPage, func(media *models.Media) error {
+		report.Media++
+		switch {
+		case media.StoragePath == "":
+		case media.State != models.MediaStateQuarantined:
+			expected[media.StoragePath] = media.ID
+		default:
+			quarantined[media.StoragePath] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	afterID := ""
+	for {
+		page, err := m.versions.ListPage(ctx, afterID, models.MaintenanceBatchSize)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, version := range page {
+			if !quarantined[version.StoragePath] {
+				expected[version.StoragePath] = version.MediaID
+			}
+		}
+
+		if len(page) < models.MaintenanceBatchSize {
+			break
+		}
+		afterID = page[len(page)-1].ID
+	}
+
+	found := make(map[string]bool)
+	err = m.minio.WalkFiles(ctx, bucket, "", "", func(object minio.ObjectInfo) error {
+		if m.reserved(object.Key) {
+			return nil
+		}
+
+		report.Objects++
+		found[object.Key] = true
+		if _, ok := expected[object.Key]; ok {
+			return nil
+		}
+		if object.LastModified.After(cutoff) {
+			report.Recent++
+			return nil
+		}
+
+		report.OrphanedObjects = append(report.OrphanedObjects, object.Key)
+		if dryRun {
+			return nil
+		}
+
+		if err := m.minio.CopyFile(ctx, bucket, object.Key, bucket, models.TrashPrefix+object.Key); err != nil {
+			return err
+		}
+		if err := m.minio.DeleteFile(ctx, bucket, object.Key); err != nil {
+			return err
+		}
+		report.Trashed++
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	for path, id := range expected {
+		if !found[path] {
+			report.MissingObjects = append(report.MissingObjects, id)
+		}
+	}
+
+	return report, nil
+}
+
+// BackfillSizes records the object size of media uploaded before sizes were
+// stored.
+func (m *Maintenance) BackfillSizes(ctx context.Context, dryRun bool) (*models.BackfillReport, error) {
+	report := &models.BackfillReport{}
+	bucket := m.opts.Config.MinIO.Bucket
+
+	err := m.walkMedia(ctx, m.repo.ListMissingSize, func(media *models.Media) error {
+		report.Checked++
+
+		info, err := m.minio.GetStatFile(ctx, bucket, media.StoragePath)
+		if err != nil {
+			m.opts.Logger.WarnContext(ctx, "object size unavailable", "media_id", media.ID, "error", err)
+			report.Failed++
+			return nil
+		}
+
+		if !dryRun {
+			if err := m.repo.SetSize(ctx, media.ID, info.Size); err != nil {
+				return err
+			}
+		}
+		report.Updated++
+		return nil
+	})
+
+	return report, err
+}
+
+// PurgeTrash removes trashed objects older than olderThan for good.
+func (m *Maintenance) PurgeTrash(ctx context.Context, olderThan time.Duration, dryRun bool) (*models.PurgeReport, error) {
+	report := &models.PurgeReport{}
+	bucket := m.opts.Config.MinIO.Bucket
+	cutoff := time.Now().Add(-olderThan)
+
+	err := m.minio.WalkFiles(ctx, bucket, models.TrashPrefix, "", func(object minio.ObjectInfo) error {
+		report.Objects++
+		if object.LastModified.After(cutoff) {
+			return nil
+		}
+
+		if !dryRun {
+			if err := m.minio.DeleteFile(ctx, bucket, object.Key); err != nil {
+				return err
+			}
+		}
+		report.Purged++
+		report.Bytes += object.Size
+		return nil
+	})
+
+	return report, err
+}
+
+// Export writes every media row to w as JSON lines and returns the count.
+func (m *Maintenance) Export(ctx context.Context, w io.Writer) (int, error) {
+	encoder := json.NewEncoder(w)
+
+	count := 0
+	err := m.walkMedia(ctx, m.repo.ListPage, func(media *models.Media) error {
+		count++
+		return encoder.Encode(media)
+	})
+
+	return count, err
+}
+
+func (m *Maintenance) walkMedia(ctx context.Context, list func(ctx context.Context, afterID string, limit int) ([]*models.Media, error), fn func(*models.Media) error) error {
+	afterID := ""
+	for {
+		page, err := list(ctx, afterID, models.MaintenanceBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, media := range page {
+			if err := fn(media); err != nil {
+				return err
+			}
+		}
+
+		if len(page) < models.MaintenanceBatchSize {
+			return nil
+		}
+		afterID = page[len(page)-1].ID
+	}
+}
+
+// reserved reports keys under the trash, export and in-bucket quarantine
+// prefixes, which media rows never point to directly.
+func (m *Maintenance) reserved(key string) bool {
+	if strings.HasPrefix(key, models.TrashPrefix) || strings.HasPrefix(key, models.ExportPrefix) {
+		return true
+	}
+
+	cfg := m.opts.Config.MinIO
+	if cfg.QuarantineBucket != "" && cfg.QuarantineBucket != cfg.Bucket {
+		return false
+	}
+
+	prefix := cfg.QuarantinePrefix
+	if prefix == "" {
+		prefix = models.DefaultQuarantinePrefix
+	}
+	return strings.HasPrefix(key, prefix)
+}
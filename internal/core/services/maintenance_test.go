@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"github.com/co1seam/ember-backend-media/config"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/minio/minio-go/v7"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+)
+
+func (r *fakeMediaRepo) ListPage(ctx context.Context, afterID string, limit int) ([]*models.Media, error) {
+	var page []*models.Media
+	for _, row := range r.rows {
+		if row.ID > afterID {
+			row := row
+			page = append(page, &row)
+		}
+	}
+	sort.Slice(page, func(i, j int) bool { return page[i].ID < page[j].ID })
+	return page[:min(limit, len(page))], nil
+}
+
+func (r *fakeVersionRepo) ListPage(ctx context.Context, afterID string, limit int) ([]*models.MediaVersion, error) {
+	var page []*models.MediaVersion
+	for _, version := range r.versions {
+		if version.ID > afterID {
+			page = append(page, version)
+		}
+	}
+	return page[:min(limit, len(page))], nil
+}
+
+func TestReconcileLeavesRecentObjectsAlone(t *testing.T) {
+	old := time.Now().Add(-2 * time.Hour)
+	repo := &fakeMediaRepo{rows: map[string]models.Media{
+		"1": {ID: "1", StoragePath: "o/1/v1/a.jpg", State: models.MediaStateActive},
+		"2": {ID: "2", StoragePath: "o/2/v1/b.jpg", State: models.MediaStatePendingScan},
+	}}
+	versions := &fakeVersionRepo{versions: []*models.MediaVersion{
+		{ID: "v0", MediaID: "1", StoragePath: "o/1/v0/old.jpg"},
+	}}
+	bucket := newFakeStore(t, nil)
+	bucket.listing = []minio.ObjectInfo{
+		{Key: "o/1/v0/old.jpg", LastModified: old},
+		{Key: "o/1/v1/a.jpg", LastModified: old},
+		{Key: "o/2/v1/b.jpg", LastModified: old},
+		{Key: "o/3/v1/deleted.jpg", LastModified: old},
+		{Key: "o/4/v1/uploading.jpg", LastModified: time.Now()},
+		{Key: "trash/o/5/v1/c.jpg", LastModified: old},
+	}
+	opts := testOptions()
+	opts.Config = &config.Config{MinIO: config.MinIO{Bucket: "media"}}
+	maintenance := NewMaintenance(repo, versions, bucket, opts)
+
+	report, err := maintenance.Reconcile(context.Background(), time.Hour, false)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	if !slices.Equal(report.OrphanedObjects, []string{"o/3/v1/deleted.jpg"}) {
+		t.Errorf("orphaned = %v, want only the deleted media's object", report.OrphanedObjects)
+	}
+	if report.Recent != 1 || report.Trashed != 1 || len(report.MissingObjects) != 0 {
+		t.Errorf("report = %+v", report)
+	}
+	if !slices.Equal(bucket.copied, []string{"o/3/v1/deleted.jpg -> trash/o/3/v1/deleted.jpg"}) {
+		t.Errorf("trashed %v", bucket.copied)
+	}
+}
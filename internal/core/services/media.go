@@ -2,32 +2,82 @@ package services
 
 import (
 	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"github.com/co1seam/ember-backend-media/internal/core/auth"
+	"github.com/co1seam/ember-backend-media/internal/core/errs"
+	"github.com/co1seam/ember-backend-media/internal/core/jobs"
 	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/core/mp4"
 	"github.com/co1seam/ember-backend-media/internal/ports"
 	"github.com/google/uuid"
 	"github.com/minio/minio-go/v7"
+	"go.opentelemetry.io/otel/attribute"
 	"io"
 	"mime"
+	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 )
 
 type Media struct {
-	repo  ports.IMediaRepo
-	minio ports.IMinio
-	opts  *models.Options
+	repo     ports.IMediaRepo
+	versions ports.IMediaVersionRepo
+	tags     ports.ITagRepo
+	outbox   ports.IOutboxRepo
+	webhooks ports.IWebhookRepo
+	auditLog ports.IAuditRepo
+	tx       ports.ITransactor
+	minio    ports.IMinio
+	scanner  ports.IScanner
+	jobs     *jobs.Queue
+	opts     *models.Options
 }
 
-func NewMedia(repo ports.IMediaRepo, minio ports.IMinio, opts *models.Options) *Media {
+func NewMedia(repo ports.IMediaRepo, versions ports.IMediaVersionRepo, tags ports.ITagRepo, outbox ports.IOutboxRepo, webhooks ports.IWebhookRepo, auditLog ports.IAuditRepo, tx ports.ITransactor, minio ports.IMinio, scanner ports.IScanner, jobs *jobs.Queue, opts *models.Options) *Media {
 	return &Media{
-		repo:  repo,
-		minio: minio,
-		opts:  opts,
+		repo:     repo,
+		versions: versions,
+		tags:     tags,
+		outbox:   outbox,
+		webhooks: webhooks,
+		auditLog: auditLog,
+		tx:       tx,
+		minio:    minio,
+		scanner:  scanner,
+		jobs:     jobs,
+		opts:     opts,
 	}
 }
 
-func (m *Media) CreateMedia(ctx context.Context, req *models.CreateMediaRequest) (*models.Media, error) {
+func (m *Media) CreateMedia(ctx context.Context, req *models.CreateMediaRequest) (_ *models.Media, err error) {
+	ctx, span := startSpan(ctx, "services.Media.CreateMedia", attribute.String("owner.id", req.OwnerID))
+	defer finish(span, &err)
+
+	if req.OwnerID == "" {
+		return nil, errs.InvalidArgument("owner id is required")
+	}
+
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) > models.MaxTagsPerMedia {
+		return nil, errs.InvalidArgument(fmt.Sprintf("media can have at most %d tags", models.MaxTagsPerMedia))
+	}
+	if err := validateMetadata(req.Metadata); err != nil {
+		return nil, err
+	}
+	if req.Metadata == nil {
+		req.Metadata = map[string]string{}
+	}
+
+	now := time.Now()
 	media := &models.Media{
 		ID:          uuid.New().String(),
 		Title:       req.Title,
@@ -35,10 +85,29 @@ func (m *Media) CreateMedia(ctx context.Context, req *models.CreateMediaRequest)
 		ContentType: req.ContentType,
 		StoragePath: "",
 		OwnerID:     req.OwnerID,
-		CreatedAt:   time.Now(),
+		State:       models.MediaStateActive,
+		Tags:        tags,
+		Metadata:    req.Metadata,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Revision:    1,
 	}
 
-	if err := m.repo.Create(ctx, media); err != nil {
+	err = m.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := m.repo.Create(ctx, media); err != nil {
+			return err
+		}
+		if len(tags) > 0 {
+			if err := m.tags.Add(ctx, media.ID, tags); err != nil {
+				return err
+			}
+		}
+		if err := m.audit(ctx, models.EventMediaCreated, nil, media); err != nil {
+			return err
+		}
+		return m.publish(ctx, models.EventMediaCreated, media)
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -53,87 +122,728 @@ func (m *Media) CreateMedia(ctx context.Context, req *models.CreateMediaRequest)
 	return media, nil
 }
 
-func (m *Media) GetMedia(ctx context.Context, id string) (*models.Media, error) {
-	media, err := m.repo.GetByID(ctx, id)
+func (m *Media) GetMedia(ctx context.Context, id string) (_ *models.Media, err error) {
+	ctx, span := startSpan(ctx, "services.Media.GetMedia", attribute.String("media.id", id))
+	defer finish(span, &err)
+
+	media, err := m.get(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	downloadURL, err := m.minio.GenerateDownloadURL(ctx, media.StoragePath, 24*time.Hour)
-	if err != nil {
+	if err := m.sign(ctx, media); err != nil {
 		return nil, err
 	}
-	media.URL = downloadURL
 
 	return media, nil
 }
 
-func (m *Media) UpdateMedia(ctx context.Context, req *models.UpdateMediaRequest) (*models.Media, error) {
-	media, err := m.repo.GetByID(ctx, req.ID)
+// sign sets the presigned download URL of media. Media that may not be
+// served gets no URL.
+func (m *Media) sign(ctx context.Context, media *models.Media) error {
+	if media.ServeError() != nil {
+		return nil
+	}
+
+	downloadURL, err := m.minio.GenerateDownloadURL(ctx, media.StoragePath, m.opts.Settings.Load().Media.URLExpiry)
+	if err != nil {
+		return err
+	}
+	media.URL = downloadURL
+
+	return nil
+}
+
+func (m *Media) UpdateMedia(ctx context.Context, req *models.UpdateMediaRequest) (_ *models.Media, err error) {
+	ctx, span := startSpan(ctx, "services.Media.UpdateMedia", attribute.String("media.id", req.ID))
+	defer finish(span, &err)
+
+	mask, err := updateMask(req.UpdateMask, models.MediaFieldTitle, models.MediaFieldDescription)
 	if err != nil {
 		return nil, err
 	}
 
-	media.Title = req.Title
-	media.Description = req.Description
+	media, err := m.get(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Revision != 0 && req.Revision != media.Revision {
+		return nil, models.ErrMediaModified
+	}
+	if len(mask) == 0 {
+		return media, nil
+	}
 
-	if err := m.repo.Update(ctx, media); err != nil {
+	before := snapshot(media)
+	for _, field := range mask {
+		switch field {
+		case models.MediaFieldTitle:
+			media.Title = req.Title
+		case models.MediaFieldDescription:
+			media.Description = req.Description
+		}
+	}
+
+	err = m.tx.WithinTx(ctx, func(ctx context.Context) error {
+		err := m.repo.UpdateFields(ctx, media, mask, media.Revision)
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrMediaModified
+		}
+		if err != nil {
+			return err
+		}
+		if err := m.audit(ctx, models.EventMediaUpdated, before, media); err != nil {
+			return err
+		}
+		return m.publish(ctx, models.EventMediaUpdated, media)
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return media, nil
 }
 
-func (m *Media) DeleteMedia(ctx context.Context, id string) error {
-	return m.repo.Delete(ctx, id)
+// updateMask validates the paths of an update mask against the fields the
+// caller may change and de-duplicates them.
+func updateMask(paths []string, fields ...string) ([]string, error) {
+	seen := make(map[string]bool, len(paths))
+	mask := make([]string, 0, len(paths))
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		if !slices.Contains(fields, path) {
+			return nil, errs.InvalidArgument(fmt.Sprintf("unknown update mask path %q", path))
+		}
+		if !seen[path] {
+			seen[path] = true
+			mask = append(mask, path)
+		}
+	}
+
+	return mask, nil
 }
 
-func (m *Media) ListMedia(ctx context.Context, ownerID string, limit int) ([]*models.Media, error) {
-	return m.repo.ListByOwner(ctx, ownerID, limit)
+func (m *Media) DeleteMedia(ctx context.Context, id string) (err error) {
+	ctx, span := startSpan(ctx, "services.Media.DeleteMedia", attribute.String("media.id", id))
+	defer finish(span, &err)
+
+	media, err := m.get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return m.delete(ctx, media)
 }
 
-func (m *Media) UploadFile(ctx context.Context, fileID string, fileName string, size int64, stream io.Reader) (string, error) {
+func (m *Media) ListMedia(ctx context.Context, req *models.ListMediaRequest) (_ []*models.Media, err error) {
+	ctx, span := startSpan(ctx, "services.Media.ListMedia", attribute.String("owner.id", req.OwnerID))
+	defer finish(span, &err)
+
+	if req.OwnerID == "" {
+		return nil, errs.InvalidArgument("owner id is required")
+	}
+
+	if req.Tags, err = normalizeTags(req.Tags); err != nil {
+		return nil, err
+	}
+	if req.Limit <= 0 {
+		req.Limit = models.DefaultListLimit
+	}
+
+	return m.repo.ListByOwner(ctx, req)
+}
+
+func (m *Media) SearchMedia(ctx context.Context, req *models.SearchMediaRequest) (_ []*models.MediaSearchHit, err error) {
+	ctx, span := startSpan(ctx, "services.Media.SearchMedia", attribute.String("owner.id", req.OwnerID))
+	defer finish(span, &err)
+
+	if req.OwnerID == "" {
+		return nil, errs.InvalidArgument("owner id is required")
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		return nil, errs.InvalidArgument("search query is required")
+	}
+	if req.Offset < 0 {
+		return nil, errs.InvalidArgument("offset must not be negative")
+	}
+
+	if req.Limit <= 0 {
+		req.Limit = models.DefaultSearchLimit
+	}
+	req.Limit = min(req.Limit, models.MaxSearchLimit)
+
+	return m.repo.Search(ctx, req)
+}
+
+func (m *Media) UploadFile(ctx context.Context, fileID string, fileName string, size int64, stream io.Reader) (_ string, err error) {
 	var media *models.Media
-	var err error
+	ctx, span := startSpan(ctx, "services.Media.UploadFile", attribute.String("media.id", fileID), attribute.Int64("file.size", size))
+	defer finish(span, &err)
 
 	if fileID == "" {
-		return "", err
+		return "", errs.InvalidArgument("file id is required")
+	}
+	if limit := m.opts.Settings.Load().Media.MaxFileSize; size > limit {
+		return "", errs.InvalidArgument(fmt.Sprintf("file exceeds the %d byte limit", limit))
 	}
 
-	media, err = m.repo.GetByID(ctx, fileID)
+	media, err = m.get(ctx, fileID)
 	if err != nil {
 		return "", err
 	}
 
-	objectPath := fmt.Sprintf("%s/%s/%s", media.OwnerID, media.ID, fileName)
+	version := &models.MediaVersion{
+		ID:         uuid.New().String(),
+		MediaID:    media.ID,
+		Size:       size,
+		UploadedBy: uploader(ctx, media),
+		CreatedAt:  time.Now(),
+	}
 
-	media.StoragePath = objectPath
-	media.URL = fmt.Sprintf("%s%s%s", m.opts.Config.MinIO.Endpoint, m.opts.Config.MinIO.Bucket, objectPath)
+	// Every version gets its own object, so an upload never overwrites the
+	// object an earlier version points to.
+	objectPath := fmt.Sprintf("%s/%s/%s/%s", media.OwnerID, media.ID, version.ID, fileName)
+	version.StoragePath = objectPath
 
 	contentType := mime.TypeByExtension(filepath.Ext(fileName))
 	if contentType == "" {
 		contentType = "application/octet-stream"
 	}
+	version.ContentType = contentType
 
-	media.ContentType = contentType
-
-	if err := m.minio.UploadFile(ctx, m.opts.Config.MinIO.Bucket, objectPath, stream, size, contentType); err != nil {
+	hash := sha256.New()
+	if err := m.minio.UploadFile(ctx, m.opts.Config.MinIO.Bucket, objectPath, io.TeeReader(stream, hash), size, contentType); err != nil {
 		return "", err
 	}
+	version.Checksum = hex.EncodeToString(hash.Sum(nil))
+
+	// A scanner outage is no verdict: the upload is kept but not served
+	// until a rescan gets one. A file the scanner refuses is never served.
+	state := models.MediaStateActive
+	result, err := m.scanObject(ctx, objectPath)
+	switch {
+	case errors.Is(err, models.ErrMediaScanFailed):
+		m.opts.Logger.WarnContext(ctx, "file could not be scanned, upload not served", "media_id", media.ID, "error", err)
+		result = &models.ScanResult{Clean: true}
+		state = models.MediaStateScanFailed
+	case err != nil:
+		m.opts.Logger.ErrorContext(ctx, "malware scan failed, upload left pending", "media_id", media.ID, "error", err)
+		result = &models.ScanResult{Clean: true}
+		state = models.MediaStatePendingScan
+	}
+
+	upload := func(media *models.Media) error {
+		media.StoragePath = objectPath
+		media.URL = fmt.Sprintf("%s%s%s", m.opts.Config.MinIO.Endpoint, m.opts.Config.MinIO.Bucket, objectPath)
+		media.ContentType = contentType
+		media.StreamingOptimized = false
+		media.Size = size
+		media.Version = version.Version
+		media.State = state
+		media.QuarantineReason = ""
+		return nil
+	}
 
-	if err := m.repo.Update(ctx, media); err != nil {
+	err = m.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := m.versions.Create(ctx, version); err != nil {
+			return err
+		}
+		if !result.Clean {
+			return nil
+		}
+		media, err = m.change(ctx, fileID, models.EventMediaUploaded, upload)
+		return err
+	})
+	if err != nil {
 		return "", err
 	}
 
+	if !result.Clean {
+		if err := m.quarantine(ctx, fileID, objectPath, result.Signature, upload); err != nil {
+			return "", err
+		}
+		return "", models.ErrMediaQuarantined
+	}
+
+	m.pruneVersions(ctx, media)
+	m.scheduleFaststart(media)
+
 	return objectPath, nil
 }
 
-func (m *Media) DownloadFile(ctx context.Context, fileID string) (*minio.Object, error) {
-	return m.minio.DownloadFile(ctx, m.opts.Config.MinIO.Bucket, fileID)
+func (m *Media) ListQuarantined(ctx context.Context, limit int) (_ []*models.Media, err error) {
+	ctx, span := startSpan(ctx, "services.Media.ListQuarantined")
+	defer finish(span, &err)
+
+	return m.repo.ListByState(ctx, models.MediaStateQuarantined, limit)
+}
+
+func (m *Media) ReleaseQuarantined(ctx context.Context, id string) (_ *models.Media, err error) {
+	ctx, span := startSpan(ctx, "services.Media.ReleaseQuarantined", attribute.String("media.id", id))
+	defer finish(span, &err)
+
+	media, err := m.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if media.State != models.MediaStateQuarantined {
+		return nil, models.ErrMediaNotQuarantined
+	}
+	storagePath := media.StoragePath
+
+	bucket, objectPath := quarantineLocation(m.opts, storagePath)
+	if err := m.minio.CopyFile(ctx, bucket, objectPath, m.opts.Config.MinIO.Bucket, storagePath); err != nil {
+		return nil, err
+	}
+
+	media, err = m.change(ctx, id, models.EventMediaUpdated, func(media *models.Media) error {
+		if media.State != models.MediaStateQuarantined {
+			return models.ErrMediaNotQuarantined
+		}
+		if media.StoragePath != storagePath {
+			return models.ErrMediaModified
+		}
+		media.State = models.MediaStateActive
+		media.QuarantineReason = ""
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.minio.DeleteFile(ctx, bucket, objectPath); err != nil {
+		m.opts.Logger.WarnContext(ctx, "quarantined object not removed", "media_id", media.ID, "error", err)
+	}
+
+	m.scheduleFaststart(media)
+
+	return media, nil
 }
 
-func (m *Media) GetFileURL(ctx context.Context, objectName string, expiry time.Duration) (string, error) {
-	return m.minio.GenerateDownloadURL(ctx, objectName, expiry)
+func (m *Media) PurgeQuarantined(ctx context.Context, id string) (err error) {
+	ctx, span := startSpan(ctx, "services.Media.PurgeQuarantined", attribute.String("media.id", id))
+	defer finish(span, &err)
+
+	media, err := m.get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if media.State != models.MediaStateQuarantined {
+		return models.ErrMediaNotQuarantined
+	}
+
+	bucket, objectPath := quarantineLocation(m.opts, media.StoragePath)
+	if err := m.minio.DeleteFile(ctx, bucket, objectPath); err != nil {
+		return err
+	}
+
+	return m.delete(ctx, media)
+}
+
+func (m *Media) get(ctx context.Context, id string) (*models.Media, error) {
+	if id == "" {
+		return nil, errs.InvalidArgument("media id is required")
+	}
+
+	media, err := m.repo.GetByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ErrMediaNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return media, nil
+}
+
+// scanObject scans a stored object. Scanner failures other than a refusal of
+// this file wrap errScannerUnavailable.
+func (m *Media) scanObject(ctx context.Context, objectPath string) (*models.ScanResult, error) {
+	object, err := m.minio.DownloadFile(ctx, m.opts.Config.MinIO.Bucket, objectPath)
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	result, err := m.scanner.Scan(ctx, object)
+	if err != nil && !errors.Is(err, models.ErrMediaScanFailed) {
+		return nil, fmt.Errorf("%w: %w", errScannerUnavailable, err)
+	}
+	return result, err
+}
+
+// quarantine moves the object at objectPath aside and marks the media
+// quarantined. apply carries the changes of the upload being quarantined.
+func (m *Media) quarantine(ctx context.Context, id, objectPath, reason string, apply func(media *models.Media) error) error {
+	bucket, quarantinePath := quarantineLocation(m.opts, objectPath)
+	if err := m.minio.CopyFile(ctx, m.opts.Config.MinIO.Bucket, objectPath, bucket, quarantinePath); err != nil {
+		return err
+	}
+
+	if err := m.minio.DeleteFile(ctx, m.opts.Config.MinIO.Bucket, objectPath); err != nil {
+		return err
+	}
+
+	media, err := m.change(ctx, id, models.EventMediaQuarantined, func(media *models.Media) error {
+		if err := apply(media); err != nil {
+			return err
+		}
+		media.State = models.MediaStateQuarantined
+		media.QuarantineReason = reason
+		media.URL = ""
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	m.opts.Logger.WarnContext(ctx, "media quarantined", "media_id", media.ID, "owner_id", media.OwnerID, "reason", reason)
+
+	return nil
+}
+
+func quarantineLocation(opts *models.Options, storagePath string) (string, string) {
+	bucket := opts.Config.MinIO.QuarantineBucket
+	if bucket == "" {
+		bucket = opts.Config.MinIO.Bucket
+	}
+
+	prefix := opts.Config.MinIO.QuarantinePrefix
+	if prefix == "" && bucket == opts.Config.MinIO.Bucket {
+		prefix = models.DefaultQuarantinePrefix
+	}
+
+	return bucket, prefix + storagePath
+}
+
+func (m *Media) scheduleFaststart(media *models.Media) {
+	if !isFaststartCandidate(media.ContentType) {
+		return
+	}
+
+	mediaID := media.ID
+	if err := m.jobs.Enqueue("faststart:"+mediaID, func(ctx context.Context) error {
+		return m.optimizeStreaming(ctx, mediaID)
+	}); err != nil {
+		m.opts.Logger.Warn("faststart job not scheduled", "media_id", mediaID, "error", err)
+	}
+}
+
+func (m *Media) optimizeStreaming(ctx context.Context, mediaID string) (err error) {
+	ctx, span := startSpan(ctx, "services.Media.optimizeStreaming", attribute.String("media.id", mediaID))
+	defer finish(span, &err)
+
+	media, err := m.get(ctx, mediaID)
+	if err != nil {
+		return err
+	}
+
+	if media.State != models.MediaStateActive {
+		return nil
+	}
+	storagePath := media.StoragePath
+
+	object, err := m.minio.DownloadFile(ctx, m.opts.Config.MinIO.Bucket, storagePath)
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+
+	info, err := object.Stat()
+	if err != nil {
+		return err
+	}
+
+	tempFile, err := os.CreateTemp("", "faststart-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+	defer tempFile.Close()
+
+	size := info.Size
+	err = mp4.Faststart(object, info.Size, tempFile)
+	switch {
+	case errors.Is(err, mp4.ErrAlreadyFaststart):
+	case err != nil:
+		return fmt.Errorf("faststart remux: %w", err)
+	default:
+		remuxed, err := tempFile.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		if _, err := tempFile.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		hash := sha256.New()
+		if err := m.minio.UploadFile(ctx, m.opts.Config.MinIO.Bucket, storagePath, io.TeeReader(tempFile, hash), remuxed, media.ContentType); err != nil {
+			return err
+		}
+		size = remuxed
+
+		if media.Version > 0 {
+			if err := m.versions.UpdateObject(ctx, media.ID, media.Version, remuxed, hex.EncodeToString(hash.Sum(nil))); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Only the flag and size are written, so concurrent edits are kept. A
+	// file uploaded or rolled back to in the meantime gets its own job.
+	return m.tx.WithinTx(ctx, func(ctx context.Context) error {
+		processed, err := m.repo.MarkStreamingOptimized(ctx, mediaID, storagePath, size)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		before := snapshot(processed)
+		before.Size = media.Size
+		before.StreamingOptimized = false
+		if err := m.audit(ctx, models.EventMediaProcessed, before, processed); err != nil {
+			return err
+		}
+		return m.publish(ctx, models.EventMediaProcessed, processed)
+	})
+}
+
+// errFileReplaced stops a change meant for a file the media no longer
+// points to.
+var errFileReplaced = errors.New("media file replaced")
+
+// errScannerUnavailable marks scan errors that are about the scanner rather
+// than the file, which are worth retrying later.
+var errScannerUnavailable = errors.New("malware scanner unavailable")
+
+// change applies fn to the stored media and writes it, reading and
+// applying again when another update won the race, so that file changes
+// never overwrite each other or an edit of the title or description.
+func (m *Media) change(ctx context.Context, id, eventType string, fn func(media *models.Media) error) (*models.Media, error) {
+	for attempt := 1; ; attempt++ {
+		media, err := m.get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		before := snapshot(media)
+
+		if err := fn(media); err != nil {
+			return nil, err
+		}
+
+		err = m.update(ctx, eventType, before, media)
+		if errors.Is(err, models.ErrMediaModified) && attempt < models.MediaUpdateAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return media, nil
+	}
+}
+
+// update writes media as long as it is still at its revision.
+func (m *Media) update(ctx context.Context, eventType string, before, media *models.Media) error {
+	return m.tx.WithinTx(ctx, func(ctx context.Context) error {
+		err := m.repo.Update(ctx, media)
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrMediaModified
+		}
+		if err != nil {
+			return err
+		}
+		if err := m.audit(ctx, eventType, before, media); err != nil {
+			return err
+		}
+		return m.publish(ctx, eventType, media)
+	})
+}
+
+func (m *Media) delete(ctx context.Context, media *models.Media) error {
+	return m.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := m.repo.Delete(ctx, media.ID); err != nil {
+			return err
+		}
+		if err := m.audit(ctx, models.EventMediaDeleted, media, nil); err != nil {
+			return err
+		}
+		return m.publish(ctx, models.EventMediaDeleted, media)
+	})
+}
+
+// pruneVersions drops the oldest versions beyond MEDIA_MAX_VERSIONS. The
+// current version is always kept, even after a rollback made it an old one.
+func (m *Media) pruneVersions(ctx context.Context, media *models.Media) {
+	limit := m.opts.Settings.Load().Media.MaxVersions
+	if limit <= 0 {
+		return
+	}
+
+	versions, err := m.versions.List(ctx, media.ID)
+	if err != nil {
+		m.opts.Logger.WarnContext(ctx, "versions not pruned", "media_id", media.ID, "error", err)
+		return
+	}
+
+	kept := 1
+	for _, version := range versions {
+		if version.Version == media.Version {
+			continue
+		}
+		if kept < limit {
+			kept++
+			continue
+		}
+
+		if err := m.versions.Delete(ctx, media.ID, version.Version); err != nil {
+			m.opts.Logger.WarnContext(ctx, "version not pruned", "media_id", media.ID, "version", version.Version, "error", err)
+			continue
+		}
+		if err := m.minio.DeleteFile(ctx, m.opts.Config.MinIO.Bucket, version.StoragePath); err != nil {
+			m.opts.Logger.WarnContext(ctx, "pruned version object not removed", "media_id", media.ID, "version", version.Version, "error", err)
+		}
+	}
+}
+
+func (m *Media) ListVersions(ctx context.Context, id string) (_ []*models.MediaVersion, err error) {
+	ctx, span := startSpan(ctx, "services.Media.ListVersions", attribute.String("media.id", id))
+	defer finish(span, &err)
+
+	media, err := m.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.versions.List(ctx, media.ID)
+}
+
+func (m *Media) DownloadVersion(ctx context.Context, id string, number int) (_ *minio.Object, _ *models.MediaVersion, err error) {
+	ctx, span := startSpan(ctx, "services.Media.DownloadVersion", attribute.String("media.id", id), attribute.Int64("media.version", int64(number)))
+	defer finish(span, &err)
+
+	media, version, err := m.getVersion(ctx, id, number)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := media.ServeError(); err != nil {
+		return nil, nil, err
+	}
+
+	object, err := m.minio.DownloadFile(ctx, m.opts.Config.MinIO.Bucket, version.StoragePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return object, version, nil
+}
+
+// RollbackVersion makes an earlier version current again. The version list
+// is left as is, so rolling forward is another rollback.
+func (m *Media) RollbackVersion(ctx context.Context, id string, number int) (_ *models.Media, err error) {
+	ctx, span := startSpan(ctx, "services.Media.RollbackVersion", attribute.String("media.id", id), attribute.Int64("media.version", int64(number)))
+	defer finish(span, &err)
+
+	media, version, err := m.getVersion(ctx, id, number)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := media.ServeError(); err != nil {
+		return nil, err
+	}
+
+	media, err = m.change(ctx, media.ID, models.EventMediaRolledBack, func(media *models.Media) error {
+		if err := media.ServeError(); err != nil {
+			return err
+		}
+		media.Version = version.Version
+		media.StoragePath = version.StoragePath
+		media.ContentType = version.ContentType
+		media.Size = version.Size
+		media.StreamingOptimized = false
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m.scheduleFaststart(media)
+
+	return media, nil
+}
+
+func (m *Media) getVersion(ctx context.Context, id string, number int) (*models.Media, *models.MediaVersion, error) {
+	media, err := m.get(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	version, err := m.versions.Get(ctx, media.ID, number)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, models.ErrVersionNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return media, version, nil
+}
+
+// uploader names who stored a version: the verified client when there is one,
+// the owner otherwise.
+func uploader(ctx context.Context, media *models.Media) string {
+	if identity, ok := auth.FromContext(ctx); ok && identity.Subject != "" {
+		return identity.Subject
+	}
+	return media.OwnerID
+}
+
+func (m *Media) publish(ctx context.Context, eventType string, media *models.Media) error {
+	event, err := newEvent(eventType, media)
+	if err != nil {
+		return err
+	}
+
+	if err := m.outbox.Add(ctx, event); err != nil {
+		return err
+	}
+	return m.webhooks.EnqueueDeliveries(ctx, event)
+}
+
+func (m *Media) DownloadFile(ctx context.Context, fileID string) (_ *minio.Object, err error) {
+	ctx, span := startSpan(ctx, "services.Media.DownloadFile", attribute.String("media.id", fileID))
+	defer finish(span, &err)
+
+	media, err := m.get(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := media.ServeError(); err != nil {
+		return nil, err
+	}
+
+	return m.minio.DownloadFile(ctx, m.opts.Config.MinIO.Bucket, media.StoragePath)
+}
+
+// GetFileURL presigns the current file of media, unless it may not be
+// served.
+func (m *Media) GetFileURL(ctx context.Context, id string, expiry time.Duration) (_ string, err error) {
+	ctx, span := startSpan(ctx, "services.Media.GetFileURL", attribute.String("media.id", id))
+	defer finish(span, &err)
+
+	media, err := m.get(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if err := media.ServeError(); err != nil {
+		return "", err
+	}
+
+	return m.minio.GenerateDownloadURL(ctx, media.StoragePath, expiry)
 }
 
 func (m *Media) GetStatFile(ctx context.Context, objectName string) (*minio.ObjectInfo, error) {
@@ -144,6 +854,15 @@ func (m *Media) DownloadFileRange(ctx context.Context, objectName string, start,
 	return m.minio.DownloadFileRange(ctx, m.opts.Config.MinIO.Bucket, objectName, start, end)
 }
 
+func isFaststartCandidate(contentType string) bool {
+	switch contentType {
+	case "video/mp4", "video/quicktime", "video/x-m4v", "audio/mp4":
+		return true
+	default:
+		return false
+	}
+}
+
 func getFileExtension(contentType string) string {
 	switch contentType {
 	case "video/mp4":
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"testing"
+)
+
+// fakeMediaRepo keeps one row per media and checks revisions the way the
+// UPDATE statement does. race, when set, runs before each Update as if
+// another request had just committed.
+type fakeMediaRepo struct {
+	ports.IMediaRepo
+	rows    map[string]models.Media
+	race    func(row *models.Media)
+	updates int
+	lookups int
+	listed  *models.ListMediaRequest
+}
+
+func (r *fakeMediaRepo) GetByID(ctx context.Context, id string) (*models.Media, error) {
+	row, ok := r.rows[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &row, nil
+}
+
+func (r *fakeMediaRepo) Update(ctx context.Context, media *models.Media) error {
+	r.updates++
+	row := r.rows[media.ID]
+	if r.race != nil {
+		r.race(&row)
+		row.Revision++
+		r.rows[media.ID] = row
+	}
+	if row.Revision != media.Revision {
+		return sql.ErrNoRows
+	}
+
+	row.ContentType = media.ContentType
+	row.StoragePath = media.StoragePath
+	row.StreamingOptimized = media.StreamingOptimized
+	row.State = media.State
+	row.QuarantineReason = media.QuarantineReason
+	row.Size = media.Size
+	row.Version = media.Version
+	row.Revision++
+	r.rows[media.ID] = row
+	media.Revision = row.Revision
+	return nil
+}
+
+func (r *fakeMediaRepo) MarkStreamingOptimized(ctx context.Context, id, storagePath string, size int64) (*models.Media, error) {
+	row, ok := r.rows[id]
+	if r.race != nil {
+		r.race(&row)
+		row.Revision++
+	}
+	if !ok || row.StoragePath != storagePath || row.State != models.MediaStateActive {
+		r.rows[id] = row
+		return nil, sql.ErrNoRows
+	}
+
+	row.StreamingOptimized = true
+	row.Size = size
+	row.Revision++
+	r.rows[id] = row
+	return &row, nil
+}
+
+type fakeVersionRepo struct {
+	ports.IMediaVersionRepo
+	versions []*models.MediaVersion
+}
+
+func (r *fakeVersionRepo) Get(ctx context.Context, mediaID string, version int) (*models.MediaVersion, error) {
+	for _, v := range r.versions {
+		if v.MediaID == mediaID && v.Version == version {
+			return v, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+type fakeEventOutbox struct {
+	ports.IOutboxRepo
+	events []*models.Event
+}
+
+func (o *fakeEventOutbox) Add(ctx context.Context, event *models.Event) error {
+	o.events = append(o.events, event)
+	return nil
+}
+
+type fakeAuditLog struct {
+	ports.IAuditRepo
+	events []*models.AuditEvent
+}
+
+func (a *fakeAuditLog) Add(ctx context.Context, event *models.AuditEvent) error {
+	a.events = append(a.events, event)
+	return nil
+}
+
+func TestRollbackVersionKeepsConcurrentEdit(t *testing.T) {
+	f := newTestMedia(t, map[string]models.Media{
+		"m": {ID: "m", Title: "old", StoragePath: "o/m/2/b.png", ContentType: "image/png", State: models.MediaStateActive, Version: 2, Revision: 3},
+	}, nil, nil)
+	media, repo, outbox := f.media, f.repo, f.outbox
+	repo.race = func(row *models.Media) {
+		row.Title = "renamed"
+		repo.race = nil
+	}
+	f.versions.versions = []*models.MediaVersion{
+		{MediaID: "m", Version: 1, StoragePath: "o/m/1/a.png", ContentType: "image/png", Size: 10},
+	}
+
+	got, err := media.RollbackVersion(context.Background(), "m", 1)
+	if err != nil {
+		t.Fatalf("RollbackVersion() error = %v", err)
+	}
+
+	row := repo.rows["m"]
+	if row.Title != "renamed" || got.Title != "renamed" {
+		t.Errorf("title = %q, want the concurrent edit kept", row.Title)
+	}
+	if row.Version != 1 || row.StoragePath != "o/m/1/a.png" {
+		t.Errorf("row = %+v, want version 1 current", row)
+	}
+	if repo.updates != 2 || row.Revision != 5 {
+		t.Errorf("updates = %d, revision = %d, want one retry", repo.updates, row.Revision)
+	}
+	if len(outbox.events) != 1 {
+		t.Errorf("published %d events, want 1", len(outbox.events))
+	}
+}
+
+func TestChangeGivesUpAfterRepeatedConflicts(t *testing.T) {
+	f := newTestMedia(t, map[string]models.Media{
+		"m": {ID: "m", State: models.MediaStateActive, Revision: 1},
+	}, nil, nil)
+	media, repo, outbox := f.media, f.repo, f.outbox
+	repo.race = func(row *models.Media) {}
+
+	_, err := media.change(context.Background(), "m", models.EventMediaProcessed, func(media *models.Media) error {
+		media.StreamingOptimized = true
+		return nil
+	})
+	if !errors.Is(err, models.ErrMediaModified) {
+		t.Fatalf("change() error = %v, want ErrMediaModified", err)
+	}
+	if repo.updates != models.MediaUpdateAttempts || len(outbox.events) != 0 {
+		t.Errorf("updates = %d, events = %d", repo.updates, len(outbox.events))
+	}
+}
+
+func TestChangeStopsOnReplacedFile(t *testing.T) {
+	f := newTestMedia(t, map[string]models.Media{
+		"m": {ID: "m", StoragePath: "o/m/2/b.mp4", State: models.MediaStateActive, Revision: 1},
+	}, nil, nil)
+	media, repo := f.media, f.repo
+
+	_, err := media.change(context.Background(), "m", models.EventMediaProcessed, func(media *models.Media) error {
+		if media.StoragePath != "o/m/1/a.mp4" {
+			return errFileReplaced
+		}
+		return nil
+	})
+	if !errors.Is(err, errFileReplaced) || repo.updates != 0 {
+		t.Errorf("change() error = %v after %d updates, want errFileReplaced before any", err, repo.updates)
+	}
+}
+
+func (r *fakeMediaRepo) ListByState(ctx context.Context, state string, limit int) ([]*models.Media, error) {
+	var list []*models.Media
+	for _, row := range r.rows {
+		if row.State == state {
+			row := row
+			list = append(list, &row)
+		}
+	}
+	return list, nil
+}
+
+func (r *fakeVersionRepo) Create(ctx context.Context, version *models.MediaVersion) error {
+	version.Version = len(r.versions) + 1
+	r.versions = append(r.versions, version)
+	return nil
+}
+
+func (r *fakeMediaRepo) Create(ctx context.Context, media *models.Media) error {
+	if r.rows == nil {
+		r.rows = make(map[string]models.Media)
+	}
+	r.rows[media.ID] = *media
+	return nil
+}
+
+// faststartMP4 already has its moov atom in front of the media data.
+const faststartMP4 = "\x00\x00\x00\x08moov\x00\x00\x00\x0cmdat\x00\x00\x00\x00"
+
+func TestOptimizeStreamingKeepsConcurrentEdit(t *testing.T) {
+	f := newTestMedia(t, map[string]models.Media{
+		"m": {ID: "m", Title: "old", StoragePath: "o/m/1/a.mp4", ContentType: "video/mp4", State: models.MediaStateActive, Size: 100, Revision: 1},
+	}, map[string]string{"o/m/1/a.mp4": faststartMP4}, nil)
+	media, repo, outbox, auditLog := f.media, f.repo, f.outbox, f.audit
+	repo.race = func(row *models.Media) {
+		row.Title = "renamed"
+		repo.race = nil
+	}
+
+	if err := media.optimizeStreaming(context.Background(), "m"); err != nil {
+		t.Fatalf("optimizeStreaming() error = %v", err)
+	}
+
+	row := repo.rows["m"]
+	if row.Title != "renamed" {
+		t.Errorf("title = %q, want the concurrent edit kept", row.Title)
+	}
+	if !row.StreamingOptimized || row.Size != int64(len(faststartMP4)) || row.Revision != 3 {
+		t.Errorf("row = %+v, want it marked optimized", row)
+	}
+	if repo.updates != 0 {
+		t.Errorf("Update called %d times, want the narrow update only", repo.updates)
+	}
+	if len(outbox.events) != 1 || outbox.events[0].Type != models.EventMediaProcessed {
+		t.Errorf("published %+v, want one %s event", outbox.events, models.EventMediaProcessed)
+	}
+	if len(auditLog.events) != 1 {
+		t.Errorf("audited %d events, want 1", len(auditLog.events))
+	}
+}
+
+func TestOptimizeStreamingSkipsReplacedFile(t *testing.T) {
+	f := newTestMedia(t, map[string]models.Media{
+		"m": {ID: "m", StoragePath: "o/m/1/a.mp4", ContentType: "video/mp4", State: models.MediaStateActive, Version: 1, Revision: 1},
+	}, map[string]string{"o/m/1/a.mp4": faststartMP4}, nil)
+	media, repo, outbox, auditLog := f.media, f.repo, f.outbox, f.audit
+	repo.race = func(row *models.Media) {
+		row.StoragePath = "o/m/2/b.mp4"
+		row.Version = 2
+		repo.race = nil
+	}
+
+	if err := media.optimizeStreaming(context.Background(), "m"); err != nil {
+		t.Fatalf("optimizeStreaming() error = %v, want the replaced file skipped", err)
+	}
+
+	if row := repo.rows["m"]; row.StreamingOptimized || row.StoragePath != "o/m/2/b.mp4" {
+		t.Errorf("row = %+v, want the new file left alone", row)
+	}
+	if len(outbox.events) != 0 || len(auditLog.events) != 0 {
+		t.Errorf("events = %d, audit = %d, want none", len(outbox.events), len(auditLog.events))
+	}
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+type OutboxRelay struct {
+	outbox   ports.IOutboxRepo
+	sink     ports.IEventSink
+	interval time.Duration
+	batch    int
+	opts     *models.Options
+	wg       sync.WaitGroup
+	cancel   context.CancelFunc
+}
+
+func NewOutboxRelay(outbox ports.IOutboxRepo, sink ports.IEventSink, opts *models.Options) *OutboxRelay {
+	interval := opts.Config.Events.PollInterval
+	if interval <= 0 {
+		interval = models.DefaultEventsPollInterval
+	}
+
+	batch := opts.Config.Events.BatchSize
+	if batch <= 0 {
+		batch = models.DefaultEventsBatchSize
+	}
+
+	return &OutboxRelay{
+		outbox:   outbox,
+		sink:     sink,
+		interval: interval,
+		batch:    batch,
+		opts:     opts,
+	}
+}
+
+func (r *OutboxRelay) Start(ctx context.Context) {
+	ctx, r.cancel = context.WithCancel(ctx)
+
+	r.wg.Add(1)
+	go r.run(ctx)
+}
+
+func (r *OutboxRelay) Stop() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.wg.Wait()
+}
+
+func (r *OutboxRelay) run(ctx context.Context) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	lastPrune := time.Time{}
+	for {
+		for {
+			n, err := r.relayBatch(ctx)
+			if err != nil {
+				r.opts.Logger.Error("outbox relay failed", "error", err)
+				break
+			}
+			if n < r.batch {
+				break
+			}
+		}
+
+		if time.Since(lastPrune) > time.Hour {
+			if _, err := r.outbox.DeletePublishedBefore(ctx, time.Now().Add(-models.OutboxRetention)); err != nil {
+				r.opts.Logger.Error("outbox prune failed", "error", err)
+			}
+			lastPrune = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// relayBatch publishes claimed events with no transaction open. A failed
+// event is retried with backoff and dead-lettered after
+// OutboxMaxAttempts; it never blocks events of other aggregates.
+func (r *OutboxRelay) relayBatch(ctx context.Context) (int, error) {
+	events, err := r.outbox.ClaimPending(ctx, r.batch, models.OutboxClaimLease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, event := range events {
+		if err := r.relay(ctx, event); err != nil {
+			r.opts.Logger.Error("outbox event not recorded", "event_id", event.ID, "error", err)
+		}
+	}
+
+	return len(events), nil
+}
+
+func (r *OutboxRelay) relay(ctx context.Context, event *models.OutboxEvent) error {
+	publishErr := r.sink.Publish(ctx, &event.Event)
+	if publishErr == nil {
+		return r.outbox.MarkPublished(ctx, event.ID)
+	}
+
+	attempts := event.Attempts + 1
+	if attempts >= models.OutboxMaxAttempts {
+		r.opts.Logger.Error("event dead-lettered", "event_id", event.ID, "type", event.Type, "attempts", attempts, "error", publishErr)
+		return r.outbox.MarkDeadLettered(ctx, event.ID, publishErr.Error())
+	}
+
+	r.opts.Logger.Warn("event publish failed", "event_id", event.ID, "type", event.Type, "attempts", attempts, "error", publishErr)
+	retryAt := time.Now().Add(backoff(attempts, models.OutboxBackoffBase, models.OutboxBackoffMax))
+	return r.outbox.MarkFailed(ctx, event.ID, publishErr.Error(), retryAt)
+}
+
+// backoff doubles base for every attempt after the first, up to ceiling.
+func backoff(attempt int, base, ceiling time.Duration) time.Duration {
+	delay := base << (attempt - 1)
+	if delay <= 0 || delay > ceiling {
+		return ceiling
+	}
+	return delay
+}
+
+func newEvent(eventType string, media *models.Media) (*models.Event, error) {
+	snapshot := *media
+	snapshot.URL = ""
+
+	payload, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Event{
+		ID:            uuid.New().String(),
+		Type:          eventType,
+		SchemaVersion: models.EventSchemaVersion,
+		AggregateID:   media.ID,
+		OwnerID:       media.OwnerID,
+		OccurredAt:    time.Now().UTC(),
+		Data:          payload,
+	}, nil
+}
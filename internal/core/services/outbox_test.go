@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/co1seam/ember-backend-media/config"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"testing"
+	"time"
+)
+
+type fakeOutboxRepo struct {
+	ports.IOutboxRepo
+	pending   []*models.OutboxEvent
+	published []string
+	failed    map[string]time.Time
+	dead      []string
+}
+
+func (r *fakeOutboxRepo) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]*models.OutboxEvent, error) {
+	return r.pending, nil
+}
+
+func (r *fakeOutboxRepo) MarkPublished(ctx context.Context, id string) error {
+	r.published = append(r.published, id)
+	return nil
+}
+
+func (r *fakeOutboxRepo) MarkFailed(ctx context.Context, id string, reason string, retryAt time.Time) error {
+	if r.failed == nil {
+		r.failed = make(map[string]time.Time)
+	}
+	r.failed[id] = retryAt
+	return nil
+}
+
+func (r *fakeOutboxRepo) MarkDeadLettered(ctx context.Context, id string, reason string) error {
+	r.dead = append(r.dead, id)
+	return nil
+}
+
+type fakeSink struct {
+	poison map[string]bool
+}
+
+func (s *fakeSink) Publish(ctx context.Context, event *models.Event) error {
+	if s.poison[event.ID] {
+		return errors.New("payload rejected")
+	}
+	return nil
+}
+
+func outboxEvent(id string, attempts int) *models.OutboxEvent {
+	return &models.OutboxEvent{Event: models.Event{ID: id, Type: models.EventMediaCreated}, Attempts: attempts}
+}
+
+func TestRelayBatchIsolatesFailures(t *testing.T) {
+	repo := &fakeOutboxRepo{pending: []*models.OutboxEvent{
+		outboxEvent("poison", models.OutboxMaxAttempts-1),
+		outboxEvent("flaky", 2),
+		outboxEvent("fine", 0),
+	}}
+	sink := &fakeSink{poison: map[string]bool{"poison": true, "flaky": true}}
+	opts := testOptions()
+	opts.Config = &config.Config{}
+	relay := NewOutboxRelay(repo, sink, opts)
+
+	before := time.Now()
+	n, err := relay.relayBatch(context.Background())
+	if err != nil || n != 3 {
+		t.Fatalf("relayBatch() = %d, %v, want 3, nil", n, err)
+	}
+
+	if len(repo.published) != 1 || repo.published[0] != "fine" {
+		t.Errorf("published = %v, want [fine] after earlier failures", repo.published)
+	}
+	if len(repo.dead) != 1 || repo.dead[0] != "poison" {
+		t.Errorf("dead-lettered = %v, want [poison]", repo.dead)
+	}
+	retryAt, ok := repo.failed["flaky"]
+	if !ok || len(repo.failed) != 1 {
+		t.Fatalf("failed = %v, want only flaky", repo.failed)
+	}
+	if wait := retryAt.Sub(before); wait < 4*models.OutboxBackoffBase {
+		t.Errorf("third attempt retries after %v, want at least %v", wait, 4*models.OutboxBackoffBase)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt, want := range map[int]time.Duration{
+		1:   time.Second,
+		2:   2 * time.Second,
+		4:   8 * time.Second,
+		7:   time.Minute,
+		100: time.Minute,
+	} {
+		if got := backoff(attempt, time.Second, time.Minute); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
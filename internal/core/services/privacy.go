@@ -0,0 +1,539 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/co1seam/ember-backend-media/internal/core/auth"
+	"github.com/co1seam/ember-backend-media/internal/core/errs"
+	"github.com/co1seam/ember-backend-media/internal/core/jobs"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"github.com/google/uuid"
+	"github.com/minio/minio-go/v7"
+	"go.opentelemetry.io/otel/attribute"
+	"io"
+	"math"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Privacy exports and erases everything stored for an owner. Exports run on
+// the job queue and land under the export prefix; erasures run inline and
+// leave their request row behind as the audit record.
+type Privacy struct {
+	repo     ports.IPrivacyRepo
+	media    ports.IMediaRepo
+	versions ports.IMediaVersionRepo
+	albums   ports.IAlbumRepo
+	webhooks ports.IWebhookRepo
+	imports  ports.IImportRepo
+	outbox   ports.IOutboxRepo
+	auditLog ports.IAuditRepo
+	tx       ports.ITransactor
+	minio    ports.IMinio
+	jobs     *jobs.Queue
+	opts     *models.Options
+}
+
+func NewPrivacy(repo ports.IPrivacyRepo, media ports.IMediaRepo, versions ports.IMediaVersionRepo, albums ports.IAlbumRepo, webhooks ports.IWebhookRepo, imports ports.IImportRepo, outbox ports.IOutboxRepo, auditLog ports.IAuditRepo, tx ports.ITransactor, minio ports.IMinio, queue *jobs.Queue, opts *models.Options) *Privacy {
+	return &Privacy{
+		repo:     repo,
+		media:    media,
+		versions: versions,
+		albums:   albums,
+		webhooks: webhooks,
+		imports:  imports,
+		outbox:   outbox,
+		auditLog: auditLog,
+		tx:       tx,
+		minio:    minio,
+		jobs:     queue,
+		opts:     opts,
+	}
+}
+
+// ExportOwner queues an export of the owner's data in the given format.
+func (p *Privacy) ExportOwner(ctx context.Context, ownerID, format string) (_ *models.PrivacyRequest, err error) {
+	ctx, span := startSpan(ctx, "services.Privacy.ExportOwner", attribute.String("owner.id", ownerID))
+	defer finish(span, &err)
+
+	if err := validOwner(ownerID); err != nil {
+		return nil, err
+	}
+	if format == "" {
+		format = models.ExportFormatZip
+	}
+	if format != models.ExportFormatZip && format != models.ExportFormatTar {
+		return nil, errs.InvalidArgument(fmt.Sprintf("unknown export format %q", format))
+	}
+
+	req, err := p.newRequest(ctx, models.PrivacyExport, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	req.Format = format
+	if err := p.repo.Create(ctx, req); err != nil {
+		return nil, err
+	}
+
+	if err := p.enqueueExport(req); err != nil {
+		p.finish(ctx, req, err)
+		return nil, err
+	}
+
+	return req, nil
+}
+
+func (p *Privacy) enqueueExport(req *models.PrivacyRequest) error {
+	return p.jobs.Enqueue("privacy-export:"+req.ID, func(ctx context.Context) error {
+		return p.runExport(ctx, req)
+	})
+}
+
+// Resume picks up the requests a previous process left unfinished, since
+// the job queue does not survive a restart. Exports are queued again; an
+// export always rewrites the same object, so running one twice is
+// harmless. Erasures are marked failed, to be run again by the caller.
+func (p *Privacy) Resume(ctx context.Context) {
+	reqs, err := p.repo.ListUnfinished(ctx, time.Now())
+	if err != nil {
+		p.opts.Logger.ErrorContext(ctx, "unfinished privacy requests not resumed", "error", err)
+		return
+	}
+
+	for _, req := range reqs {
+		if req.Kind != models.PrivacyExport {
+			p.finish(ctx, req, errs.Aborted("interrupted by a restart; erase the owner again"))
+			continue
+		}
+
+		p.opts.Logger.InfoContext(ctx, "resuming privacy export", "privacy_id", req.ID, "owner_id", req.OwnerID)
+		req.Summary = map[string]int64{}
+		if err := p.enqueueExport(req); err != nil {
+			p.finish(ctx, req, err)
+		}
+	}
+}
+
+// GetRequest returns a privacy request, with a presigned download link once
+// an export has completed.
+func (p *Privacy) GetRequest(ctx context.Context, id string) (_ *models.PrivacyRequest, err error) {
+	ctx, span := startSpan(ctx, "services.Privacy.GetRequest", attribute.String("privacy.id", id))
+	defer finish(span, &err)
+
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, models.ErrPrivacyNotFound
+	}
+
+	req, err := p.repo.Get(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ErrPrivacyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Kind == models.PrivacyExport && req.State == models.PrivacyStateCompleted {
+		req.URL, err = p.minio.GenerateDownloadURL(ctx, req.StoragePath, p.opts.Settings.Load().Media.URLExpiry)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return req, nil
+}
+
+// EraseOwner deletes the owner's rows and outbox events, scrubs the changes
+// recorded in their audit events, records the erasure of each media in the
+// audit log, and then deletes every object under the owner's prefixes,
+// including trashed, quarantined and exported copies. It publishes no
+// events. A failed erasure can simply be run again.
+func (p *Privacy) EraseOwner(ctx context.Context, ownerID string) (_ *models.PrivacyRequest, err error) {
+	ctx, span := startSpan(ctx, "services.Privacy.EraseOwner", attribute.String("owner.id", ownerID))
+	defer finish(span, &err)
+
+	if err := validOwner(ownerID); err != nil {
+		return nil, err
+	}
+
+	req, err := p.newRequest(ctx, models.PrivacyErase, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	req.State = models.PrivacyStateRunning
+	if err := p.repo.Create(ctx, req); err != nil {
+		return nil, err
+	}
+
+	err = p.tx.WithinTx(ctx, func(ctx context.Context) error {
+		erased, err := p.ownerMedia(ctx, ownerID)
+		if err != nil {
+			return err
+		}
+
+		steps := []struct {
+			name string
+			fn   func(ctx context.Context, ownerID string) (int64, error)
+		}{
+			{"media", p.media.DeleteByOwner},
+			{"albums", p.albums.DeleteByOwner},
+			{"webhooks", p.webhooks.DeleteByOwner},
+			{"import_jobs", p.imports.DeleteByOwner},
+			{"outbox_events", p.outbox.DeleteByOwner},
+			{"audit_events_scrubbed", p.auditLog.ScrubOwner},
+		}
+		for _, step := range steps {
+			count, err := step.fn(ctx, ownerID)
+			if err != nil {
+				return err
+			}
+			req.Summary[step.name] = count
+		}
+
+		// Recorded after the scrub, without changes, so nothing personal is
+		// kept.
+		for _, media := range erased {
+			if err := p.auditLog.Add(ctx, auditEvent(ctx, models.AuditActionMediaErased, media, nil)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		err = p.eraseObjects(ctx, req)
+	}
+
+	p.finish(ctx, req, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// ownerMedia lists the media of ownerID, reduced to what an erasure audit
+// event may keep.
+func (p *Privacy) ownerMedia(ctx context.Context, ownerID string) ([]*models.Media, error) {
+	var media []*models.Media
+	afterID := ""
+	for {
+		page, err := p.media.ListOwnerPage(ctx, ownerID, afterID, models.MaintenanceBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(page) == 0 {
+			return media, nil
+		}
+
+		for _, m := range page {
+			media = append(media, &models.Media{ID: m.ID, OwnerID: m.OwnerID})
+		}
+		afterID = page[len(page)-1].ID
+	}
+}
+
+func (p *Privacy) eraseObjects(ctx context.Context, req *models.PrivacyRequest) error {
+	bucket := p.opts.Config.MinIO.Bucket
+	ownerPrefix := req.OwnerID + "/"
+	quarantineBucket, quarantinePrefix := quarantineLocation(p.opts, ownerPrefix)
+
+	locations := []struct{ bucket, prefix string }{
+		{bucket, ownerPrefix},
+		{bucket, models.TrashPrefix + ownerPrefix},
+		{bucket, models.ExportPrefix + ownerPrefix},
+		{quarantineBucket, quarantinePrefix},
+	}
+	for _, location := range locations {
+		err := p.minio.WalkFiles(ctx, location.bucket, location.prefix, "", func(object minio.ObjectInfo) error {
+			if err := p.minio.DeleteFile(ctx, location.bucket, object.Key); err != nil {
+				return err
+			}
+			req.Summary["objects"]++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runExport gathers the owner's rows into data.json and streams it, along
+// with every stored version of their files, into a temporary archive that
+// is then uploaded under the export prefix.
+func (p *Privacy) runExport(ctx context.Context, req *models.PrivacyRequest) (err error) {
+	ctx, span := startSpan(ctx, "services.Privacy.runExport", attribute.String("privacy.id", req.ID))
+	defer finish(span, &err)
+	defer func() { p.finish(ctx, req, err) }()
+
+	req.State = models.PrivacyStateRunning
+	if err := p.repo.Update(ctx, req); err != nil {
+		return err
+	}
+
+	data, err := p.gather(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp("", "ember-export-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if err := p.writeExport(ctx, req, data, file); err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	contentType := "application/zip"
+	if req.Format == models.ExportFormatTar {
+		contentType = "application/x-tar"
+	}
+	storagePath := fmt.Sprintf("%s%s/%s.%s", models.ExportPrefix, req.OwnerID, req.ID, req.Format)
+	if err := p.minio.UploadFile(ctx, p.opts.Config.MinIO.Bucket, storagePath, file, info.Size(), contentType); err != nil {
+		return err
+	}
+
+	req.StoragePath = storagePath
+	req.Size = info.Size()
+	return nil
+}
+
+func (p *Privacy) gather(ctx context.Context, req *models.PrivacyRequest) (*models.OwnerExport, error) {
+	data := &models.OwnerExport{
+		OwnerID:    req.OwnerID,
+		ExportedAt: time.Now().UTC(),
+		Media:      []*models.ExportedMedia{},
+		Albums:     []*models.ExportedAlbum{},
+	}
+
+	afterID := ""
+	for {
+		page, err := p.media.ListOwnerPage(ctx, req.OwnerID, afterID, models.MaintenanceBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(page) == 0 {
+			break
+		}
+
+		ids := make([]string, len(page))
+		for i, media := range page {
+			ids[i] = media.ID
+		}
+		versions, err := p.versions.ListForMedia(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		byMedia := make(map[string][]*models.MediaVersion)
+		for _, version := range versions {
+			byMedia[version.MediaID] = append(byMedia[version.MediaID], version)
+		}
+
+		for _, media := range page {
+			data.Media = append(data.Media, &models.ExportedMedia{Media: media, Versions: byMedia[media.ID]})
+			req.Summary["versions"] += int64(len(byMedia[media.ID]))
+		}
+		afterID = page[len(page)-1].ID
+	}
+	req.Summary["media"] = int64(len(data.Media))
+
+	albums, err := p.albums.ListByOwner(ctx, req.OwnerID, math.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+	for _, album := range albums {
+		ids, err := p.albums.MediaIDs(ctx, album.ID)
+		if err != nil {
+			return nil, err
+		}
+		data.Albums = append(data.Albums, &models.ExportedAlbum{Album: album, MediaIDs: ids})
+	}
+	req.Summary["albums"] = int64(len(data.Albums))
+
+	data.Webhooks, err = p.webhooks.ListByOwner(ctx, req.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+	for _, webhook := range data.Webhooks {
+		webhook.Secret = ""
+	}
+	req.Summary["webhooks"] = int64(len(data.Webhooks))
+
+	data.ImportJobs, err = p.imports.ListByOwner(ctx, req.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+	req.Summary["import_jobs"] = int64(len(data.ImportJobs))
+
+	return data, nil
+}
+
+// writeExport writes data.json followed by the files, each version under
+// files/<media id>/v<version>/. The object of quarantined media is left out,
+// and objects that have gone missing are only counted.
+func (p *Privacy) writeExport(ctx context.Context, req *models.PrivacyRequest, data *models.OwnerExport, w io.Writer) error {
+	archive := newExportArchive(req.Format, w)
+
+	document, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := archive.add(models.ExportDataName, int64(len(document)), data.ExportedAt, false, strings.NewReader(string(document))); err != nil {
+		return err
+	}
+
+	bucket := p.opts.Config.MinIO.Bucket
+	for _, media := range data.Media {
+		for _, version := range media.Versions {
+			if media.State == models.MediaStateQuarantined && version.StoragePath == media.StoragePath {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			object, err := p.minio.DownloadFile(ctx, bucket, version.StoragePath)
+			if err != nil {
+				return err
+			}
+			info, err := object.Stat()
+			if err != nil {
+				object.Close()
+				if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+					req.Summary["missing_objects"]++
+					continue
+				}
+				return err
+			}
+
+			name := fmt.Sprintf("files/%s/v%d/%s", media.ID, version.Version, path.Base(version.StoragePath))
+			err = archive.add(name, info.Size, version.CreatedAt, precompressed(version.ContentType), object)
+			object.Close()
+			if err != nil {
+				return err
+			}
+			req.Summary["objects"]++
+		}
+	}
+
+	return archive.Close()
+}
+
+// newRequest records the verified caller as the requester; privacy requests
+// cannot be made anonymously.
+func (p *Privacy) newRequest(ctx context.Context, kind, ownerID string) (*models.PrivacyRequest, error) {
+	identity, ok := auth.FromContext(ctx)
+	if !ok || identity.Subject == "" {
+		return nil, models.ErrPermissionDenied
+	}
+
+	return &models.PrivacyRequest{
+		ID:          uuid.New().String(),
+		Kind:        kind,
+		OwnerID:     ownerID,
+		State:       models.PrivacyStatePending,
+		Summary:     map[string]int64{},
+		RequestedBy: identity.Subject,
+		CreatedAt:   time.Now(),
+	}, nil
+}
+
+// finish records the outcome of a request even when ctx is already done.
+func (p *Privacy) finish(ctx context.Context, req *models.PrivacyRequest, err error) {
+	now := time.Now()
+	req.FinishedAt = &now
+	req.State = models.PrivacyStateCompleted
+	if err != nil {
+		req.State = models.PrivacyStateFailed
+		req.Error = err.Error()
+	}
+
+	if err := p.repo.Update(context.WithoutCancel(ctx), req); err != nil {
+		p.opts.Logger.ErrorContext(ctx, "privacy request not updated", "privacy_id", req.ID, "error", err)
+	}
+	if req.State == models.PrivacyStateFailed {
+		p.opts.Logger.ErrorContext(ctx, "privacy request failed", "privacy_id", req.ID, "kind", req.Kind, "owner_id", req.OwnerID, "error", req.Error)
+		return
+	}
+	p.opts.Logger.InfoContext(ctx, "privacy request completed", "privacy_id", req.ID, "kind", req.Kind, "owner_id", req.OwnerID, "requested_by", req.RequestedBy)
+}
+
+// validOwner rejects owner ids that could reach into another owner's
+// storage prefix.
+func validOwner(ownerID string) error {
+	if ownerID == "" {
+		return errs.InvalidArgument("owner id is required")
+	}
+	if strings.ContainsAny(ownerID, "/\\") || ownerID == "." || ownerID == ".." {
+		return errs.InvalidArgument("invalid owner id")
+	}
+	return nil
+}
+
+// exportArchive hides whether an export is written as ZIP or tar.
+type exportArchive struct {
+	zip *zip.Writer
+	tar *tar.Writer
+}
+
+func newExportArchive(format string, w io.Writer) *exportArchive {
+	if format == models.ExportFormatTar {
+		return &exportArchive{tar: tar.NewWriter(w)}
+	}
+	return &exportArchive{zip: zip.NewWriter(w)}
+}
+
+func (a *exportArchive) add(name string, size int64, modified time.Time, stored bool, r io.Reader) error {
+	var w io.Writer
+	if a.tar != nil {
+		err := a.tar.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0o644,
+			Size:    size,
+			ModTime: modified,
+		})
+		if err != nil {
+			return err
+		}
+		w = a.tar
+	} else {
+		method := zip.Deflate
+		if stored {
+			method = zip.Store
+		}
+		fw, err := a.zip.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: modified})
+		if err != nil {
+			return err
+		}
+		w = fw
+	}
+
+	_, err := io.CopyN(w, r, size)
+	return err
+}
+
+func (a *exportArchive) Close() error {
+	if a.tar != nil {
+		return a.tar.Close()
+	}
+	return a.zip.Close()
+}
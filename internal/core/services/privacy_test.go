@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/co1seam/ember-backend-media/config"
+	"github.com/co1seam/ember-backend-media/internal/core/auth"
+	"github.com/co1seam/ember-backend-media/internal/core/jobs"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"github.com/minio/minio-go/v7"
+	"slices"
+	"testing"
+	"time"
+)
+
+type fakePrivacyRepo struct {
+	ports.IPrivacyRepo
+	created    []*models.PrivacyRequest
+	updated    []models.PrivacyRequest
+	unfinished []*models.PrivacyRequest
+}
+
+func (r *fakePrivacyRepo) Create(ctx context.Context, req *models.PrivacyRequest) error {
+	copied := *req
+	r.created = append(r.created, &copied)
+	return nil
+}
+
+func (r *fakePrivacyRepo) Update(ctx context.Context, req *models.PrivacyRequest) error {
+	r.updated = append(r.updated, *req)
+	return nil
+}
+
+func (r *fakePrivacyRepo) ListUnfinished(ctx context.Context, before time.Time) ([]*models.PrivacyRequest, error) {
+	return r.unfinished, nil
+}
+
+// ownerRows records which owner tables were cleared, and whether inside
+// the transaction.
+type ownerRows struct {
+	cleared []string
+	outside []string
+	audited []*models.AuditEvent
+}
+
+func (r *ownerRows) clear(ctx context.Context, table string) (int64, error) {
+	r.cleared = append(r.cleared, table)
+	if ctx.Value(txKey{}) == nil {
+		r.outside = append(r.outside, table)
+	}
+	return 1, nil
+}
+
+type fakeOwnerMedia struct {
+	ports.IMediaRepo
+	rows *ownerRows
+}
+
+func (f fakeOwnerMedia) DeleteByOwner(ctx context.Context, ownerID string) (int64, error) {
+	return f.rows.clear(ctx, "media")
+}
+
+func (f fakeOwnerMedia) ListOwnerPage(ctx context.Context, ownerID, afterID string, limit int) ([]*models.Media, error) {
+	if afterID != "" {
+		return nil, nil
+	}
+	return []*models.Media{
+		{ID: "m1", OwnerID: ownerID, Title: "holiday", StoragePath: ownerID + "/m1/a.jpg"},
+		{ID: "m2", OwnerID: ownerID, Title: "passport"},
+	}, nil
+}
+
+type fakeOwnerAlbums struct {
+	ports.IAlbumRepo
+	rows *ownerRows
+}
+
+func (f fakeOwnerAlbums) DeleteByOwner(ctx context.Context, ownerID string) (int64, error) {
+	return f.rows.clear(ctx, "albums")
+}
+
+type fakeOwnerWebhooks struct {
+	ports.IWebhookRepo
+	rows *ownerRows
+}
+
+func (f fakeOwnerWebhooks) DeleteByOwner(ctx context.Context, ownerID string) (int64, error) {
+	return f.rows.clear(ctx, "webhooks")
+}
+
+type fakeOwnerImports struct {
+	ports.IImportRepo
+	rows *ownerRows
+}
+
+func (f fakeOwnerImports) DeleteByOwner(ctx context.Context, ownerID string) (int64, error) {
+	return f.rows.clear(ctx, "import_jobs")
+}
+
+type fakeOwnerOutbox struct {
+	ports.IOutboxRepo
+	rows *ownerRows
+}
+
+func (f fakeOwnerOutbox) DeleteByOwner(ctx context.Context, ownerID string) (int64, error) {
+	return f.rows.clear(ctx, "outbox_events")
+}
+
+type fakeOwnerAudit struct {
+	ports.IAuditRepo
+	rows *ownerRows
+}
+
+func (f fakeOwnerAudit) ScrubOwner(ctx context.Context, ownerID string) (int64, error) {
+	return f.rows.clear(ctx, "audit_events")
+}
+
+func (f fakeOwnerAudit) Add(ctx context.Context, event *models.AuditEvent) error {
+	if !slices.Contains(f.rows.cleared, "audit_events") {
+		return errors.New("erasure recorded before the scrub")
+	}
+	if ctx.Value(txKey{}) == nil {
+		f.rows.outside = append(f.rows.outside, "audit:"+event.MediaID)
+	}
+	f.rows.audited = append(f.rows.audited, event)
+	return nil
+}
+
+func TestExportOwnerRecordsVerifiedRequester(t *testing.T) {
+	repo := &fakePrivacyRepo{}
+	opts := testOptions()
+	queue := jobs.NewQueue(1, 1, opts.Logger)
+	privacy := NewPrivacy(repo, nil, nil, nil, nil, nil, nil, nil, nil, nil, queue, opts)
+
+	if _, err := privacy.ExportOwner(context.Background(), "owner", ""); !errors.Is(err, models.ErrPermissionDenied) {
+		t.Fatalf("anonymous ExportOwner() error = %v, want ErrPermissionDenied", err)
+	}
+	if len(repo.created) != 0 {
+		t.Fatal("an anonymous request was recorded")
+	}
+
+	ctx := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "dpo"})
+	req, err := privacy.ExportOwner(ctx, "owner", "")
+	if err != nil {
+		t.Fatalf("ExportOwner() error = %v", err)
+	}
+	if req.RequestedBy != "dpo" || repo.created[0].RequestedBy != "dpo" {
+		t.Errorf("RequestedBy = %q, want the verified subject", req.RequestedBy)
+	}
+	if req.Format != models.ExportFormatZip || queue.Len() != 1 {
+		t.Errorf("format = %q, queued = %d, want a queued zip export", req.Format, queue.Len())
+	}
+}
+
+func TestEraseOwnerClearsEveryOwnerTableInOneTx(t *testing.T) {
+	repo := &fakePrivacyRepo{}
+	rows := &ownerRows{}
+	store := newFakeStore(t, nil)
+	store.listing = []minio.ObjectInfo{{Key: "owner/a.jpg"}, {Key: "exports/owner/1.zip"}, {Key: "quarantine/owner/b.exe"}}
+	opts := testOptions()
+	opts.Config = &config.Config{MinIO: config.MinIO{Bucket: "media"}}
+	privacy := NewPrivacy(repo, fakeOwnerMedia{rows: rows}, nil, fakeOwnerAlbums{rows: rows}, fakeOwnerWebhooks{rows: rows},
+		fakeOwnerImports{rows: rows}, fakeOwnerOutbox{rows: rows}, fakeOwnerAudit{rows: rows}, fakeTx{}, store, nil, opts)
+
+	ctx := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "dpo"})
+	req, err := privacy.EraseOwner(ctx, "owner")
+	if err != nil {
+		t.Fatalf("EraseOwner() error = %v", err)
+	}
+
+	want := []string{"media", "albums", "webhooks", "import_jobs", "outbox_events", "audit_events"}
+	if !slices.Equal(rows.cleared, want) {
+		t.Errorf("cleared %v, want %v", rows.cleared, want)
+	}
+	if len(rows.outside) != 0 {
+		t.Errorf("cleared %v outside the transaction", rows.outside)
+	}
+	if len(store.deleted) != 3 || req.Summary["objects"] != 3 {
+		t.Errorf("deleted %v, want every object under the owner's prefixes", store.deleted)
+	}
+	if req.Summary["outbox_events"] != 1 || req.Summary["audit_events_scrubbed"] != 1 {
+		t.Errorf("summary = %v, want outbox and audit counts", req.Summary)
+	}
+	if req.State != models.PrivacyStateCompleted {
+		t.Errorf("state = %q, want completed", req.State)
+	}
+
+	if len(rows.audited) != 2 {
+		t.Fatalf("audit events = %d, want one per erased media", len(rows.audited))
+	}
+	for _, event := range rows.audited {
+		if event.Action != models.AuditActionMediaErased || event.OwnerID != "owner" || event.Actor != "dpo" {
+			t.Errorf("audit event = %+v, want an erasure by dpo", event)
+		}
+		if len(event.Changes) != 0 {
+			t.Errorf("audit event of %s keeps changes %v", event.MediaID, event.Changes)
+		}
+	}
+}
+
+func TestResumeRequeuesExportsAndFailsErasures(t *testing.T) {
+	export := &models.PrivacyRequest{ID: "export", Kind: models.PrivacyExport, OwnerID: "owner", State: models.PrivacyStateRunning}
+	erase := &models.PrivacyRequest{ID: "erase", Kind: models.PrivacyErase, OwnerID: "owner", State: models.PrivacyStateRunning}
+	repo := &fakePrivacyRepo{unfinished: []*models.PrivacyRequest{export, erase}}
+	opts := testOptions()
+	queue := jobs.NewQueue(1, 1, opts.Logger)
+	privacy := NewPrivacy(repo, nil, nil, nil, nil, nil, nil, nil, nil, nil, queue, opts)
+
+	privacy.Resume(context.Background())
+
+	if queue.Len() != 1 {
+		t.Errorf("queued = %d, want the export queued again", queue.Len())
+	}
+	if len(repo.updated) != 1 || repo.updated[0].ID != "erase" {
+		t.Fatalf("updated %v, want only the erasure finished", repo.updated)
+	}
+	if repo.updated[0].State != models.PrivacyStateFailed || repo.updated[0].Error == "" {
+		t.Errorf("erasure = %+v, want failed with a reason", repo.updated[0])
+	}
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"go.opentelemetry.io/otel/attribute"
+	"sync"
+	"time"
+)
+
+// Rescans scans the uploads the malware scanner could not check when they
+// arrived, releasing clean ones and quarantining infected ones. A file that
+// fails on its own is logged and skipped; a batch only stops when the scanner
+// itself is unavailable, since it is likely still down.
+type Rescans struct {
+	media  *Media
+	opts   *models.Options
+	wg     sync.WaitGroup
+	cancel context.CancelFunc
+}
+
+func NewRescans(media *Media, opts *models.Options) *Rescans {
+	return &Rescans{
+		media: media,
+		opts:  opts,
+	}
+}
+
+func (r *Rescans) Start(ctx context.Context) {
+	ctx, r.cancel = context.WithCancel(ctx)
+
+	r.wg.Add(1)
+	go r.run(ctx)
+}
+
+func (r *Rescans) Stop() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.wg.Wait()
+}
+
+func (r *Rescans) run(ctx context.Context) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(models.RescanInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.rescanBatch(ctx); err != nil {
+			r.opts.Logger.Error("pending uploads not rescanned", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Rescans) rescanBatch(ctx context.Context) error {
+	pending, err := r.media.repo.ListByState(ctx, models.MediaStatePendingScan, models.RescanBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, media := range pending {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := r.media.rescan(ctx, media)
+		if errors.Is(err, errScannerUnavailable) {
+			return err
+		}
+		if err != nil {
+			r.opts.Logger.WarnContext(ctx, "pending upload not rescanned", "media_id", media.ID, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// rescan scans the file of media, which is pending a scan, and settles its
+// state. Only a failure to scan is returned; media that changed meanwhile
+// is left to its own flow.
+func (m *Media) rescan(ctx context.Context, media *models.Media) (err error) {
+	ctx, span := startSpan(ctx, "services.Media.rescan", attribute.String("media.id", media.ID))
+	defer finish(span, &err)
+
+	id, storagePath := media.ID, media.StoragePath
+	result, err := m.scanObject(ctx, storagePath)
+	if err != nil && !errors.Is(err, models.ErrMediaScanFailed) {
+		return err
+	}
+	scanErr := err
+
+	pending := func(media *models.Media) error {
+		if media.State != models.MediaStatePendingScan || media.StoragePath != storagePath {
+			return errFileReplaced
+		}
+		return nil
+	}
+
+	switch {
+	case scanErr != nil:
+		_, err = m.change(ctx, id, models.EventMediaUpdated, func(media *models.Media) error {
+			if err := pending(media); err != nil {
+				return err
+			}
+			media.State = models.MediaStateScanFailed
+			return nil
+		})
+		if err == nil {
+			m.opts.Logger.WarnContext(ctx, "file could not be scanned, upload not served", "media_id", id, "error", scanErr)
+		}
+	case result.Clean:
+		media, err = m.change(ctx, id, models.EventMediaUpdated, func(media *models.Media) error {
+			if err := pending(media); err != nil {
+				return err
+			}
+			media.State = models.MediaStateActive
+			return nil
+		})
+		if err == nil {
+			m.scheduleFaststart(media)
+		}
+	default:
+		err = m.quarantine(ctx, id, storagePath, result.Signature, pending)
+	}
+
+	if err != nil && !errors.Is(err, errFileReplaced) {
+		m.opts.Logger.ErrorContext(ctx, "rescanned media not updated", "media_id", id, "error", err)
+	}
+	return nil
+}
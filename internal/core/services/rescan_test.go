@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/co1seam/ember-backend-media/internal/core/errs"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeScanner struct {
+	result *models.ScanResult
+	err    error
+}
+
+func (s *fakeScanner) Scan(ctx context.Context, r io.Reader) (*models.ScanResult, error) {
+	return s.result, s.err
+}
+
+func TestUploadKeptPendingWhileScannerIsDown(t *testing.T) {
+	scanner := &fakeScanner{err: errors.New("clamd dial: connection refused")}
+	f := newTestMedia(t, map[string]models.Media{
+		"m": {ID: "m", OwnerID: "o", State: models.MediaStateActive, Revision: 1},
+	}, map[string]string{"o/m/1/a.txt": "hello"}, scanner)
+	media, repo, store := f.media, f.repo, f.store
+	ctx := context.Background()
+
+	if _, err := media.UploadFile(ctx, "m", "a.txt", 5, strings.NewReader("hello")); err != nil {
+		t.Fatalf("UploadFile() error = %v", err)
+	}
+	if row := repo.rows["m"]; row.State != models.MediaStatePendingScan || row.QuarantineReason != "" {
+		t.Fatalf("state = %q (%q), want pending_scan", row.State, row.QuarantineReason)
+	}
+	if len(store.copied) != 0 {
+		t.Errorf("moved %v, want the upload left in place", store.copied)
+	}
+	if _, err := media.GetFileURL(ctx, "m", time.Minute); !errors.Is(err, models.ErrMediaPendingScan) {
+		t.Errorf("GetFileURL() error = %v, want ErrMediaPendingScan", err)
+	}
+	if _, err := media.DownloadFile(ctx, "m"); !errors.Is(err, models.ErrMediaPendingScan) {
+		t.Errorf("DownloadFile() error = %v, want ErrMediaPendingScan", err)
+	}
+
+	rescans := NewRescans(media, media.opts)
+	if err := rescans.rescanBatch(ctx); err == nil {
+		t.Fatal("rescanBatch() with the scanner still down reported no error")
+	}
+
+	scanner.err, scanner.result = nil, &models.ScanResult{Clean: true}
+	if err := rescans.rescanBatch(ctx); err != nil {
+		t.Fatalf("rescanBatch() error = %v", err)
+	}
+	if row := repo.rows["m"]; row.State != models.MediaStateActive {
+		t.Fatalf("state = %q after a clean rescan, want active", row.State)
+	}
+	url, err := media.GetFileURL(ctx, "m", time.Minute)
+	if err != nil || !strings.HasPrefix(url, "https://minio/o/m/") {
+		t.Errorf("GetFileURL() = %q, %v", url, err)
+	}
+}
+
+func TestRescanQuarantinesInfectedUpload(t *testing.T) {
+	scanner := &fakeScanner{result: &models.ScanResult{Signature: "Eicar-Test-Signature"}}
+	f := newTestMedia(t, map[string]models.Media{
+		"m": {ID: "m", OwnerID: "o", StoragePath: "o/m/1/a.txt", State: models.MediaStatePendingScan, Revision: 1},
+	}, map[string]string{"o/m/1/a.txt": "hello"}, scanner)
+	media, repo, store := f.media, f.repo, f.store
+
+	if err := NewRescans(media, media.opts).rescanBatch(context.Background()); err != nil {
+		t.Fatalf("rescanBatch() error = %v", err)
+	}
+
+	row := repo.rows["m"]
+	if row.State != models.MediaStateQuarantined || row.QuarantineReason != "Eicar-Test-Signature" {
+		t.Errorf("row = %q (%q), want quarantined with the signature", row.State, row.QuarantineReason)
+	}
+	if len(store.copied) != 1 || store.copied[0] != "o/m/1/a.txt -> quarantine/o/m/1/a.txt" {
+		t.Errorf("moved %v, want the object quarantined", store.copied)
+	}
+}
+
+func TestUploadNotServedWhenScannerRefusesFile(t *testing.T) {
+	scanner := &fakeScanner{err: errs.Wrap(models.ErrMediaScanFailed, errors.New("clamd: INSTREAM size limit exceeded."))}
+	f := newTestMedia(t, map[string]models.Media{
+		"m": {ID: "m", OwnerID: "o", State: models.MediaStateActive, Revision: 1},
+	}, map[string]string{"o/m/1/a.txt": "hello"}, scanner)
+	media, repo, store := f.media, f.repo, f.store
+	ctx := context.Background()
+
+	if _, err := media.UploadFile(ctx, "m", "a.txt", 5, strings.NewReader("hello")); err != nil {
+		t.Fatalf("UploadFile() error = %v", err)
+	}
+	if row := repo.rows["m"]; row.State != models.MediaStateScanFailed {
+		t.Fatalf("state = %q, want scan_failed", row.State)
+	}
+	if len(store.copied) != 0 {
+		t.Errorf("moved %v, want the upload left in place", store.copied)
+	}
+	if _, err := media.GetFileURL(ctx, "m", time.Minute); !errors.Is(err, models.ErrMediaScanFailed) {
+		t.Errorf("GetFileURL() error = %v, want ErrMediaScanFailed", err)
+	}
+}
+
+func TestRescanBatchContinuesPastRefusedFiles(t *testing.T) {
+	scanner := &fakeScanner{err: errs.Wrap(models.ErrMediaScanFailed, errors.New("clamd: INSTREAM size limit exceeded."))}
+	f := newTestMedia(t, map[string]models.Media{
+		"m1": {ID: "m1", OwnerID: "o", StoragePath: "o/m/1/a.txt", State: models.MediaStatePendingScan, Revision: 1},
+		"m2": {ID: "m2", OwnerID: "o", StoragePath: "o/m/1/a.txt", State: models.MediaStatePendingScan, Revision: 1},
+	}, map[string]string{"o/m/1/a.txt": "hello"}, scanner)
+	media, repo := f.media, f.repo
+
+	if err := NewRescans(media, media.opts).rescanBatch(context.Background()); err != nil {
+		t.Fatalf("rescanBatch() error = %v", err)
+	}
+	for id, row := range repo.rows {
+		if row.State != models.MediaStateScanFailed {
+			t.Errorf("%s: state = %q, want scan_failed", id, row.State)
+		}
+	}
+}
@@ -2,16 +2,34 @@ package services
 
 import (
 	"github.com/co1seam/ember-backend-media/internal/adapters/repository"
+	"github.com/co1seam/ember-backend-media/internal/core/jobs"
 	"github.com/co1seam/ember-backend-media/internal/core/models"
 	"github.com/co1seam/ember-backend-media/internal/ports"
 )
 
 type Services struct {
-	Media ports.IMediaService
+	Media    ports.IMediaService
+	Albums   ports.IAlbumService
+	Webhooks *Webhooks
+	Privacy  *Privacy
+	Audit    ports.IAuditService
+	Jobs     *jobs.Queue
+	Relay    *OutboxRelay
+	Rescans  *Rescans
 }
 
-func NewService(repos *repository.Repository, opts *models.Options) *Services {
+func NewService(repos *repository.Repository, scanner ports.IScanner, sink ports.IEventSink, sender ports.IWebhookSender, opts *models.Options) *Services {
+	queue := jobs.NewQueue(models.JobWorkers, models.JobQueueSize, opts.Logger)
+	media := NewMedia(repos.Media, repos.Versions, repos.Tags, repos.Outbox, repos.Webhooks, repos.Audit, repos.Transactor, repos.MinIO, scanner, queue, opts)
+
 	return &Services{
-		Media: NewMedia(repos.Media, repos.MinIO, opts),
+		Media:    media,
+		Albums:   NewAlbums(repos.Albums, repos.Media, repos.Transactor, opts),
+		Webhooks: NewWebhooks(repos.Webhooks, sender, opts),
+		Privacy:  NewPrivacy(repos.Privacy, repos.Media, repos.Versions, repos.Albums, repos.Webhooks, repos.Imports, repos.Outbox, repos.Audit, repos.Transactor, repos.MinIO, queue, opts),
+		Audit:    NewAudit(repos.Audit, opts),
+		Jobs:     queue,
+		Relay:    NewOutboxRelay(repos.Outbox, sink, opts),
+		Rescans:  NewRescans(media, opts),
 	}
 }
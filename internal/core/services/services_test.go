@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/co1seam/ember-backend-media/config"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testOptions() *models.Options {
+	return &models.Options{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+}
+
+type txKey struct{}
+
+// fakeTx runs fn in place, marking its context as inside the transaction.
+type fakeTx struct{}
+
+func (fakeTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(context.WithValue(ctx, txKey{}, true))
+}
+
+// objectServer answers object reads the way S3 does, with a NoSuchKey
+// error for anything not in objects.
+func objectServer(t *testing.T, objects map[string]string) *minio.Client {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := objects[strings.TrimPrefix(r.URL.Path, "/media/")]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+			return
+		}
+		w.Header().Set("ETag", `"etag"`)
+		http.ServeContent(w, r, "", time.Now(), strings.NewReader(body))
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := minio.New(strings.TrimPrefix(server.URL, "http://"), &minio.Options{
+		Creds:  credentials.NewStaticV4("key", "secret", ""),
+		Region: "us-east-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// fakeStore keeps uploads in objects, which objectServer serves back, and
+// records copies and deletes. WalkFiles lists listing; objects under
+// broken/ cannot be signed.
+type fakeStore struct {
+	ports.IMinio
+	client  *minio.Client
+	objects map[string]string
+	listing []minio.ObjectInfo
+	copied  []string
+	deleted []string
+}
+
+func newFakeStore(t *testing.T, objects map[string]string) *fakeStore {
+	if objects == nil {
+		objects = make(map[string]string)
+	}
+	return &fakeStore{client: objectServer(t, objects), objects: objects}
+}
+
+func (s *fakeStore) UploadFile(ctx context.Context, bucketName, objectName string, reader io.Reader, objectSize int64, contentType string) error {
+	body, err := io.ReadAll(reader)
+	s.objects[objectName] = string(body)
+	return err
+}
+
+func (s *fakeStore) DownloadFile(ctx context.Context, bucketName, objectName string) (*minio.Object, error) {
+	return s.client.GetObject(ctx, bucketName, objectName, minio.GetObjectOptions{})
+}
+
+func (s *fakeStore) GenerateDownloadURL(ctx context.Context, objectName string, expiry time.Duration) (string, error) {
+	if strings.HasPrefix(objectName, "broken/") {
+		return "", errors.New("minio: connection refused")
+	}
+	return "https://minio/" + objectName, nil
+}
+
+func (s *fakeStore) CopyFile(ctx context.Context, srcBucket, srcObject, dstBucket, dstObject string) error {
+	s.copied = append(s.copied, srcObject+" -> "+dstObject)
+	return nil
+}
+
+func (s *fakeStore) DeleteFile(ctx context.Context, bucketName, objectName string) error {
+	s.deleted = append(s.deleted, objectName)
+	delete(s.objects, objectName)
+	return nil
+}
+
+func (s *fakeStore) WalkFiles(ctx context.Context, bucketName, prefix, startAfter string, fn func(minio.ObjectInfo) error) error {
+	for _, object := range s.listing {
+		if !strings.HasPrefix(object.Key, prefix) || object.Key <= startAfter {
+			continue
+		}
+		if err := fn(object); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mediaFixture is a Media service wired to in-memory fakes.
+type mediaFixture struct {
+	media    *Media
+	repo     *fakeMediaRepo
+	versions *fakeVersionRepo
+	outbox   *fakeEventOutbox
+	audit    *fakeAuditLog
+	store    *fakeStore
+}
+
+// newTestMedia builds a Media service over rows. The store serves objects,
+// and scanner, which may be nil, checks uploads.
+func newTestMedia(t *testing.T, rows map[string]models.Media, objects map[string]string, scanner ports.IScanner) *mediaFixture {
+	t.Helper()
+
+	cfg := &config.Config{
+		MinIO: config.MinIO{Bucket: "media"},
+		Media: config.Media{MaxFileSize: 1 << 20},
+	}
+	opts := testOptions()
+	opts.Config = cfg
+	opts.Settings = config.NewStore(cfg)
+
+	repo := &fakeMediaRepo{rows: rows}
+	f := &mediaFixture{
+		repo:     repo,
+		versions: &fakeVersionRepo{},
+		outbox:   &fakeEventOutbox{},
+		audit:    &fakeAuditLog{},
+		store:    newFakeStore(t, objects),
+	}
+	f.media = NewMedia(repo, f.versions, &fakeTagRepo{media: repo}, f.outbox, &fakeWebhookRepo{}, f.audit, fakeTx{}, f.store, scanner, nil, opts)
+	return f
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/co1seam/ember-backend-media/internal/core/errs"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"go.opentelemetry.io/otel/attribute"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+func (m *Media) AddTags(ctx context.Context, id string, tags []string) (_ *models.Media, err error) {
+	ctx, span := startSpan(ctx, "services.Media.AddTags", attribute.String("media.id", id))
+	defer finish(span, &err)
+
+	tags, err = normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) == 0 {
+		return nil, errs.InvalidArgument("at least one tag is required")
+	}
+
+	return m.modify(ctx, id, func(ctx context.Context, media *models.Media) error {
+		if err := m.tags.Add(ctx, media.ID, tags); err != nil {
+			return err
+		}
+
+		count, err := m.tags.Count(ctx, media.ID)
+		if err != nil {
+			return err
+		}
+		if count > models.MaxTagsPerMedia {
+			return errs.InvalidArgument(fmt.Sprintf("media can have at most %d tags", models.MaxTagsPerMedia))
+		}
+		return nil
+	})
+}
+
+func (m *Media) RemoveTags(ctx context.Context, id string, tags []string) (_ *models.Media, err error) {
+	ctx, span := startSpan(ctx, "services.Media.RemoveTags", attribute.String("media.id", id))
+	defer finish(span, &err)
+
+	tags, err = normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) == 0 {
+		return nil, errs.InvalidArgument("at least one tag is required")
+	}
+
+	return m.modify(ctx, id, func(ctx context.Context, media *models.Media) error {
+		return m.tags.Remove(ctx, media.ID, tags)
+	})
+}
+
+func (m *Media) PatchMetadata(ctx context.Context, id string, req *models.PatchMetadataRequest) (_ *models.Media, err error) {
+	ctx, span := startSpan(ctx, "services.Media.PatchMetadata", attribute.String("media.id", id))
+	defer finish(span, &err)
+
+	if len(req.Set) == 0 && len(req.Remove) == 0 {
+		return nil, errs.InvalidArgument("metadata patch is empty")
+	}
+
+	return m.modify(ctx, id, func(ctx context.Context, media *models.Media) error {
+		merged := make(map[string]string, len(media.Metadata)+len(req.Set))
+		for key, value := range media.Metadata {
+			merged[key] = value
+		}
+		for key, value := range req.Set {
+			merged[key] = value
+		}
+		for _, key := range req.Remove {
+			delete(merged, key)
+		}
+
+		if err := validateMetadata(merged); err != nil {
+			return err
+		}
+		return m.repo.PatchMetadata(ctx, media.ID, req.Set, req.Remove)
+	})
+}
+
+// modify applies fn and publishes the reloaded media in one transaction. The
+// row is locked before fn sees it, so limits are checked against the latest
+// tags and metadata rather than a snapshot a concurrent change has outdated.
+func (m *Media) modify(ctx context.Context, id string, fn func(ctx context.Context, media *models.Media) error) (*models.Media, error) {
+	if id == "" {
+		return nil, errs.InvalidArgument("media id is required")
+	}
+
+	var media *models.Media
+	err := m.tx.WithinTx(ctx, func(ctx context.Context) error {
+		current, err := m.repo.GetForUpdate(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrMediaNotFound
+		}
+		if err != nil {
+			return err
+		}
+		before := snapshot(current)
+
+		if err := fn(ctx, current); err != nil {
+			return err
+		}
+		if err := m.repo.Touch(ctx, id); err != nil {
+			return err
+		}
+
+		media, err = m.repo.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := m.audit(ctx, models.EventMediaUpdated, before, media); err != nil {
+			return err
+		}
+		return m.publish(ctx, models.EventMediaUpdated, media)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return media, nil
+}
+
+// normalizeTags lower-cases, trims and de-duplicates tags. Tags may contain
+// letters, digits and "-", "_", ":" or ".".
+func normalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+
+		if len(tag) > models.MaxTagLength {
+			return nil, errs.InvalidArgument(fmt.Sprintf("tag %q is longer than %d bytes", tag, models.MaxTagLength))
+		}
+		for _, r := range tag {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("-_:.", r) {
+				return nil, errs.InvalidArgument(fmt.Sprintf("tag %q contains %q", tag, r))
+			}
+		}
+
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+func validateMetadata(metadata map[string]string) error {
+	if len(metadata) > models.MaxMetadataKeys {
+		return errs.InvalidArgument(fmt.Sprintf("metadata can have at most %d keys", models.MaxMetadataKeys))
+	}
+
+	for key, value := range metadata {
+		switch {
+		case key == "":
+			return errs.InvalidArgument("metadata keys must not be empty")
+		case len(key) > models.MaxMetadataKeyLength:
+			return errs.InvalidArgument(fmt.Sprintf("metadata key %q is longer than %d bytes", key, models.MaxMetadataKeyLength))
+		case len(value) > models.MaxMetadataValueLength:
+			return errs.InvalidArgument(fmt.Sprintf("metadata value of %q is longer than %d bytes", key, models.MaxMetadataValueLength))
+		}
+	}
+
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	if len(encoded) > models.MaxMetadataBytes {
+		return errs.InvalidArgument(fmt.Sprintf("metadata is larger than %d bytes", models.MaxMetadataBytes))
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/co1seam/ember-backend-media/internal/core/errs"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// GetForUpdate lets race land a concurrent change just before the lock is
+// taken.
+func (r *fakeMediaRepo) GetForUpdate(ctx context.Context, id string) (*models.Media, error) {
+	if row, ok := r.rows[id]; ok && r.race != nil {
+		r.race(&row)
+		row.Revision++
+		r.rows[id] = row
+	}
+	return r.GetByID(ctx, id)
+}
+
+func (r *fakeMediaRepo) Touch(ctx context.Context, id string) error {
+	row := r.rows[id]
+	row.Revision++
+	r.rows[id] = row
+	return nil
+}
+
+func (r *fakeMediaRepo) PatchMetadata(ctx context.Context, id string, set map[string]string, remove []string) error {
+	row := r.rows[id]
+	metadata := maps.Clone(row.Metadata)
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	maps.Copy(metadata, set)
+	for _, key := range remove {
+		delete(metadata, key)
+	}
+	row.Metadata = metadata
+	r.rows[id] = row
+	return nil
+}
+
+func (r *fakeMediaRepo) ListByOwner(ctx context.Context, req *models.ListMediaRequest) ([]*models.Media, error) {
+	r.listed = req
+	return nil, nil
+}
+
+// fakeTagRepo keeps the tags on the rows of a fakeMediaRepo, sorted the way
+// the select returns them.
+type fakeTagRepo struct {
+	ports.ITagRepo
+	media *fakeMediaRepo
+}
+
+func (r *fakeTagRepo) Add(ctx context.Context, mediaID string, names []string) error {
+	row := r.media.rows[mediaID]
+	for _, name := range names {
+		if !slices.Contains(row.Tags, name) {
+			row.Tags = append(row.Tags, name)
+		}
+	}
+	slices.Sort(row.Tags)
+	r.media.rows[mediaID] = row
+	return nil
+}
+
+func (r *fakeTagRepo) Remove(ctx context.Context, mediaID string, names []string) error {
+	row := r.media.rows[mediaID]
+	row.Tags = slices.DeleteFunc(row.Tags, func(tag string) bool { return slices.Contains(names, tag) })
+	r.media.rows[mediaID] = row
+	return nil
+}
+
+func (r *fakeTagRepo) Count(ctx context.Context, mediaID string) (int, error) {
+	return len(r.media.rows[mediaID].Tags), nil
+}
+
+func TestNormalizeTags(t *testing.T) {
+	got, err := normalizeTags([]string{" Holiday ", "beach", "holiday", "", "year:2024", "Ünïcode"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"beach", "holiday", "year:2024", "ünïcode"}; !slices.Equal(got, want) {
+		t.Errorf("normalizeTags() = %v, want %v", got, want)
+	}
+
+	for _, tag := range []string{"two words", "semi;colon", "<b>", strings.Repeat("x", models.MaxTagLength+1)} {
+		if _, err := normalizeTags([]string{tag}); errs.KindOf(err) != errs.KindInvalidArgument {
+			t.Errorf("normalizeTags(%q) error = %v, want InvalidArgument", tag, err)
+		}
+	}
+}
+
+func TestValidateMetadata(t *testing.T) {
+	tooMany := make(map[string]string, models.MaxMetadataKeys+1)
+	for i := range models.MaxMetadataKeys + 1 {
+		tooMany[fmt.Sprintf("k%d", i)] = "v"
+	}
+	tooBig := make(map[string]string)
+	for i := range models.MaxMetadataBytes/models.MaxMetadataValueLength + 1 {
+		tooBig[fmt.Sprintf("k%d", i)] = strings.Repeat("v", models.MaxMetadataValueLength)
+	}
+
+	for name, tc := range map[string]struct {
+		metadata map[string]string
+		valid    bool
+	}{
+		"ok":         {map[string]string{"camera": "x100v", "iso": ""}, true},
+		"empty":      {nil, true},
+		"empty key":  {map[string]string{"": "v"}, false},
+		"long key":   {map[string]string{strings.Repeat("k", models.MaxMetadataKeyLength+1): "v"}, false},
+		"long value": {map[string]string{"k": strings.Repeat("v", models.MaxMetadataValueLength+1)}, false},
+		"too many":   {tooMany, false},
+		"too big":    {tooBig, false},
+	} {
+		err := validateMetadata(tc.metadata)
+		if tc.valid && err != nil {
+			t.Errorf("%s: validateMetadata() error = %v", name, err)
+		}
+		if !tc.valid && errs.KindOf(err) != errs.KindInvalidArgument {
+			t.Errorf("%s: validateMetadata() error = %v, want InvalidArgument", name, err)
+		}
+	}
+}
+
+func TestAddTags(t *testing.T) {
+	f := newTestMedia(t, map[string]models.Media{
+		"m": {ID: "m", State: models.MediaStateActive, Tags: []string{"beach"}, Revision: 1},
+	}, nil, nil)
+	media, repo, outbox := f.media, f.repo, f.outbox
+
+	got, err := media.AddTags(context.Background(), "m", []string{"Sunset", "beach"})
+	if err != nil {
+		t.Fatalf("AddTags() error = %v", err)
+	}
+	if want := []string{"beach", "sunset"}; !slices.Equal(got.Tags, want) {
+		t.Errorf("tags = %v, want %v", got.Tags, want)
+	}
+	if got.Revision != 2 || len(outbox.events) != 1 || outbox.events[0].Type != models.EventMediaUpdated {
+		t.Errorf("revision = %d, events = %d, want the change published", got.Revision, len(outbox.events))
+	}
+
+	if _, err := media.AddTags(context.Background(), "m", []string{" ", ""}); errs.KindOf(err) != errs.KindInvalidArgument {
+		t.Errorf("AddTags(blank) error = %v, want InvalidArgument", err)
+	}
+
+	full := make([]string, models.MaxTagsPerMedia)
+	for i := range full {
+		full[i] = fmt.Sprintf("t%02d", i)
+	}
+	repo.rows["m"] = models.Media{ID: "m", State: models.MediaStateActive, Tags: full, Revision: 2}
+	if _, err := media.AddTags(context.Background(), "m", []string{"one-more"}); errs.KindOf(err) != errs.KindInvalidArgument {
+		t.Errorf("AddTags() past the limit error = %v, want InvalidArgument", err)
+	}
+}
+
+func TestRemoveTags(t *testing.T) {
+	media := newTestMedia(t, map[string]models.Media{
+		"m": {ID: "m", State: models.MediaStateActive, Tags: []string{"beach", "sunset"}, Revision: 1},
+	}, nil, nil).media
+
+	got, err := media.RemoveTags(context.Background(), "m", []string{"BEACH"})
+	if err != nil {
+		t.Fatalf("RemoveTags() error = %v", err)
+	}
+	if want := []string{"sunset"}; !slices.Equal(got.Tags, want) {
+		t.Errorf("tags = %v, want %v", got.Tags, want)
+	}
+}
+
+func TestPatchMetadata(t *testing.T) {
+	f := newTestMedia(t, map[string]models.Media{
+		"m": {ID: "m", State: models.MediaStateActive, Metadata: map[string]string{"camera": "x100v", "lens": "23mm"}, Revision: 1},
+	}, nil, nil)
+	media, repo := f.media, f.repo
+
+	got, err := media.PatchMetadata(context.Background(), "m", &models.PatchMetadataRequest{
+		Set:    map[string]string{"iso": "400"},
+		Remove: []string{"lens"},
+	})
+	if err != nil {
+		t.Fatalf("PatchMetadata() error = %v", err)
+	}
+	if want := map[string]string{"camera": "x100v", "iso": "400"}; !maps.Equal(got.Metadata, want) {
+		t.Errorf("metadata = %v, want %v", got.Metadata, want)
+	}
+
+	_, err = media.PatchMetadata(context.Background(), "m", &models.PatchMetadataRequest{})
+	if errs.KindOf(err) != errs.KindInvalidArgument {
+		t.Errorf("PatchMetadata(empty) error = %v, want InvalidArgument", err)
+	}
+
+	before := repo.rows["m"]
+	_, err = media.PatchMetadata(context.Background(), "m", &models.PatchMetadataRequest{
+		Set: map[string]string{"notes": strings.Repeat("v", models.MaxMetadataValueLength+1)},
+	})
+	if errs.KindOf(err) != errs.KindInvalidArgument {
+		t.Errorf("PatchMetadata(too long) error = %v, want InvalidArgument", err)
+	}
+	if after := repo.rows["m"]; !maps.Equal(after.Metadata, before.Metadata) || after.Revision != before.Revision {
+		t.Error("rejected patch was written")
+	}
+}
+
+func TestPatchMetadataChecksLatestRow(t *testing.T) {
+	keys := make(map[string]string, models.MaxMetadataKeys)
+	for i := range models.MaxMetadataKeys - 1 {
+		keys[fmt.Sprintf("k%d", i)] = "v"
+	}
+	f := newTestMedia(t, map[string]models.Media{
+		"m": {ID: "m", State: models.MediaStateActive, Metadata: keys, Revision: 1},
+	}, nil, nil)
+	media, repo, audit := f.media, f.repo, f.audit
+
+	// A concurrent patch fills the last free key after the request started.
+	repo.race = func(row *models.Media) {
+		row.Metadata = maps.Clone(row.Metadata)
+		row.Metadata["concurrent"] = "v"
+	}
+	_, err := media.PatchMetadata(context.Background(), "m", &models.PatchMetadataRequest{Set: map[string]string{"late": "v"}})
+	if errs.KindOf(err) != errs.KindInvalidArgument {
+		t.Errorf("PatchMetadata(over the limit) error = %v, want InvalidArgument", err)
+	}
+
+	repo.race = func(row *models.Media) { row.Metadata = map[string]string{"concurrent": "v"} }
+	if _, err := media.PatchMetadata(context.Background(), "m", &models.PatchMetadataRequest{Set: map[string]string{"late": "v"}}); err != nil {
+		t.Fatalf("PatchMetadata() error = %v", err)
+	}
+	if got := repo.rows["m"].Metadata; !maps.Equal(got, map[string]string{"concurrent": "v", "late": "v"}) {
+		t.Errorf("metadata = %v, want late added to the locked row", got)
+	}
+	if len(audit.events) != 1 || !slices.Equal(slices.Collect(maps.Keys(audit.events[0].Changes)), []string{"metadata.late"}) {
+		t.Errorf("audit = %+v, want only metadata.late changed from the locked row", audit.events)
+	}
+}
+
+func TestListMediaNormalizesTagFilter(t *testing.T) {
+	f := newTestMedia(t, nil, nil, nil)
+	media, repo := f.media, f.repo
+
+	req := &models.ListMediaRequest{OwnerID: "o", Tags: []string{"Beach", "beach", "Sunset"}, Metadata: map[string]string{"camera": "x100v"}}
+	if _, err := media.ListMedia(context.Background(), req); err != nil {
+		t.Fatalf("ListMedia() error = %v", err)
+	}
+	if !slices.Equal(repo.listed.Tags, []string{"beach", "sunset"}) || repo.listed.Limit != models.DefaultListLimit {
+		t.Errorf("listed %+v, want normalized tags and the default limit", repo.listed)
+	}
+
+	_, err := media.ListMedia(context.Background(), &models.ListMediaRequest{OwnerID: "o", Tags: []string{"no spaces"}})
+	if errs.KindOf(err) != errs.KindInvalidArgument {
+		t.Errorf("ListMedia(bad tag) error = %v, want InvalidArgument", err)
+	}
+}
@@ -0,0 +1,26 @@
+package services
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Services trace through the otel API only; the SDK and exporter are wired
+// by the tracing adapter at startup.
+var tracer = otel.Tracer("github.com/co1seam/ember-backend-media/internal/core/services")
+
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// finish records *err, if any, and ends the span: defer finish(span, &err).
+func finish(span trace.Span, err *error) {
+	if err != nil && *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}
@@ -0,0 +1,273 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/co1seam/ember-backend-media/internal/core/errs"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"github.com/google/uuid"
+	"net/url"
+	"sync"
+	"time"
+)
+
+type Webhooks struct {
+	repo   ports.IWebhookRepo
+	sender ports.IWebhookSender
+	opts   *models.Options
+	wg     sync.WaitGroup
+	cancel context.CancelFunc
+}
+
+func NewWebhooks(repo ports.IWebhookRepo, sender ports.IWebhookSender, opts *models.Options) *Webhooks {
+	return &Webhooks{
+		repo:   repo,
+		sender: sender,
+		opts:   opts,
+	}
+}
+
+func (w *Webhooks) RegisterWebhook(ctx context.Context, req *models.RegisterWebhookRequest) (*models.Webhook, error) {
+	target, err := url.Parse(req.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, models.ErrInvalidWebhook
+	}
+	if req.OwnerID == "" {
+		return nil, models.ErrInvalidWebhook
+	}
+	if err := w.sender.CheckTarget(ctx, target.String()); err != nil {
+		return nil, errs.Wrap(models.ErrWebhookTarget, err)
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		secret = hex.EncodeToString(buf)
+	}
+
+	webhook := &models.Webhook{
+		ID:        uuid.New().String(),
+		OwnerID:   req.OwnerID,
+		URL:       target.String(),
+		Secret:    secret,
+		Events:    req.Events,
+		Active:    true,
+		CreatedAt: time.Now(),
+	}
+	if webhook.Events == nil {
+		webhook.Events = []string{}
+	}
+
+	if err := w.repo.Create(ctx, webhook); err != nil {
+		return nil, err
+	}
+
+	return webhook, nil
+}
+
+func (w *Webhooks) ListWebhooks(ctx context.Context, ownerID string) ([]*models.Webhook, error) {
+	webhooks, err := w.repo.ListByOwner(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, webhook := range webhooks {
+		webhook.Secret = ""
+	}
+
+	return webhooks, nil
+}
+
+func (w *Webhooks) DeleteWebhook(ctx context.Context, ownerID, id string) error {
+	if _, err := w.owned(ctx, ownerID, id); err != nil {
+		return err
+	}
+
+	return w.repo.Delete(ctx, id)
+}
+
+// PingWebhook delivers a synchronous test event. It is logged like any other
+// delivery but never retried, and a successful ping re-enables a webhook that
+// was disabled after repeated failures.
+func (w *Webhooks) PingWebhook(ctx context.Context, ownerID, id string) (*models.WebhookDelivery, error) {
+	webhook, err := w.owned(ctx, ownerID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	event := &models.Event{
+		ID:            uuid.New().String(),
+		Type:          models.EventWebhookPing,
+		SchemaVersion: models.EventSchemaVersion,
+		OwnerID:       webhook.OwnerID,
+		OccurredAt:    time.Now().UTC(),
+		Data:          json.RawMessage(`{}`),
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	delivery := &models.WebhookDelivery{
+		ID:            uuid.New().String(),
+		WebhookID:     webhook.ID,
+		EventID:       event.ID,
+		EventType:     event.Type,
+		Payload:       payload,
+		State:         models.DeliveryStatePending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		URL:           webhook.URL,
+		Secret:        webhook.Secret,
+	}
+
+	if err := w.repo.CreateDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+
+	w.attempt(ctx, delivery)
+	if delivery.State == models.DeliveryStatePending {
+		delivery.State = models.DeliveryStateFailed
+	}
+
+	if err := w.repo.UpdateDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+
+	if delivery.State == models.DeliveryStateDelivered && !webhook.Active {
+		if err := w.repo.SetActive(ctx, webhook.ID, true); err != nil {
+			return nil, err
+		}
+	}
+
+	return delivery, nil
+}
+
+func (w *Webhooks) ListDeliveries(ctx context.Context, ownerID, id string, limit int) ([]*models.WebhookDelivery, error) {
+	if _, err := w.owned(ctx, ownerID, id); err != nil {
+		return nil, err
+	}
+
+	return w.repo.ListDeliveries(ctx, id, limit)
+}
+
+func (w *Webhooks) Start(ctx context.Context) {
+	ctx, w.cancel = context.WithCancel(ctx)
+
+	w.wg.Add(1)
+	go w.run(ctx)
+}
+
+func (w *Webhooks) Stop() {
+	if w.cancel != nil {
+		w.cancel()
+	}
+	w.wg.Wait()
+}
+
+func (w *Webhooks) run(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(models.WebhookPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.dispatchBatch(ctx); err != nil {
+			w.opts.Logger.Error("webhook dispatch failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatchBatch claims due deliveries and sends them with no transaction
+// open; each result is recorded on its own, so a slow endpoint holds no
+// locks and a failed write only affects its delivery.
+func (w *Webhooks) dispatchBatch(ctx context.Context) error {
+	skipped, err := w.repo.SkipInactiveDeliveries(ctx)
+	if err != nil {
+		return err
+	}
+	if skipped > 0 {
+		w.opts.Logger.Info("skipped deliveries of disabled webhooks", "count", skipped)
+	}
+
+	deliveries, err := w.repo.ClaimDueDeliveries(ctx, models.WebhookBatchSize, models.WebhookClaimLease)
+	if err != nil {
+		return err
+	}
+
+	for _, delivery := range deliveries {
+		w.attempt(ctx, delivery)
+
+		if err := w.repo.UpdateDelivery(ctx, delivery); err != nil {
+			w.opts.Logger.Error("webhook delivery not recorded", "delivery_id", delivery.ID, "error", err)
+			continue
+		}
+
+		active, err := w.repo.RecordResult(ctx, delivery.WebhookID, delivery.State == models.DeliveryStateDelivered, models.WebhookDisableAfter)
+		if err != nil {
+			w.opts.Logger.Error("webhook result not recorded", "webhook_id", delivery.WebhookID, "error", err)
+			continue
+		}
+		if !active {
+			w.opts.Logger.Warn("webhook disabled after repeated failures", "webhook_id", delivery.WebhookID)
+		}
+	}
+
+	return nil
+}
+
+func (w *Webhooks) attempt(ctx context.Context, delivery *models.WebhookDelivery) {
+	started := time.Now()
+	code, err := w.sender.Send(ctx, delivery)
+
+	delivery.Attempts++
+	delivery.LastStatusCode = code
+	delivery.LastDuration = time.Since(started)
+
+	if err == nil {
+		delivery.State = models.DeliveryStateDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = &started
+		return
+	}
+
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= models.WebhookMaxAttempts {
+		delivery.State = models.DeliveryStateFailed
+		return
+	}
+
+	delivery.NextAttemptAt = time.Now().Add(backoff(delivery.Attempts, models.WebhookBackoffBase, models.WebhookBackoffMax))
+}
+
+func (w *Webhooks) owned(ctx context.Context, ownerID, id string) (*models.Webhook, error) {
+	webhook, err := w.repo.GetByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if webhook.OwnerID != ownerID {
+		return nil, models.ErrWebhookNotFound
+	}
+
+	return webhook, nil
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/co1seam/ember-backend-media/internal/core/errs"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"testing"
+	"time"
+)
+
+type fakeWebhookRepo struct {
+	ports.IWebhookRepo
+	due      []*models.WebhookDelivery
+	lease    time.Duration
+	skipped  bool
+	updated  []models.WebhookDelivery
+	results  map[string][]bool
+	failures map[string]bool
+	created  []*models.Webhook
+}
+
+func (r *fakeWebhookRepo) SkipInactiveDeliveries(ctx context.Context) (int64, error) {
+	r.skipped = true
+	return 0, nil
+}
+
+func (r *fakeWebhookRepo) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*models.WebhookDelivery, error) {
+	r.lease = lease
+	return r.due, nil
+}
+
+func (r *fakeWebhookRepo) UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	if r.failures[delivery.ID] {
+		return errors.New("connection reset")
+	}
+	r.updated = append(r.updated, *delivery)
+	return nil
+}
+
+func (r *fakeWebhookRepo) RecordResult(ctx context.Context, id string, success bool, disableAfter int) (bool, error) {
+	if r.results == nil {
+		r.results = make(map[string][]bool)
+	}
+	r.results[id] = append(r.results[id], success)
+	return true, nil
+}
+
+func (r *fakeWebhookRepo) Create(ctx context.Context, webhook *models.Webhook) error {
+	r.created = append(r.created, webhook)
+	return nil
+}
+
+type fakeSender struct {
+	codes   map[string]int
+	targets map[string]error
+	sent    []string
+}
+
+func (s *fakeSender) Send(ctx context.Context, delivery *models.WebhookDelivery) (int, error) {
+	s.sent = append(s.sent, delivery.ID)
+	code := s.codes[delivery.URL]
+	if code < 200 || code >= 300 {
+		return code, errors.New("endpoint failed")
+	}
+	return code, nil
+}
+
+func (s *fakeSender) CheckTarget(ctx context.Context, target string) error {
+	return s.targets[target]
+}
+
+func TestDispatchBatchRecordsEachDelivery(t *testing.T) {
+	repo := &fakeWebhookRepo{
+		due: []*models.WebhookDelivery{
+			{ID: "ok", WebhookID: "w1", URL: "https://a.example/hook", State: models.DeliveryStatePending},
+			{ID: "lost", WebhookID: "w2", URL: "https://b.example/hook", State: models.DeliveryStatePending},
+			{ID: "down", WebhookID: "w3", URL: "https://c.example/hook", State: models.DeliveryStatePending},
+		},
+		failures: map[string]bool{"lost": true},
+	}
+	sender := &fakeSender{codes: map[string]int{
+		"https://a.example/hook": 200,
+		"https://b.example/hook": 200,
+		"https://c.example/hook": 503,
+	}}
+	w := NewWebhooks(repo, sender, testOptions())
+
+	before := time.Now()
+	if err := w.dispatchBatch(context.Background()); err != nil {
+		t.Fatalf("dispatchBatch() error = %v", err)
+	}
+
+	if !repo.skipped {
+		t.Error("deliveries of disabled webhooks were not skipped")
+	}
+	if repo.lease != models.WebhookClaimLease {
+		t.Errorf("lease = %v, want %v", repo.lease, models.WebhookClaimLease)
+	}
+	if len(sender.sent) != 3 {
+		t.Fatalf("sent = %v, want all three despite a failed write", sender.sent)
+	}
+	if len(repo.updated) != 2 {
+		t.Fatalf("updated = %d deliveries, want 2", len(repo.updated))
+	}
+
+	ok, down := repo.updated[0], repo.updated[1]
+	if ok.State != models.DeliveryStateDelivered || ok.Attempts != 1 || ok.DeliveredAt == nil {
+		t.Errorf("delivered = %+v", ok)
+	}
+	if down.State != models.DeliveryStatePending || down.LastStatusCode != 503 || down.LastError == "" {
+		t.Errorf("failed = %+v", down)
+	}
+	if down.NextAttemptAt.Before(before.Add(models.WebhookBackoffBase)) {
+		t.Errorf("next attempt = %v, want at least %v later", down.NextAttemptAt, models.WebhookBackoffBase)
+	}
+	if got := repo.results["w3"]; len(got) != 1 || got[0] {
+		t.Errorf("results for w3 = %v, want one failure", got)
+	}
+	if _, ok := repo.results["w2"]; ok {
+		t.Error("a result was recorded for a delivery that was not saved")
+	}
+}
+
+func TestRegisterWebhookRejectsPrivateTarget(t *testing.T) {
+	repo := &fakeWebhookRepo{}
+	sender := &fakeSender{targets: map[string]error{
+		"http://169.254.169.254/latest": errors.New("private"),
+	}}
+	w := NewWebhooks(repo, sender, testOptions())
+
+	_, err := w.RegisterWebhook(context.Background(), &models.RegisterWebhookRequest{
+		OwnerID: "owner",
+		URL:     "http://169.254.169.254/latest",
+	})
+	if !errors.Is(err, models.ErrWebhookTarget) || errs.KindOf(err) != errs.KindInvalidArgument {
+		t.Fatalf("RegisterWebhook() error = %v, want ErrWebhookTarget", err)
+	}
+	if len(repo.created) != 0 {
+		t.Fatal("the webhook was stored")
+	}
+
+	if _, err := w.RegisterWebhook(context.Background(), &models.RegisterWebhookRequest{
+		OwnerID: "owner",
+		URL:     "https://hooks.example/media",
+	}); err != nil || len(repo.created) != 1 {
+		t.Fatalf("RegisterWebhook(public) error = %v, created %d", err, len(repo.created))
+	}
+}
+
+func (r *fakeWebhookRepo) EnqueueDeliveries(ctx context.Context, event *models.Event) error {
+	return nil
+}
@@ -0,0 +1,162 @@
+package settings
+
+import (
+	"context"
+	"github.com/co1seam/ember-backend-media/config"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"github.com/co1seam/ember-backend-media/internal/ports"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Watcher reloads the configuration when the config file or the settings
+// table changes, or when asked to, and hands the result to the store.
+type Watcher struct {
+	store     *config.Store
+	repo      ports.ISettingsRepo
+	path      string
+	overrides config.Overrides
+	opts      *models.Options
+	requests  chan struct{}
+
+	mu          sync.Mutex
+	fingerprint string
+	wg          sync.WaitGroup
+	cancel      context.CancelFunc
+}
+
+func NewWatcher(store *config.Store, repo ports.ISettingsRepo, path string, overrides config.Overrides, opts *models.Options) *Watcher {
+	return &Watcher{
+		store:     store,
+		repo:      repo,
+		path:      path,
+		overrides: overrides,
+		opts:      opts,
+		requests:  make(chan struct{}, 1),
+	}
+}
+
+func (w *Watcher) Start(ctx context.Context) {
+	source := w.store.Load().Settings.Source
+	if source == "none" {
+		return
+	}
+
+	ctx, w.cancel = context.WithCancel(ctx)
+
+	// Settings stored in the table apply from the start, not the first change.
+	w.Reload(ctx, true)
+
+	w.wg.Add(1)
+	go w.run(ctx)
+}
+
+func (w *Watcher) Stop() {
+	if w.cancel != nil {
+		w.cancel()
+	}
+	w.wg.Wait()
+}
+
+// Request asks the running watcher for a forced reload, as on SIGHUP. It
+// never blocks; requests made while one is pending are merged, and requests
+// are ignored when reloading is disabled.
+func (w *Watcher) Request() {
+	select {
+	case w.requests <- struct{}{}:
+	default:
+	}
+}
+
+func (w *Watcher) run(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.store.Load().Settings.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.Reload(ctx, false)
+		case <-w.requests:
+			w.Reload(ctx, true)
+		}
+	}
+}
+
+// Reload rebuilds the configuration from its sources. Unless forced it is
+// skipped when neither the file nor the settings table changed. A snapshot
+// that fails validation is logged and the current one stays active.
+func (w *Watcher) Reload(ctx context.Context, force bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, models.SettingsReloadTimeout)
+	defer cancel()
+
+	fingerprint, rows, err := w.snapshot(ctx)
+	if err != nil {
+		w.opts.Logger.ErrorContext(ctx, "settings table read failed", "error", err)
+		return
+	}
+	if !force && fingerprint == w.fingerprint {
+		return
+	}
+	w.fingerprint = fingerprint
+
+	next, err := config.Load(w.path,
+		config.Layer{Name: models.SettingsTable, Values: rows},
+		config.Layer{Name: "flags", Values: w.overrides},
+	)
+	if err != nil {
+		w.opts.Logger.ErrorContext(ctx, "configuration reload rejected", "error", err)
+		return
+	}
+
+	changed, restart := w.store.Update(next)
+	if len(restart) > 0 {
+		w.opts.Logger.WarnContext(ctx, "configuration changes need a restart", "keys", restart)
+	}
+	if len(changed) > 0 {
+		w.opts.Logger.InfoContext(ctx, "configuration reloaded", "keys", changed)
+	}
+}
+
+// snapshot reads the watched sources: the file is represented by its size
+// and modification time, the table by its rows.
+func (w *Watcher) snapshot(ctx context.Context) (string, map[string]string, error) {
+	source := w.store.Load().Settings.Source
+
+	var parts []string
+	if w.path != "" && (source == "file" || source == "all") {
+		if info, err := os.Stat(w.path); err == nil {
+			parts = append(parts, info.ModTime().String(), strconv.FormatInt(info.Size(), 10))
+		}
+	}
+
+	var rows map[string]string
+	if source == "postgres" || source == "all" {
+		var err error
+		rows, err = w.repo.List(ctx)
+		if err != nil {
+			return "", nil, err
+		}
+
+		keys := make([]string, 0, len(rows))
+		for key := range rows {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			parts = append(parts, key+"="+rows[key])
+		}
+	}
+
+	return strings.Join(parts, "\n"), rows, nil
+}
@@ -0,0 +1,79 @@
+package settings
+
+import (
+	"context"
+	"github.com/co1seam/ember-backend-media/config"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
+	"io"
+	"log/slog"
+	"maps"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeSettingsRepo struct {
+	mu   sync.Mutex
+	rows map[string]string
+}
+
+func (r *fakeSettingsRepo) List(ctx context.Context) (map[string]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return maps.Clone(r.rows), nil
+}
+
+func (r *fakeSettingsRepo) set(key, value string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rows[key] = value
+}
+
+func TestWatcherReloadsOnRequest(t *testing.T) {
+	overrides := config.Overrides{
+		"POSTGRES_HOST":            "localhost",
+		"POSTGRES_USER":            "ember",
+		"POSTGRES_NAME":            "ember",
+		"MINIO_ENDPOINT":           "localhost:9000",
+		"MINIO_ACCESS_KEY":         "access",
+		"MINIO_SECRET_KEY":         "secret",
+		"MINIO_BUCKET":             "media",
+		"REDIS_HOST":               "localhost",
+		"SETTINGS_SOURCE":          "postgres",
+		"SETTINGS_RELOAD_INTERVAL": "1h",
+	}
+	initial, err := config.New("", overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := config.NewStore(initial)
+	repo := &fakeSettingsRepo{rows: map[string]string{"MEDIA_MAX_FILE_SIZE": "1024"}}
+	opts := &models.Options{Logger: slog.New(slog.NewTextHandler(io.Discard, nil)), Settings: store}
+
+	watcher := NewWatcher(store, repo, "", overrides, opts)
+	watcher.Start(context.Background())
+	defer watcher.Stop()
+
+	if got := store.Load().Media.MaxFileSize; got != 1024 {
+		t.Fatalf("MaxFileSize after start = %d, want the stored 1024", got)
+	}
+
+	repo.set("MEDIA_MAX_FILE_SIZE", "2048")
+	watcher.Request()
+
+	deadline := time.Now().Add(time.Second)
+	for store.Load().Media.MaxFileSize != 2048 {
+		if time.Now().After(deadline) {
+			t.Fatalf("MaxFileSize = %d, want 2048 after a requested reload", store.Load().Media.MaxFileSize)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWatcherRequestNeverBlocks(t *testing.T) {
+	watcher := NewWatcher(nil, nil, "", nil, nil)
+	for range 3 {
+		watcher.Request()
+	}
+}
@@ -12,9 +12,54 @@ type (
 	IMediaRepo interface {
 		Create(ctx context.Context, media *models.Media) error
 		GetByID(ctx context.Context, id string) (*models.Media, error)
+		GetForUpdate(ctx context.Context, id string) (*models.Media, error)
+		GetByIDs(ctx context.Context, ids []string) ([]*models.Media, error)
 		Update(ctx context.Context, media *models.Media) error
+		MarkStreamingOptimized(ctx context.Context, id, storagePath string, size int64) (*models.Media, error)
+		UpdateFields(ctx context.Context, media *models.Media, fields []string, revision int64) error
+		UpdateFieldsBatch(ctx context.Context, patches []*models.MediaPatch) ([]string, error)
+		Touch(ctx context.Context, id string) error
 		Delete(ctx context.Context, id string) error
-		ListByOwner(ctx context.Context, ownerID string, limit int) ([]*models.Media, error)
+		DeleteByIDs(ctx context.Context, ids []string) ([]string, error)
+		ListByOwner(ctx context.Context, req *models.ListMediaRequest) ([]*models.Media, error)
+		ListByState(ctx context.Context, state string, limit int) ([]*models.Media, error)
+		Search(ctx context.Context, req *models.SearchMediaRequest) ([]*models.MediaSearchHit, error)
+		ListPage(ctx context.Context, afterID string, limit int) ([]*models.Media, error)
+		ListOwnerPage(ctx context.Context, ownerID, afterID string, limit int) ([]*models.Media, error)
+		DeleteByOwner(ctx context.Context, ownerID string) (int64, error)
+		ListMissingSize(ctx context.Context, afterID string, limit int) ([]*models.Media, error)
+		SetSize(ctx context.Context, id string, size int64) error
+		PatchMetadata(ctx context.Context, id string, set map[string]string, remove []string) error
+	}
+
+	ITagRepo interface {
+		Add(ctx context.Context, mediaID string, names []string) error
+		Remove(ctx context.Context, mediaID string, names []string) error
+		Count(ctx context.Context, mediaID string) (int, error)
+	}
+
+	IMediaVersionRepo interface {
+		Create(ctx context.Context, version *models.MediaVersion) error
+		Get(ctx context.Context, mediaID string, version int) (*models.MediaVersion, error)
+		List(ctx context.Context, mediaID string) ([]*models.MediaVersion, error)
+		ListForMedia(ctx context.Context, mediaIDs []string) ([]*models.MediaVersion, error)
+		ListPage(ctx context.Context, afterID string, limit int) ([]*models.MediaVersion, error)
+		UpdateObject(ctx context.Context, mediaID string, version int, size int64, checksum string) error
+		Delete(ctx context.Context, mediaID string, version int) error
+	}
+
+	IAlbumRepo interface {
+		Create(ctx context.Context, album *models.Album) error
+		GetByID(ctx context.Context, id string) (*models.Album, error)
+		Update(ctx context.Context, album *models.Album) error
+		Delete(ctx context.Context, id string) error
+		ListByOwner(ctx context.Context, ownerID string, limit int) ([]*models.Album, error)
+		DeleteByOwner(ctx context.Context, ownerID string) (int64, error)
+		AddMedia(ctx context.Context, albumID string, mediaIDs []string) error
+		RemoveMedia(ctx context.Context, albumID string, mediaIDs []string) error
+		Reorder(ctx context.Context, albumID string, mediaIDs []string) error
+		MediaIDs(ctx context.Context, albumID string) ([]string, error)
+		ListMedia(ctx context.Context, albumID string, afterPosition, limit int) ([]*models.Media, int, error)
 	}
 
 	IMediaService interface {
@@ -22,12 +67,58 @@ type (
 		GetMedia(ctx context.Context, id string) (*models.Media, error)
 		UpdateMedia(ctx context.Context, req *models.UpdateMediaRequest) (*models.Media, error)
 		DeleteMedia(ctx context.Context, id string) error
-		ListMedia(ctx context.Context, ownerID string, limit int) ([]*models.Media, error)
-		GetFileURL(ctx context.Context, objectName string, expiry time.Duration) (string, error)
+		BatchGetMedia(ctx context.Context, ids []string) ([]*models.BatchResult, error)
+		BatchUpdateMedia(ctx context.Context, reqs []*models.UpdateMediaRequest) ([]*models.BatchResult, error)
+		BatchDeleteMedia(ctx context.Context, ids []string) ([]*models.BatchResult, error)
+		PlanArchive(ctx context.Context, ownerID string, ids []string) (*models.Archive, error)
+		WriteArchive(ctx context.Context, archive *models.Archive, w io.Writer) error
+		ListMedia(ctx context.Context, req *models.ListMediaRequest) ([]*models.Media, error)
+		SearchMedia(ctx context.Context, req *models.SearchMediaRequest) ([]*models.MediaSearchHit, error)
+		AddTags(ctx context.Context, id string, tags []string) (*models.Media, error)
+		RemoveTags(ctx context.Context, id string, tags []string) (*models.Media, error)
+		PatchMetadata(ctx context.Context, id string, req *models.PatchMetadataRequest) (*models.Media, error)
+		GetFileURL(ctx context.Context, id string, expiry time.Duration) (string, error)
 		UploadFile(ctx context.Context, fileID string, fileName string, size int64, stream io.Reader) (string, error)
 		DownloadFile(ctx context.Context, fileID string) (*minio.Object, error)
 		GetStatFile(ctx context.Context, objectName string) (*minio.ObjectInfo, error)
 		DownloadFileRange(ctx context.Context, objectName string, start, end int64) (io.ReadCloser, error)
+		ListQuarantined(ctx context.Context, limit int) ([]*models.Media, error)
+		ReleaseQuarantined(ctx context.Context, id string) (*models.Media, error)
+		PurgeQuarantined(ctx context.Context, id string) error
+		ListVersions(ctx context.Context, id string) ([]*models.MediaVersion, error)
+		DownloadVersion(ctx context.Context, id string, version int) (*minio.Object, *models.MediaVersion, error)
+		RollbackVersion(ctx context.Context, id string, version int) (*models.Media, error)
+	}
+
+	IAlbumService interface {
+		CreateAlbum(ctx context.Context, req *models.CreateAlbumRequest) (*models.Album, error)
+		GetAlbum(ctx context.Context, ownerID, id string) (*models.Album, error)
+		UpdateAlbum(ctx context.Context, req *models.UpdateAlbumRequest) (*models.Album, error)
+		DeleteAlbum(ctx context.Context, ownerID, id string) error
+		ListAlbums(ctx context.Context, ownerID string, limit int) ([]*models.Album, error)
+		AddMedia(ctx context.Context, ownerID, id string, mediaIDs []string) (*models.Album, error)
+		RemoveMedia(ctx context.Context, ownerID, id string, mediaIDs []string) (*models.Album, error)
+		ReorderMedia(ctx context.Context, ownerID, id string, mediaIDs []string) (*models.Album, error)
+		ListAlbumMedia(ctx context.Context, ownerID, id, pageToken string, limit int) (*models.AlbumPage, error)
+	}
+
+	IWebhookService interface {
+		RegisterWebhook(ctx context.Context, req *models.RegisterWebhookRequest) (*models.Webhook, error)
+		ListWebhooks(ctx context.Context, ownerID string) ([]*models.Webhook, error)
+		DeleteWebhook(ctx context.Context, ownerID, id string) error
+		PingWebhook(ctx context.Context, ownerID, id string) (*models.WebhookDelivery, error)
+		ListDeliveries(ctx context.Context, ownerID, id string, limit int) ([]*models.WebhookDelivery, error)
+	}
+
+	IPrivacyService interface {
+		ExportOwner(ctx context.Context, ownerID, format string) (*models.PrivacyRequest, error)
+		GetRequest(ctx context.Context, id string) (*models.PrivacyRequest, error)
+		EraseOwner(ctx context.Context, ownerID string) (*models.PrivacyRequest, error)
+	}
+
+	IAuditService interface {
+		ListAuditEvents(ctx context.Context, query *models.AuditQuery, pageToken string) (*models.AuditPage, error)
+		PruneAuditEvents(ctx context.Context, olderThan time.Duration, dryRun bool) (int64, error)
 	}
 
 	FileUploadStream interface {
@@ -2,6 +2,7 @@ package ports
 
 import (
 	"context"
+	"github.com/co1seam/ember-backend-media/internal/core/models"
 	"github.com/minio/minio-go/v7"
 	"io"
 	"time"
@@ -14,6 +15,81 @@ type IMinio interface {
 	DownloadFile(ctx context.Context, bucketName, objectName string) (*minio.Object, error)
 	GetStatFile(ctx context.Context, bucketName, objectName string) (*minio.ObjectInfo, error)
 	DownloadFileRange(ctx context.Context, bucketName, storagePath string, start, end int64) (io.ReadCloser, error)
+	CopyFile(ctx context.Context, srcBucket, srcObject, dstBucket, dstObject string) error
+	DeleteFile(ctx context.Context, bucketName, objectName string) error
+	WalkFiles(ctx context.Context, bucketName, prefix, startAfter string, fn func(minio.ObjectInfo) error) error
+}
+
+type IImportRepo interface {
+	CreateJob(ctx context.Context, job *models.ImportJob) error
+	GetJob(ctx context.Context, id string) (*models.ImportJob, error)
+	UpdateJob(ctx context.Context, job *models.ImportJob) error
+	AddItem(ctx context.Context, item *models.ImportItem) error
+	ListFailures(ctx context.Context, jobID string, limit int) ([]*models.ImportItem, error)
+	ListByOwner(ctx context.Context, ownerID string) ([]*models.ImportJob, error)
+	DeleteByOwner(ctx context.Context, ownerID string) (int64, error)
+}
+
+type IPrivacyRepo interface {
+	Create(ctx context.Context, req *models.PrivacyRequest) error
+	Get(ctx context.Context, id string) (*models.PrivacyRequest, error)
+	Update(ctx context.Context, req *models.PrivacyRequest) error
+	ListUnfinished(ctx context.Context, before time.Time) ([]*models.PrivacyRequest, error)
+}
+
+type IAuditRepo interface {
+	Add(ctx context.Context, event *models.AuditEvent) error
+	List(ctx context.Context, query *models.AuditQuery) ([]*models.AuditEvent, error)
+	DeleteBefore(ctx context.Context, before time.Time) (int64, error)
+	CountBefore(ctx context.Context, before time.Time) (int64, error)
+	ScrubOwner(ctx context.Context, ownerID string) (int64, error)
+}
+
+type ITransactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type IOutboxRepo interface {
+	Add(ctx context.Context, event *models.Event) error
+	ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]*models.OutboxEvent, error)
+	MarkPublished(ctx context.Context, id string) error
+	MarkFailed(ctx context.Context, id string, reason string, retryAt time.Time) error
+	MarkDeadLettered(ctx context.Context, id string, reason string) error
+	DeleteByOwner(ctx context.Context, ownerID string) (int64, error)
+	DeletePublishedBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type IWebhookRepo interface {
+	Create(ctx context.Context, webhook *models.Webhook) error
+	GetByID(ctx context.Context, id string) (*models.Webhook, error)
+	ListByOwner(ctx context.Context, ownerID string) ([]*models.Webhook, error)
+	Delete(ctx context.Context, id string) error
+	DeleteByOwner(ctx context.Context, ownerID string) (int64, error)
+	SetActive(ctx context.Context, id string, active bool) error
+	RecordResult(ctx context.Context, id string, success bool, disableAfter int) (bool, error)
+	EnqueueDeliveries(ctx context.Context, event *models.Event) error
+	CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*models.WebhookDelivery, error)
+	SkipInactiveDeliveries(ctx context.Context) (int64, error)
+	UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	ListDeliveries(ctx context.Context, webhookID string, limit int) ([]*models.WebhookDelivery, error)
+}
+
+type ISettingsRepo interface {
+	List(ctx context.Context) (map[string]string, error)
+}
+
+type IWebhookSender interface {
+	Send(ctx context.Context, delivery *models.WebhookDelivery) (int, error)
+	CheckTarget(ctx context.Context, target string) error
+}
+
+type IEventSink interface {
+	Publish(ctx context.Context, event *models.Event) error
+}
+
+type IScanner interface {
+	Scan(ctx context.Context, r io.Reader) (*models.ScanResult, error)
 }
 
 type ICache interface {